@@ -0,0 +1,161 @@
+package g2
+
+import (
+	"path"
+	"strings"
+)
+
+// AtomCandidate identifies a concrete package version that an atom can be matched against.
+type AtomCandidate struct {
+	Category string
+	Name     string
+	Version  string // PVR, e.g. "1.2.3-r1"
+	Slot     string // SLOT, optionally including the sub-slot ("0/1.2")
+	Repo     string
+}
+
+// NewAtomCandidate builds a candidate from a "category/name-version" string plus slot and repository.
+func NewAtomCandidate(cpv, slot, repo string) AtomCandidate {
+	atom := ParsePackageAtom(cpv)
+	return AtomCandidate{
+		Category: atom.Category,
+		Name:     atom.Name,
+		Version:  atom.Version,
+		Slot:     slot,
+		Repo:     repo,
+	}
+}
+
+// String returns the candidate as "category/name-version:slot::repo", omitting empty parts.
+func (c AtomCandidate) String() string {
+	return PackageAtom{Category: c.Category, Name: c.Name, Version: c.Version, Slot: c.Slot, Repo: c.Repo}.String()
+}
+
+// Key returns the "category/name" part of the candidate.
+func (c AtomCandidate) Key() string {
+	return c.Category + "/" + c.Name
+}
+
+// Key returns the "category/name" part of the atom.
+func (a PackageAtom) Key() string {
+	if a.Category == "" {
+		return a.Name
+	}
+	return a.Category + "/" + a.Name
+}
+
+// IsBlocker reports whether the atom is a weak (!) or strong (!!) blocker.
+func (a PackageAtom) IsBlocker() bool {
+	return strings.HasPrefix(a.Operator, "!")
+}
+
+// VersionOperator returns the version comparison operator with any blocker prefix removed.
+func (a PackageAtom) VersionOperator() string {
+	return strings.TrimLeft(a.Operator, "!")
+}
+
+// MatchesName reports whether the atom's category and package name match, honouring "*" globs
+// as used in user configuration files (e.g. "*/*" or "dev-python/*").
+func (a PackageAtom) MatchesName(category, name string) bool {
+	if a.Category != "" && !matchAtomGlob(a.Category, category) {
+		return false
+	}
+	return matchAtomGlob(a.Name, name)
+}
+
+// Matches reports whether the atom matches the candidate according to PMS atom semantics: category and
+// package name, the version operator, the slot or slot/sub-slot (slot operators match any slot)
+// and the repository qualifier. USE dependencies and blocker prefixes are not evaluated.
+func (a PackageAtom) Matches(c AtomCandidate) bool {
+	if !a.MatchesName(c.Category, c.Name) {
+		return false
+	}
+	if a.Repo != "" && a.Repo != c.Repo {
+		return false
+	}
+	if !matchAtomSlot(a.Slot, c.Slot) {
+		return false
+	}
+	return matchAtomVersion(a.VersionOperator(), a.Version, c.Version)
+}
+
+func matchAtomGlob(pattern, s string) bool {
+	if !strings.Contains(pattern, "*") {
+		return pattern == s
+	}
+	ok, err := path.Match(pattern, s)
+	return err == nil && ok
+}
+
+func matchAtomSlot(spec, slot string) bool {
+	spec = strings.TrimSuffix(spec, "=")
+	if spec == "" || spec == "*" {
+		return true
+	}
+	wantSlot, wantSub, hasSub := strings.Cut(spec, "/")
+	haveSlot, haveSub, found := strings.Cut(slot, "/")
+	if !found {
+		haveSub = haveSlot
+	}
+	if haveSlot == "" {
+		haveSlot = "0"
+		haveSub = "0"
+	}
+	if wantSlot != haveSlot {
+		return false
+	}
+	return !hasSub || wantSub == haveSub
+}
+
+func matchAtomVersion(op, want, have string) bool {
+	if op == "" || want == "" {
+		return op == ""
+	}
+	if have == "" {
+		return false
+	}
+
+	switch op {
+	case "=":
+		if strings.HasSuffix(want, "*") {
+			return matchVersionGlob(strings.TrimSuffix(want, "*"), have)
+		}
+		return CompareVersions(have, want) == 0
+	case "~":
+		return CompareVersions(stripRevision(have), stripRevision(want)) == 0
+	case ">":
+		return CompareVersions(have, want) > 0
+	case ">=":
+		return CompareVersions(have, want) >= 0
+	case "<":
+		return CompareVersions(have, want) < 0
+	case "<=":
+		return CompareVersions(have, want) <= 0
+	}
+	return false
+}
+
+// matchVersionGlob implements the "=cat/pkg-1.2*" prefix match: the candidate must start with the
+// prefix and the prefix must end on a version component boundary.
+func matchVersionGlob(prefix, have string) bool {
+	if !strings.HasPrefix(have, prefix) {
+		return false
+	}
+	if len(have) == len(prefix) || strings.HasSuffix(prefix, ".") {
+		return true
+	}
+	last := prefix[len(prefix)-1]
+	next := have[len(prefix)]
+	isDigit := func(b byte) bool { return b >= '0' && b <= '9' }
+	return !(isDigit(last) && isDigit(next))
+}
+
+func stripRevision(v string) string {
+	if idx := strings.LastIndex(v, "-r"); idx != -1 {
+		rest := v[idx+2:]
+		if rest != "" && strings.Trim(rest, "0123456789") == "" {
+			return v[:idx]
+		}
+	}
+	return v
+}
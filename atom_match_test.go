@@ -0,0 +1,54 @@
+package g2
+
+import "testing"
+
+func TestPackageAtomMatches(t *testing.T) {
+	candidate := AtomCandidate{Category: "dev-libs", Name: "foo", Version: "1.2.3-r1", Slot: "0/1.2", Repo: "gentoo"}
+
+	tests := []struct {
+		atom string
+		want bool
+	}{
+		{"dev-libs/foo", true},
+		{"dev-libs/bar", false},
+		{"dev-libs/*", true},
+		{"*/*", true},
+		{"=dev-libs/foo-1.2.3-r1", true},
+		{"=dev-libs/foo-1.2.3", false},
+		{"~dev-libs/foo-1.2.3", true},
+		{"=dev-libs/foo-1.2*", true},
+		{"=dev-libs/foo-1.2.3*", true},
+		{"=dev-libs/foo-1.2.30*", false},
+		{"=dev-libs/foo-1*", true},
+		{">=dev-libs/foo-1.2.3", true},
+		{">dev-libs/foo-1.2.3-r1", false},
+		{"<dev-libs/foo-2", true},
+		{"<=dev-libs/foo-1.2.3", false},
+		{"dev-libs/foo:0", true},
+		{"dev-libs/foo:1", false},
+		{"dev-libs/foo:0/1.2", true},
+		{"dev-libs/foo:0/1.3", false},
+		{"dev-libs/foo:=", true},
+		{"dev-libs/foo:0=", true},
+		{"dev-libs/foo::gentoo", true},
+		{"dev-libs/foo::guru", false},
+		{"!dev-libs/foo", true},
+		{"!<dev-libs/foo-1", false},
+	}
+
+	for _, tt := range tests {
+		if got := ParsePackageAtom(tt.atom).Matches(candidate); got != tt.want {
+			t.Errorf("%q.Matches(%s) = %v, want %v", tt.atom, candidate, got, tt.want)
+		}
+	}
+}
+
+func TestNewAtomCandidate(t *testing.T) {
+	c := NewAtomCandidate("dev-libs/foo-bar-1.0-r2", "0", "gentoo")
+	if c.Category != "dev-libs" || c.Name != "foo-bar" || c.Version != "1.0-r2" {
+		t.Errorf("unexpected candidate: %+v", c)
+	}
+	if c.String() != "dev-libs/foo-bar-1.0-r2:0::gentoo" {
+		t.Errorf("unexpected String(): %s", c.String())
+	}
+}
@@ -0,0 +1,174 @@
+package g2
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PackagesIndexKeys are the metadata keys copied from each binary package to the binhost Packages file.
+var PackagesIndexKeys = []string{
+	"BDEPEND", "BUILD_ID", "BUILD_TIME", "CHOST", "DEFINED_PHASES", "DEPEND", "DESCRIPTION", "EAPI",
+	"IDEPEND", "IUSE", "KEYWORDS", "LICENSE", "PDEPEND", "PROPERTIES", "PROVIDES", "RDEPEND", "REQUIRES",
+	"RESTRICT", "SLOT", "USE", "repository",
+}
+
+// packagesIndexDefaults are values Portage leaves out of the Packages file.
+var packagesIndexDefaults = map[string]string{
+	"EAPI": "0",
+	"SLOT": "0",
+}
+
+// PackagesIndex is a Portage binhost Packages file: a header stanza followed by one stanza per package.
+type PackagesIndex struct {
+	Header   map[string]string
+	Packages []map[string]string
+}
+
+// String renders the index in the Packages file format, with the keys of each stanza sorted.
+func (p *PackagesIndex) String() string {
+	var sb strings.Builder
+	writeStanza := func(m map[string]string) {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "%s: %s\n", k, m[k])
+		}
+		sb.WriteString("\n")
+	}
+	writeStanza(p.Header)
+	for _, pkg := range p.Packages {
+		writeStanza(pkg)
+	}
+	return sb.String()
+}
+
+// BuildPackagesIndex reads every .gpkg.tar below dir and returns the Packages index for them. The
+// header holds PACKAGES, TIMESTAMP and VERSION, plus CHOST when all packages share it.
+func BuildPackagesIndex(dir string) (*PackagesIndex, error) {
+	idx := &PackagesIndex{Header: make(map[string]string)}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".gpkg.tar") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		pkg, err := packagesIndexEntry(path, filepath.ToSlash(rel))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		idx.Packages = append(idx.Packages, pkg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(idx.Packages, func(i, j int) bool {
+		a, b := idx.Packages[i], idx.Packages[j]
+		if a["CPV"] != b["CPV"] {
+			return a["CPV"] < b["CPV"]
+		}
+		ai, _ := strconv.Atoi(a["BUILD_ID"])
+		bi, _ := strconv.Atoi(b["BUILD_ID"])
+		return ai < bi
+	})
+
+	idx.Header["PACKAGES"] = strconv.Itoa(len(idx.Packages))
+	idx.Header["TIMESTAMP"] = strconv.FormatInt(time.Now().Unix(), 10)
+	idx.Header["VERSION"] = "0"
+	chost := ""
+	for i, pkg := range idx.Packages {
+		if i == 0 {
+			chost = pkg["CHOST"]
+		} else if pkg["CHOST"] != chost {
+			chost = ""
+			break
+		}
+	}
+	if chost != "" {
+		idx.Header["CHOST"] = chost
+	}
+	return idx, nil
+}
+
+func packagesIndexEntry(path, rel string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	g, err := ReadGpkg(f, st.Size())
+	if err != nil {
+		return nil, err
+	}
+	if g.Metadata == nil {
+		return nil, fmt.Errorf("metadata archive cannot be read (compression of %s)", strings.Join(g.Undecoded, ", "))
+	}
+	cpv := g.CPV()
+	if cpv == "" {
+		return nil, fmt.Errorf("metadata has no CATEGORY or PF")
+	}
+
+	pkg := map[string]string{"CPV": cpv, "PATH": rel}
+	for _, k := range PackagesIndexKeys {
+		v := strings.Join(strings.Fields(g.Metadata[k]), " ")
+		if v == "" || packagesIndexDefaults[k] == v {
+			continue
+		}
+		pkg[k] = v
+	}
+	// Like Portage, only flags in IUSE are recorded as enabled.
+	if use, ok := pkg["USE"]; ok {
+		if filtered := filterUseToIuse(use, g.Metadata["IUSE"]); filtered != "" {
+			pkg["USE"] = filtered
+		} else {
+			delete(pkg, "USE")
+		}
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	sums, err := ChecksumReader(f, []string{HashMd5, HashSha1})
+	if err != nil {
+		return nil, err
+	}
+	pkg["SIZE"] = strconv.FormatInt(sums.Size, 10)
+	pkg["MD5"] = sums.Hashes[HashMd5]
+	pkg["SHA1"] = sums.Hashes[HashSha1]
+	pkg["MTIME"] = strconv.FormatInt(st.ModTime().Unix(), 10)
+	return pkg, nil
+}
+
+func filterUseToIuse(use, iuse string) string {
+	known := make(map[string]bool)
+	for _, flag := range strings.Fields(iuse) {
+		known[strings.TrimLeft(flag, "+-")] = true
+	}
+	var res []string
+	for _, flag := range strings.Fields(use) {
+		if known[flag] {
+			res = append(res, flag)
+		}
+	}
+	return strings.Join(res, " ")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+func (cfg *MainArgConfig) cmdBinhost(args []string) error {
+	fs := flag.NewFlagSet("binhost", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: g2 binhost <subcommand>\n")
+		fmt.Printf("\t\t %s \t\t %s\n", "index [-header K=V]... <dir>", "write the Packages index for the .gpkg.tar files below dir")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing subcommand for binhost")
+	}
+	switch fs.Arg(0) {
+	case "index":
+		return cfg.cmdBinhostIndex(fs.Args()[1:])
+	case "help", "-help", "--help":
+		fs.Usage()
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown binhost subcommand: %s", fs.Arg(0))
+	}
+}
+
+func (cfg *MainArgConfig) cmdBinhostIndex(args []string) error {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	var headers StringSliceFlag
+	fs.Var(&headers, "header", "Extra header entry KEY=VALUE, such as ARCH=amd64 (repeatable)")
+	output := fs.String("o", "", "Output file (default: <dir>/Packages)")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: g2 binhost index [-header K=V]... <dir>")
+	}
+	dir := fs.Arg(0)
+
+	idx, err := g2.BuildPackagesIndex(dir)
+	if err != nil {
+		return err
+	}
+	for _, h := range headers {
+		k, v, ok := strings.Cut(h, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid header %q, want KEY=VALUE", h)
+		}
+		idx.Header[k] = v
+	}
+	if *output == "" {
+		*output = filepath.Join(dir, "Packages")
+	}
+	if err := g2.SafeWriteFileAtomic(*output, []byte(idx.String()), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", *output, err)
+	}
+	fmt.Printf("Wrote %d packages to %s\n", len(idx.Packages), *output)
+	return nil
+}
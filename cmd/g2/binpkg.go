@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/arran4/g2"
+)
+
+func (cfg *MainArgConfig) cmdBinpkg(args []string) error {
+	fs := flag.NewFlagSet("binpkg", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: g2 binpkg <subcommand>\n")
+		fmt.Printf("\t\t %s \t\t %s\n", "inspect <file.gpkg.tar>", "show the members, metadata, image listing and signatures of a binary package")
+		fmt.Printf("\t\t %s \t\t %s\n", "verify [-keyring FILE] <file.gpkg.tar>...", "check members against the package Manifest and, with a keyring, its signatures")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing subcommand for binpkg")
+	}
+	switch fs.Arg(0) {
+	case "inspect":
+		return cfg.cmdBinpkgInspect(fs.Args()[1:])
+	case "verify":
+		return cfg.cmdBinpkgVerify(fs.Args()[1:])
+	case "help", "-help", "--help":
+		fs.Usage()
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown binpkg subcommand: %s", fs.Arg(0))
+	}
+}
+
+// openGpkg reads the binary package at path. The package reads its members from the file, so the
+// returned file must be closed once the package is no longer used.
+func openGpkg(path string) (*g2.Gpkg, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	g, err := g2.ReadGpkg(f, st.Size())
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return g, f, nil
+}
+
+// readKeyRing reads an armored or binary OpenPGP keyring.
+func readKeyRing(path string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data)); err == nil {
+		return keys, nil
+	}
+	keys, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading keyring %s: %w", path, err)
+	}
+	return keys, nil
+}
+
+func (cfg *MainArgConfig) cmdBinpkgInspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: g2 binpkg inspect <file.gpkg.tar>")
+	}
+	g, f, err := openGpkg(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	fmt.Printf("Package: %s (%s)\n", g.CPV(), g.Dir)
+	fmt.Printf("Members:\n")
+	for _, m := range g.Members {
+		fmt.Printf("  %-24s %10d\n", m.Name, m.Size)
+	}
+	for _, name := range g.Undecoded {
+		fmt.Printf("Warning: %s uses an unsupported compression and was not read\n", name)
+	}
+
+	if g.Metadata != nil {
+		fmt.Printf("Metadata:\n")
+		keys := make([]string, 0, len(g.Metadata))
+		for k := range g.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			lines := strings.Split(g.Metadata[k], "\n")
+			fmt.Printf("  %s: %s\n", k, lines[0])
+			for _, line := range lines[1:] {
+				fmt.Printf("    %s\n", strings.TrimSpace(line))
+			}
+		}
+	}
+
+	fmt.Printf("Image (%d entries):\n", len(g.Image))
+	for _, e := range g.Image {
+		line := fmt.Sprintf("  %s %10d %s", e.Mode, e.Size, e.Path)
+		if e.Linkname != "" {
+			line += " -> " + e.Linkname
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Printf("Signatures:\n")
+	if !g.Signed() {
+		fmt.Printf("  (none)\n")
+	}
+	if g.ManifestSigned {
+		fmt.Printf("  Manifest (clearsigned)\n")
+	}
+	var signed []string
+	for name := range g.Signatures {
+		signed = append(signed, name)
+	}
+	sort.Strings(signed)
+	for _, name := range signed {
+		fmt.Printf("  %s (%s.sig)\n", name, name)
+	}
+	return nil
+}
+
+func (cfg *MainArgConfig) cmdBinpkgVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	keyringPath := fs.String("keyring", "", "OpenPGP keyring to verify signatures against; unsigned packages then fail")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: g2 binpkg verify [-keyring FILE] <file.gpkg.tar>...")
+	}
+	var keyring openpgp.EntityList
+	if *keyringPath != "" {
+		var err error
+		if keyring, err = readKeyRing(*keyringPath); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, path := range fs.Args() {
+		g, f, err := openGpkg(path)
+		if err != nil {
+			fmt.Printf("%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		errs := g.VerifyManifest()
+		if keyring != nil {
+			if !g.Signed() {
+				errs = append(errs, fmt.Errorf("package is not signed"))
+			}
+			errs = append(errs, g.VerifySignatures(keyring)...)
+		}
+		_ = f.Close()
+		for _, err := range errs {
+			fmt.Printf("%s: %v\n", path, err)
+		}
+		if len(errs) > 0 {
+			failed++
+			continue
+		}
+		fmt.Printf("%s: OK\n", path)
+	}
+	if failed > 0 {
+		return &ExitError{Code: 1, Err: fmt.Errorf("%d of %d packages failed verification", failed, fs.NArg())}
+	}
+	return nil
+}
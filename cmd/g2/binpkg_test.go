@@ -0,0 +1,120 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arran4/g2"
+)
+
+// writeTestGpkg writes a minimal uncompressed gpkg for category/pf to path.
+func writeTestGpkg(t *testing.T, path, category, pf string) {
+	t.Helper()
+	tarball := func(files [][2]string) string {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, f := range files {
+			if err := tw.WriteHeader(&tar.Header{Name: f[0], Mode: 0644, Size: int64(len(f[1])), Typeflag: tar.TypeReg}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(f[1])); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	dir := pf + "-1"
+	members := [][2]string{
+		{g2.GpkgFormatFile, "gpkg-1\n"},
+		{"metadata.tar", tarball([][2]string{{"metadata/CATEGORY", category + "\n"}, {"metadata/PF", pf + "\n"}, {"metadata/EAPI", "8\n"}, {"metadata/BUILD_ID", "1\n"}})},
+		{"image.tar", tarball([][2]string{{"image/usr/bin/" + pf, "#!/bin/sh\n"}})},
+	}
+	var manifest strings.Builder
+	for _, m := range members {
+		sums, err := g2.ChecksumReader(strings.NewReader(m[1]), []string{g2.HashBlake2b, g2.HashSha512})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&manifest, "DATA %s %d BLAKE2B %s SHA512 %s\n", m[0], len(m[1]), sums.Hashes[g2.HashBlake2b], sums.Hashes[g2.HashSha512])
+	}
+	members = append(members, [2]string{"Manifest", manifest.String()})
+	var outer [][2]string
+	for _, m := range members {
+		outer = append(outer, [2]string{dir + "/" + m[0], m[1]})
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(tarball(outer)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBinpkgInspectAndVerify(t *testing.T) {
+	root := t.TempDir()
+	pkg := filepath.Join(root, "app-misc", "foo", "foo-1.0-1.gpkg.tar")
+	writeTestGpkg(t, pkg, "app-misc", "foo-1.0")
+	cfg := &MainArgConfig{}
+
+	out, err := captureOutput(t, func() error { return cfg.cmdBinpkg([]string{"inspect", pkg}) })
+	if err != nil {
+		t.Fatalf("inspect: %v", err)
+	}
+	for _, want := range []string{"Package: app-misc/foo-1.0 (foo-1.0-1)", "  CATEGORY: app-misc", "/usr/bin/foo-1.0", "  (none)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("inspect output missing %q:\n%s", want, out)
+		}
+	}
+
+	out, err = captureOutput(t, func() error { return cfg.cmdBinpkg([]string{"verify", pkg}) })
+	if err != nil || !strings.Contains(out, "OK") {
+		t.Errorf("verify = %v:\n%s", err, out)
+	}
+
+	data, err := os.ReadFile(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := filepath.Join(root, "tampered.gpkg.tar")
+	if err := os.WriteFile(tampered, bytes.Replace(data, []byte("#!/bin/sh"), []byte("#!/bin/zz"), 1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = captureOutput(t, func() error { return cfg.cmdBinpkg([]string{"verify", pkg, tampered}) })
+	if err == nil || !strings.Contains(out, "image.tar: BLAKE2B mismatch") {
+		t.Errorf("verify of a tampered package = %v:\n%s", err, out)
+	}
+}
+
+func TestBinhostIndex(t *testing.T) {
+	root := t.TempDir()
+	writeTestGpkg(t, filepath.Join(root, "app-misc", "foo", "foo-1.0-1.gpkg.tar"), "app-misc", "foo-1.0")
+	writeTestGpkg(t, filepath.Join(root, "dev-libs", "bar", "bar-2-1.gpkg.tar"), "dev-libs", "bar-2")
+	cfg := &MainArgConfig{}
+
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdBinhost([]string{"index", "-header", "ARCH=amd64", root})
+	}); err != nil {
+		t.Fatalf("binhost index: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{"ARCH: amd64\n", "PACKAGES: 2\n", "CPV: app-misc/foo-1.0\n", "PATH: dev-libs/bar/bar-2-1.gpkg.tar\n", "EAPI: 8\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Packages missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "CPV: app-misc/foo-1.0") > strings.Index(got, "CPV: dev-libs/bar-2") {
+		t.Errorf("packages are not sorted by CPV:\n%s", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/arran4/g2"
@@ -22,6 +23,7 @@ func (cfg *MainArgConfig) cmdCache(args []string) error {
 		fmt.Printf("\t\t %s \t\t %s\n", "set-method", "To set the cache method in layout.conf")
 		fmt.Printf("\t\t %s \t\t %s\n", "list-methods", "To list available cache methods")
 		fmt.Printf("\t\t %s \t\t %s\n", "clean", "To clean up unused cache entries")
+		fmt.Printf("\t\t %s \t\t %s\n", "changelogs", "To write the ChangeLog of each package from the git history, like egencache --update-changelogs")
 	}
 
 	if err := fs.Parse(args); err != nil {
@@ -47,6 +49,8 @@ func (cfg *MainArgConfig) cmdCache(args []string) error {
 		return cfg.cmdCacheListMethods(fs.Args()[1:])
 	case "clean":
 		return cfg.cmdCacheClean(fs.Args()[1:])
+	case "changelogs":
+		return cfg.cmdCacheChangelogs(fs.Args()[1:])
 	case "help", "-help", "--help":
 		fs.Usage()
 		return nil
@@ -281,3 +285,46 @@ func doCacheClean(cfs g2.CacheFS, repoDir string) error {
 	fmt.Printf("Cleaned %d unused cache entries.\n", cleanedCount)
 	return nil
 }
+
+func (cfg *MainArgConfig) cmdCacheChangelogs(args []string) error {
+	fsFlags := flag.NewFlagSet("changelogs", flag.ExitOnError)
+	repoDir := fsFlags.String("repo", ".", "Path to the repository root")
+	historyDepth := fsFlags.Int("history-depth", 0, "Number of commits of history to read, or 0 for the whole history")
+	if err := fsFlags.Parse(args); err != nil {
+		return err
+	}
+
+	n, err := updateChangeLogs(*repoDir, g2.HistoryOptions{MaxCommits: *historyDepth})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d ChangeLogs.\n", n)
+	return nil
+}
+
+// updateChangeLogs writes a ChangeLog into the directory of each package of the repository at repoDir
+// from its git history, as egencache --update-changelogs does, and returns how many it wrote. Packages
+// that have been removed since are skipped.
+func updateChangeLogs(repoDir string, opts g2.HistoryOptions) (int, error) {
+	history, err := g2.ReadRepoHistory(repoDir, opts)
+	if err != nil {
+		return 0, err
+	}
+	pkgs := make([]string, 0, len(history))
+	for pkg := range history {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	n := 0
+	for _, pkg := range pkgs {
+		dir := filepath.Join(repoDir, filepath.FromSlash(pkg))
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		if err := writeChangeLogFile(filepath.Join(dir, "ChangeLog"), pkg, history[pkg]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
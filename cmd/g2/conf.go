@@ -15,6 +15,10 @@ func (cfg *MainArgConfig) cmdConf(args []string) error {
 		return cfg.cmdConfAll(args[1:])
 	case "overlay":
 		return cfg.cmdConfOverlay(args[1:])
+	case "apply-updates":
+		return cfg.cmdConfApplyUpdates(args[1:])
+	case "use", "keywords", "license", "env":
+		return cfg.cmdConfTokens(subcmd, args[1:])
 	default:
 		return fmt.Errorf("unknown conf subcommand: %s", subcmd)
 	}
@@ -6,7 +6,6 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 
 	"github.com/arran4/g2"
 )
@@ -62,115 +61,45 @@ func (cfg *MainArgConfig) cmdConfAll(args []string) error {
 	if profileToUse != "" {
 		fmt.Printf("=== Profile: %s ===\n", profileToUse)
 
-		profilesDescBytes, err := os.ReadFile(filepath.Join(*repoDir, "profiles", "profiles.desc"))
-		var profilesDescEntries []g2.ProfileDescEntry
-		if err == nil {
-			profilesDescEntries = parseProfilesDesc(string(profilesDescBytes))
+		profileDir := profileToUse
+		if !filepath.IsAbs(profileDir) {
+			profileDir = filepath.Join(*repoDir, "profiles", profileDir)
 		}
-
-		profilesData, err := parseProfilesDir(*repoDir, profilesDescEntries)
+		locations := map[string]string{readRepoName(*repoDir): *repoDir}
+		if repos, err := resolveRepoStack(nil, filepath.Join(*portageConfDir, "repos.conf")); err == nil {
+			for _, repo := range repos {
+				if _, ok := locations[repo.RepoName]; !ok {
+					locations[repo.RepoName] = repo.Location
+				}
+			}
+		}
+		stack, err := g2.ProfileStack(profileDir, locations)
 		if err != nil {
-			fmt.Printf("Warning: failed to parse profiles: %v\n", err)
+			fmt.Printf("Warning: failed to read profile %s: %v\n", profileToUse, err)
 		} else {
-
-			// Try to find the profile
-			var targetProfile *g2.ProfileData
-
-			// Resolve absolute profileToUse to relative path if possible
-			relProfilePath := profileToUse
-			if filepath.IsAbs(profileToUse) {
-				profilesDirAbs, err := filepath.Abs(filepath.Join(*repoDir, "profiles"))
+			mergedVars := make(map[string]string)
+			for _, dir := range stack {
+				path := filepath.Join(dir, "make.defaults")
+				content, err := os.ReadFile(path)
+				if err != nil {
+					continue
+				}
+				vars, err := g2.ParseMakeConfContent(string(content), path)
 				if err == nil {
-					rel, err := filepath.Rel(profilesDirAbs, profileToUse)
-					if err == nil && !strings.HasPrefix(rel, "..") {
-						relProfilePath = rel
+					for k, v := range vars {
+						mergedVars[k] = v
 					}
 				}
 			}
 
-			// Try resolving as relative from just repo/profiles
-			if strings.HasPrefix(relProfilePath, *repoDir) {
-				rel, err := filepath.Rel(filepath.Join(*repoDir, "profiles"), relProfilePath)
-				if err == nil && !strings.HasPrefix(rel, "..") {
-					relProfilePath = rel
-				}
-			}
-			// Just blindly try to clean it
-			if filepath.IsAbs(relProfilePath) {
-				p := filepath.Clean(relProfilePath)
-				idx := strings.Index(p, "profiles/")
-				if idx != -1 {
-					relProfilePath = p[idx+len("profiles/"):]
-				}
-			}
-
-			for i, p := range profilesData {
-				if p.Path == relProfilePath || p.Path == profileToUse {
-					targetProfile = &profilesData[i]
-					break
-				}
+			fmt.Println("\n--- Merged Profile Defaults ---")
+			var mKeys []string
+			for k := range mergedVars {
+				mKeys = append(mKeys, k)
 			}
-
-			if targetProfile != nil {
-				visited := make(map[string]bool)
-				var orderedParents []string
-
-				var collectParents func(path string)
-				collectParents = func(path string) {
-					if visited[path] {
-						return
-					}
-					visited[path] = true
-
-					for _, p := range profilesData {
-						if p.Path == path {
-							for _, parent := range p.Parents {
-								collectParents(parent)
-							}
-							break
-						}
-					}
-					orderedParents = append(orderedParents, path)
-				}
-
-				collectParents(targetProfile.Path)
-
-				makeDefaults := make(map[string]string)
-
-				for _, parentPath := range orderedParents {
-					for _, p := range profilesData {
-						if p.Path == parentPath {
-							if md, ok := p.Files["make.defaults"]; ok {
-								makeDefaults[parentPath] = md
-							}
-							break
-						}
-					}
-				}
-
-				mergedVars := make(map[string]string)
-				for _, parentPath := range orderedParents {
-					if content, ok := makeDefaults[parentPath]; ok {
-						vars, err := g2.ParseMakeConfContent(content, parentPath+"/make.defaults")
-						if err == nil {
-							for k, v := range vars {
-								mergedVars[k] = v
-							}
-						}
-					}
-				}
-
-				fmt.Println("\n--- Merged Profile Defaults ---")
-				var mKeys []string
-				for k := range mergedVars {
-					mKeys = append(mKeys, k)
-				}
-				sort.Strings(mKeys)
-				for _, k := range mKeys {
-					fmt.Printf("%s=%s\n", k, mergedVars[k])
-				}
-			} else {
-				fmt.Printf("Profile %s (rel: %s) not found in parsed data\n", profileToUse, relProfilePath)
+			sort.Strings(mKeys)
+			for _, k := range mKeys {
+				fmt.Printf("%s=%s\n", k, mergedVars[k])
 			}
 		}
 	} else {
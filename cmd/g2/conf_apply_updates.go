@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+// configUpdateChange is a single rewritten line of a configuration file.
+type configUpdateChange struct {
+	Line int
+	Old  string
+	New  string
+}
+
+// loadRepoUpdates reads the profiles/updates directory of every enabled repository that has any moves.
+func loadRepoUpdates(repos []*g2.RepoInfo) ([]g2.RepoPackageUpdate, error) {
+	var updates []g2.RepoPackageUpdate
+	for _, repo := range repos {
+		if repo.Disabled {
+			continue
+		}
+		u, err := g2.ParseUpdatesDir(filepath.Join(repo.Location, "profiles", "updates"))
+		if err != nil {
+			return nil, fmt.Errorf("reading updates of %s: %w", repo.RepoName, err)
+		}
+		if len(u.Moves)+len(u.SlotMoves) > 0 {
+			updates = append(updates, g2.RepoPackageUpdate{Repo: repo.RepoName, Update: u})
+		}
+	}
+	return updates, nil
+}
+
+func (cfg *MainArgConfig) cmdConfApplyUpdates(args []string) error {
+	fs := flag.NewFlagSet("apply-updates", flag.ExitOnError)
+	configRoot := fs.String("config-root", "/etc/portage", "Path to portage config root")
+	reposConf := fs.String("repos-conf", "", "Path to repos.conf (default: <config-root>/repos.conf)")
+	var repoDirs StringSliceFlag
+	fs.Var(&repoDirs, "repo", "Path to a repository whose profiles/updates are applied (repeatable, default: all repositories in repos.conf)")
+	worldFile := fs.String("world", "/var/lib/portage/world", "Path to the world file")
+	dryRun := fs.Bool("dry-run", false, "Show a diff of the changes without writing them")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if *reposConf == "" {
+		*reposConf = filepath.Join(*configRoot, "repos.conf")
+	}
+
+	repos, err := resolveRepoStack(repoDirs, *reposConf)
+	if err != nil {
+		return err
+	}
+	updates, err := loadRepoUpdates(repos)
+	if err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		fmt.Println("No package updates found.")
+		return nil
+	}
+
+	files, err := listUpdateTargets(*configRoot)
+	if err != nil {
+		return err
+	}
+	if *worldFile != "" {
+		files = append(files, *worldFile)
+	}
+
+	total := 0
+	for _, file := range files {
+		changes, err := applyUpdatesToFile(file, updates, *dryRun)
+		if err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		total += len(changes)
+		if *dryRun {
+			writeUpdateDiff(os.Stdout, file, changes)
+		} else {
+			fmt.Printf("Updated %d entries in %s\n", len(changes), file)
+		}
+	}
+	if total == 0 {
+		fmt.Println("No entries need updating.")
+	}
+	return nil
+}
+
+// writeUpdateDiff writes changes to file as a unified diff with a hunk for each rewritten line, which
+// patch -p1 applies from the root directory.
+func writeUpdateDiff(w io.Writer, file string, changes []configUpdateChange) {
+	name := strings.TrimPrefix(filepath.ToSlash(file), "/")
+	_, _ = fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", name, name)
+	for _, c := range changes {
+		_, _ = fmt.Fprintf(w, "@@ -%d,1 +%d,1 @@\n-%s\n+%s\n", c.Line, c.Line, c.Old, c.New)
+	}
+}
+
+// listUpdateTargets returns the package.* files, including files inside package.* directories, and the
+// set files under configRoot. Hidden files and editor backups ending in ~ or .bak are skipped, as Portage
+// skips them.
+func listUpdateTargets(configRoot string) ([]string, error) {
+	var roots []string
+	matches, err := filepath.Glob(filepath.Join(configRoot, "package.*"))
+	if err != nil {
+		return nil, err
+	}
+	roots = append(roots, matches...)
+	roots = append(roots, filepath.Join(configRoot, "sets"))
+
+	var files []string
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			name := d.Name()
+			if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".bak") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.IsDir() {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// applyUpdatesToFile rewrites the atoms of file, writing it back unless dryRun is set.
+func applyUpdatesToFile(file string, updates []g2.RepoPackageUpdate, dryRun bool) ([]configUpdateChange, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", file, err)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	var changes []configUpdateChange
+	for i, line := range lines {
+		updated, changed := g2.RewriteConfigLineAtom(line, func(atom g2.PackageAtom) (g2.PackageAtom, bool) {
+			return g2.ApplyRepoUpdatesToAtom(updates, atom)
+		})
+		if changed {
+			changes = append(changes, configUpdateChange{Line: i + 1, Old: line, New: updated})
+			lines[i] = updated
+		}
+	}
+	if len(changes) == 0 || dryRun {
+		return changes, nil
+	}
+	if err := g2.SafeWriteFileAtomic(file, []byte(strings.Join(lines, "\n")), info.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdConfApplyUpdates(t *testing.T) {
+	tmpDir := t.TempDir()
+	repo := filepath.Join(tmpDir, "repo")
+	configRoot := filepath.Join(tmpDir, "portage")
+	world := filepath.Join(tmpDir, "world")
+
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":       "test\n",
+		"profiles/updates/1Q-2024": "move app-misc/foo app-misc/bar\nslotmove dev-libs/baz 1 2\n",
+	})
+	useFile := "# foo needs ssl\napp-misc/foo ssl # inline\n"
+	writeTestFiles(t, configRoot, map[string]string{
+		"package.use/foo":         useFile,
+		"package.accept_keywords": "dev-libs/baz:1 ~amd64\napp-misc/foo::other ~amd64\n",
+		"sets/mine":               "app-misc/foo\n",
+		"package.use/.hidden":     "app-misc/foo ssl\n",
+		"package.use/foo~":        "app-misc/foo ssl\n",
+		"package.use.bak":         "app-misc/foo ssl\n",
+		"package.mask~":           "app-misc/foo\n",
+	})
+	writeTestFiles(t, tmpDir, map[string]string{
+		"world": "app-misc/foo\ndev-libs/other\n",
+	})
+
+	cfg := &MainArgConfig{}
+	args := []string{"-repo", repo, "-config-root", configRoot, "-world", world}
+
+	out, err := captureOutput(t, func() error {
+		return cfg.cmdConfApplyUpdates(append(args, "-dry-run"))
+	})
+	if err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	useName := strings.TrimPrefix(filepath.ToSlash(filepath.Join(configRoot, "package.use/foo")), "/")
+	if !strings.Contains(out, "--- a/"+useName+"\n+++ b/"+useName+"\n@@ -2,1 +2,1 @@\n-app-misc/foo ssl # inline\n+app-misc/bar ssl # inline\n") {
+		t.Errorf("unexpected dry run output:\n%s", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(configRoot, "package.use/foo")); string(data) != useFile {
+		t.Errorf("dry run modified file:\n%s", data)
+	}
+
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdConfApplyUpdates(args)
+	}); err != nil {
+		t.Fatalf("apply-updates failed: %v", err)
+	}
+
+	want := map[string]string{
+		filepath.Join(configRoot, "package.use/foo"):         "# foo needs ssl\napp-misc/bar ssl # inline\n",
+		filepath.Join(configRoot, "package.accept_keywords"): "dev-libs/baz:2 ~amd64\napp-misc/foo::other ~amd64\n",
+		filepath.Join(configRoot, "sets/mine"):               "app-misc/bar\n",
+		filepath.Join(configRoot, "package.use/.hidden"):     "app-misc/foo ssl\n",
+		filepath.Join(configRoot, "package.use/foo~"):        "app-misc/foo ssl\n",
+		filepath.Join(configRoot, "package.use.bak"):         "app-misc/foo ssl\n",
+		filepath.Join(configRoot, "package.mask~"):           "app-misc/foo\n",
+		world: "app-misc/bar\ndev-libs/other\n",
+	}
+	for file, content := range want {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("reading %s: %v", file, err)
+		}
+		if string(data) != content {
+			t.Errorf("unexpected content of %s:\n%s\nwant:\n%s", file, data, content)
+		}
+	}
+}
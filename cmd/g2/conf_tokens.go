@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+// confTokenFiles maps the conf subcommands for token carrying files to their location under the config root.
+var confTokenFiles = map[string]string{
+	"use":      "package.use",
+	"keywords": "package.accept_keywords",
+	"license":  "package.license",
+	"env":      "package.env",
+}
+
+func (cfg *MainArgConfig) cmdConfTokens(kind string, args []string) error {
+	fs := flag.NewFlagSet("conf "+kind, flag.ContinueOnError)
+	configRoot := fs.String("config-root", "/etc/portage", "Path to config root")
+
+	// Flags must precede the action, as negated tokens such as -X look like flags.
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	positional := fs.Args()
+	usage := fmt.Errorf("usage: g2 conf %s [-config-root <path>] add|remove|toggle|list [<atom> [<token>...]]", kind)
+	if len(positional) == 0 {
+		return usage
+	}
+
+	configPath := filepath.Join(*configRoot, confTokenFiles[kind])
+	action, rest := positional[0], positional[1:]
+
+	switch action {
+	case "list":
+		if len(rest) > 1 {
+			return usage
+		}
+		return listConfTokens(configPath, rest)
+	case "add", "remove", "toggle":
+	default:
+		return fmt.Errorf("unknown conf %s subcommand: %s", kind, action)
+	}
+
+	if len(rest) == 0 {
+		return usage
+	}
+	atomStr, tokens := rest[0], rest[1:]
+	if atom := g2.ParsePackageAtom(atomStr); atom.Category == "" || atom.Name == "" {
+		return fmt.Errorf("invalid package atom %q", atomStr)
+	}
+
+	var add, remove []string
+	switch action {
+	case "add":
+		if len(tokens) == 0 {
+			return fmt.Errorf("missing tokens to add for %s", atomStr)
+		}
+		add = tokens
+		if kind == "env" {
+			for _, t := range tokens {
+				if _, err := os.Stat(filepath.Join(*configRoot, "env", t)); err != nil {
+					log.Printf("Warning: env file %s does not exist", filepath.Join(*configRoot, "env", t))
+				}
+			}
+		}
+	case "remove":
+		if len(tokens) == 0 {
+			removed, err := g2.RemoveUserConfigAtom(configPath, atomStr)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Removed %d entries for %s from %s\n", removed, atomStr, configPath)
+			return nil
+		}
+		remove = tokens
+	case "toggle":
+		if len(tokens) == 0 {
+			return fmt.Errorf("missing tokens to toggle for %s", atomStr)
+		}
+		current, err := currentConfTokens(configPath, atomStr)
+		if err != nil {
+			return err
+		}
+		for _, t := range tokens {
+			positive := strings.TrimPrefix(t, "-")
+			if current[positive] {
+				add = append(add, "-"+positive)
+			} else {
+				add = append(add, positive)
+			}
+		}
+	}
+
+	changed, file, err := g2.UpdateUserConfigTokens(configPath, atomStr, add, remove)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		fmt.Printf("No change for %s in %s\n", atomStr, configPath)
+		return nil
+	}
+	fmt.Printf("Updated %s in %s\n", atomStr, file)
+	return nil
+}
+
+// currentConfTokens returns which tokens are in effect for atomStr, later lines overriding earlier ones.
+func currentConfTokens(configPath, atomStr string) (map[string]bool, error) {
+	entries, err := g2.ReadUserConfigTokens(configPath)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]bool)
+	for _, e := range entries {
+		if e.Atom != atomStr {
+			continue
+		}
+		for _, t := range e.Tokens {
+			if strings.HasPrefix(t, "-") {
+				delete(res, t[1:])
+			} else {
+				res[t] = true
+			}
+		}
+	}
+	return res, nil
+}
+
+func listConfTokens(configPath string, filter []string) error {
+	entries, err := g2.ReadUserConfigTokens(configPath)
+	if err != nil {
+		return err
+	}
+	var atom *g2.PackageAtom
+	if len(filter) > 0 {
+		a := g2.ParsePackageAtom(filter[0])
+		atom = &a
+	}
+	for _, e := range entries {
+		if atom != nil {
+			entryAtom := g2.ParsePackageAtom(e.Atom)
+			if e.Atom != filter[0] && !atom.MatchesName(entryAtom.Category, entryAtom.Name) {
+				continue
+			}
+		}
+		rel, err := filepath.Rel(filepath.Dir(configPath), e.FilePath)
+		if err != nil {
+			rel = e.FilePath
+		}
+		fmt.Printf("%s:%d\t%s %s\n", rel, e.LineNumber, e.Atom, strings.Join(e.Tokens, " "))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdConfTokens(t *testing.T) {
+	configRoot := t.TempDir()
+	writeTestFiles(t, configRoot, map[string]string{
+		"package.accept_keywords": "# testing\n=app-misc/foo-1* ~amd64\n",
+		"env/nolto.conf":          "CFLAGS=\"-O2\"\n",
+	})
+	cfg := &MainArgConfig{}
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdConf(args)
+		})
+		if err != nil {
+			t.Fatalf("conf %v failed: %v", args, err)
+		}
+		return out
+	}
+
+	run("keywords", "-config-root", configRoot, "add", "=app-misc/foo-1*", "~arm64", "~amd64")
+	data, _ := os.ReadFile(filepath.Join(configRoot, "package.accept_keywords"))
+	if string(data) != "# testing\n=app-misc/foo-1* ~amd64 ~arm64\n" {
+		t.Errorf("unexpected package.accept_keywords:\n%s", data)
+	}
+
+	run("use", "-config-root", configRoot, "add", "app-misc/foo", "ssl", "-X")
+	run("use", "-config-root", configRoot, "toggle", "app-misc/foo", "ssl", "X")
+	data, _ = os.ReadFile(filepath.Join(configRoot, "package.use", "g2.conf"))
+	if string(data) != "app-misc/foo -ssl X\n" {
+		t.Errorf("unexpected package.use:\n%s", data)
+	}
+
+	run("env", "-config-root", configRoot, "add", "www-client/firefox", "nolto.conf")
+	if out := run("env", "-config-root", configRoot, "list", "firefox"); !strings.Contains(out, "package.env/g2.conf:1\twww-client/firefox nolto.conf") {
+		t.Errorf("unexpected list output: %q", out)
+	}
+
+	run("keywords", "-config-root", configRoot, "remove", "=app-misc/foo-1*")
+	data, _ = os.ReadFile(filepath.Join(configRoot, "package.accept_keywords"))
+	if string(data) != "# testing\n" {
+		t.Errorf("unexpected package.accept_keywords after remove:\n%s", data)
+	}
+}
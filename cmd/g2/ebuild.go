@@ -32,10 +32,12 @@ func (cfg *MainArgConfig) cmdEbuild(args []string) error {
 		fmt.Printf("\t\t %s \t\t %s\n", "sh-parse-to-json", "Parse ebuild using shell parser and output JSON")
 		fmt.Printf("\t\t %s \t\t %s\n", "as-json", "Parse ebuild using native parser and output JSON")
 		fmt.Printf("\t\t %s \t\t %s\n", "explain", "Human-readable summary output of an ebuild")
+		fmt.Printf("\t\t %s \t\t %s\n", "diff", "Semantic diff of two ebuilds, such as two versions of a package")
 		fmt.Printf("\t\t %s \t\t %s\n", "check", "A lightweight structural validator for ebuild files (alias: lint)")
 		fmt.Printf("\t\t %s \t\t %s\n", "deps", "Extract and format dependency fields")
 		fmt.Printf("\t\t %s \t\t %s\n", "query", "Query specific fields from parsed output")
 		fmt.Printf("\t\t %s \t\t %s\n", "bump-version", "Rename ebuild to a new version and fix references")
+		fmt.Printf("\t\t %s \t\t %s\n", "keywords", "Change the KEYWORDS of ebuilds, like ekeyword")
 		fmt.Printf("\t\t %s \t\t %s\n", "tag", "Ebuild specific tag subcommand supporting version comparisons")
 		fmt.Printf("\t\t %s \t\t %s\n", "check-exists", "Determine if any revision of a given version exists")
 		fmt.Printf("\t\t %s \t\t %s\n", "next-revision", "Generate the next revision name and optionally inspect contents")
@@ -82,6 +84,10 @@ func (cfg *MainArgConfig) cmdEbuild(args []string) error {
 		if err := config.cmdEbuildExplain(fs.Args()[1:]); err != nil {
 			return fmt.Errorf("ebuild explain: %w", err)
 		}
+	case "diff":
+		if err := config.cmdEbuildDiff(fs.Args()[1:]); err != nil {
+			return fmt.Errorf("ebuild diff: %w", err)
+		}
 	case "templates":
 		if err := config.cmdEbuildTemplates(fs.Args()[1:]); err != nil {
 			return fmt.Errorf("ebuild templates: %w", err)
@@ -98,6 +104,10 @@ func (cfg *MainArgConfig) cmdEbuild(args []string) error {
 		if err := config.cmdEbuildBumpVersion(fs.Args()[1:]); err != nil {
 			return fmt.Errorf("ebuild bump-version: %w", err)
 		}
+	case "keywords":
+		if err := config.cmdEbuildKeywords(fs.Args()[1:]); err != nil {
+			return fmt.Errorf("ebuild keywords: %w", err)
+		}
 	case "tag":
 		if err := config.cmdEbuildTag(fs.Args()[1:]); err != nil {
 			return fmt.Errorf("ebuild tag: %w", err)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/arran4/g2"
@@ -17,13 +18,14 @@ func (cfg *CmdEbuildArgConfig) cmdEbuildBumpVersion(args []string) error {
 	bumpPatch := fs.Bool("patch", false, "Bump the patch version")
 	bumpMinor := fs.Bool("minor", false, "Bump the minor version")
 	bumpMajor := fs.Bool("major", false, "Bump the major version")
+	dropStable := fs.Bool("testing", false, "Drop the stable keywords of the new ebuild to testing")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
 	if fs.NArg() < 1 {
-		return fmt.Errorf("usage: g2 ebuild bump-version [--tag] [--revision|--patch|--minor|--major] <old-ebuild> [new-version]")
+		return fmt.Errorf("usage: g2 ebuild bump-version [--tag] [--testing] [--revision|--patch|--minor|--major] <old-ebuild> [new-version]")
 	}
 
 	oldEbuildPath := fs.Arg(0)
@@ -65,7 +67,7 @@ func (cfg *CmdEbuildArgConfig) cmdEbuildBumpVersion(args []string) error {
 		newVersion = gv.String()
 	} else {
 		if fs.NArg() != 2 {
-			return fmt.Errorf("usage: g2 ebuild bump-version [--tag] <old-ebuild> <new-version>")
+			return fmt.Errorf("usage: g2 ebuild bump-version [--tag] [--testing] <old-ebuild> <new-version>")
 		}
 		newVersion = fs.Arg(1)
 	}
@@ -80,6 +82,14 @@ func (cfg *CmdEbuildArgConfig) cmdEbuildBumpVersion(args []string) error {
 		return fmt.Errorf("failed to rename ebuild: %w", err)
 	}
 
+	if *dropStable {
+		editor := repoKeywordEditor(filepath.Join(dir, "..", ".."))
+		ops := []g2.KeywordOp{{Kind: g2.KeywordOpTestingAll}}
+		if _, err := rewriteEbuildKeywords(newEbuildPath, editor, ops, false, nil); err != nil && !errors.Is(err, g2.ErrNoKeywords) {
+			return fmt.Errorf("dropping keywords of %s to testing: %w", newBase, err)
+		}
+	}
+
 	if *tagOnly {
 		log.Printf("Tag mode specified, skipping Manifest update.")
 		return nil
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+func parseEbuildFile(filename string) (*g2.Ebuild, error) {
+	ebuild, err := g2.ParseEbuild(os.DirFS(filepath.Dir(filename)), filepath.Base(filename), g2.ParseFull)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ebuild %s: %w", filename, err)
+	}
+	return ebuild, nil
+}
+
+func (cfg *CmdEbuildArgConfig) cmdEbuildDiff(args []string, opts ...any) error {
+	var out io.Writer = os.Stdout
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case io.Writer:
+			out = o
+		}
+	}
+
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "Output the diff as JSON")
+	summary := fs.Bool("summary", false, "Output only one line per change, as for a commit message")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: g2 ebuild diff [-json|-summary] <old ebuild> <new ebuild>")
+	}
+	old, err := parseEbuildFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	new, err := parseEbuildFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	d := g2.DiffEbuilds(old, new)
+
+	switch {
+	case *asJSON:
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case *summary:
+		for _, line := range d.Summary() {
+			_, _ = fmt.Fprintln(out, line)
+		}
+		return nil
+	}
+	writeEbuildDiff(out, d)
+	return nil
+}
+
+// writeEbuildDiff writes d for reading: one section per changed aspect, then the diffs of the changed
+// functions.
+func writeEbuildDiff(out io.Writer, d *g2.EbuildDiff) {
+	_, _ = fmt.Fprintf(out, "=== %s -> %s ===\n", d.OldVersion, d.NewVersion)
+	if d.Empty() {
+		_, _ = fmt.Fprintln(out, "No changes")
+		return
+	}
+	if d.EAPI != nil {
+		_, _ = fmt.Fprintf(out, "EAPI: %s -> %s\n", d.EAPI.Old, d.EAPI.New)
+	}
+	for _, v := range d.Variables {
+		_, _ = fmt.Fprintf(out, "%s: %q -> %q\n", v.Name, v.Old, v.New)
+	}
+	writeSetChange := func(name string, c g2.SetChange) {
+		if c.Empty() {
+			return
+		}
+		_, _ = fmt.Fprintf(out, "%s:\n", name)
+		for _, a := range c.Added {
+			_, _ = fmt.Fprintf(out, "  + %s\n", a)
+		}
+		for _, r := range c.Removed {
+			_, _ = fmt.Fprintf(out, "  - %s\n", r)
+		}
+	}
+	for _, c := range d.Dependencies {
+		writeSetChange(c.Class, c.SetChange)
+	}
+	if len(d.Keywords) > 0 {
+		_, _ = fmt.Fprintln(out, "KEYWORDS:")
+		for _, k := range d.Keywords {
+			switch {
+			case k.Old == "":
+				_, _ = fmt.Fprintf(out, "  + %s\n", k.New)
+			case k.New == "":
+				_, _ = fmt.Fprintf(out, "  - %s\n", k.Old)
+			default:
+				_, _ = fmt.Fprintf(out, "  %s -> %s\n", k.Old, k.New)
+			}
+		}
+	}
+	writeSetChange("IUSE", d.IUSE)
+	if d.RequiredUse != nil {
+		_, _ = fmt.Fprintf(out, "REQUIRED_USE: %q -> %q\n", d.RequiredUse.Old, d.RequiredUse.New)
+	}
+	writeSetChange("inherit", d.Inherited)
+	writeSetChange("Distfiles", d.Distfiles)
+	writeSetChange("SRC_URI", d.SrcURI)
+	for _, f := range d.Functions {
+		_, _ = fmt.Fprintf(out, "\n--- %s() (%s)\n", f.Name, f.Action)
+		_, _ = fmt.Fprint(out, strings.TrimSuffix(f.Diff, "\n")+"\n")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arran4/g2"
+	"golang.org/x/tools/txtar"
+)
+
+func TestEbuildDiffCommand(t *testing.T) {
+	ar, err := txtar.ParseFile("testdata/ebuild-diff-basic.txtar")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	tmpDir := t.TempDir()
+	var expectedOutput string
+	for _, f := range ar.Files {
+		if f.Name == "expected.txt" {
+			expectedOutput = string(f.Data)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, f.Name), f.Data, 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", f.Name, err)
+		}
+	}
+	oldFile, newFile := filepath.Join(tmpDir, "foo-1.0.ebuild"), filepath.Join(tmpDir, "foo-1.1.ebuild")
+	cmdCfg := &CmdEbuildArgConfig{MainArgConfig: &MainArgConfig{Args: []string{"g2"}}}
+
+	var buf bytes.Buffer
+	if err := cmdCfg.cmdEbuildDiff([]string{oldFile, newFile}, &buf); err != nil {
+		t.Fatalf("cmdEbuildDiff failed: %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != strings.TrimSpace(expectedOutput) {
+		t.Errorf("output mismatch.\nwant:\n%s\ngot:\n%s", expectedOutput, buf.String())
+	}
+
+	buf.Reset()
+	if err := cmdCfg.cmdEbuildDiff([]string{"-json", oldFile, newFile}, &buf); err != nil {
+		t.Fatalf("cmdEbuildDiff -json failed: %v", err)
+	}
+	var d g2.EbuildDiff
+	if err := json.Unmarshal(buf.Bytes(), &d); err != nil {
+		t.Fatalf("decoding %s: %v", buf.String(), err)
+	}
+	if d.NewVersion != "1.1" || len(d.Functions) != 2 {
+		t.Errorf("JSON diff = %+v", d)
+	}
+
+	buf.Reset()
+	if err := cmdCfg.cmdEbuildDiff([]string{"-summary", oldFile, newFile}, &buf); err != nil {
+		t.Fatalf("cmdEbuildDiff -summary failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "BDEPEND: +virtual/pkgconfig\n") {
+		t.Errorf("summary = %s", buf.String())
+	}
+}
+
+func TestGenerateSiteEbuildDiff(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":           "diffs\n",
+		"profiles/categories":          "app-misc\n",
+		"app-misc/foo/foo-1.9.ebuild":  "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n",
+		"app-misc/foo/foo-1.10.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\nRDEPEND=\"dev-libs/bar\"\n",
+	})
+	site, err := parseRepo(os.DirFS(repo), ".", "Diffs", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	outDir := t.TempDir()
+	if err := generateSite(outDir, []*g2.SiteData{site}, 90*24*time.Hour, "3 months", GenerationInfo{}); err != nil {
+		t.Fatalf("generateSite: %v", err)
+	}
+	ebuildDir := filepath.Join(outDir, "repos", "diffs", "categories", "app-misc", "packages", "foo", "ebuild")
+	page, err := os.ReadFile(filepath.Join(ebuildDir, "1.10", "diff", "index.html"))
+	if err != nil {
+		t.Fatalf("no diff page for 1.10: %v", err)
+	}
+	if !strings.Contains(string(page), "dev-libs/bar") || !strings.Contains(string(page), "Changes from <a href=\"../../1.9/\">1.9</a>") {
+		t.Errorf("diff page: %s", page)
+	}
+	if _, err := os.Stat(filepath.Join(ebuildDir, "1.9", "diff")); !os.IsNotExist(err) {
+		t.Errorf("the lowest version has a diff page: %v", err)
+	}
+	details, err := os.ReadFile(filepath.Join(ebuildDir, "1.10", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(details), "Changes since 1.9") {
+		t.Error("ebuild page does not link the diff")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+func (cfg *CmdEbuildArgConfig) cmdEbuildKeywords(args []string, opts ...any) error {
+	var out io.Writer = os.Stdout
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case io.Writer:
+			out = o
+		}
+	}
+
+	fs := flag.NewFlagSet("keywords", flag.ExitOnError)
+	repo := fs.String("repo", "", "Path to the repository (default: two directories above each ebuild)")
+	dryRun := fs.Bool("n", false, "Print the new KEYWORDS without writing the ebuilds")
+	flagArgs, positional := splitKnownFlags(fs, args)
+	if err := fs.Parse(flagArgs); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	var opArgs, ebuilds []string
+	for _, a := range positional {
+		if strings.HasSuffix(a, ".ebuild") {
+			ebuilds = append(ebuilds, a)
+		} else {
+			opArgs = append(opArgs, a)
+		}
+	}
+	if len(opArgs) == 0 || len(ebuilds) == 0 {
+		return fmt.Errorf("usage: g2 ebuild keywords [-repo dir] [-n] <~arch|arch|-arch|^arch|-*|all|~all>... <ebuild>...")
+	}
+	ops, err := g2.ParseKeywordOps(opArgs)
+	if err != nil {
+		return err
+	}
+
+	editors := map[string]*g2.KeywordEditor{}
+	for _, path := range ebuilds {
+		repoDir := *repo
+		if repoDir == "" {
+			repoDir = filepath.Join(filepath.Dir(path), "..", "..")
+		}
+		editor, ok := editors[repoDir]
+		if !ok {
+			editor = repoKeywordEditor(repoDir)
+			editors[repoDir] = editor
+		}
+		changed, err := rewriteEbuildKeywords(path, editor, ops, *dryRun, out)
+		if err != nil {
+			return &ExitError{Code: 1, Err: fmt.Errorf("%s: %w", path, err)}
+		}
+		if !changed {
+			_, _ = fmt.Fprintf(out, "%s: unchanged\n", path)
+		}
+	}
+	return nil
+}
+
+// splitKnownFlags separates the arguments naming a flag of fs, with their values, from the rest, so that
+// operations such as -* and -arm are not taken for flags.
+func splitKnownFlags(fs *flag.FlagSet, args []string) (flagArgs, positional []string) {
+	for i := 0; i < len(args); i++ {
+		name := strings.TrimLeft(args[i], "-")
+		name, _, hasValue := strings.Cut(name, "=")
+		f := fs.Lookup(name)
+		if !strings.HasPrefix(args[i], "-") || name == "" || f == nil {
+			positional = append(positional, args[i])
+			continue
+		}
+		flagArgs = append(flagArgs, args[i])
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && !(ok && bf.IsBoolFlag()) && i+1 < len(args) {
+			i++
+			flagArgs = append(flagArgs, args[i])
+		}
+	}
+	return flagArgs, positional
+}
+
+// repoKeywordEditor returns a KeywordEditor for the arch.list and profiles.desc of the repository at dir.
+func repoKeywordEditor(dir string) *g2.KeywordEditor {
+	archList, _ := readRepoArches(dir)
+	editor := &g2.KeywordEditor{ArchList: archList}
+	if b, err := os.ReadFile(filepath.Join(dir, "profiles", "profiles.desc")); err == nil {
+		editor.Profiles = parseProfilesDesc(string(b))
+	}
+	return editor
+}
+
+// rewriteEbuildKeywords applies ops to the KEYWORDS of the ebuild at path and, unless dryRun, writes it
+// back. The new assignment is printed when it changed.
+func rewriteEbuildKeywords(path string, editor *g2.KeywordEditor, ops []g2.KeywordOp, dryRun bool, out io.Writer) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	updated, changed, err := editor.RewriteKeywords(string(content), ops)
+	if err != nil || !changed {
+		return false, err
+	}
+	if out != nil {
+		_, _ = fmt.Fprintf(out, "%s: %s\n", path, keywordsAssignments(updated))
+	}
+	if dryRun {
+		return true, nil
+	}
+	return true, g2.SafeWriteFileAtomic(path, []byte(updated), info.Mode().Perm())
+}
+
+// keywordsAssignments returns the non-empty KEYWORDS assignments of content, trimmed and joined by "; ".
+func keywordsAssignments(content string) string {
+	var lines []string
+	for _, line := range strings.Split(content, "\n") {
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "KEYWORDS=") && trimmed != `KEYWORDS=""` {
+			lines = append(lines, trimmed)
+		}
+	}
+	return strings.Join(lines, "; ")
+}
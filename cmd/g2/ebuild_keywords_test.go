@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEbuildKeywords(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/arch.list":          "amd64\narm\narm64\nx86\n",
+		"profiles/profiles.desc":      "amd64 default/linux/amd64/23.0 stable\narm64 default/linux/arm64/23.0 stable\narm default/linux/arm/23.0 dev\n",
+		"app-misc/foo/foo-1.0.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nKEYWORDS=\"~x86 ~amd64\"\n",
+		"app-misc/foo/foo-1.1.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nKEYWORDS='~amd64 ~arm'\n",
+	})
+	foo10 := filepath.Join(repo, "app-misc", "foo", "foo-1.0.ebuild")
+	foo11 := filepath.Join(repo, "app-misc", "foo", "foo-1.1.ebuild")
+	cfg := &CmdEbuildArgConfig{MainArgConfig: &MainArgConfig{}}
+
+	var buf bytes.Buffer
+	if err := cfg.cmdEbuildKeywords([]string{"-*", "~arm64", "amd64", foo10, foo11}, &buf); err != nil {
+		t.Fatalf("keywords: %v", err)
+	}
+	if got, _ := os.ReadFile(foo10); string(got) != "EAPI=8\nDESCRIPTION=\"Foo\"\nKEYWORDS=\"-* amd64 ~arm64 ~x86\"\n" {
+		t.Errorf("foo-1.0:\n%s", got)
+	}
+	if got, _ := os.ReadFile(foo11); string(got) != "EAPI=8\nDESCRIPTION=\"Foo\"\nKEYWORDS='-* amd64 ~arm ~arm64'\n" {
+		t.Errorf("foo-1.1:\n%s", got)
+	}
+	if !strings.Contains(buf.String(), foo10+`: KEYWORDS="-* amd64 ~arm64 ~x86"`) {
+		t.Errorf("output: %s", buf.String())
+	}
+
+	// arm has no stable profile and sparc is not in arch.list.
+	for _, op := range []string{"arm", "~sparc"} {
+		if err := cfg.cmdEbuildKeywords([]string{op, foo11}, &buf); err == nil {
+			t.Errorf("%s: expected an error", op)
+		}
+	}
+
+	buf.Reset()
+	if err := cfg.cmdEbuildKeywords([]string{"-n", "^-*", "^arm", foo11}, &buf); err != nil {
+		t.Fatalf("keywords -n: %v", err)
+	}
+	if got, _ := os.ReadFile(foo11); !strings.Contains(string(got), "'-* amd64 ~arm ~arm64'") {
+		t.Errorf("dry run wrote the ebuild:\n%s", got)
+	}
+	if buf.String() != foo11+": KEYWORDS='amd64 ~arm64'\n" {
+		t.Errorf("dry run output: %q", buf.String())
+	}
+
+	if err := cfg.cmdEbuildBumpVersion([]string{"-tag", "-testing", foo10, "1.2"}); err != nil {
+		t.Fatalf("bump-version -testing: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(repo, "app-misc", "foo", "foo-1.2.ebuild")); !strings.Contains(string(got), `KEYWORDS="-* ~amd64 ~arm64 ~x86"`) {
+		t.Errorf("bumped ebuild:\n%s", got)
+	}
+}
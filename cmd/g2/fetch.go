@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -70,7 +71,9 @@ func giteaUrlConverter(gitUrl string) (string, error) {
 	return fmt.Sprintf("https://%s%s/archive/HEAD.zip", u.Host, path), nil
 }
 
-func FetchRepo(ctx context.Context, gitUrl string, destDir string, useZip bool, workMode string, retries int) error {
+// FetchRepo checks out gitUrl into destDir with the last depth commits of history, or the whole history
+// when depth is 0 or less.
+func FetchRepo(ctx context.Context, gitUrl string, destDir string, useZip bool, workMode string, retries int, depth int) error {
 	var err error
 	for i := 0; i <= retries; i++ {
 		if i > 0 {
@@ -78,7 +81,7 @@ func FetchRepo(ctx context.Context, gitUrl string, destDir string, useZip bool,
 			_ = os.RemoveAll(destDir)
 			time.Sleep(1 * time.Second)
 		}
-		err = fetchRepoAttempt(ctx, gitUrl, destDir, useZip, workMode)
+		err = fetchRepoAttempt(ctx, gitUrl, destDir, useZip, workMode, depth)
 		if err == nil {
 			return nil
 		}
@@ -87,11 +90,18 @@ func FetchRepo(ctx context.Context, gitUrl string, destDir string, useZip bool,
 	return err
 }
 
-func updatePersistentRepo(ctx context.Context, destDir string) error {
+// gitFullDepth is the depth git fetch documents as fetching the whole history, which also deepens a
+// shallow clone.
+const gitFullDepth = 2147483647
+
+func updatePersistentRepo(ctx context.Context, destDir string, depth int) error {
 	log.Printf("Persistent repo exists, attempting to fetch and reset: %s", destDir)
 	noPromptEnv := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
 
-	cmdFetch := exec.CommandContext(ctx, "git", "fetch", "--force", "--depth", "1", "origin", "HEAD")
+	if depth <= 0 {
+		depth = gitFullDepth
+	}
+	cmdFetch := exec.CommandContext(ctx, "git", "fetch", "--force", "--depth", strconv.Itoa(depth), "origin", "HEAD")
 	cmdFetch.Env = noPromptEnv
 	cmdFetch.Dir = destDir
 	cmdFetch.Stdout = os.Stdout
@@ -131,11 +141,11 @@ type osWriteFS struct{}
 func (osWriteFS) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
 func (osWriteFS) Create(name string) (io.WriteCloser, error)   { return os.Create(name) }
 
-func fetchRepoAttempt(ctx context.Context, gitUrl string, destDir string, useZip bool, workMode string) error {
+func fetchRepoAttempt(ctx context.Context, gitUrl string, destDir string, useZip bool, workMode string, depth int) error {
 	if workMode == "persistent" {
 		gitDir := filepath.Join(destDir, ".git")
 		if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
-			err := updatePersistentRepo(ctx, destDir)
+			err := updatePersistentRepo(ctx, destDir, depth)
 			if err == nil {
 				return nil
 			}
@@ -178,7 +188,11 @@ func fetchRepoAttempt(ctx context.Context, gitUrl string, destDir string, useZip
 		return fmt.Errorf("invalid git url protocol: %s", gitUrl)
 	}
 
-	cmd := exec.CommandContext(ctx, "git", "clone", "--depth", "1", gitUrl, destDir)
+	args := []string{"clone"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	cmd := exec.CommandContext(ctx, "git", append(args, gitUrl, destDir)...)
 	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -182,7 +182,7 @@ func TestGitUrlValidation(t *testing.T) {
 		t.Run(tt.url, func(t *testing.T) {
 			// We only want to test validation, not actual cloning
 			// fetchRepoAttempt with valid url will try to run 'git clone' and fail since the url is fake/unreachable
-			err := fetchRepoAttempt(ctx, tt.url, destDir, false, "")
+			err := fetchRepoAttempt(ctx, tt.url, destDir, false, "", 1)
 			if tt.wantErr {
 				if err == nil {
 					t.Errorf("expected error, got nil")
@@ -6,6 +6,8 @@ type GenerationInfo struct {
 	RepositoriesXML string
 	FastGit         bool
 	RecentDuration  string
+	// API writes the static JSON API below api/v1/ alongside the pages.
+	API bool
 	// Placeholders for later:
 	TimeTaken      string
 	MemoryConsumed string
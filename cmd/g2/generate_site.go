@@ -6,7 +6,6 @@ import (
 	"encoding/xml"
 	"fmt"
 	"html/template"
-	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -16,7 +15,6 @@ import (
 	"time"
 
 	"github.com/arran4/g2"
-	sitetemplates "github.com/arran4/g2/templates"
 	"golang.org/x/sync/errgroup"
 	"sort"
 )
@@ -732,6 +730,10 @@ func generateRepoPages(outDir string, tmpl *template.Template, sites []*g2.SiteD
 			if err := generateRepoPackagesPages(repoDir, tmpl, site, data, title, version, genInfo); err != nil {
 				return err
 			}
+
+			if err := generateRepoHistoryPages(repoDir, tmpl, site, title, version, genInfo); err != nil {
+				return err
+			}
 			return nil
 		})
 	}
@@ -1152,7 +1154,7 @@ func generateGlobalNewsFeeds(dir, siteTitle string, news []AggNewsItem) error {
 		}
 		items = append(items, newsFeedItem(item.NewsItem, "archive/"+item.ArchivePath+"/", description, item.RepoName))
 	}
-	return renderNewsFeeds(dir, g2.Feed{
+	return renderFeeds(dir, g2.Feed{
 		Title:       siteTitle + " News",
 		Link:        "./",
 		Description: "News from " + siteTitle,
@@ -1165,7 +1167,7 @@ func generateRepoNewsFeeds(dir string, site *g2.SiteData) error {
 	for _, item := range site.News {
 		items = append(items, newsFeedItem(item, "archive/"+item.DirName+"/", item.Body, site.RepoName))
 	}
-	return renderNewsFeeds(dir, g2.Feed{
+	return renderFeeds(dir, g2.Feed{
 		Title:       site.RepoName + " News",
 		Link:        "./",
 		Description: "News from the " + site.RepoName + " repository",
@@ -1191,12 +1193,18 @@ func newsFeedItem(item g2.NewsItem, link, description, repoName string) g2.FeedI
 	}
 }
 
-func renderNewsFeeds(dir string, feed g2.Feed) error {
+// completeFeed sets the ID of feed and dates it by its newest item.
+func completeFeed(feed g2.Feed) g2.Feed {
 	feed.ID = stableURN("feed", feed.Title)
 	if len(feed.Items) > 0 {
 		feed.LastBuildDate = feed.Items[0].PubDate
 		feed.Updated = feed.Items[0].Updated
 	}
+	return feed
+}
+
+func renderFeeds(dir string, feed g2.Feed) error {
+	feed = completeFeed(feed)
 	rssFeed := feed
 	rssFeed.Title += " RSS Feed"
 	if err := renderXMLFeed(filepath.Join(dir, "index.rss"), "rss.xml", rssFeed); err != nil {
@@ -1235,7 +1243,7 @@ func renderXMLFeed(path, templateName string, feed g2.Feed) error {
 	if err := f.Close(); err != nil {
 		return fmt.Errorf("closing feed %s: %w", path, err)
 	}
-	log.Printf("Generated %s news feed with %d items at %s", templateName, len(feed.Items), path)
+	log.Printf("Generated %s feed with %d items at %s", templateName, len(feed.Items), path)
 	return nil
 }
 
@@ -1253,7 +1261,7 @@ func getXMLTemplate(templateName string) (*texttemplate.Template, error) {
 		return feedTemplate, nil
 	}
 
-	b, err := fs.ReadFile(sitetemplates.SiteFS, "site/"+templateName)
+	b, err := readSiteAsset("site/" + templateName)
 	if err != nil {
 		return nil, fmt.Errorf("reading %s template: %w", templateName, err)
 	}
@@ -1526,11 +1534,26 @@ func generateRepoPackagesPages(repoDir string, tmpl *template.Template, site *g2
 				return fmt.Errorf("rendering page: %w", err)
 			}
 
-			for _, v := range pkg.Versions {
-				versionStr := v.Version
-				if v.Ebuild != nil && v.Ebuild.Vars != nil && v.Ebuild.Vars["PV"] != "" {
-					versionStr = v.Ebuild.Vars["PV"]
-				}
+			keywordsDir := filepath.Join(pkgDir, "keywords")
+			if err := os.MkdirAll(keywordsDir, 0755); err != nil {
+				return fmt.Errorf("creating directory %s: %w", keywordsDir, err)
+			}
+			if err := renderPage(filepath.Join(keywordsDir, "index.html"), tmpl, "repo_package_keywords.html", GenericPageContext{
+				Title:         fmt.Sprintf("%s - %s/%s - Keywords", site.RepoName, pkg.Category, pkg.Name),
+				BaseURL:       "../../../../../../../",
+				Breadcrumbs:   []g2.Breadcrumb{{Name: title, URL: "../../../../../../../"}, {Name: site.RepoName, URL: "../../../../../"}, {Name: "Categories", URL: "../../../../"}, {Name: pkg.Category, URL: "../../../"}, {Name: pkg.Name, URL: "../"}, {Name: "Keywords"}},
+				Repo:          site,
+				RepoPackage:   &pkg,
+				KeywordMatrix: g2.NewKeywordMatrix(pkg.Versions, site.ArchList, site.ArchesDesc),
+				Version:       version,
+				GenInfo:       genInfo,
+			}); err != nil {
+				return fmt.Errorf("rendering page: %w", err)
+			}
+
+			previous := previousVersions(pkg.Versions)
+			for i, v := range pkg.Versions {
+				versionStr := ebuildPageVersion(v)
 
 				ebuildDir := filepath.Join(pkgDir, "ebuild", versionStr)
 				if err := os.MkdirAll(ebuildDir, 0755); err != nil {
@@ -1549,6 +1572,13 @@ func generateRepoPackagesPages(repoDir string, tmpl *template.Template, site *g2
 					}
 				}
 
+				var ebuildDiff *g2.EbuildDiff
+				var diffBase string
+				if p := previous[i]; p >= 0 {
+					ebuildDiff = g2.DiffEbuilds(pkg.Versions[p].Ebuild, v.Ebuild)
+					diffBase = ebuildPageVersion(pkg.Versions[p])
+				}
+
 				if err := renderPage(filepath.Join(ebuildDir, "index.html"), tmpl, "ebuild_details.html", GenericPageContext{
 					Title:            fmt.Sprintf("%s - %s/%s-%s", site.RepoName, pkg.Category, pkg.Name, versionStr),
 					BaseURL:          "../../../../../../../../",
@@ -1560,9 +1590,32 @@ func generateRepoPackagesPages(repoDir string, tmpl *template.Template, site *g2
 					Version:          version,
 					GenInfo:          genInfo,
 					ValidLicenses:    data.ValidLicenses,
+					EbuildDiff:       ebuildDiff,
+					DiffBaseVersion:  diffBase,
 				}); err != nil {
 					return fmt.Errorf("rendering page: %w", err)
 				}
+
+				if ebuildDiff != nil {
+					diffDir := filepath.Join(ebuildDir, "diff")
+					if err := os.MkdirAll(diffDir, 0755); err != nil {
+						return fmt.Errorf("creating directory %s: %w", diffDir, err)
+					}
+					if err := renderPage(filepath.Join(diffDir, "index.html"), tmpl, "ebuild_diff.html", GenericPageContext{
+						Title:           fmt.Sprintf("%s - %s/%s - %s -> %s", site.RepoName, pkg.Category, pkg.Name, diffBase, versionStr),
+						BaseURL:         "../../../../../../../../../",
+						Breadcrumbs:     []g2.Breadcrumb{{Name: title, URL: "../../../../../../../../../"}, {Name: site.RepoName, URL: "../../../../../../../"}, {Name: "Categories", URL: "../../../../../../"}, {Name: pkg.Category, URL: "../../../../../"}, {Name: pkg.Name, URL: "../../../"}, {Name: "Ebuild", URL: "../../"}, {Name: versionStr, URL: "../"}, {Name: "Changes"}},
+						Repo:            site,
+						RepoPackage:     &pkg,
+						VersionData:     &v,
+						EbuildDiff:      ebuildDiff,
+						DiffBaseVersion: diffBase,
+						Version:         version,
+						GenInfo:         genInfo,
+					}); err != nil {
+						return fmt.Errorf("rendering page: %w", err)
+					}
+				}
 			}
 			return nil
 		})
@@ -1570,6 +1623,36 @@ func generateRepoPackagesPages(repoDir string, tmpl *template.Template, site *g2
 	return gPkgs.Wait()
 }
 
+// ebuildPageVersion returns the version an ebuild page of v is named by.
+func ebuildPageVersion(v g2.VersionData) string {
+	if v.Ebuild != nil && v.Ebuild.Vars != nil && v.Ebuild.Vars["PV"] != "" {
+		return v.Ebuild.Vars["PV"]
+	}
+	return v.Version
+}
+
+// previousVersions returns, for each of versions, the index of the next lower version with a parsed
+// ebuild, or -1.
+func previousVersions(versions []g2.VersionData) []int {
+	order := make([]int, 0, len(versions))
+	for i, v := range versions {
+		if v.Ebuild != nil {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return g2.CompareVersions(versions[order[a]].Version, versions[order[b]].Version) < 0
+	})
+	previous := make([]int, len(versions))
+	for i := range previous {
+		previous[i] = -1
+	}
+	for k := 1; k < len(order); k++ {
+		previous[order[k]] = order[k-1]
+	}
+	return previous
+}
+
 func generateSite(outDir string, sites []*g2.SiteData, recentDuration time.Duration, recentDurationStr string, genInfo GenerationInfo) error {
 	if genInfo.Profiler == nil {
 		genInfo.Profiler = NewProfiler(false, "")
@@ -1638,6 +1721,10 @@ func generateSite(outDir string, sites []*g2.SiteData, recentDuration time.Durat
 		title = sites[0].Title
 	}
 
+	build := newSiteBuild(outDir, sites, data, title, recentDurationStr)
+	activeSiteBuild.Store(build)
+	defer activeSiteBuild.Store(nil)
+
 	// Render Phases
 	log.Printf("[PHASE] Rendering global pages...")
 	stepGlobalPages := genInfo.Profiler.Track("Render Global Pages")
@@ -1689,6 +1776,21 @@ func generateSite(outDir string, sites []*g2.SiteData, recentDuration time.Durat
 		}
 	}
 
+	if genInfo.API {
+		stepAPI := genInfo.Profiler.Track("Generate JSON API")
+		if err := generateSiteAPI(outDir, sites, data); err != nil {
+			return fmt.Errorf("generating JSON API: %w", err)
+		}
+		stepAPI()
+	}
+
+	if err := copySiteStatic(outDir); err != nil {
+		return err
+	}
+	if err := build.finish(); err != nil {
+		return err
+	}
+
 	log.Printf("[DONE] Site generation complete. Total nodes generated: %d", totalNodes)
 
 	return nil
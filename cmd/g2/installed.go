@@ -0,0 +1,262 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/arran4/g2"
+)
+
+type CmdInstalledArgConfig struct {
+	*MainArgConfig
+	VdbRoot string
+}
+
+func (cfg *MainArgConfig) cmdInstalled(args []string) error {
+	fs := flag.NewFlagSet("installed", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage:\n")
+		fmt.Printf("\t%s [-vdb <path>] <subcommand>\n", strings.Join(cfg.Args, " "))
+		fmt.Printf("\t\t %s \t\t %s\n", "list", "list installed packages")
+		fmt.Printf("\t\t %s \t\t %s\n", "show", "show the recorded metadata of installed packages matching an atom")
+		fmt.Printf("\t\t %s \t\t %s\n", "owner", "find the installed packages that own a file")
+		fmt.Printf("\t\t %s \t\t %s\n", "outdated", "list installed packages with a newer visible version in the configured repositories")
+	}
+	vdbRoot := fs.String("vdb", g2.DefaultVdbRoot, "Path to the installed package database")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing subcommand")
+	}
+
+	cmd := fs.Arg(0)
+	cfg.Args = append(cfg.Args, cmd)
+
+	config := &CmdInstalledArgConfig{
+		MainArgConfig: cfg,
+		VdbRoot:       *vdbRoot,
+	}
+
+	switch cmd {
+	case "list":
+		return config.cmdInstalledList(fs.Args()[1:])
+	case "show":
+		return config.cmdInstalledShow(fs.Args()[1:])
+	case "owner":
+		return config.cmdInstalledOwner(fs.Args()[1:])
+	case "outdated":
+		return config.cmdInstalledOutdated(fs.Args()[1:])
+	case "help", "-help", "--help":
+		fs.Usage()
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %s", cmd)
+	}
+}
+
+func (cfg *CmdInstalledArgConfig) cmdInstalledList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Include slot and repository")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	pkgs, err := g2.ListInstalledPackages(cfg.VdbRoot)
+	if err != nil {
+		return err
+	}
+
+	var filter *g2.PackageAtom
+	if fs.NArg() > 0 {
+		atom := g2.ParsePackageAtom(fs.Arg(0))
+		filter = &atom
+	}
+
+	for _, p := range pkgs {
+		if filter != nil && !matchesInstalledFilter(*filter, p) {
+			continue
+		}
+		if *verbose {
+			fmt.Println(p.Candidate().String())
+		} else {
+			fmt.Println(p.CPV())
+		}
+	}
+	return nil
+}
+
+// matchesInstalledFilter matches a user supplied atom, which may omit the category, against an installed package.
+func matchesInstalledFilter(atom g2.PackageAtom, p *g2.InstalledPackage) bool {
+	if atom.Category == "" {
+		atom.Category = "*"
+	}
+	return atom.Matches(p.Candidate())
+}
+
+func (cfg *CmdInstalledArgConfig) cmdInstalledShow(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	contents := fs.Bool("contents", false, "Also list installed files")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: g2 installed show [-contents] <atom>")
+	}
+	atom := g2.ParsePackageAtom(fs.Arg(0))
+
+	pkgs, err := g2.ListInstalledPackages(cfg.VdbRoot)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, p := range pkgs {
+		if !matchesInstalledFilter(atom, p) {
+			continue
+		}
+		if found {
+			fmt.Println()
+		}
+		found = true
+
+		fmt.Printf("%s\n", p.Candidate().String())
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if !p.BuildTime.IsZero() {
+			_, _ = fmt.Fprintf(w, "  BUILD_TIME\t%s\n", p.BuildTime.UTC().Format("2006-01-02 15:04:05 MST"))
+		}
+		keys := make([]string, 0, len(p.Metadata))
+		for k := range p.Metadata {
+			if k != "BUILD_TIME" && p.Metadata[k] != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			_, _ = fmt.Fprintf(w, "  %s\t%s\n", k, strings.Join(strings.Fields(p.Metadata[k]), " "))
+		}
+		_ = w.Flush()
+
+		if *contents {
+			entries, err := g2.ReadVdbContentsFS(os.DirFS(cfg.VdbRoot), p.Path)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("  Contents:\n")
+			for _, e := range entries {
+				if e.Type == "sym" {
+					fmt.Printf("    %s %s -> %s\n", e.Type, e.Path, e.Target)
+				} else {
+					fmt.Printf("    %s %s\n", e.Type, e.Path)
+				}
+			}
+		}
+	}
+
+	if !found {
+		return &ExitError{Code: 1, Err: fmt.Errorf("no installed package matches %s", fs.Arg(0))}
+	}
+	return nil
+}
+
+func (cfg *CmdInstalledArgConfig) cmdInstalledOwner(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: g2 installed owner <file> [<file> ...]")
+	}
+
+	paths := make([]string, len(args))
+	for i, file := range args {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return fmt.Errorf("resolving %s: %w", file, err)
+		}
+		paths[i] = filepath.ToSlash(abs)
+	}
+	owners, err := g2.FindFileOwnersFS(os.DirFS(cfg.VdbRoot), paths...)
+	if err != nil {
+		return err
+	}
+	missing := 0
+	for i, file := range args {
+		found := false
+		for _, o := range owners {
+			if o.Entry.Path == paths[i] {
+				fmt.Printf("%s (%s)\n", o.Package.CPV(), o.Entry.Path)
+				found = true
+			}
+		}
+		if !found {
+			fmt.Printf("%s: not owned by any installed package\n", file)
+			missing++
+		}
+	}
+	if missing > 0 {
+		return &ExitError{Code: 1}
+	}
+	return nil
+}
+
+func (cfg *CmdInstalledArgConfig) cmdInstalledOutdated(args []string) error {
+	fs := flag.NewFlagSet("outdated", flag.ExitOnError)
+	var repoDirs StringSliceFlag
+	fs.Var(&repoDirs, "repo", "Path to a repository (repeatable, default: all repositories in repos.conf)")
+	configRoot := fs.String("config-root", "/etc/portage", "Path to portage config root")
+	reposConf := fs.String("repos-conf", "", "Path to repos.conf (default: <config-root>/repos.conf)")
+	makeConf := fs.String("make-conf", "", "Path to make.conf (default: <config-root>/make.conf)")
+	acceptKeywords := fs.String("accept-keywords", "", "Override ACCEPT_KEYWORDS from the profile and make.conf")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if *reposConf == "" {
+		*reposConf = filepath.Join(*configRoot, "repos.conf")
+	}
+	if *makeConf == "" {
+		*makeConf = filepath.Join(*configRoot, "make.conf")
+	}
+
+	repos, err := resolveRepoStack(repoDirs, *reposConf)
+	if err != nil {
+		return err
+	}
+	vis, err := loadVisibility(repos, *configRoot, *makeConf, *acceptKeywords)
+	if err != nil {
+		return err
+	}
+
+	pkgs, err := g2.ListInstalledPackages(cfg.VdbRoot)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, p := range pkgs {
+		available, err := listRepoEbuilds(repos, p.Category, p.Name)
+		if err != nil {
+			return err
+		}
+		installedSlot, _, _ := strings.Cut(p.Slot, "/")
+		var sameSlot []repoEbuild
+		for _, e := range available {
+			slot, _, _ := strings.Cut(e.Candidate.Slot, "/")
+			if slot == installedSlot || installedSlot == "" {
+				sameSlot = append(sameSlot, e)
+			}
+		}
+		best := vis.bestVisible(sameSlot)
+		if best == nil || g2.CompareVersions(best.Candidate.Version, p.Version) <= 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s:%s\t%s\t->\t%s::%s\n", p.Key(), installedSlot, p.Version, best.Candidate.Version, best.Candidate.Repo)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("creating dir for %s: %v", rel, err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", rel, err)
+		}
+	}
+}
+
+func TestCmdInstalled(t *testing.T) {
+	tmpDir := t.TempDir()
+	vdb := filepath.Join(tmpDir, "vdb")
+	repo := filepath.Join(tmpDir, "repo")
+	configRoot := filepath.Join(tmpDir, "portage")
+
+	writeTestFiles(t, vdb, map[string]string{
+		"app-misc/foo-1.0/SLOT":       "0\n",
+		"app-misc/foo-1.0/repository": "test\n",
+		"app-misc/foo-1.0/CONTENTS":   "dir /usr/bin\nobj /usr/bin/foo 0123456789abcdef0123456789abcdef 1700000000\n",
+		"dev-libs/bar-2.0/SLOT":       "2/2.0\n",
+		"dev-libs/bar-2.0/repository": "test\n",
+		"dev-libs/baz-1.0/SLOT":       "0\n",
+	})
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":                  "test\n",
+		"profiles/package.mask":               "=dev-libs/baz-3.0\n",
+		"app-misc/foo/foo-1.0.ebuild":         "EAPI=8\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+		"app-misc/foo/foo-1.1.ebuild":         "EAPI=8\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+		"app-misc/foo/foo-1.2.ebuild":         "EAPI=8\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n",
+		"app-misc/foo/foo-1.3.ebuild":         "EAPI=8\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+		"profiles/arch/amd64/make.defaults":   "ARCH=\"amd64\"\nACCEPT_KEYWORDS=\"${ARCH}\"\n",
+		"profiles/default/amd64/parent":       "../../arch/amd64\n",
+		"profiles/default/amd64/package.mask": "=app-misc/foo-1.3\n",
+		"dev-libs/bar/bar-2.0.ebuild":         "EAPI=8\nSLOT=\"2/2.0\"\nKEYWORDS=\"amd64\"\n",
+		"dev-libs/bar/bar-3.0.ebuild":         "EAPI=8\nSLOT=\"3\"\nKEYWORDS=\"amd64\"\n",
+		"dev-libs/baz/baz-3.0.ebuild":         "EAPI=8\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+	})
+	writeTestFiles(t, configRoot, map[string]string{
+		"make.conf/00-local": "FEATURES=\"sandbox\"\n",
+	})
+	if err := os.Symlink(filepath.Join(repo, "profiles", "default", "amd64"), filepath.Join(configRoot, "make.profile")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &MainArgConfig{}
+
+	t.Run("list", func(t *testing.T) {
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdInstalled([]string{"-vdb", vdb, "list", "-v"})
+		})
+		if err != nil {
+			t.Fatalf("list failed: %v", err)
+		}
+		want := "app-misc/foo-1.0:0::test\ndev-libs/bar-2.0:2/2.0::test\ndev-libs/baz-1.0:0\n"
+		if out != want {
+			t.Errorf("unexpected output:\n%s\nwant:\n%s", out, want)
+		}
+	})
+
+	t.Run("show", func(t *testing.T) {
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdInstalled([]string{"-vdb", vdb, "show", "bar"})
+		})
+		if err != nil {
+			t.Fatalf("show failed: %v", err)
+		}
+		if !strings.Contains(out, "dev-libs/bar-2.0:2/2.0::test") || !strings.Contains(out, "SLOT        2/2.0") {
+			t.Errorf("unexpected output:\n%s", out)
+		}
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdInstalled([]string{"-vdb", vdb, "owner", "/usr/bin/foo"})
+		})
+		if err != nil {
+			t.Fatalf("owner failed: %v", err)
+		}
+		if strings.TrimSpace(out) != "app-misc/foo-1.0 (/usr/bin/foo)" {
+			t.Errorf("unexpected output: %q", out)
+		}
+	})
+
+	t.Run("owner relative", func(t *testing.T) {
+		t.Chdir("/usr")
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdInstalled([]string{"-vdb", vdb, "owner", "bin/foo", "/usr/bin/foo"})
+		})
+		if err != nil {
+			t.Fatalf("owner failed: %v", err)
+		}
+		if out != "app-misc/foo-1.0 (/usr/bin/foo)\napp-misc/foo-1.0 (/usr/bin/foo)\n" {
+			t.Errorf("unexpected output: %q", out)
+		}
+	})
+
+	t.Run("outdated", func(t *testing.T) {
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdInstalled([]string{"-vdb", vdb, "outdated", "-repo", repo, "-config-root", configRoot})
+		})
+		if err != nil {
+			t.Fatalf("outdated failed: %v", err)
+		}
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != 1 || !strings.HasPrefix(lines[0], "app-misc/foo:0") || !strings.HasSuffix(lines[0], "1.1::test") {
+			t.Errorf("expected only app-misc/foo to be outdated (1.1, not masked or testing versions), got:\n%s", out)
+		}
+	})
+
+	t.Run("outdated accept keywords", func(t *testing.T) {
+		writeTestFiles(t, configRoot, map[string]string{"package.accept_keywords/foo": "app-misc/foo\n"})
+		defer func() { _ = os.RemoveAll(filepath.Join(configRoot, "package.accept_keywords")) }()
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdInstalled([]string{"-vdb", vdb, "outdated", "-repo", repo, "-config-root", configRoot})
+		})
+		if err != nil {
+			t.Fatalf("outdated failed: %v", err)
+		}
+		if got := strings.TrimSpace(out); !strings.HasPrefix(got, "app-misc/foo:0") || !strings.HasSuffix(got, "1.2::test") {
+			t.Errorf("expected app-misc/foo 1.2 to be accepted through package.accept_keywords, got:\n%s", out)
+		}
+	})
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/arran4/g2"
+)
+
+type CmdKeywordsArgConfig struct {
+	*MainArgConfig
+}
+
+func (cfg *MainArgConfig) cmdKeywords(args []string) error {
+	fs := flag.NewFlagSet("keywords", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage:\n")
+		fmt.Printf("\t%s\n", strings.Join(cfg.Args, " "))
+		fmt.Printf("\t\t %s \t\t %s\n", "matrix", "show the version by arch keyword table of a package")
+		fmt.Printf("\t\t %s \t\t %s\n", "candidates", "list ebuilds that have been ~arch long enough to stabilize")
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing subcommand")
+	}
+
+	cmd := fs.Arg(0)
+	cfg.Args = append(cfg.Args, cmd)
+	config := &CmdKeywordsArgConfig{MainArgConfig: cfg}
+	switch cmd {
+	case "matrix":
+		return config.cmdKeywordsMatrix(fs.Args()[1:])
+	case "candidates":
+		return config.cmdKeywordsCandidates(fs.Args()[1:])
+	case "help", "-help", "--help":
+		fs.Usage()
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown subcommand: %s", cmd)
+	}
+}
+
+// readRepoArches reads profiles/arch.list and profiles/arches.desc of the repository at dir; either is
+// nil when missing.
+func readRepoArches(dir string) (*g2.ArchList, *g2.ArchesDesc) {
+	archList, err := g2.ParseArchListFile(filepath.Join(dir, "profiles", "arch.list"))
+	if err != nil {
+		archList = nil
+	}
+	var archesDesc *g2.ArchesDesc
+	if f, err := os.Open(filepath.Join(dir, "profiles", "arches.desc")); err == nil {
+		archesDesc, _ = g2.ParseArchesDesc(f)
+		_ = f.Close()
+	}
+	return archList, archesDesc
+}
+
+// readPackageVersions parses the ebuilds of the package directory dir, skipping those it cannot parse
+// with a warning. Each version carries the modification time of its ebuild.
+func readPackageVersions(dir string) ([]g2.VersionData, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []g2.VersionData
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".ebuild") {
+			continue
+		}
+		ebuild, err := g2.ParseEbuild(os.DirFS(dir), entry.Name(), g2.ParseVariables)
+		if err != nil {
+			log.Printf("Warning: skipping %s: %v", filepath.Join(dir, entry.Name()), err)
+			continue
+		}
+		v := g2.VersionData{Version: ebuild.Vars["PVR"], Ebuild: ebuild}
+		if info, err := entry.Info(); err == nil {
+			v.ModTime = info.ModTime()
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// readRepoPackages reads the arches and the versions of every package of the repository at dir with
+// readPackageVersions, without the rest of what parseRepo gathers for the site.
+func readRepoPackages(dir string) (*g2.SiteData, error) {
+	site := &g2.SiteData{RepoName: readRepoName(dir)}
+	site.ArchList, site.ArchesDesc = readRepoArches(dir)
+	categories, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range categories {
+		if !c.IsDir() || isIgnoredDir(c.Name()) {
+			continue
+		}
+		packages, err := os.ReadDir(filepath.Join(dir, c.Name()))
+		if err != nil {
+			return nil, err
+		}
+		category := g2.CategoryData{Name: c.Name()}
+		for _, p := range packages {
+			if !p.IsDir() || strings.HasPrefix(p.Name(), ".") {
+				continue
+			}
+			versions, err := readPackageVersions(filepath.Join(dir, c.Name(), p.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if len(versions) > 0 {
+				category.Packages = append(category.Packages, g2.PackageData{Name: p.Name(), Category: c.Name(), Versions: versions})
+			}
+		}
+		if len(category.Packages) > 0 {
+			site.Categories = append(site.Categories, category)
+		}
+	}
+	return site, nil
+}
+
+func (cfg *CmdKeywordsArgConfig) cmdKeywordsMatrix(args []string, opts ...any) error {
+	var out io.Writer = os.Stdout
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case io.Writer:
+			out = o
+		}
+	}
+
+	fs := flag.NewFlagSet("matrix", flag.ExitOnError)
+	repo := fs.String("repo", ".", "Path to the repository")
+	asJSON := fs.Bool("json", false, "Output the table as JSON")
+	positional, err := parseFlagsAndArgs(fs, args)
+	if err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: g2 keywords matrix [-repo dir] [-json] <category/package>")
+	}
+	atom := g2.ParsePackageAtom(positional[0])
+	if atom.Category == "" || atom.Name == "" {
+		return fmt.Errorf("invalid package %q", positional[0])
+	}
+
+	versions, err := readPackageVersions(filepath.Join(*repo, atom.Category, atom.Name))
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", atom.Key(), err)
+	}
+	if len(versions) == 0 {
+		return &ExitError{Code: 1, Err: fmt.Errorf("no ebuilds for %s in %s", atom.Key(), *repo)}
+	}
+	archList, archesDesc := readRepoArches(*repo)
+	m := g2.NewKeywordMatrix(versions, archList, archesDesc)
+
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(m)
+	}
+	var table bytes.Buffer
+	w := tabwriter.NewWriter(&table, 0, 0, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "VERSION\tSLOT\t%s\n", strings.Join(m.Arches, "\t"))
+	for _, row := range m.Rows {
+		symbols := make([]string, len(m.Arches))
+		for i, arch := range m.Arches {
+			symbols[i] = row.Status(arch).Symbol()
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", row.Version, row.Slot, strings.Join(symbols, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	// Unkeyworded arches at the end of a row would leave trailing blanks.
+	for _, line := range strings.SplitAfter(table.String(), "\n") {
+		if line != "" {
+			_, _ = fmt.Fprintln(out, strings.TrimRight(line, " \n"))
+		}
+	}
+	return nil
+}
+
+func (cfg *CmdKeywordsArgConfig) cmdKeywordsCandidates(args []string, opts ...any) error {
+	var out io.Writer = os.Stdout
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case io.Writer:
+			out = o
+		}
+	}
+
+	fs := flag.NewFlagSet("candidates", flag.ExitOnError)
+	repo := fs.String("repo", ".", "Path to the repository")
+	days := fs.Int("days", 30, "Days an ebuild must have been keyworded for testing")
+	archesOpt := fs.String("arch", "", "Comma separated arches to report (default: the stable arches of arches.desc)")
+	historyDepth := fs.Int("history-depth", 5000, "Number of commits of history to read, or 0 for the whole history")
+	var masterDirs StringSliceFlag
+	fs.Var(&masterDirs, "master", "Path to a master repository to resolve dependencies in (repeatable, default: the masters of layout.conf found in repos.conf)")
+	reposConf := fs.String("repos-conf", "/etc/portage/repos.conf", "Path to repos.conf file or directory")
+	if _, err := parseFlagsAndArgs(fs, args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	abs, err := filepath.Abs(*repo)
+	if err != nil {
+		return err
+	}
+	site, err := readRepoPackages(abs)
+	if err != nil {
+		return fmt.Errorf("parsing repository %s: %w", *repo, err)
+	}
+	if err := attachRepoHistory(abs, site, g2.HistoryOptions{MaxCommits: *historyDepth}); err != nil {
+		log.Printf("Warning: reading git history of %s, using file modification times: %v", *repo, err)
+	}
+
+	var arches []string
+	for _, a := range strings.Split(*archesOpt, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			arches = append(arches, a)
+		}
+	}
+	now := time.Now()
+	candidates := g2.StabilizationCandidates(site, g2.StabilizationOptions{
+		MinAge:  time.Duration(*days) * 24 * time.Hour,
+		Arches:  arches,
+		Now:     now,
+		Masters: masterPackageVersions(abs, masterDirs, *reposConf),
+	})
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, c := range candidates {
+		_, _ = fmt.Fprintf(w, "=%s-%s\t%s\t%d days\n", c.Package, c.Version, c.Arch, int(c.Age(now).Hours()/24))
+	}
+	return w.Flush()
+}
+
+// masterPackageVersions returns a lookup of package versions in the master repositories of the
+// repository at dir: masterDirs when given, otherwise the masters its layout.conf names, found in
+// reposConf. It returns nil for a repository without masters.
+func masterPackageVersions(dir string, masterDirs []string, reposConf string) func(pkg string) []g2.VersionData {
+	var names []string
+	if lc, err := g2.ParseLayoutConf(filepath.Join(dir, "metadata", "layout.conf")); err == nil {
+		names = lc.Masters()
+	}
+	if len(masterDirs) == 0 && len(names) == 0 {
+		return nil
+	}
+	var locations []string
+	if len(masterDirs) > 0 {
+		locations = masterDirs
+	} else {
+		repos, err := resolveRepoStack(nil, reposConf)
+		if err != nil {
+			log.Printf("Warning: finding the masters of %s: %v", dir, err)
+		}
+		for _, name := range names {
+			found := false
+			for _, r := range repos {
+				if r.RepoName == name {
+					locations = append(locations, r.Location)
+					found = true
+					break
+				}
+			}
+			if !found {
+				log.Printf("Warning: master repository %s of %s is not configured; its packages count as unstable", name, dir)
+			}
+		}
+	}
+	return func(pkg string) []g2.VersionData {
+		for _, loc := range locations {
+			if versions, err := readPackageVersions(filepath.Join(loc, pkg)); err == nil && len(versions) > 0 {
+				return versions
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arran4/g2"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestKeywordsCommands(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":          "kw\n",
+		"profiles/categories":         "app-misc\ndev-libs\n",
+		"profiles/arch.list":          "amd64\narm64\nx86\n",
+		"profiles/arches.desc":        "amd64 stable\narm64 stable\nx86 testing\n",
+		"app-misc/foo/foo-1.0.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64 ~arm64 x86\"\nRDEPEND=\"dev-libs/bar\"\n",
+		"app-misc/foo/foo-1.1.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n",
+		"dev-libs/bar/bar-1.ebuild":   "EAPI=8\nDESCRIPTION=\"Bar\"\nSLOT=\"0\"\nKEYWORDS=\"amd64 ~arm64\"\n",
+	})
+	cfg := &CmdKeywordsArgConfig{MainArgConfig: &MainArgConfig{Args: []string{"g2", "keywords"}}}
+
+	var buf bytes.Buffer
+	if err := cfg.cmdKeywordsMatrix([]string{"-repo", repo, "app-misc/foo"}, &buf); err != nil {
+		t.Fatalf("matrix: %v", err)
+	}
+	want := "VERSION SLOT amd64 arm64 x86\n" +
+		"1.1     0    ~\n" +
+		"1.0     0    ~     ~     +\n"
+	if buf.String() != want {
+		t.Errorf("matrix:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	gitRepo, err := git.PlainInit(repo, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := gitRepo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add("."); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "Jane Doe", Email: "jane@example.org", When: time.Now().AddDate(0, 0, -45)}
+	if _, err := wt.Commit("initial import", &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := cfg.cmdKeywordsCandidates([]string{"-repo", repo, "-days", "30"}, &buf); err != nil {
+		t.Fatalf("candidates: %v", err)
+	}
+	// foo-1.0 is not a candidate on arm64 until dev-libs/bar is stable there; x86 is not a stable arch.
+	if got := buf.String(); got != "=app-misc/foo-1.0  amd64  45 days\n=app-misc/foo-1.1  amd64  45 days\n=dev-libs/bar-1    arm64  45 days\n" {
+		t.Errorf("candidates:\n%s", got)
+	}
+	buf.Reset()
+	if err := cfg.cmdKeywordsCandidates([]string{"-repo", repo, "-days", "60"}, &buf); err != nil {
+		t.Fatalf("candidates: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("candidates younger than 60 days: %s", buf.String())
+	}
+
+	site, err := parseRepo(os.DirFS(repo), ".", "Keywords", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	outDir := t.TempDir()
+	if err := generateSite(outDir, []*g2.SiteData{site}, 90*24*time.Hour, "3 months", GenerationInfo{}); err != nil {
+		t.Fatalf("generateSite: %v", err)
+	}
+	page, err := os.ReadFile(filepath.Join(outDir, "repos", "kw", "categories", "app-misc", "packages", "foo", "keywords", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `title="x86: stable">`) || !strings.Contains(string(page), `>x86</a>*</th>`) {
+		t.Errorf("keywords page: %s", page)
+	}
+}
+
+func TestKeywordsCandidatesRevisions(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":             "kw\n",
+		"profiles/categories":            "app-misc\n",
+		"profiles/arch.list":             "amd64\n",
+		"profiles/arches.desc":           "amd64 stable\n",
+		"app-misc/foo/foo-2.0.ebuild":    "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n",
+		"app-misc/foo/foo-2.0-r1.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\nRDEPEND=\"sys-libs/zlib\"\n",
+		"app-misc/foo/foo-2.1.ebuild":    "EAPI=8\nKEYWORDS=\"~amd64\n",
+		"metadata/layout.conf":           "masters = gentoo\n",
+	})
+	master := t.TempDir()
+	writeTestFiles(t, master, map[string]string{
+		"profiles/repo_name":               "gentoo\n",
+		"sys-libs/zlib/zlib-1.3-r1.ebuild": "EAPI=8\nSLOT=\"0/1\"\nKEYWORDS=\"amd64\"\n",
+	})
+	cfg := &CmdKeywordsArgConfig{MainArgConfig: &MainArgConfig{Args: []string{"g2", "keywords"}}}
+
+	var buf, logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	if err := cfg.cmdKeywordsCandidates([]string{"-repo", repo, "-master", master, "-days", "0"}, &buf); err != nil {
+		t.Fatalf("candidates: %v", err)
+	}
+	// The unparsable foo-2.1 is skipped rather than hiding the rest of app-misc/foo.
+	if got := buf.String(); got != "=app-misc/foo-2.0-r1  amd64  0 days\n" {
+		t.Errorf("candidates:\n%s", got)
+	}
+	if !strings.Contains(logs.String(), "Warning: skipping "+filepath.Join(repo, "app-misc", "foo", "foo-2.1.ebuild")) {
+		t.Errorf("expected a warning for the unparsable ebuild:\n%s", logs.String())
+	}
+	// Without its master sys-libs/zlib cannot be shown to be stable.
+	buf.Reset()
+	if err := cfg.cmdKeywordsCandidates([]string{"-repo", repo, "-repos-conf", filepath.Join(repo, "missing"), "-days", "0"}, &buf); err != nil {
+		t.Fatalf("candidates: %v", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("candidates without the master:\n%s", buf.String())
+	}
+
+	site, err := parseRepo(os.DirFS(repo), ".", "Keywords", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	m := g2.NewKeywordMatrix(site.Categories[0].Packages[0].Versions, site.ArchList, site.ArchesDesc)
+	if len(m.Rows) != 2 || m.Rows[0].Version != "2.0-r1" || m.Rows[1].Version != "2.0" {
+		t.Errorf("matrix rows = %+v", m.Rows)
+	}
+}
@@ -13,11 +13,11 @@ import (
 	"github.com/arran4/g2/lints"
 	"github.com/arran4/g2/lints/ebuild"
 	"github.com/arran4/g2/lints/layout"
+	lintmetadata "github.com/arran4/g2/lints/metadata"
 
 	_ "github.com/arran4/g2/lints/ebuild"
 	_ "github.com/arran4/g2/lints/eclass"
 	_ "github.com/arran4/g2/lints/md5cache"
-	_ "github.com/arran4/g2/lints/metadata"
 	_ "github.com/arran4/g2/lints/news"
 	_ "github.com/arran4/g2/lints/profiles"
 )
@@ -93,6 +93,7 @@ func (cfg *MainArgConfig) runOldLint(args []string) error {
 	allowGithubAPI := fs.Bool("allow-github-api", false, "Allow fetching upstream categories via Github API for layout lint")
 	upstreamRepoPath := fs.String("upstream-repo-path", "", "Path to upstream repository on disk for layout lint (overrides github API)")
 	checkGLEP81Externally := fs.Bool("check-glep81-externally", false, "Enable external checking for GLEP 81 policy")
+	openpgpKey := fs.String("openpgp-key", "", "Keyring file to check Manifest signatures against")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -102,6 +103,9 @@ func (cfg *MainArgConfig) runOldLint(args []string) error {
 	layout.AllowGithubAPI = *allowGithubAPI
 	layout.UpstreamRepoPath = *upstreamRepoPath
 	ebuild.CheckGLEP81Externally = *checkGLEP81Externally
+	if err := setManifestKeyring(*openpgpKey); err != nil {
+		return err
+	}
 
 	location := "."
 	var targetPkgs []string
@@ -275,6 +279,20 @@ func (cfg *MainArgConfig) runOldLint(args []string) error {
 	return nil
 }
 
+// setManifestKeyring loads the keyring that the ManifestSignature lint checks signatures against.
+func setManifestKeyring(path string) error {
+	lintmetadata.ManifestKeyring = nil
+	if path == "" {
+		return nil
+	}
+	keyring, err := readKeyRing(path)
+	if err != nil {
+		return err
+	}
+	lintmetadata.ManifestKeyring = keyring
+	return nil
+}
+
 func printGithubActions(results []lints.LintResult) {
 	for _, res := range results {
 		level := "error"
@@ -704,6 +722,7 @@ func (cfg *MainArgConfig) cmdLintRepo(args []string) error {
 	allowGithubAPI := fs.Bool("allow-github-api", false, "Allow fetching upstream categories via Github API for layout lint")
 	upstreamRepoPath := fs.String("upstream-repo-path", "", "Path to upstream repository on disk for layout lint (overrides github API)")
 	checkGLEP81Externally := fs.Bool("check-glep81-externally", false, "Enable external checking for GLEP 81 policy")
+	openpgpKey := fs.String("openpgp-key", "", "Keyring file to check Manifest signatures against")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -713,6 +732,9 @@ func (cfg *MainArgConfig) cmdLintRepo(args []string) error {
 	layout.AllowGithubAPI = *allowGithubAPI
 	layout.UpstreamRepoPath = *upstreamRepoPath
 	ebuild.CheckGLEP81Externally = *checkGLEP81Externally
+	if err := setManifestKeyring(*openpgpKey); err != nil {
+		return err
+	}
 
 	location := "."
 	if fs.NArg() > 0 {
@@ -742,6 +764,7 @@ func (cfg *MainArgConfig) cmdLintPackage(args []string) error {
 	allowGithubAPI := fs.Bool("allow-github-api", false, "Allow fetching upstream categories via Github API for layout lint")
 	upstreamRepoPath := fs.String("upstream-repo-path", "", "Path to upstream repository on disk for layout lint (overrides github API)")
 	checkGLEP81Externally := fs.Bool("check-glep81-externally", false, "Enable external checking for GLEP 81 policy")
+	openpgpKey := fs.String("openpgp-key", "", "Keyring file to check Manifest signatures against")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -751,6 +774,9 @@ func (cfg *MainArgConfig) cmdLintPackage(args []string) error {
 	layout.AllowGithubAPI = *allowGithubAPI
 	layout.UpstreamRepoPath = *upstreamRepoPath
 	ebuild.CheckGLEP81Externally = *checkGLEP81Externally
+	if err := setManifestKeyring(*openpgpKey); err != nil {
+		return err
+	}
 
 	location := "."
 	var targetPkgs []string
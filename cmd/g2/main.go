@@ -51,6 +51,7 @@ func main() {
 		fmt.Printf("\t\t %s \t\t %s\n", "versions", "commands relating to version utilities")
 		fmt.Printf("\t\t %s \t\t %s\n", "metadata", "commands relating to metadata.xml files")
 		fmt.Printf("\t\t %s \t\t %s\n", "ebuild", "commands relating to ebuild files")
+		fmt.Printf("\t\t %s \t\t %s\n", "keywords", "keyword tables and stabilization candidates")
 		fmt.Printf("\t\t %s \t\t %s\n", "overlay", "commands relating to a single overlay")
 		fmt.Printf("\t\t %s \t\t %s\n", "overlays", "commands relating to multiple overlays")
 		fmt.Printf("\t\t %s \t\t %s\n", "lint", "lints the repository for errors (supports optional target packages)")
@@ -64,10 +65,17 @@ func main() {
 		fmt.Printf("\t\t %s \t\t %s\n", "arch", "commands relating to architectures")
 		fmt.Printf("\t\t %s \t\t %s\n", "profile", "commands relating to profiles")
 		fmt.Printf("\t\t %s \t\t %s\n", "repos-conf", "commands relating to repos.conf")
+		fmt.Printf("\t\t %s \t\t %s\n", "repos", "sync repositories configured in repos.conf")
 		fmt.Printf("\t\t %s \t\t %s\n", "make-conf", "commands relating to make.conf")
 		fmt.Printf("\t\t %s \t\t %s\n", "conf", "commands relating to portage configuration")
 		fmt.Printf("\t\t %s \t\t %s\n", "skill", "manage agent skills")
 		fmt.Printf("\t\t %s \t\t %s\n", "world", "manage the portage world file via TUI")
+		fmt.Printf("\t\t %s \t\t %s\n", "sets", "manage package sets in /etc/portage/sets and world_sets")
+		fmt.Printf("\t\t %s \t\t %s\n", "installed", "query the installed package database")
+		fmt.Printf("\t\t %s \t\t %s\n", "news", "read and track repository news items for the local system")
+		fmt.Printf("\t\t %s \t\t %s\n", "binpkg", "inspect and verify GLEP 78 binary packages")
+		fmt.Printf("\t\t %s \t\t %s\n", "binhost", "generate binhost Packages indexes")
+		fmt.Printf("\t\t %s \t\t %s\n", "sbom", "export SPDX or CycloneDX bills of materials for a repository or the installed system")
 	}
 	if err := fs.Parse(os.Args); err != nil {
 		log.Printf("Flag parse error: %s", err)
@@ -97,10 +105,24 @@ func main() {
 		err = ProfileCommand(fs.Args()[2:])
 	case "repos-conf":
 		err = cfg.cmdReposConf(fs.Args()[2:])
+	case "repos":
+		err = cfg.cmdRepos(fs.Args()[2:])
 	case "make-conf":
 		err = cfg.cmdMakeConf(fs.Args()[2:])
+	case "sets":
+		err = cfg.cmdSets(fs.Args()[2:])
+	case "binpkg":
+		err = cfg.cmdBinpkg(fs.Args()[2:])
+	case "binhost":
+		err = cfg.cmdBinhost(fs.Args()[2:])
+	case "sbom":
+		err = cfg.cmdSbom(fs.Args()[2:])
 	case "world":
 		err = cfg.cmdWorld(fs.Args()[2:])
+	case "installed":
+		err = cfg.cmdInstalled(fs.Args()[2:])
+	case "news":
+		err = cfg.cmdNews(fs.Args()[2:])
 	case "manifest":
 		logPrefix = "generate"
 		err = cfg.cmdManifest(fs.Args()[2:])
@@ -112,6 +134,8 @@ func main() {
 		err = cfg.cmdMetadata(fs.Args()[2:])
 	case "ebuild":
 		err = cfg.cmdEbuild(fs.Args()[2:])
+	case "keywords":
+		err = cfg.cmdKeywords(fs.Args()[2:])
 	case "overlay":
 		err = cfg.cmdOverlay(fs.Args()[2:])
 	case "overlays":
@@ -166,6 +190,8 @@ func (cfg *MainArgConfig) cmdManifest(args []string) error {
 		fmt.Printf("\t\t %s \t\t %s\n", "upsert-from-url", "To update or insert Manifest entries streamed from a URL")
 		fmt.Printf("\t\t %s \t\t %s\n", "verify", "To verify the manifest against ebuild files")
 		fmt.Printf("\t\t %s \t\t %s\n", "clean", "To clean up the manifest from unused entries")
+		fmt.Printf("\t\t %s \t\t %s\n", "tree", "To generate or verify the GLEP 74 Manifest tree of a repository")
+		fmt.Printf("\t\t %s \t\t %s\n", "sign", "To clearsign Manifests with an OpenPGP key")
 	}
 
 	config := &CmdManifestArgConfig{
@@ -299,6 +325,10 @@ func (cfg *MainArgConfig) cmdManifest(args []string) error {
 		if err := config.cmdClean(cleanArgs); err != nil {
 			return fmt.Errorf("clean manifest: %w", err)
 		}
+	case "tree":
+		return config.cmdManifestTree(fs.Args()[1:])
+	case "sign":
+		return config.cmdManifestSign(fs.Args()[1:])
 	case "help", "-help", "--help":
 		fs.Usage()
 		return nil
@@ -371,13 +401,15 @@ func (cfg *CmdManifestArgConfig) cmdVerify(args []string, hashes []string) error
 	fs := flag.NewFlagSet("verify", flag.ExitOnError)
 	fix := fs.Bool("fix", false, "Force fix missing manifest entries")
 	clean := fs.Bool("clean", false, "Clean up unused manifest entries")
+	openpgpKey := fs.String("openpgp-key", "", "Check the Manifest signature against the keys in this keyring file")
+	stripSignature := fs.Bool("strip-signature", false, "Allow -fix and -clean to rewrite a signed Manifest without its signature")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
 	if fs.NArg() < 1 {
-		return fmt.Errorf("usage: verify [--fix] [--clean] <manifestFileOrDir>")
+		return fmt.Errorf("usage: verify [--fix] [--clean] [--strip-signature] [--openpgp-key FILE] <manifestFileOrDir>")
 	}
 
 	target := fs.Arg(0)
@@ -397,6 +429,17 @@ func (cfg *CmdManifestArgConfig) cmdVerify(args []string, hashes []string) error
 		directory = filepath.Dir(target)
 	}
 
+	if *openpgpKey != "" {
+		if err := verifyManifestSignature(manifestPath, *openpgpKey); err != nil {
+			return err
+		}
+	}
+	if *fix || *clean {
+		if err := checkSignedManifestWrite(manifestPath, *stripSignature); err != nil {
+			return err
+		}
+	}
+
 	log.Printf("Processing directory: %s", directory)
 
 	// Load Manifest
@@ -497,10 +540,15 @@ func (cfg *CmdManifestArgConfig) cmdVerify(args []string, hashes []string) error
 }
 
 func (cfg *CmdManifestArgConfig) cmdClean(args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("usage: clean <manifestFileOrDir>")
+	fs := flag.NewFlagSet("clean", flag.ExitOnError)
+	stripSignature := fs.Bool("strip-signature", false, "Allow rewriting a signed Manifest without its signature")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: clean [--strip-signature] <manifestFileOrDir>")
 	}
-	target := args[0]
+	target := fs.Arg(0)
 
 	// Determine manifest path and directory
 	var manifestPath, directory string
@@ -517,6 +565,10 @@ func (cfg *CmdManifestArgConfig) cmdClean(args []string) error {
 		directory = filepath.Dir(target)
 	}
 
+	if err := checkSignedManifestWrite(manifestPath, *stripSignature); err != nil {
+		return err
+	}
+
 	log.Printf("Processing directory: %s", directory)
 
 	manifest, err := g2.ParseManifest(manifestPath)
@@ -532,6 +584,25 @@ func (cfg *CmdManifestArgConfig) cmdClean(args []string) error {
 	return os.WriteFile(manifestPath, []byte(manifest.String()), 0644)
 }
 
+// checkSignedManifestWrite refuses to rewrite the Manifest at manifestPath when it is clearsigned, as the
+// rewritten Manifest carries no signature, unless stripSignature allows it; then it warns that the
+// Manifest must be signed again.
+func checkSignedManifestWrite(manifestPath string, stripSignature bool) error {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		// A missing Manifest is created unsigned; other errors are reported when it is parsed.
+		return nil
+	}
+	if _, signed := g2.ManifestPlaintext(data); !signed {
+		return nil
+	}
+	if !stripSignature {
+		return &ExitError{Code: 1, Err: fmt.Errorf("%s is signed and rewriting it would drop the signature; pass -strip-signature and sign it again with g2 manifest sign", manifestPath)}
+	}
+	log.Printf("Warning: %s was signed; the rewritten Manifest is unsigned and must be signed again with g2 manifest sign", manifestPath)
+	return nil
+}
+
 func (cfg *CmdManifestArgConfig) upsertFromUrlLogic(url, filename, manifestPath string, hashes []string) error {
 	checksums, err := g2.DownloadAndChecksum(url, hashes)
 	if err != nil {
@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
+	"os"
 
 	"github.com/arran4/g2"
 )
@@ -10,10 +12,15 @@ import (
 func (cfg *MainArgConfig) cmdMakeConf(args []string) error {
 	fs := flag.NewFlagSet("make-conf", flag.ExitOnError)
 	fs.Usage = func() {
-		fmt.Printf("Usage: g2 make-conf <key>\n")
+		fmt.Printf("Usage: g2 make-conf [-location <path>] <key>\n")
+		fmt.Printf("       g2 make-conf [-location <path>] <subcommand>\n")
+		fmt.Printf("\t\t %s \t\t %s\n", "set <key> <value>", "set a variable, replacing its last assignment")
+		fmt.Printf("\t\t %s \t\t %s\n", "unset <key>", "remove every assignment of a variable")
+		fmt.Printf("\t\t %s \t\t %s\n", "add-token <key> <token>...", "add tokens to a variable such as USE or FEATURES")
+		fmt.Printf("\t\t %s \t\t %s\n", "remove-token [-negate] <key> <token>...", "remove tokens from a variable")
 	}
 
-	locationOpt := fs.String("location", "/etc/portage/make.conf", "Path to make.conf file")
+	locationOpt := fs.String("location", "/etc/portage/make.conf", "Path to make.conf file or directory")
 
 	if err := fs.Parse(args); err != nil {
 		return err
@@ -24,8 +31,23 @@ func (cfg *MainArgConfig) cmdMakeConf(args []string) error {
 		return fmt.Errorf("missing key for make-conf")
 	}
 
+	switch fs.Arg(0) {
+	case "set", "unset", "add-token", "remove-token":
+		return cmdMakeConfEdit(*locationOpt, fs.Arg(0), fs.Args()[1:])
+	}
+
 	key := fs.Arg(0)
 
+	if info, err := os.Stat(*locationOpt); err == nil && info.IsDir() {
+		// make.conf directories are read through the editor, which knows their file order.
+		editor, err := g2.LoadMakeConfEditor(*locationOpt)
+		if err != nil {
+			return err
+		}
+		val, _ := editor.Value(key)
+		fmt.Println(val)
+		return nil
+	}
 	vars, err := g2.ParseMakeConf(*locationOpt)
 	if err != nil {
 		return fmt.Errorf("parsing make.conf: %w", err)
@@ -41,3 +63,67 @@ func (cfg *MainArgConfig) cmdMakeConf(args []string) error {
 
 	return nil
 }
+
+func cmdMakeConfEdit(location, action string, args []string) error {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	negate := false
+	if action == "remove-token" {
+		fs.BoolVar(&negate, "negate", false, "For incremental variables, add -token so that profile defaults are disabled too")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+
+	editor, err := g2.LoadMakeConfEditor(location)
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	switch action {
+	case "set":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: g2 make-conf set <key> <value>")
+		}
+		changed = editor.Set(args[0], args[1])
+	case "unset":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: g2 make-conf unset <key>")
+		}
+		changed = editor.Unset(args[0]) > 0
+	case "add-token", "remove-token":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: g2 make-conf %s <key> <token>...", action)
+		}
+		key, tokens := args[0], args[1:]
+		if !g2.IsIncrementalMakeConfVar(key) {
+			log.Printf("Warning: %s is not an incremental variable; its make.conf value replaces the profile's", key)
+		}
+		switch {
+		case action == "add-token":
+			changed = editor.UpdateTokens(key, tokens, nil)
+		case negate:
+			var negated []string
+			for _, t := range tokens {
+				negated = append(negated, "-"+t)
+			}
+			changed = editor.UpdateTokens(key, negated, nil)
+		default:
+			changed = editor.UpdateTokens(key, nil, tokens)
+		}
+	}
+
+	if !changed {
+		fmt.Println("No changes.")
+		return nil
+	}
+	files, err := editor.Save()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		fmt.Printf("Updated %s\n", f)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdMakeConfEdit(t *testing.T) {
+	dir := t.TempDir()
+	makeConf := filepath.Join(dir, "make.conf")
+	writeTestFiles(t, dir, map[string]string{
+		"make.conf": "# USE flags\nUSE=\"X -wayland\" # desktop\nFEATURES=\"ccache\"\n",
+	})
+	cfg := &MainArgConfig{}
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdMakeConf(append([]string{"-location", makeConf}, args...))
+		})
+		if err != nil {
+			t.Fatalf("make-conf %v failed: %v", args, err)
+		}
+		return out
+	}
+
+	run("add-token", "USE", "wayland", "pipewire")
+	run("remove-token", "-negate", "USE", "X")
+	run("remove-token", "FEATURES", "ccache")
+	run("set", "MAKEOPTS", "-j8 -l8")
+	if out := run("unset", "NOPE"); !strings.Contains(out, "No changes.") {
+		t.Errorf("unexpected unset output: %q", out)
+	}
+
+	data, _ := os.ReadFile(makeConf)
+	want := "# USE flags\nUSE=\"-X wayland pipewire\" # desktop\nFEATURES=\"\"\nMAKEOPTS=\"-j8 -l8\"\n"
+	if string(data) != want {
+		t.Errorf("unexpected make.conf:\n%s\nwant:\n%s", data, want)
+	}
+	if out := run("MAKEOPTS"); out != "-j8 -l8\n" {
+		t.Errorf("unexpected value: %q", out)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/arran4/g2"
+)
+
+func (cfg *CmdManifestArgConfig) cmdManifestSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	keyringPath := fs.String("keyring", "", "Armored or binary OpenPGP keyring holding the secret key")
+	keyQuery := fs.String("key", "", "Key ID, fingerprint or user ID of the signing key (default: the first secret key)")
+	passphraseFile := fs.String("passphrase-file", "", "File holding the passphrase of an encrypted secret key")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if *keyringPath == "" || fs.NArg() == 0 {
+		return fmt.Errorf("usage: manifest sign -keyring FILE [-key ID] [-passphrase-file FILE] <manifestFileOrDir>...")
+	}
+	keyring, err := readKeyRing(*keyringPath)
+	if err != nil {
+		return err
+	}
+	signer, err := findSigningKey(keyring, *keyQuery)
+	if err != nil {
+		return err
+	}
+	if *passphraseFile != "" {
+		passphrase, err := os.ReadFile(*passphraseFile)
+		if err != nil {
+			return fmt.Errorf("reading passphrase: %w", err)
+		}
+		if err := signer.DecryptPrivateKeys(bytes.TrimRight(passphrase, "\r\n")); err != nil {
+			return fmt.Errorf("decrypting secret key: %w", err)
+		}
+	}
+
+	key, err := g2.ManifestSigningKey(signer)
+	if err != nil {
+		return &ExitError{Code: 1, Err: err}
+	}
+
+	for _, target := range fs.Args() {
+		manifestPath := target
+		if info, err := os.Stat(target); err == nil && info.IsDir() {
+			manifestPath = filepath.Join(target, "Manifest")
+		}
+		data, err := os.ReadFile(manifestPath)
+		if err != nil {
+			return &ExitError{Code: 1, Err: fmt.Errorf("reading manifest: %w", err)}
+		}
+		signed, err := g2.SignManifest(data, signer)
+		if err != nil {
+			return &ExitError{Code: 1, Err: fmt.Errorf("signing %s: %w", manifestPath, err)}
+		}
+		if err := g2.SafeWriteFileAtomic(manifestPath, signed, 0644); err != nil {
+			return &ExitError{Code: 1, Err: fmt.Errorf("writing %s: %w", manifestPath, err)}
+		}
+		fmt.Printf("Signed %s with %X\n", manifestPath, key.Fingerprint)
+	}
+	return nil
+}
+
+// findSigningKey returns the first entity with a secret key whose key ID, fingerprint or user ID matches
+// query, or the first one when query is empty.
+func findSigningKey(keyring openpgp.EntityList, query string) (*openpgp.Entity, error) {
+	query = strings.ToUpper(strings.TrimPrefix(strings.ReplaceAll(query, " ", ""), "0x"))
+	for _, e := range keyring {
+		if e.PrivateKey == nil {
+			continue
+		}
+		if query == "" || strings.HasSuffix(fmt.Sprintf("%X", e.PrimaryKey.Fingerprint), query) {
+			return e, nil
+		}
+		for name := range e.Identities {
+			if strings.Contains(strings.ToUpper(name), query) {
+				return e, nil
+			}
+		}
+	}
+	if query == "" {
+		return nil, fmt.Errorf("keyring has no secret key")
+	}
+	return nil, fmt.Errorf("no secret key matching %s", query)
+}
+
+// verifyManifestSignature checks the signature of the Manifest at manifestPath against the keys in
+// keyringPath and reports the signer.
+func verifyManifestSignature(manifestPath, keyringPath string) error {
+	keyring, err := readKeyRing(keyringPath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("reading manifest: %w", err)
+	}
+	sig, err := g2.VerifyManifestSignature(data, keyring)
+	if err != nil {
+		return fmt.Errorf("%s: %w", manifestPath, err)
+	}
+	fmt.Printf("%s: good signature from %s made %s\n", manifestPath, sig, sig.Created.UTC().Format("2006-01-02 15:04:05 MST"))
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// writeTestKeyring writes an armored keyring holding a new key, with its secret key when secret is set.
+func writeTestKeyring(t *testing.T, path string, e *openpgp.Entity, secret bool) {
+	t.Helper()
+	var buf bytes.Buffer
+	blockType := openpgp.PublicKeyType
+	if secret {
+		blockType = openpgp.PrivateKeyType
+	}
+	w, err := armor.Encode(&buf, blockType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret {
+		err = e.SerializePrivate(w, nil)
+	} else {
+		err = e.Serialize(w)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManifestSignAndVerify(t *testing.T) {
+	root := t.TempDir()
+	signer, err := openpgp.NewEntity("Overlay Maintainer", "", "maintainer@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secring := filepath.Join(root, "secring.asc")
+	pubring := filepath.Join(root, "pubring.asc")
+	writeTestKeyring(t, secring, signer, true)
+	writeTestKeyring(t, pubring, signer, false)
+	pkgDir := filepath.Join(root, "app-misc", "foo")
+	writeTestFiles(t, pkgDir, map[string]string{
+		"foo-1.0.ebuild": "EAPI=8\n",
+		"Manifest":       "DIST foo-1.0.tar.gz 10 BLAKE2B aa SHA512 bb\n",
+	})
+	cfg := &MainArgConfig{}
+
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdManifest([]string{"sign", "-keyring", pubring, pkgDir})
+	}); err == nil || !strings.Contains(err.Error(), "no secret key") {
+		t.Errorf("signing with a public keyring: %v", err)
+	}
+	out, err := captureOutput(t, func() error {
+		return cfg.cmdManifest([]string{"sign", "-keyring", secring, "-key", "maintainer@example.org", pkgDir})
+	})
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if !strings.Contains(out, "Signed "+filepath.Join(pkgDir, "Manifest")) {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	out, err = captureOutput(t, func() error {
+		return cfg.cmdManifest([]string{"verify", "--openpgp-key", pubring, pkgDir})
+	})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !strings.Contains(out, "good signature from Overlay Maintainer <maintainer@example.org>") {
+		t.Errorf("unexpected output: %s", out)
+	}
+
+	manifest := filepath.Join(pkgDir, "Manifest")
+	data, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifest, bytes.Replace(data, []byte(" 10 "), []byte(" 12 "), 1), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdManifest([]string{"verify", "--openpgp-key", pubring, pkgDir})
+	}); err == nil || !strings.Contains(err.Error(), "bad signature") {
+		t.Errorf("verify of a modified Manifest: %v", err)
+	}
+
+	// Rewriting a signed Manifest would drop its signature.
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdManifest([]string{"sign", "-keyring", secring, pkgDir})
+	}); err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	if _, err := captureOutput(t, func() error { return cfg.cmdManifest([]string{"clean", pkgDir}) }); err == nil || !strings.Contains(err.Error(), "-strip-signature") {
+		t.Errorf("clean of a signed Manifest: %v", err)
+	}
+	if _, err := captureOutput(t, func() error { return cfg.cmdManifest([]string{"verify", "-clean", pkgDir}) }); err == nil {
+		t.Error("expected verify -clean of a signed Manifest to fail")
+	}
+	if data, _ := os.ReadFile(manifest); !bytes.HasPrefix(data, []byte("-----BEGIN PGP SIGNED MESSAGE-----")) {
+		t.Errorf("refused clean rewrote the Manifest:\n%s", data)
+	}
+	if _, err := captureOutput(t, func() error { return cfg.cmdManifest([]string{"clean", "-strip-signature", pkgDir}) }); err != nil {
+		t.Fatalf("clean -strip-signature: %v", err)
+	}
+	if data, _ := os.ReadFile(manifest); bytes.Contains(data, []byte("PGP")) {
+		t.Errorf("clean -strip-signature left a signature:\n%s", data)
+	}
+
+	// So would regenerating a signed top-level Manifest.
+	topManifest := filepath.Join(root, "Manifest")
+	for _, args := range [][]string{{"tree", "generate", root}, {"sign", "-keyring", secring, root}} {
+		if _, err := captureOutput(t, func() error { return cfg.cmdManifest(args) }); err != nil {
+			t.Fatalf("%s: %v", strings.Join(args[:len(args)-1], " "), err)
+		}
+	}
+	if _, err := captureOutput(t, func() error { return cfg.cmdManifest([]string{"tree", "generate", root}) }); err == nil || !strings.Contains(err.Error(), "-strip-signature") {
+		t.Errorf("tree generate over a signed Manifest: %v", err)
+	}
+	if data, _ := os.ReadFile(topManifest); !bytes.HasPrefix(data, []byte("-----BEGIN PGP SIGNED MESSAGE-----")) {
+		t.Errorf("refused tree generate rewrote the Manifest:\n%s", data)
+	}
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdManifest([]string{"tree", "generate", "-strip-signature", root})
+	}); err != nil {
+		t.Fatalf("tree generate -strip-signature: %v", err)
+	}
+	if data, _ := os.ReadFile(topManifest); bytes.Contains(data, []byte("PGP")) {
+		t.Errorf("tree generate -strip-signature left a signature:\n%s", data)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+func (cfg *CmdManifestArgConfig) cmdManifestTree(args []string) error {
+	fs := flag.NewFlagSet("", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage:\n")
+		fmt.Printf("\t%s\n", strings.Join(cfg.Args, " "))
+		fmt.Printf("\t\t %s \t\t %s\n", "generate [-hashes \"H1 H2\"] [-no-compress] <repo>", "Write the GLEP 74 Manifest tree of a repository")
+		fmt.Printf("\t\t %s \t\t %s\n", "verify <repo>", "Verify a repository against its Manifest tree")
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing subcommand")
+	}
+	cmd := fs.Arg(0)
+	cfg.Args = append(cfg.Args, cmd)
+
+	switch cmd {
+	case "generate":
+		return cfg.cmdManifestTreeGenerate(fs.Args()[1:])
+	case "verify":
+		return cfg.cmdManifestTreeVerify(fs.Args()[1:])
+	case "help", "-help", "--help":
+		fs.Usage()
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %s", cmd)
+	}
+}
+
+// repoLayoutConf reads metadata/layout.conf of repo, returning an empty configuration when there is none.
+func repoLayoutConf(repo string) (*g2.LayoutConf, error) {
+	lc, err := g2.ParseLayoutConf(filepath.Join(repo, "metadata", "layout.conf"))
+	if errors.Is(err, os.ErrNotExist) {
+		return &g2.LayoutConf{}, nil
+	}
+	return lc, err
+}
+
+func (cfg *CmdManifestArgConfig) cmdManifestTreeGenerate(args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	hashesFlag := fs.String("hashes", "", "Space separated hashes to record (default: manifest-hashes of layout.conf)")
+	noCompress := fs.Bool("no-compress", false, "Write the sub-Manifests uncompressed")
+	stripSignature := fs.Bool("strip-signature", false, "Allow rewriting a signed top-level Manifest without its signature")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: manifest tree generate [-hashes \"H1 H2\"] [-no-compress] [-strip-signature] <repo>")
+	}
+	repo := fs.Arg(0)
+	if err := checkSignedManifestWrite(filepath.Join(repo, "Manifest"), *stripSignature); err != nil {
+		return err
+	}
+	hashes := strings.Fields(*hashesFlag)
+	if len(hashes) == 0 {
+		lc, err := repoLayoutConf(repo)
+		if err != nil {
+			return fmt.Errorf("reading layout.conf: %w", err)
+		}
+		hashes = lc.ManifestHashes()
+	}
+	written, err := g2.GenerateManifestTree(repo, g2.ManifestTreeOptions{Hashes: hashes, Compress: !*noCompress})
+	if err != nil {
+		return &ExitError{Code: 1, Err: fmt.Errorf("generating Manifest tree: %w", err)}
+	}
+	fmt.Printf("Wrote %d Manifests\n", len(written))
+	return nil
+}
+
+func (cfg *CmdManifestArgConfig) cmdManifestTreeVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: manifest tree verify <repo>")
+	}
+	repo := fs.Arg(0)
+	lc, err := repoLayoutConf(repo)
+	if err != nil {
+		return fmt.Errorf("reading layout.conf: %w", err)
+	}
+	res, err := g2.VerifyManifestTree(os.DirFS(repo), lc.ManifestRequiredHashes())
+	if err != nil {
+		return &ExitError{Code: 1, Err: fmt.Errorf("verifying Manifest tree: %w", err)}
+	}
+	for _, p := range res.Problems {
+		if p.Fatal {
+			fmt.Printf("ERROR %s\n", p)
+		} else {
+			fmt.Printf("WARNING %s\n", p)
+		}
+	}
+	fmt.Printf("%d Manifests, %d files, %d distfiles, timestamp %s\n", len(res.Manifests), res.Files, res.Distfiles, res.Timestamp)
+	if res.Failed() {
+		return &ExitError{Code: 1, Err: fmt.Errorf("manifest tree verification failed")}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifestTree(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":        "example\n",
+		"metadata/layout.conf":      "masters = gentoo\nmanifest-hashes = SHA256 SHA512\nmanifest-required-hashes = SHA512\n",
+		"app-misc/foo/foo-1.ebuild": "EAPI=8\n",
+		"app-misc/foo/Manifest":     "DIST foo-1.tar.gz 10 SHA256 aa SHA512 bb\n",
+	})
+	cfg := &MainArgConfig{}
+
+	stdout, err := captureOutput(t, func() error {
+		return cfg.cmdManifest([]string{"tree", "generate", repo})
+	})
+	if err != nil {
+		t.Fatalf("tree generate: %v\n%s", err, stdout)
+	}
+	data, err := os.ReadFile(filepath.Join(repo, "Manifest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "MANIFEST app-misc/Manifest.gz ") || !strings.Contains(string(data), " SHA256 ") || strings.Contains(string(data), "BLAKE2B") {
+		t.Errorf("unexpected top-level Manifest:\n%s", data)
+	}
+
+	stdout, err = captureOutput(t, func() error {
+		return cfg.cmdManifest([]string{"tree", "verify", repo})
+	})
+	if err != nil {
+		t.Fatalf("tree verify: %v\n%s", err, stdout)
+	}
+	if !strings.Contains(stdout, "5 Manifests, 7 files, 1 distfiles") {
+		t.Errorf("unexpected output:\n%s", stdout)
+	}
+
+	writeTestFiles(t, repo, map[string]string{"app-misc/foo/foo-1.ebuild": "EAPI=7\n"})
+	stdout, err = captureOutput(t, func() error {
+		return cfg.cmdManifest([]string{"tree", "verify", repo})
+	})
+	if err == nil || !strings.Contains(stdout, "ERROR app-misc/foo/foo-1.ebuild: SHA512 mismatch") {
+		t.Errorf("expected a failure, got %v:\n%s", err, stdout)
+	}
+}
@@ -74,6 +74,10 @@ type GenericPageContext struct {
 	VersionData           *g2.VersionData
 	FilteredManifest      []g2.ManifestEntryData
 	Manifest              *g2.ManifestEntryData
+	Changes               []g2.PackageChange
+	EbuildDiff            *g2.EbuildDiff
+	DiffBaseVersion       string // the ebuild page EbuildDiff compares VersionData against
+	KeywordMatrix         *g2.KeywordMatrix
 
 	// Legacy generic interface overrides for TmplPkgs and map
 	Category map[string]interface{}
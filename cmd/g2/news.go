@@ -0,0 +1,380 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+type CmdNewsArgConfig struct {
+	*MainArgConfig
+	StateDir       string
+	VdbRoot        string
+	ConfigRoot     string
+	ReposConf      string
+	MakeConf       string
+	RepoDirs       []string
+	Lang           string
+	AcceptKeywords string
+	Profile        string
+}
+
+// systemNewsItem is a news item together with the repository it came from and its position in listings.
+type systemNewsItem struct {
+	Index int
+	Repo  string
+	Item  g2.NewsItem
+	State *g2.NewsState
+}
+
+func (cfg *MainArgConfig) cmdNews(args []string) error {
+	fs := flag.NewFlagSet("news", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage:\n")
+		fmt.Printf("\t%s [flags] <subcommand>\n", strings.Join(cfg.Args, " "))
+		fmt.Printf("\t\t %s \t\t %s\n", "list", "list relevant news items and whether they have been read")
+		fmt.Printf("\t\t %s \t\t %s\n", "read", "display news items and mark them as read (default: all unread items)")
+		fmt.Printf("\t\t %s \t\t %s\n", "unread", "mark news items as unread")
+		fmt.Printf("\t\t %s \t\t %s\n", "purge", "forget read news items and items removed from repositories")
+		fmt.Printf("\t\t %s \t\t %s\n", "create", "scaffold a new news item in a repository")
+		fmt.Printf("\t\t %s \t\t %s\n", "translate", "create or synchronise a translation of a news item")
+		fmt.Printf("\t\t %s \t\t %s\n", "translations", "report translations that lag behind the English original")
+		fs.PrintDefaults()
+	}
+	stateDir := fs.String("state-dir", g2.DefaultNewsStateDir, "Directory holding the news read state")
+	vdbRoot := fs.String("vdb", g2.DefaultVdbRoot, "Path to the installed package database")
+	configRoot := fs.String("config-root", "/etc/portage", "Path to portage config root")
+	reposConf := fs.String("repos-conf", "", "Path to repos.conf (default: <config-root>/repos.conf)")
+	makeConf := fs.String("make-conf", "", "Path to make.conf (default: <config-root>/make.conf)")
+	var repoDirs StringSliceFlag
+	fs.Var(&repoDirs, "repo", "Path to a repository (repeatable, default: all repositories in repos.conf)")
+	lang := fs.String("lang", "en", "Preferred news item language")
+	acceptKeywords := fs.String("accept-keywords", "", "Override the keywords used for Display-If-Keyword")
+	profile := fs.String("profile", "", "Override the active profile used for Display-If-Profile (default: read <config-root>/make.profile)")
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing subcommand")
+	}
+
+	cmd := fs.Arg(0)
+	cfg.Args = append(cfg.Args, cmd)
+
+	config := &CmdNewsArgConfig{
+		MainArgConfig:  cfg,
+		StateDir:       *stateDir,
+		VdbRoot:        *vdbRoot,
+		ConfigRoot:     *configRoot,
+		ReposConf:      *reposConf,
+		MakeConf:       *makeConf,
+		RepoDirs:       repoDirs,
+		Lang:           *lang,
+		AcceptKeywords: *acceptKeywords,
+		Profile:        *profile,
+	}
+	if config.ReposConf == "" {
+		config.ReposConf = filepath.Join(config.ConfigRoot, "repos.conf")
+	}
+	if config.MakeConf == "" {
+		config.MakeConf = filepath.Join(config.ConfigRoot, "make.conf")
+	}
+
+	switch cmd {
+	case "list":
+		return config.cmdNewsList(fs.Args()[1:])
+	case "read":
+		return config.cmdNewsRead(fs.Args()[1:])
+	case "unread":
+		return config.cmdNewsUnread(fs.Args()[1:])
+	case "purge":
+		return config.cmdNewsPurge(fs.Args()[1:])
+	case "create":
+		return config.cmdNewsCreate(fs.Args()[1:])
+	case "translate":
+		return config.cmdNewsTranslate(fs.Args()[1:])
+	case "translations":
+		return config.cmdNewsTranslations(fs.Args()[1:])
+	case "help", "-help", "--help":
+		fs.Usage()
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command %s", cmd)
+	}
+}
+
+// newsEnvironment gathers the installed packages, keywords and active profile news relevance is checked against.
+// The keywords are ARCH and ACCEPT_KEYWORDS of the make.profile stack and make.conf.
+func (cfg *CmdNewsArgConfig) newsEnvironment(repos []*g2.RepoInfo) (g2.NewsEnvironment, error) {
+	var env g2.NewsEnvironment
+
+	pkgs, err := g2.ListInstalledPackages(cfg.VdbRoot)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return env, err
+	}
+	for _, p := range pkgs {
+		env.Installed = append(env.Installed, p.Candidate())
+	}
+
+	if cfg.AcceptKeywords != "" {
+		env.Keywords = strings.Fields(cfg.AcceptKeywords)
+	} else {
+		conf, err := loadPortageConfig(repos, cfg.ConfigRoot, cfg.MakeConf)
+		if err != nil {
+			return env, err
+		}
+		for _, kw := range append(conf.AcceptKeywords(), strings.Fields(conf.Vars["ARCH"])...) {
+			if !strings.HasPrefix(kw, "-") {
+				env.Keywords = append(env.Keywords, kw)
+			}
+		}
+	}
+
+	env.Profile = cfg.Profile
+	if env.Profile == "" {
+		env.Profile = activeProfile(cfg.ConfigRoot)
+	}
+	return env, nil
+}
+
+// activeProfile returns the profile selected by the make.profile symlink, relative to the profiles directory.
+func activeProfile(configRoot string) string {
+	target, err := os.Readlink(filepath.Join(configRoot, "make.profile"))
+	if err != nil {
+		return ""
+	}
+	target = filepath.ToSlash(filepath.Clean(target))
+	if idx := strings.LastIndex(target, "profiles/"); idx != -1 {
+		return target[idx+len("profiles/"):]
+	}
+	return target
+}
+
+// loadNews reads the news of every repository, records newly relevant items as unread and returns
+// the items that are tracked as read or unread, numbered in listing order.
+func (cfg *CmdNewsArgConfig) loadNews() ([]systemNewsItem, error) {
+	repos, err := resolveRepoStack(cfg.RepoDirs, cfg.ReposConf)
+	if err != nil {
+		return nil, err
+	}
+	env, err := cfg.newsEnvironment(repos)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []systemNewsItem
+	for _, repo := range repos {
+		if repo.Disabled {
+			continue
+		}
+		items, err := g2.ReadRepoNewsFS(os.DirFS(repo.Location), cfg.Lang)
+		if err != nil {
+			log.Printf("Warning: reading news of %s: %v", repo.RepoName, err)
+			continue
+		}
+		state, err := g2.LoadNewsState(cfg.StateDir, repo.RepoName)
+		if err != nil {
+			return nil, err
+		}
+		if added := state.Update(items, env); len(added) > 0 {
+			if err := state.Save(); err != nil {
+				return nil, err
+			}
+		}
+		for _, item := range items {
+			if !state.Read[item.DirName] && !state.Unread[item.DirName] {
+				continue
+			}
+			res = append(res, systemNewsItem{Index: len(res) + 1, Repo: repo.RepoName, Item: item, State: state})
+		}
+	}
+	return res, nil
+}
+
+// selectNews resolves item arguments, given as list numbers or item names, or "all" and "new".
+func selectNews(items []systemNewsItem, args []string) ([]systemNewsItem, error) {
+	var res []systemNewsItem
+	for _, arg := range args {
+		switch arg {
+		case "all":
+			return items, nil
+		case "new":
+			for _, it := range items {
+				if it.State.Unread[it.Item.DirName] {
+					res = append(res, it)
+				}
+			}
+			continue
+		}
+		found := false
+		n, numErr := strconv.Atoi(arg)
+		for _, it := range items {
+			if (numErr == nil && it.Index == n) || it.Item.DirName == arg {
+				res = append(res, it)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no such news item: %s", arg)
+		}
+	}
+	return res, nil
+}
+
+func saveNewsStates(items []systemNewsItem) error {
+	saved := make(map[*g2.NewsState]bool)
+	for _, it := range items {
+		if saved[it.State] {
+			continue
+		}
+		saved[it.State] = true
+		if err := it.State.Save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *CmdNewsArgConfig) cmdNewsList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	unreadOnly := fs.Bool("unread", false, "Only list unread items")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	items, err := cfg.loadNews()
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Println("No news items.")
+		return nil
+	}
+	for _, it := range items {
+		unread := it.State.Unread[it.Item.DirName]
+		if *unreadOnly && !unread {
+			continue
+		}
+		marker := " "
+		if unread {
+			marker = "N"
+		}
+		fmt.Printf("  [%d]  %s  %s  %s  (%s)\n", it.Index, marker, it.Item.Posted.Format("2006-01-02"), it.Item.Title, it.Repo)
+	}
+	return nil
+}
+
+func (cfg *CmdNewsArgConfig) cmdNewsRead(args []string) error {
+	fs := flag.NewFlagSet("read", flag.ExitOnError)
+	quiet := fs.Bool("quiet", false, "Mark items as read without displaying them")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	items, err := cfg.loadNews()
+	if err != nil {
+		return err
+	}
+	selection := fs.Args()
+	if len(selection) == 0 {
+		selection = []string{"new"}
+	}
+	selected, err := selectNews(items, selection)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		fmt.Println("No unread news items.")
+		return nil
+	}
+
+	for i, it := range selected {
+		if !*quiet {
+			if i > 0 {
+				fmt.Println()
+			}
+			printNewsItem(it)
+		}
+		it.State.MarkRead(it.Item.DirName)
+	}
+	return saveNewsStates(selected)
+}
+
+func printNewsItem(it systemNewsItem) {
+	n := it.Item
+	fmt.Printf("%s\n", it.Item.DirName)
+	fmt.Printf("  Title     %s\n", n.Title)
+	fmt.Printf("  Author    %s\n", n.Author)
+	for _, t := range n.Translator {
+		fmt.Printf("  Translator %s\n", t)
+	}
+	fmt.Printf("  Posted    %s\n", n.Posted.Format("2006-01-02"))
+	fmt.Printf("  Revision  %s\n", n.Revision)
+	fmt.Println()
+	fmt.Println(n.ToText(true))
+}
+
+func (cfg *CmdNewsArgConfig) cmdNewsUnread(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: g2 news unread <item>|all ...")
+	}
+	items, err := cfg.loadNews()
+	if err != nil {
+		return err
+	}
+	selected, err := selectNews(items, args)
+	if err != nil {
+		return err
+	}
+	for _, it := range selected {
+		it.State.MarkUnread(it.Item.DirName)
+	}
+	return saveNewsStates(selected)
+}
+
+func (cfg *CmdNewsArgConfig) cmdNewsPurge(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: g2 news purge")
+	}
+	repos, err := resolveRepoStack(cfg.RepoDirs, cfg.ReposConf)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, repo := range repos {
+		if repo.Disabled {
+			continue
+		}
+		items, err := g2.ReadRepoNewsFS(os.DirFS(repo.Location), cfg.Lang)
+		if err != nil {
+			return err
+		}
+		state, err := g2.LoadNewsState(cfg.StateDir, repo.RepoName)
+		if err != nil {
+			return err
+		}
+		removed := state.Purge(items)
+		if removed == 0 {
+			continue
+		}
+		if err := state.Save(); err != nil {
+			return err
+		}
+		total += removed
+	}
+	fmt.Printf("Purged %d news item(s).\n", total)
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/arran4/g2"
+	"github.com/arran4/g2/lints"
+	newslint "github.com/arran4/g2/lints/news"
+)
+
+var newsSlugRegex = regexp.MustCompile(`^[a-z0-9+_-]{1,20}$`)
+
+// newsLangRegex matches the BCP 47 language tags GLEP 42 names translations by, such as de or pt-BR.
+var newsLangRegex = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// newsItemArg resolves a news item given by directory name or path to its directory name.
+func newsItemArg(arg string) string {
+	return filepath.Base(filepath.Clean(arg))
+}
+
+// validateNewsItem runs the news lint over the repository and returns the findings for one item.
+func validateNewsItem(repoDir, dirName string) ([]lints.LintResult, bool) {
+	rule := newslint.NewNewsValidityLintRule(newslint.WithFS(os.DirFS(repoDir)))
+	var res []lints.LintResult
+	failed := false
+	for _, r := range rule.Lint(repoDir, nil) {
+		if r.Package != "metadata/news/"+dirName {
+			continue
+		}
+		res = append(res, r)
+		if r.RuleMetadata.Severity == lints.SeverityError {
+			failed = true
+		}
+	}
+	return res, failed
+}
+
+func reportNewsValidation(repoDir, dirName string) error {
+	results, failed := validateNewsItem(repoDir, dirName)
+	for _, r := range results {
+		if r.Line > 0 {
+			fmt.Printf("%s:%d: %s\n", r.File, r.Line, r.Message)
+		} else {
+			fmt.Printf("%s: %s\n", r.File, r.Message)
+		}
+	}
+	if failed {
+		return &ExitError{Code: 1, Err: fmt.Errorf("news item %s failed validation", dirName)}
+	}
+	return nil
+}
+
+func (cfg *CmdNewsArgConfig) cmdNewsCreate(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	repoDir := fs.String("repo", ".", "Path to the repository")
+	title := fs.String("title", "", "Title of the news item (at most 50 characters)")
+	var authors StringSliceFlag
+	fs.Var(&authors, "author", "Author as 'Name <email>' (repeatable)")
+	posted := fs.String("posted", "", "Posting date as YYYY-MM-DD (default: today)")
+	format := fs.String("format", "2.0", "News-Item-Format")
+	bodyFile := fs.String("body", "", "File containing the body text, or - for stdin")
+	var installed, keywords, profiles StringSliceFlag
+	fs.Var(&installed, "display-if-installed", "Only display to systems with this atom installed (repeatable)")
+	fs.Var(&keywords, "display-if-keyword", "Only display to systems using this keyword (repeatable)")
+	fs.Var(&profiles, "display-if-profile", "Only display to systems using this profile (repeatable)")
+	force := fs.Bool("force", false, "Overwrite an existing news item")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: g2 news create -title <title> -author <author> [flags] <slug>")
+	}
+	slug := fs.Arg(0)
+	if !newsSlugRegex.MatchString(slug) {
+		return fmt.Errorf("invalid slug %q: must be 1-20 characters of a-z, 0-9, +, _ and -", slug)
+	}
+	if *title == "" || len(authors) == 0 {
+		return fmt.Errorf("-title and -author are required")
+	}
+
+	date := *posted
+	if date == "" {
+		date = time.Now().UTC().Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		return fmt.Errorf("invalid -posted date %q: expected YYYY-MM-DD", date)
+	}
+
+	body := "TODO: Write the news item body."
+	if *bodyFile != "" {
+		var data []byte
+		var err error
+		if *bodyFile == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(*bodyFile)
+		}
+		if err != nil {
+			return fmt.Errorf("reading body: %w", err)
+		}
+		body = string(data)
+	}
+
+	headers := []g2.NewsHeader{{Key: "Title", Value: *title}}
+	for _, a := range authors {
+		headers = append(headers, g2.NewsHeader{Key: "Author", Value: a})
+	}
+	if *format == "1.0" {
+		headers = append(headers, g2.NewsHeader{Key: "Content-Type", Value: "text/plain"})
+	}
+	headers = append(headers,
+		g2.NewsHeader{Key: "Posted", Value: date},
+		g2.NewsHeader{Key: "Revision", Value: "1"},
+		g2.NewsHeader{Key: "News-Item-Format", Value: *format},
+	)
+	for _, v := range installed {
+		headers = append(headers, g2.NewsHeader{Key: "Display-If-Installed", Value: v})
+	}
+	for _, v := range keywords {
+		headers = append(headers, g2.NewsHeader{Key: "Display-If-Keyword", Value: v})
+	}
+	for _, v := range profiles {
+		headers = append(headers, g2.NewsHeader{Key: "Display-If-Profile", Value: v})
+	}
+
+	dirName := date + "-" + slug
+	target := filepath.Join(*repoDir, "metadata", "news", dirName, dirName+".en.txt")
+	if _, err := os.Stat(target); err == nil && !*force {
+		return fmt.Errorf("%s already exists; use -force to overwrite", target)
+	}
+	content := g2.FormatNewsFile(headers, g2.WrapNewsBody(strings.TrimSpace(body), g2.NewsBodyWidth))
+	if err := g2.SafeWriteFileAtomic(target, []byte(content), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Created %s\n", target)
+
+	return reportNewsValidation(*repoDir, dirName)
+}
+
+func (cfg *CmdNewsArgConfig) cmdNewsTranslate(args []string) error {
+	fs := flag.NewFlagSet("translate", flag.ExitOnError)
+	repoDir := fs.String("repo", ".", "Path to the repository")
+	var translators StringSliceFlag
+	fs.Var(&translators, "translator", "Translator as 'Name <email>' (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: g2 news translate [-translator <translator>] <item> <lang>")
+	}
+	dirName := newsItemArg(fs.Arg(0))
+	lang := fs.Arg(1)
+	if !newsLangRegex.MatchString(lang) {
+		return fmt.Errorf("invalid language %q: expected a language tag such as de or pt-BR", lang)
+	}
+	if lang == "en" {
+		return fmt.Errorf("the English original cannot be a translation")
+	}
+
+	itemDir := filepath.Join(*repoDir, "metadata", "news", dirName)
+	englishData, err := os.ReadFile(filepath.Join(itemDir, dirName+".en.txt"))
+	if err != nil {
+		return fmt.Errorf("reading English original: %w", err)
+	}
+	english, englishBody := g2.ParseNewsHeaders(string(englishData))
+
+	target := filepath.Join(itemDir, dirName+"."+lang+".txt")
+	var existing []g2.NewsHeader
+	body := englishBody
+	data, err := os.ReadFile(target)
+	switch {
+	case err == nil:
+		existing, body = g2.ParseNewsHeaders(string(data))
+	case os.IsNotExist(err):
+		// A new translation starts from the English revision it is based on.
+	default:
+		return fmt.Errorf("reading %s: %w", target, err)
+	}
+	for _, t := range translators {
+		if !containsNewsHeader(existing, "Translator", t) {
+			existing = append(existing, g2.NewsHeader{Key: "Translator", Value: t})
+		}
+	}
+
+	headers := g2.SyncNewsTranslationHeaders(english, existing)
+	if err := g2.SafeWriteFileAtomic(target, []byte(g2.FormatNewsFile(headers, body)), 0644); err != nil {
+		return err
+	}
+	if data != nil {
+		fmt.Printf("Synchronised headers of %s\n", target)
+	} else {
+		fmt.Printf("Created %s\n", target)
+	}
+	if status := translationStatus(english, headers); status != "" {
+		fmt.Printf("Note: %s\n", status)
+	}
+	return reportNewsValidation(*repoDir, dirName)
+}
+
+func containsNewsHeader(headers []g2.NewsHeader, key, value string) bool {
+	for _, h := range headers {
+		if h.Key == key && h.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// translationStatus describes how far a translation lags behind the English original, or "" if it is current.
+func translationStatus(english, translation []g2.NewsHeader) string {
+	enRev, err := strconv.Atoi(g2.NewsHeaderValue(english, "Revision"))
+	if err != nil {
+		return ""
+	}
+	trRev, err := strconv.Atoi(g2.NewsHeaderValue(translation, "Revision"))
+	if err != nil {
+		return "translation has no valid Revision"
+	}
+	if trRev < enRev {
+		return fmt.Sprintf("translation is at revision %d, English original is at revision %d", trRev, enRev)
+	}
+	return ""
+}
+
+func (cfg *CmdNewsArgConfig) cmdNewsTranslations(args []string) error {
+	fs := flag.NewFlagSet("translations", flag.ExitOnError)
+	repoDir := fs.String("repo", ".", "Path to the repository")
+	outdatedOnly := fs.Bool("outdated", false, "Only report translations that lag behind the English original")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+
+	newsDir := filepath.Join(*repoDir, "metadata", "news")
+	files, err := filepath.Glob(filepath.Join(newsDir, "*", "*.txt"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	outdated := 0
+	for _, file := range files {
+		dirName := filepath.Base(filepath.Dir(file))
+		lang := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), dirName+"."), ".txt")
+		if lang == "en" || lang == filepath.Base(file) {
+			continue
+		}
+		englishData, err := os.ReadFile(filepath.Join(newsDir, dirName, dirName+".en.txt"))
+		if err != nil {
+			fmt.Printf("%s\t%s\tno English original\n", dirName, lang)
+			outdated++
+			continue
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		english, _ := g2.ParseNewsHeaders(string(englishData))
+		translation, _ := g2.ParseNewsHeaders(string(data))
+		status := translationStatus(english, translation)
+		if status != "" {
+			outdated++
+			fmt.Printf("%s\t%s\t%s\n", dirName, lang, status)
+		} else if !*outdatedOnly {
+			fmt.Printf("%s\t%s\tup to date\n", dirName, lang)
+		}
+	}
+	if outdated > 0 {
+		return &ExitError{Code: 1}
+	}
+	return nil
+}
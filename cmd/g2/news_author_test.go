@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdNewsCreateAndTranslate(t *testing.T) {
+	repo := t.TempDir()
+	bodyFile := filepath.Join(repo, "body.txt")
+	writeTestFiles(t, repo, map[string]string{
+		"body.txt": "Users of foo must migrate their configuration before upgrading to version 2, because the old format is no longer read.\n",
+	})
+
+	cfg := &MainArgConfig{}
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		return captureOutput(t, func() error {
+			return cfg.cmdNews(args)
+		})
+	}
+
+	if _, err := run("create", "-repo", repo, "-title", "foo 2 migration", "-author", "Dev <dev@example.com>",
+		"-posted", "2024-05-01", "-body", bodyFile, "-display-if-installed", "app-misc/foo", "foo-2"); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+	original := filepath.Join(repo, "metadata/news/2024-05-01-foo-2/2024-05-01-foo-2.en.txt")
+	data, err := os.ReadFile(original)
+	if err != nil {
+		t.Fatalf("reading created item: %v", err)
+	}
+	want := "Title: foo 2 migration\n" +
+		"Author: Dev <dev@example.com>\n" +
+		"Posted: 2024-05-01\n" +
+		"Revision: 1\n" +
+		"News-Item-Format: 2.0\n" +
+		"Display-If-Installed: app-misc/foo\n" +
+		"\n" +
+		"Users of foo must migrate their configuration before upgrading to\n" +
+		"version 2, because the old format is no longer read.\n"
+	if string(data) != want {
+		t.Errorf("unexpected news item:\n%s\nwant:\n%s", data, want)
+	}
+
+	if _, err := run("create", "-repo", repo, "-title", "x", "-author", "Dev <dev@example.com>", "Bad Slug"); err == nil {
+		t.Errorf("expected invalid slug to be rejected")
+	}
+	if _, err := run("create", "-repo", repo, "-title", "t", "-author", "nobody", "-posted", "2024-05-02", "bad-author"); err == nil {
+		t.Errorf("expected validation failure for malformed author")
+	}
+
+	for _, lang := range []string{"../../x", "de/x", "d"} {
+		if _, err := run("translate", "-repo", repo, "2024-05-01-foo-2", lang); err == nil {
+			t.Errorf("translate accepted the language %q", lang)
+		}
+	}
+	if _, err := run("translate", "-repo", repo, "-translator", "Tr <tr@example.com>", "2024-05-01-foo-2", "de"); err != nil {
+		t.Fatalf("translate failed: %v", err)
+	}
+	if out, err := run("translations", "-repo", repo); err != nil || !strings.Contains(out, "2024-05-01-foo-2\tde\tup to date") {
+		t.Errorf("unexpected translations output: %q, %v", out, err)
+	}
+
+	if err := os.WriteFile(original, []byte(strings.Replace(string(data), "Revision: 1", "Revision: 2", 1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := run("translations", "-repo", repo, "-outdated")
+	if err == nil || !strings.Contains(out, "translation is at revision 1, English original is at revision 2") {
+		t.Errorf("expected outdated translation to be reported, got %q, %v", out, err)
+	}
+}
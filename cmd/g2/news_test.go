@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdNews(t *testing.T) {
+	tmpDir := t.TempDir()
+	repo := filepath.Join(tmpDir, "repo")
+	vdb := filepath.Join(tmpDir, "vdb")
+	configRoot := filepath.Join(tmpDir, "portage")
+	stateDir := filepath.Join(tmpDir, "news")
+
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name": "test\n",
+		"metadata/news/2024-01-01-foo/2024-01-01-foo.en.txt": "Title: Foo changes\nAuthor: Dev <dev@example.com>\nPosted: 2024-01-01\nRevision: 1\nNews-Item-Format: 2.0\nDisplay-If-Installed: app-misc/foo\n\nFoo body.\n",
+		"metadata/news/2024-02-01-bar/2024-02-01-bar.en.txt": "Title: Bar changes\nAuthor: Dev <dev@example.com>\nPosted: 2024-02-01\nRevision: 1\nNews-Item-Format: 2.0\nDisplay-If-Installed: app-misc/bar\n\nBar body.\n",
+		"metadata/news/2024-03-01-all/2024-03-01-all.en.txt": "Title: Everyone\nAuthor: Dev <dev@example.com>\nPosted: 2024-03-01\nRevision: 1\nNews-Item-Format: 2.0\nDisplay-If-Profile: default/linux/amd64/*\n\nEveryone body.\n",
+		"metadata/news/2024-04-01-arm/2024-04-01-arm.en.txt": "Title: Arm changes\nAuthor: Dev <dev@example.com>\nPosted: 2024-04-01\nRevision: 1\nNews-Item-Format: 2.0\nDisplay-If-Keyword: arm64\n\nArm body.\n",
+		"metadata/news/2024-05-01-amd/2024-05-01-amd.en.txt": "Title: Amd changes\nAuthor: Dev <dev@example.com>\nPosted: 2024-05-01\nRevision: 1\nNews-Item-Format: 2.0\nDisplay-If-Keyword: amd64\n\nAmd body.\n",
+		"profiles/arch/amd64/make.defaults":                  "ARCH=\"amd64\"\nACCEPT_KEYWORDS=\"${ARCH}\"\n",
+		"profiles/default/linux/amd64/23.0/parent":           "../../../../arch/amd64\n",
+	})
+	writeTestFiles(t, vdb, map[string]string{
+		"app-misc/foo-1.0/SLOT": "0\n",
+	})
+	if err := os.MkdirAll(configRoot, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(repo, "profiles", "default", "linux", "amd64", "23.0"), filepath.Join(configRoot, "make.profile")); err != nil {
+		t.Fatalf("creating make.profile: %v", err)
+	}
+
+	cfg := &MainArgConfig{}
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdNews(append([]string{"-state-dir", stateDir, "-vdb", vdb, "-config-root", configRoot, "-repo", repo}, args...))
+		})
+		if err != nil {
+			t.Fatalf("news %v failed: %v", args, err)
+		}
+		return out
+	}
+
+	out := run("list")
+	want := "  [1]  N  2024-01-01  Foo changes  (test)\n  [2]  N  2024-03-01  Everyone  (test)\n  [3]  N  2024-05-01  Amd changes  (test)\n"
+	if out != want {
+		t.Fatalf("unexpected list output:\n%s\nwant:\n%s", out, want)
+	}
+
+	out = run("read", "1")
+	if !strings.Contains(out, "Title     Foo changes") || !strings.Contains(out, "Foo body.") {
+		t.Errorf("unexpected read output:\n%s", out)
+	}
+	if data, err := os.ReadFile(filepath.Join(stateDir, "news-test.read")); err != nil || string(data) != "2024-01-01-foo\n" {
+		t.Errorf("unexpected read state: %q, %v", data, err)
+	}
+
+	if out = run("list", "-unread"); out != "  [2]  N  2024-03-01  Everyone  (test)\n  [3]  N  2024-05-01  Amd changes  (test)\n" {
+		t.Errorf("unexpected unread list:\n%s", out)
+	}
+
+	run("unread", "2024-01-01-foo")
+	run("read", "-quiet", "all")
+	if out = run("list", "-unread"); out != "" {
+		t.Errorf("expected no unread items, got:\n%s", out)
+	}
+
+	if out = run("purge"); !strings.Contains(out, "Purged 3") {
+		t.Errorf("unexpected purge output: %q", out)
+	}
+	if out = run("list"); out != "No news items.\n" {
+		t.Errorf("expected purged items to be hidden, got:\n%s", out)
+	}
+}
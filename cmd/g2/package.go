@@ -35,6 +35,7 @@ func (cfg *MainArgConfig) cmdPackage(args []string) error {
 		fmt.Printf("\t\t %s \t\t %s\n", "update", "update the local index from a remote zip file")
 		fmt.Printf("\t\t %s \t\t %s\n", "deprecated", "commands relating to deprecated packages")
 		fmt.Printf("\t\t %s \t\t %s\n", "masked", "commands relating to masked packages")
+		fmt.Printf("\t\t %s \t\t %s\n", "subslot-impact", "find reverse dependencies affected by a SLOT or sub-slot change")
 	}
 
 	if err := fs.Parse(args); err != nil {
@@ -82,6 +83,10 @@ func (cfg *MainArgConfig) cmdPackage(args []string) error {
 		if err := config.cmdMasked(fs.Args()[1:]); err != nil {
 			return err
 		}
+	case "subslot-impact":
+		if err := config.cmdSubslotImpact(fs.Args()[1:]); err != nil {
+			return err
+		}
 	case "help", "-help", "--help":
 		fs.Usage()
 		return nil
@@ -92,9 +97,19 @@ func (cfg *MainArgConfig) cmdPackage(args []string) error {
 	return nil
 }
 
-func (cfg *CmdPackageArgConfig) cmdSearch(args []string) error {
+func (cfg *CmdPackageArgConfig) cmdSearch(args []string, opts ...any) error {
+	var out io.Writer = os.Stdout
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case io.Writer:
+			out = o
+		}
+	}
+
 	fs := flag.NewFlagSet("search", flag.ExitOnError)
 	path := fs.String("path", "", "Path to the search index directory, zip, tar, txtar, or URL")
+	facets := fs.Bool("facets", false, "Print the category, overlay, license, EAPI, arch and mask counts of the results")
+	fuzzy := fs.Bool("fuzzy", false, "When nothing matches, show the results of the first corrected query instead of only suggesting it")
 
 	fs.Usage = func() {
 		fmt.Printf("Usage:\n")
@@ -119,27 +134,63 @@ func (cfg *CmdPackageArgConfig) cmdSearch(args []string) error {
 	}
 
 	engine := NewSearchEngine()
+	defer func() { _ = engine.Close() }()
 
 	if err := LoadSearchEngine(searchPath, engine); err != nil {
 		return err
 	}
 
 	results := engine.Search(query)
+	if len(results) == 0 {
+		suggestions := engine.Suggest(query)
+		if len(suggestions) > 0 {
+			_, _ = fmt.Fprintf(out, "Did you mean: %s?\n", strings.Join(suggestions, ", "))
+		}
+		if len(suggestions) > 0 && *fuzzy {
+			log.Printf("No results for '%s', showing results for '%s'", query, suggestions[0])
+			query = suggestions[0]
+			results = engine.Search(query)
+		}
+	}
 
 	for _, res := range results {
-		fmt.Printf("%s\n", res.FullName)
+		_, _ = fmt.Fprintf(out, "%s\n", res.FullName)
 		if res.Description != "" {
-			fmt.Printf("  %s\n", res.Description)
+			_, _ = fmt.Fprintf(out, "  %s\n", res.Description)
 		}
 		if res.Version != "" {
-			fmt.Printf("  Version: %s\n", res.Version)
+			_, _ = fmt.Fprintf(out, "  Version: %s\n", res.Version)
 		}
 	}
 
+	if *facets {
+		printSearchFacets(out, countSearchFacets(results))
+	}
+
 	log.Printf("Found %d results for '%s'", len(results), query)
 	return nil
 }
 
+// searchFacetLimit is the most values of each facet printed by package search -facets.
+const searchFacetLimit = 10
+
+// printSearchFacets writes the most common values of each facet with their counts.
+func printSearchFacets(out io.Writer, facets []SearchFacet) {
+	for _, f := range facets {
+		if len(f.Values) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "\n%s:\n", f.Name)
+		for i, v := range f.Values {
+			if i == searchFacetLimit {
+				_, _ = fmt.Fprintf(out, "  ... %d more\n", len(f.Values)-i)
+				break
+			}
+			_, _ = fmt.Fprintf(out, "  %s:%s (%d)\n", f.Field, v.Value, v.Count)
+		}
+	}
+}
+
 func LoadSearchEngine(searchPath string, engine *SearchEngine) error {
 	// Handle loading logic based on type
 	if strings.HasPrefix(strings.ToLower(searchPath), "http://") || strings.HasPrefix(strings.ToLower(searchPath), "https://") {
@@ -459,6 +510,14 @@ func LoadSearchEngine(searchPath string, engine *SearchEngine) error {
 			return fmt.Errorf("decoding manifest: %w", err)
 		}
 
+		if manifest.IndexFile != "" {
+			err := engine.LoadIndexFile(filepath.Join(dataDir, manifest.IndexFile))
+			if err == nil {
+				return nil
+			}
+			log.Printf("Warning: %v; loading the search documents instead", err)
+		}
+
 		if len(manifest.DataFiles) > 0 {
 			for _, file := range manifest.DataFiles {
 				dataPath := filepath.Join(dataDir, file)
@@ -533,7 +592,7 @@ func (cfg *CmdPackageArgConfig) cmdIndexOverlay(args []string) error {
 
 	sites := []*g2.SiteData{repo}
 
-	if err := generateSearchData(*outDir, *outZip, sites); err != nil {
+	if err := generateSearchData(*outDir, *outZip, sites, true); err != nil {
 		return fmt.Errorf("generating search data: %w", err)
 	}
 
@@ -611,7 +670,7 @@ func (cfg *CmdPackageArgConfig) cmdIndexRepositories(args []string) error {
 		sites = append(sites, r)
 	}
 
-	if err := generateSearchData(*outDir, *outZip, sites); err != nil {
+	if err := generateSearchData(*outDir, *outZip, sites, true); err != nil {
 		return fmt.Errorf("generating search data: %w", err)
 	}
 
@@ -704,7 +763,7 @@ func (cfg *CmdPackageArgConfig) cmdIndex(args []string) error {
 		sites = append(sites, repo)
 	}
 
-	if err := generateSearchData(*outDir, *outZip, sites); err != nil {
+	if err := generateSearchData(*outDir, *outZip, sites, true); err != nil {
 		return fmt.Errorf("generating search data: %w", err)
 	}
 
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/arran4/g2"
+)
+
+func (cfg *CmdPackageArgConfig) cmdSubslotImpact(args []string) error {
+	fs := flag.NewFlagSet("subslot-impact", flag.ExitOnError)
+	var repoDirs StringSliceFlag
+	fs.Var(&repoDirs, "repo", "Path to a repository to scan (repeatable, default: all repositories in repos.conf)")
+	reposConf := fs.String("repos-conf", "/etc/portage/repos.conf", "Path to repos.conf file or directory")
+	bump := fs.Bool("bump", false, "Create the next revision of every ebuild that needs a manual revision bump")
+
+	fs.Usage = func() {
+		fmt.Printf("Usage:\n")
+		fmt.Printf("\t%s [flags] <atom> <new-slot/subslot>\n", strings.Join(cfg.Args, " "))
+		fs.PrintDefaults()
+	}
+
+	positional, err := parseFlagsAndArgs(fs, args)
+	if err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if len(positional) != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected <atom> and <new-slot/subslot>")
+	}
+
+	target := g2.ParsePackageAtom(positional[0])
+	if target.Category == "" || target.Name == "" || strings.HasPrefix(target.Operator, "!") {
+		return fmt.Errorf("invalid target atom %q", positional[0])
+	}
+	newSlot, newSubSlot, err := g2.SplitSlot(positional[1])
+	if err != nil {
+		return err
+	}
+
+	repos, err := resolveRepoStack(repoDirs, *reposConf)
+	if err != nil {
+		return err
+	}
+
+	var rebuilds, revbumps []g2.SubSlotImpact
+	locations := make(map[string]string)
+	for _, repo := range repos {
+		locations[repo.RepoName] = repo.Location
+		impacts, err := g2.FindSubSlotImpactFS(os.DirFS(repo.Location), repo.RepoName, target, newSlot, newSubSlot)
+		if err != nil {
+			return fmt.Errorf("scanning %s: %w", repo.RepoName, err)
+		}
+		for _, im := range impacts {
+			if im.Action == g2.SubSlotRebuild {
+				rebuilds = append(rebuilds, im)
+			} else {
+				revbumps = append(revbumps, im)
+			}
+		}
+	}
+
+	printImpacts := func(title string, impacts []g2.SubSlotImpact) {
+		consumers := groupSubSlotImpacts(impacts)
+		fmt.Printf("%s (%d):\n", title, len(consumers))
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, c := range consumers {
+			im := c.Impact
+			_, _ = fmt.Fprintf(w, "  %s/%s-%s::%s\t%s\t%s\t%s\n", im.Category, im.Package, im.Version, im.Repo, strings.Join(c.Classes, ", "), strings.Join(c.Dependencies, " "), strings.Join(c.Reasons, "; "))
+		}
+		_ = w.Flush()
+	}
+	printImpacts("Rebuilt automatically", rebuilds)
+	printImpacts("Needs manual revision bump", revbumps)
+
+	if !*bump {
+		return nil
+	}
+
+	for _, c := range groupSubSlotImpacts(revbumps) {
+		im := c.Impact
+		newPath, err := bumpEbuildRevision(filepath.Join(locations[im.Repo], im.EbuildPath), c.Dependencies, newSlot, newSubSlot)
+		if err != nil {
+			return fmt.Errorf("bumping %s: %w", im.EbuildPath, err)
+		}
+		if newPath != "" {
+			log.Printf("Created %s", newPath)
+		}
+	}
+	return nil
+}
+
+// subSlotConsumer is one ebuild affected by a slot change, with the dependency classes, dependencies and
+// reasons of its impacts. A dependency listed in several classes, as with DEPEND="${RDEPEND}", is listed
+// once.
+type subSlotConsumer struct {
+	Impact       g2.SubSlotImpact // the first impact of the ebuild
+	Classes      []string
+	Dependencies []string
+	Reasons      []string
+}
+
+// groupSubSlotImpacts groups impacts by the ebuild they affect, keeping the order of their first impact.
+func groupSubSlotImpacts(impacts []g2.SubSlotImpact) []*subSlotConsumer {
+	var consumers []*subSlotConsumer
+	byEbuild := make(map[string]*subSlotConsumer)
+	for _, im := range impacts {
+		key := im.Repo + "/" + im.EbuildPath
+		c, ok := byEbuild[key]
+		if !ok {
+			c = &subSlotConsumer{Impact: im}
+			byEbuild[key] = c
+			consumers = append(consumers, c)
+		}
+		c.Classes = appendUnique(c.Classes, im.Class)
+		c.Dependencies = appendUnique(c.Dependencies, im.Dependency)
+		c.Reasons = appendUnique(c.Reasons, im.Reason)
+	}
+	return consumers
+}
+
+// appendUnique appends s to list unless list already holds it.
+func appendUnique(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
+
+// bumpEbuildRevision copies ebuildPath to its next revision, as computed by getNextRevision, rewriting the
+// slot specification of the given dependencies. It returns the new path, or "" when that revision already exists.
+func bumpEbuildRevision(ebuildPath string, deps []string, newSlot, newSubSlot string) (string, error) {
+	dir := filepath.Dir(ebuildPath)
+	base := filepath.Base(ebuildPath)
+	vars := g2.ParseEbuildVariables(base)
+	if vars == nil || vars["PN"] == "" {
+		return "", fmt.Errorf("failed to parse PN from ebuild filename %s", base)
+	}
+
+	nextVersion, _, err := getNextRevision(dir, vars["PVR"], "")
+	if err != nil {
+		return "", err
+	}
+	newPath := filepath.Join(dir, fmt.Sprintf("%s-%s.ebuild", vars["PN"], nextVersion))
+	if _, err := os.Stat(newPath); err == nil {
+		log.Printf("Skipping %s: %s already exists", base, filepath.Base(newPath))
+		return "", nil
+	}
+
+	info, err := os.Stat(ebuildPath)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(ebuildPath)
+	if err != nil {
+		return "", err
+	}
+
+	text := string(content)
+	for _, dep := range deps {
+		rewritten := g2.RewriteSlotDependency(dep, newSlot, newSubSlot)
+		if rewritten == dep {
+			continue
+		}
+		re := regexp.MustCompile(`(^|[\s"'(])` + regexp.QuoteMeta(dep) + `($|[\s"')])`)
+		if !re.MatchString(text) {
+			log.Printf("Warning: %s: could not find %s to rewrite, please update it by hand", base, dep)
+			continue
+		}
+		text = re.ReplaceAllString(text, "${1}"+strings.ReplaceAll(rewritten, "$", "$$")+"${2}")
+	}
+
+	if err := g2.SafeWriteFileAtomic(newPath, []byte(text), info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	return newPath, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdSubslotImpact(t *testing.T) {
+	repoDir := t.TempDir()
+	writeFile := func(rel, content string) {
+		path := filepath.Join(repoDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("writing %s: %v", rel, err)
+		}
+	}
+	writeFile("profiles/repo_name", "test-overlay\n")
+	writeFile("dev-libs/foo/foo-2.0.ebuild", "EAPI=8\nSLOT=\"0/2\"\n")
+	writeFile("app-misc/auto/auto-1.0.ebuild", "EAPI=8\nRDEPEND=\"dev-libs/foo:=\"\n")
+	writeFile("app-misc/pinned/pinned-1.0.ebuild", "EAPI=8\nRDEPEND=\"dev-libs/foo:0/1=\"\nDEPEND=\"${RDEPEND}\"\n")
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	cfg := &CmdPackageArgConfig{MainArgConfig: &MainArgConfig{}}
+	out, err := captureOutput(t, func() error {
+		return cfg.cmdSubslotImpact([]string{"-repo", repoDir, "-bump", "dev-libs/foo", "0/2"})
+	})
+	if err != nil {
+		t.Fatalf("cmdSubslotImpact failed: %v", err)
+	}
+	if strings.Contains(logs.String(), "could not find") {
+		t.Errorf("DEPEND=\"${RDEPEND}\" was rewritten twice:\n%s", logs.String())
+	}
+
+	if !strings.Contains(out, "Rebuilt automatically (1)") || !strings.Contains(out, "app-misc/auto-1.0::test-overlay") {
+		t.Errorf("expected auto rebuild in output, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Needs manual revision bump (1)") || !strings.Contains(out, "app-misc/pinned-1.0::test-overlay  DEPEND, RDEPEND  dev-libs/foo:0/1=") {
+		t.Errorf("expected manual revbump in output, got:\n%s", out)
+	}
+
+	bumped, err := os.ReadFile(filepath.Join(repoDir, "app-misc/pinned/pinned-1.0-r1.ebuild"))
+	if err != nil {
+		t.Fatalf("expected bumped ebuild: %v", err)
+	}
+	if !strings.Contains(string(bumped), `RDEPEND="dev-libs/foo:0/2="`) || strings.Count(string(bumped), "dev-libs/foo:") != 1 {
+		t.Errorf("expected pinned sub-slot to be rewritten, got:\n%s", bumped)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "app-misc/auto/auto-1.0-r1.ebuild")); !os.IsNotExist(err) {
+		t.Errorf("did not expect automatic rebuild consumer to be bumped")
+	}
+}
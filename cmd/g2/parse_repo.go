@@ -231,365 +231,407 @@ func isCategoryAllowed(repoCats map[string]bool, hasGentooMaster bool, mainCats
 	return false
 }
 
-// parseRepoCategoriesAndPackages recursively crawls and parses the repo's categories, packages, and their ebuilds.
-func parseRepoCategoriesAndPackages(sysFS fs.FS, repoDir string, repoName string, fastGit bool, remoteURL string, site *g2.SiteData) error {
-	supportedCategories := make(map[string]bool)
-	if categoriesBytes, err := fs.ReadFile(sysFS, filepath.ToSlash(filepath.Join(repoDir, "profiles", "categories"))); err == nil {
+// repoPackageParser holds the repository-wide data that the packages of a repository are parsed against.
+type repoPackageParser struct {
+	sysFS     fs.FS
+	repoDir   string
+	repoName  string
+	fastGit   bool
+	remoteURL string
+	site      *g2.SiteData
+
+	supportedCategories map[string]bool
+	deprecatedMap       map[string]*g2.PackageDeprecated
+	maskedMap           map[string]*g2.PackageMasked
+	slotMovesMap        map[string][]g2.PackageSlotMove
+	infoPkgsMap         map[string]bool
+	hasGentooMaster     bool
+	mainCats            map[string]bool
+}
+
+func newRepoPackageParser(sysFS fs.FS, repoDir string, repoName string, fastGit bool, remoteURL string, site *g2.SiteData) *repoPackageParser {
+	rp := &repoPackageParser{
+		sysFS:     sysFS,
+		repoDir:   repoDir,
+		repoName:  repoName,
+		fastGit:   fastGit,
+		remoteURL: remoteURL,
+		site:      site,
+	}
+	rp.supportedCategories = make(map[string]bool)
+	if categoriesBytes, err := fs.ReadFile(rp.sysFS, filepath.ToSlash(filepath.Join(rp.repoDir, "profiles", "categories"))); err == nil {
 		for _, line := range strings.Split(string(categoriesBytes), "\n") {
 			cat := strings.TrimSpace(line)
 			if cat != "" && !strings.HasPrefix(cat, "#") {
-				supportedCategories[cat] = true
+				rp.supportedCategories[cat] = true
 			}
 		}
 	}
 
-	deprecatedMap := make(map[string]*g2.PackageDeprecated)
-	for i := range site.Deprecated {
-		for _, entry := range site.Deprecated[i].Entries {
+	rp.deprecatedMap = make(map[string]*g2.PackageDeprecated)
+	for i := range rp.site.Deprecated {
+		for _, entry := range rp.site.Deprecated[i].Entries {
 			pkgName := g2.ExtractPackageNameFromDep(entry.Package)
 			if pkgName != "" {
-				deprecatedMap[pkgName] = &site.Deprecated[i]
+				rp.deprecatedMap[pkgName] = &rp.site.Deprecated[i]
 			}
 		}
 	}
 
-	maskedMap := make(map[string]*g2.PackageMasked)
-	for i := range site.Masked {
-		for _, entry := range site.Masked[i].Entries {
+	rp.maskedMap = make(map[string]*g2.PackageMasked)
+	for i := range rp.site.Masked {
+		for _, entry := range rp.site.Masked[i].Entries {
 			pkgName := g2.ExtractPackageNameFromDep(entry.Package)
 			if pkgName != "" {
-				maskedMap[pkgName] = &site.Masked[i]
+				rp.maskedMap[pkgName] = &rp.site.Masked[i]
 			}
 		}
 	}
 
-	slotMovesMap := make(map[string][]g2.PackageSlotMove)
-	if site.SlotMoves != nil {
-		for _, sm := range site.SlotMoves {
-			slotMovesMap[sm.Package] = append(slotMovesMap[sm.Package], sm)
+	rp.slotMovesMap = make(map[string][]g2.PackageSlotMove)
+	if rp.site.SlotMoves != nil {
+		for _, sm := range rp.site.SlotMoves {
+			rp.slotMovesMap[sm.Package] = append(rp.slotMovesMap[sm.Package], sm)
 		}
 	}
 
-	infoPkgsMap := make(map[string]bool)
-	for j := range site.InfoPkgs {
-		atom := site.InfoPkgs[j].PackageAtom
+	rp.infoPkgsMap = make(map[string]bool)
+	for j := range rp.site.InfoPkgs {
+		atom := rp.site.InfoPkgs[j].PackageAtom
 		baseAtom := atom
 		if idx := strings.Index(atom, ":"); idx != -1 {
 			baseAtom = atom[:idx]
 		}
-		infoPkgsMap[baseAtom] = true
+		rp.infoPkgsMap[baseAtom] = true
 	}
 
-	hasGentooMaster := false
-	if site.LayoutConf != nil {
-		for _, master := range site.LayoutConf.Masters() {
+	if rp.site.LayoutConf != nil {
+		for _, master := range rp.site.LayoutConf.Masters() {
 			if master == "gentoo" {
-				hasGentooMaster = true
+				rp.hasGentooMaster = true
 				break
 			}
 		}
 	}
-	mainCats := g2.FetchMainGentooCategories()
+	rp.mainCats = g2.FetchMainGentooCategories()
 
-	entries, err := fs.ReadDir(sysFS, filepath.ToSlash(repoDir))
+	return rp
+}
+
+// categoryAllowed reports whether the top-level directory name of the repository holds packages.
+func (rp *repoPackageParser) categoryAllowed(name string) bool {
+	return !isIgnoredDir(name) && isCategoryAllowed(rp.supportedCategories, rp.hasGentooMaster, rp.mainCats, name)
+}
+
+// parsePackage parses the package directory name/pkgName. It reports false when the directory holds no
+// ebuilds.
+func (rp *repoPackageParser) parsePackage(name, pkgName string) (g2.PackageData, bool) {
+	catPath := filepath.Join(rp.repoDir, name)
+	inMain := len(rp.mainCats) == 0 || rp.mainCats[name]
+
+	pkgPath := filepath.Join(catPath, pkgName)
+	pkgData := g2.PackageData{
+		Name:     pkgName,
+		Category: name,
+	}
+
+	pkgStr := name + "/" + pkgName
+
+	files, err := fs.ReadDir(rp.sysFS, filepath.ToSlash(pkgPath))
 	if err != nil {
-		return fmt.Errorf("reading repo dir: %w", err)
+		log.Printf("Warning: reading package dir %s: %v", pkgPath, err)
+		return g2.PackageData{}, false
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".ebuild") {
 			continue
 		}
-		name := entry.Name()
-		if isIgnoredDir(name) {
+
+		ebuildPath := filepath.Join(pkgPath, file.Name())
+		ebuild, err := g2.ParseEbuild(rp.sysFS, filepath.ToSlash(ebuildPath), g2.ParseFull)
+		if err != nil {
+			log.Printf("Warning: parsing ebuild %s in repo %s: %v", ebuildPath, rp.repoName, err)
 			continue
 		}
 
-		if !isCategoryAllowed(supportedCategories, hasGentooMaster, mainCats, name) {
-			continue
+		for _, w := range ebuild.ParseWarnings {
+			log.Printf("Warning: parsing ebuild %s in repo %s: %v", ebuildPath, rp.repoName, w)
 		}
 
-		catData := g2.CategoryData{Name: name}
-		catPath := filepath.Join(repoDir, name)
+		version := ""
+		if ebuild.Vars != nil {
+			version = ebuild.Vars["PV"]
+		}
+		if version == "" {
+			vars := g2.ParseEbuildVariables(file.Name())
+			if vars != nil {
+				version = vars["PV"]
+			}
+		}
 
-		inMain := len(mainCats) == 0 || mainCats[name]
+		var ebuildRawURL string
+		relPath, _ := filepath.Rel(rp.repoDir, ebuildPath)
+		if rp.remoteURL != "" {
+			if commitHash, _ := getFileCommit(rp.repoDir, relPath); commitHash != "" {
+				ebuildRawURL = generateGitHubRawURL(rp.remoteURL, commitHash, relPath)
+			}
+		}
 
-		pkgEntries, err := fs.ReadDir(sysFS, filepath.ToSlash(catPath))
-		if err != nil {
-			log.Printf("Warning: reading category dir %s: %v", catPath, err)
-			continue
+		modTime := getFileModTime(rp.repoDir, relPath, rp.fastGit)
+		if modTime.After(pkgData.ModTime) {
+			pkgData.ModTime = modTime
 		}
 
-		for _, pkgEntry := range pkgEntries {
-			if !pkgEntry.IsDir() {
-				continue
-			}
-			pkgName := pkgEntry.Name()
-			if strings.HasPrefix(pkgName, ".") {
-				continue
-			}
+		vd := g2.VersionData{
+			Version:      version,
+			Ebuild:       ebuild,
+			EbuildRawURL: ebuildRawURL,
+			ModTime:      modTime,
+		}
 
-			pkgPath := filepath.Join(catPath, pkgName)
-			pkgData := g2.PackageData{
-				Name:     pkgName,
-				Category: name,
+		if slot := ebuild.Vars["SLOT"]; slot != "" {
+			if moves, ok := rp.slotMovesMap[pkgStr]; ok {
+				for _, sm := range moves {
+					if sm.Old == slot {
+						vd.MovedToSlot = sm.New
+						break
+					}
+				}
 			}
+		}
 
-			pkgStr := name + "/" + pkgName
+		pkgData.Versions = append(pkgData.Versions, vd)
+	}
 
-			files, err := fs.ReadDir(sysFS, filepath.ToSlash(pkgPath))
-			if err != nil {
-				log.Printf("Warning: reading package dir %s: %v", pkgPath, err)
-				continue
-			}
+	if len(pkgData.Versions) == 0 {
+		return g2.PackageData{}, false
+	}
 
-			for _, file := range files {
-				if file.IsDir() || !strings.HasSuffix(file.Name(), ".ebuild") {
-					continue
-				}
+	pkgData.HighestStableVersion, pkgData.HighestTestingVersion, pkgData.SnapshotVersion, pkgData.EbuildCount = getHighestVersionsAndCount(pkgData.Versions, rp.site)
 
-				ebuildPath := filepath.Join(pkgPath, file.Name())
-				ebuild, err := g2.ParseEbuild(sysFS, filepath.ToSlash(ebuildPath), g2.ParseFull)
-				if err != nil {
-					log.Printf("Warning: parsing ebuild %s in repo %s: %v", ebuildPath, repoName, err)
-					continue
-				}
+	metaPath := filepath.Join(pkgPath, "metadata.xml")
+	metadata, err := parseMetadataFromFS(rp.sysFS, filepath.ToSlash(metaPath))
+	if err == nil {
+		if pkgMd, ok := metadata.(*g2.PkgMetadata); ok {
+			pkgData.Metadata = pkgMd
+		} else {
+			pkgData.MetadataError = fmt.Errorf("metadata.xml is not a pkgmetadata")
+		}
+	} else {
+		pkgData.MetadataError = err
+	}
 
-				for _, w := range ebuild.ParseWarnings {
-					log.Printf("Warning: parsing ebuild %s in repo %s: %v", ebuildPath, repoName, w)
-				}
+	var highestUnmasked *g2.Ebuild
+	var highestMasked *g2.Ebuild
+	for _, v := range pkgData.Versions {
+		if v.Ebuild == nil || v.Ebuild.Vars == nil {
+			continue
+		}
+		isMasked := true
+		for _, p := range strings.Fields(v.Ebuild.Vars["KEYWORDS"]) {
+			if !strings.HasPrefix(p, "-") && !strings.HasPrefix(p, "~") {
+				isMasked = false
+				break
+			}
+		}
+		if !isMasked {
+			if highestUnmasked == nil || g2.CompareVersions(v.Version, highestUnmasked.Vars["PV"]) > 0 {
+				highestUnmasked = v.Ebuild
+			}
+		} else {
+			if highestMasked == nil || g2.CompareVersions(v.Version, highestMasked.Vars["PV"]) > 0 {
+				highestMasked = v.Ebuild
+			}
+		}
+	}
 
-				version := ""
-				if ebuild.Vars != nil {
-					version = ebuild.Vars["PV"]
-				}
-				if version == "" {
-					vars := g2.ParseEbuildVariables(file.Name())
-					if vars != nil {
-						version = vars["PV"]
-					}
-				}
+	targetEbuild := highestUnmasked
+	if targetEbuild == nil {
+		targetEbuild = highestMasked
+	}
+	if targetEbuild == nil && len(pkgData.Versions) > 0 {
+		for _, v := range pkgData.Versions {
+			if v.Ebuild != nil && v.Ebuild.Vars != nil {
+				targetEbuild = v.Ebuild
+				break
+			}
+		}
+	}
 
-				var ebuildRawURL string
-				relPath, _ := filepath.Rel(repoDir, ebuildPath)
-				if remoteURL != "" {
-					if commitHash, _ := getFileCommit(repoDir, relPath); commitHash != "" {
-						ebuildRawURL = generateGitHubRawURL(remoteURL, commitHash, relPath)
-					}
-				}
+	if pkgData.Metadata != nil && len(pkgData.Metadata.LongDescription) > 0 {
+		pkgData.DominantDescription = pkgData.Metadata.LongDescription[0].Body
+	} else if targetEbuild != nil {
+		pkgData.DominantDescription = targetEbuild.Vars["DESCRIPTION"]
+	}
 
-				modTime := getFileModTime(repoDir, relPath, fastGit)
-				if modTime.After(pkgData.ModTime) {
-					pkgData.ModTime = modTime
-				}
+	if targetEbuild != nil {
+		pkgData.DominantHomepage = targetEbuild.Vars["HOMEPAGE"]
+		pkgData.DominantLicense = targetEbuild.Vars["LICENSE"]
+	}
 
-				vd := g2.VersionData{
-					Version:      version,
-					Ebuild:       ebuild,
-					EbuildRawURL: ebuildRawURL,
-					ModTime:      modTime,
-				}
+	sort.Slice(pkgData.Versions, func(i, j int) bool {
+		return pkgData.Versions[i].Version > pkgData.Versions[j].Version
+	})
+
+	if rp.remoteURL != "" {
+		relPath, _ := filepath.Rel(rp.repoDir, metaPath)
+		if commitHash, _ := getFileCommit(rp.repoDir, relPath); commitHash != "" {
+			pkgData.MetadataRawURL = generateGitHubRawURL(rp.remoteURL, commitHash, relPath)
+		}
+	}
 
-				if slot := ebuild.Vars["SLOT"]; slot != "" {
-					if moves, ok := slotMovesMap[pkgStr]; ok {
-						for _, sm := range moves {
-							if sm.Old == slot {
-								vd.MovedToSlot = sm.New
-								break
-							}
+	for i, v := range pkgData.Versions {
+		if v.Ebuild != nil {
+			applicableMirrors := make(map[string][]string)
+			for _, uri := range v.Ebuild.SrcUri {
+				if strings.HasPrefix(uri.URL, "mirror://") {
+					parts := strings.SplitN(uri.URL[len("mirror://"):], "/", 2)
+					if len(parts) > 0 {
+						mirrorName := parts[0]
+						if mirrors, ok := rp.site.ThirdPartyMirrors[mirrorName]; ok {
+							applicableMirrors[mirrorName] = mirrors
 						}
 					}
 				}
-
-				pkgData.Versions = append(pkgData.Versions, vd)
 			}
-
-			if len(pkgData.Versions) == 0 {
-				continue
+			if len(applicableMirrors) > 0 {
+				pkgData.Versions[i].ApplicableMirrors = applicableMirrors
 			}
+		}
+	}
 
-			pkgData.HighestStableVersion, pkgData.HighestTestingVersion, pkgData.SnapshotVersion, pkgData.EbuildCount = getHighestVersionsAndCount(pkgData.Versions, site)
-
-			metaPath := filepath.Join(pkgPath, "metadata.xml")
-			metadata, err := parseMetadataFromFS(sysFS, filepath.ToSlash(metaPath))
-			if err == nil {
-				if pkgMd, ok := metadata.(*g2.PkgMetadata); ok {
-					pkgData.Metadata = pkgMd
-				} else {
-					pkgData.MetadataError = fmt.Errorf("metadata.xml is not a pkgmetadata")
-				}
-			} else {
-				pkgData.MetadataError = err
-			}
+	manifestPath := filepath.Join(pkgPath, "Manifest")
+	manifest, err := parseManifestFromFS(rp.sysFS, filepath.ToSlash(manifestPath))
+	if err == nil {
+		pkgData.Manifest = manifest
+		pkgData.ManifestData = buildManifestData(manifest, pkgData.Versions, rp.site.ThirdPartyMirrors)
+	}
 
-			var highestUnmasked *g2.Ebuild
-			var highestMasked *g2.Ebuild
-			for _, v := range pkgData.Versions {
-				if v.Ebuild == nil || v.Ebuild.Vars == nil {
-					continue
-				}
-				isMasked := true
-				for _, p := range strings.Fields(v.Ebuild.Vars["KEYWORDS"]) {
-					if !strings.HasPrefix(p, "-") && !strings.HasPrefix(p, "~") {
-						isMasked = false
-						break
-					}
-				}
-				if !isMasked {
-					if highestUnmasked == nil || g2.CompareVersions(v.Version, highestUnmasked.Vars["PV"]) > 0 {
-						highestUnmasked = v.Ebuild
+	filesDirPath := filepath.Join(pkgPath, "files")
+	if info, err := fs.Stat(rp.sysFS, filepath.ToSlash(filesDirPath)); err == nil && info.IsDir() {
+		fileEntries, err := fs.ReadDir(rp.sysFS, filepath.ToSlash(filesDirPath))
+		if err == nil {
+			for _, fe := range fileEntries {
+				if !fe.IsDir() {
+					fd := g2.FileData{
+						Name: fe.Name(),
+						Path: filepath.Join(filesDirPath, fe.Name()),
 					}
-				} else {
-					if highestMasked == nil || g2.CompareVersions(v.Version, highestMasked.Vars["PV"]) > 0 {
-						highestMasked = v.Ebuild
+					if rp.remoteURL != "" {
+						relPath, _ := filepath.Rel(rp.repoDir, fd.Path)
+						if commitHash, _ := getFileCommit(rp.repoDir, relPath); commitHash != "" {
+							fd.RawURL = generateGitHubRawURL(rp.remoteURL, commitHash, relPath)
+						}
 					}
+					pkgData.Files = append(pkgData.Files, fd)
 				}
 			}
+		}
+	}
 
-			targetEbuild := highestUnmasked
-			if targetEbuild == nil {
-				targetEbuild = highestMasked
-			}
-			if targetEbuild == nil && len(pkgData.Versions) > 0 {
-				for _, v := range pkgData.Versions {
-					if v.Ebuild != nil && v.Ebuild.Vars != nil {
-						targetEbuild = v.Ebuild
-						break
-					}
-				}
-			}
+	g2PkgData := g2.PackageData{
+		Name:          pkgData.Name,
+		Category:      pkgData.Category,
+		Metadata:      pkgData.Metadata,
+		MetadataError: pkgData.MetadataError,
+		Manifest:      pkgData.Manifest,
+	}
 
-			if pkgData.Metadata != nil && len(pkgData.Metadata.LongDescription) > 0 {
-				pkgData.DominantDescription = pkgData.Metadata.LongDescription[0].Body
-			} else if targetEbuild != nil {
-				pkgData.DominantDescription = targetEbuild.Vars["DESCRIPTION"]
-			}
+	if dep, ok := rp.deprecatedMap[pkgStr]; ok {
+		pkgData.Deprecated = dep
+	}
 
-			if targetEbuild != nil {
-				pkgData.DominantHomepage = targetEbuild.Vars["HOMEPAGE"]
-				pkgData.DominantLicense = targetEbuild.Vars["LICENSE"]
-			}
+	if mask, ok := rp.maskedMap[pkgStr]; ok {
+		pkgData.Masked = mask
+	}
 
-			sort.Slice(pkgData.Versions, func(i, j int) bool {
-				return pkgData.Versions[i].Version > pkgData.Versions[j].Version
-			})
+	for i, v := range pkgData.Versions {
+		pkgData.Versions[i].Deprecated = pkgData.Deprecated
+		pkgData.Versions[i].Masked = pkgData.Masked
 
-			if remoteURL != "" {
-				relPath, _ := filepath.Rel(repoDir, metaPath)
-				if commitHash, _ := getFileCommit(repoDir, relPath); commitHash != "" {
-					pkgData.MetadataRawURL = generateGitHubRawURL(remoteURL, commitHash, relPath)
-				}
-			}
+		g2PkgData.Versions = append(g2PkgData.Versions, g2.VersionData{
+			Version:      v.Version,
+			Ebuild:       v.Ebuild,
+			EbuildRawURL: v.EbuildRawURL,
+			Deprecated:   pkgData.Versions[i].Deprecated,
+			Masked:       pkgData.Versions[i].Masked,
+		})
+	}
 
-			for i, v := range pkgData.Versions {
-				if v.Ebuild != nil {
-					applicableMirrors := make(map[string][]string)
-					for _, uri := range v.Ebuild.SrcUri {
-						if strings.HasPrefix(uri.URL, "mirror://") {
-							parts := strings.SplitN(uri.URL[len("mirror://"):], "/", 2)
-							if len(parts) > 0 {
-								mirrorName := parts[0]
-								if mirrors, ok := site.ThirdPartyMirrors[mirrorName]; ok {
-									applicableMirrors[mirrorName] = mirrors
-								}
-							}
-						}
-					}
-					if len(applicableMirrors) > 0 {
-						pkgData.Versions[i].ApplicableMirrors = applicableMirrors
-					}
-				}
-			}
+	if rp.infoPkgsMap[pkgStr] {
+		pkgData.IsInfoPkg = true
+	}
 
-			manifestPath := filepath.Join(pkgPath, "Manifest")
-			manifest, err := parseManifestFromFS(sysFS, filepath.ToSlash(manifestPath))
-			if err == nil {
-				pkgData.Manifest = manifest
-				pkgData.ManifestData = buildManifestData(manifest, pkgData.Versions, site.ThirdPartyMirrors)
-			}
+	pkgData.LintWarnings = lints.PerformLinting(rp.repoDir, &g2PkgData)
 
-			filesDirPath := filepath.Join(pkgPath, "files")
-			if info, err := fs.Stat(sysFS, filepath.ToSlash(filesDirPath)); err == nil && info.IsDir() {
-				fileEntries, err := fs.ReadDir(sysFS, filepath.ToSlash(filesDirPath))
-				if err == nil {
-					for _, fe := range fileEntries {
-						if !fe.IsDir() {
-							fd := g2.FileData{
-								Name: fe.Name(),
-								Path: filepath.Join(filesDirPath, fe.Name()),
-							}
-							if remoteURL != "" {
-								relPath, _ := filepath.Rel(repoDir, fd.Path)
-								if commitHash, _ := getFileCommit(repoDir, relPath); commitHash != "" {
-									fd.RawURL = generateGitHubRawURL(remoteURL, commitHash, relPath)
-								}
-							}
-							pkgData.Files = append(pkgData.Files, fd)
-						}
-					}
-				}
-			}
+	if len(rp.supportedCategories) > 0 && !rp.supportedCategories[name] {
+		if rp.hasGentooMaster && rp.mainCats[name] {
+			// No warning, perfectly valid inherited category
+		} else if inMain {
+			pkgData.LintWarnings = append(pkgData.LintWarnings, fmt.Sprintf("Warning: category '%s' is not listed in repo's profiles/categories", name))
+		} else {
+			pkgData.LintWarnings = append(pkgData.LintWarnings, fmt.Sprintf("Error: category '%s' is not listed in repo's profiles/categories or the main gentoo categories list", name))
+		}
+	} else if len(rp.mainCats) > 0 && !inMain {
+		pkgData.LintWarnings = append(pkgData.LintWarnings, fmt.Sprintf("Note: category '%s' is not in the main gentoo categories list", name))
+	}
 
-			g2PkgData := g2.PackageData{
-				Name:          pkgData.Name,
-				Category:      pkgData.Category,
-				Metadata:      pkgData.Metadata,
-				MetadataError: pkgData.MetadataError,
-				Manifest:      pkgData.Manifest,
-			}
+	return pkgData, true
+}
 
-			if dep, ok := deprecatedMap[pkgStr]; ok {
-				pkgData.Deprecated = dep
-			}
+// parseRepoCategoriesAndPackages recursively crawls and parses the repo's categories, packages, and their ebuilds.
+func parseRepoCategoriesAndPackages(sysFS fs.FS, repoDir string, repoName string, fastGit bool, remoteURL string, site *g2.SiteData) error {
+	rp := newRepoPackageParser(sysFS, repoDir, repoName, fastGit, remoteURL, site)
 
-			if mask, ok := maskedMap[pkgStr]; ok {
-				pkgData.Masked = mask
-			}
+	entries, err := fs.ReadDir(sysFS, filepath.ToSlash(repoDir))
+	if err != nil {
+		return fmt.Errorf("reading repo dir: %w", err)
+	}
 
-			for i, v := range pkgData.Versions {
-				pkgData.Versions[i].Deprecated = pkgData.Deprecated
-				pkgData.Versions[i].Masked = pkgData.Masked
-
-				g2PkgData.Versions = append(g2PkgData.Versions, g2.VersionData{
-					Version:      v.Version,
-					Ebuild:       v.Ebuild,
-					EbuildRawURL: v.EbuildRawURL,
-					Deprecated:   pkgData.Versions[i].Deprecated,
-					Masked:       pkgData.Versions[i].Masked,
-				})
-			}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if !rp.categoryAllowed(name) {
+			continue
+		}
 
-			if infoPkgsMap[pkgStr] {
-				pkgData.IsInfoPkg = true
-			}
+		catData := g2.CategoryData{Name: name}
+		catPath := filepath.Join(repoDir, name)
 
-			pkgData.LintWarnings = lints.PerformLinting(repoDir, &g2PkgData)
+		inMain := len(rp.mainCats) == 0 || rp.mainCats[name]
 
-			if len(supportedCategories) > 0 && !supportedCategories[name] {
-				if hasGentooMaster && mainCats[name] {
-					// No warning, perfectly valid inherited category
-				} else if inMain {
-					pkgData.LintWarnings = append(pkgData.LintWarnings, fmt.Sprintf("Warning: category '%s' is not listed in repo's profiles/categories", name))
-				} else {
-					pkgData.LintWarnings = append(pkgData.LintWarnings, fmt.Sprintf("Error: category '%s' is not listed in repo's profiles/categories or the main gentoo categories list", name))
-				}
-			} else if len(mainCats) > 0 && !inMain {
-				pkgData.LintWarnings = append(pkgData.LintWarnings, fmt.Sprintf("Note: category '%s' is not in the main gentoo categories list", name))
-			}
+		pkgEntries, err := fs.ReadDir(sysFS, filepath.ToSlash(catPath))
+		if err != nil {
+			log.Printf("Warning: reading category dir %s: %v", catPath, err)
+			continue
+		}
 
-			catData.Packages = append(catData.Packages, pkgData)
+		for _, pkgEntry := range pkgEntries {
+			if !pkgEntry.IsDir() {
+				continue
+			}
+			pkgName := pkgEntry.Name()
+			if strings.HasPrefix(pkgName, ".") {
+				continue
+			}
+			if pkgData, ok := rp.parsePackage(name, pkgName); ok {
+				catData.Packages = append(catData.Packages, pkgData)
+			}
 		}
 
 		if len(catData.Packages) > 0 {
-			if len(supportedCategories) > 0 && !supportedCategories[name] {
-				if hasGentooMaster && mainCats[name] {
+			if len(rp.supportedCategories) > 0 && !rp.supportedCategories[name] {
+				if rp.hasGentooMaster && rp.mainCats[name] {
 					// No warning, perfectly valid inherited category
 				} else if inMain {
 					log.Printf("Warning: category '%s' is not listed in repo's profiles/categories", name)
 				} else {
 					log.Printf("Error: category '%s' is not listed in repo's profiles/categories or the main gentoo categories list", name)
 				}
-			} else if len(mainCats) > 0 && !inMain {
+			} else if len(rp.mainCats) > 0 && !inMain {
 				log.Printf("Note: category '%s' is not in the main gentoo categories list", name)
 			}
 
@@ -604,37 +646,47 @@ func parseRepoCategoriesAndPackages(sysFS fs.FS, repoDir string, repoName string
 		return site.Categories[i].Name < site.Categories[j].Name
 	})
 
-	pkgMap := make(map[string]bool)
+	pkgMap := repoPackageSet(site)
 	for i := range site.Categories {
 		for j := range site.Categories[i].Packages {
-			pkgMap[site.Categories[i].Packages[j].Category+"/"+site.Categories[i].Packages[j].Name] = true
+			resolvePackageDeps(&site.Categories[i].Packages[j], pkgMap)
 		}
 	}
 
+	return nil
+}
+
+// repoPackageSet returns the category/name of every package of site.
+func repoPackageSet(site *g2.SiteData) map[string]bool {
+	pkgMap := make(map[string]bool)
 	for i := range site.Categories {
 		for j := range site.Categories[i].Packages {
-			for k := range site.Categories[i].Packages[j].Versions {
-				ver := &site.Categories[i].Packages[j].Versions[k]
-				if ver.Ebuild != nil && ver.Ebuild.Vars != nil {
-					depsMap := map[string][]ResolvedDepNode{}
-					for _, depType := range []string{"DEPEND", "RDEPEND", "BDEPEND", "PDEPEND", "REQUIRED_USE", "LICENSE"} {
-						if depStr := ver.Ebuild.Vars[depType]; depStr != "" {
-							tree := g2.ParseDepTree(depStr)
-							var nodes []ResolvedDepNode
-							for _, n := range tree.Nodes {
-								nodes = append(nodes, resolveDependencies(n, pkgMap))
-							}
-							depsMap[depType] = nodes
-						}
+			pkgMap[site.Categories[i].Packages[j].Category+"/"+site.Categories[i].Packages[j].Name] = true
+		}
+	}
+	return pkgMap
+}
+
+// resolvePackageDeps resolves the dependencies of the versions of pkg against the packages of pkgMap.
+func resolvePackageDeps(pkg *g2.PackageData, pkgMap map[string]bool) {
+	for k := range pkg.Versions {
+		ver := &pkg.Versions[k]
+		if ver.Ebuild != nil && ver.Ebuild.Vars != nil {
+			depsMap := map[string][]ResolvedDepNode{}
+			for _, depType := range []string{"DEPEND", "RDEPEND", "BDEPEND", "PDEPEND", "REQUIRED_USE", "LICENSE"} {
+				if depStr := ver.Ebuild.Vars[depType]; depStr != "" {
+					tree := g2.ParseDepTree(depStr)
+					var nodes []ResolvedDepNode
+					for _, n := range tree.Nodes {
+						nodes = append(nodes, resolveDependencies(n, pkgMap))
 					}
-					jsonData, _ := json.Marshal(depsMap)
-					ver.ResolvedDepsJSON = string(jsonData)
+					depsMap[depType] = nodes
 				}
 			}
+			jsonData, _ := json.Marshal(depsMap)
+			ver.ResolvedDepsJSON = string(jsonData)
 		}
 	}
-
-	return nil
 }
 
 // parseRepoEclasses parses .eclass files in the eclass directory.
@@ -662,3 +714,59 @@ func parseRepoEclasses(sysFS fs.FS, repoDir string, site *g2.SiteData) {
 		return site.Eclasses[i].Vars["PN"] < site.Eclasses[j].Vars["PN"]
 	})
 }
+
+// reparseRepoPackage parses the package category/name of site again, adding, replacing or removing it,
+// and refreshes what is derived from the package set of the repository.
+func reparseRepoPackage(sysFS fs.FS, repoDir string, site *g2.SiteData, category, name string) {
+	rp := newRepoPackageParser(sysFS, repoDir, site.RepoName, false, site.RemoteURL, site)
+	var pkgData g2.PackageData
+	found := false
+	if rp.categoryAllowed(category) {
+		pkgData, found = rp.parsePackage(category, name)
+	}
+
+	ci := sort.Search(len(site.Categories), func(i int) bool { return site.Categories[i].Name >= category })
+	hasCat := ci < len(site.Categories) && site.Categories[ci].Name == category
+	if !found && !hasCat {
+		return
+	}
+	if !hasCat {
+		site.Categories = append(site.Categories, g2.CategoryData{})
+		copy(site.Categories[ci+1:], site.Categories[ci:])
+		site.Categories[ci] = g2.CategoryData{Name: category}
+	}
+	cat := &site.Categories[ci]
+	pi := sort.Search(len(cat.Packages), func(i int) bool { return cat.Packages[i].Name >= name })
+	hasPkg := pi < len(cat.Packages) && cat.Packages[pi].Name == name
+	switch {
+	case found && hasPkg:
+		cat.Packages[pi] = pkgData
+	case found:
+		cat.Packages = append(cat.Packages, g2.PackageData{})
+		copy(cat.Packages[pi+1:], cat.Packages[pi:])
+		cat.Packages[pi] = pkgData
+	case hasPkg:
+		cat.Packages = append(cat.Packages[:pi], cat.Packages[pi+1:]...)
+		if len(cat.Packages) == 0 {
+			site.Categories = append(site.Categories[:ci], site.Categories[ci+1:]...)
+		}
+	}
+
+	count := 0
+	for _, cat := range site.Categories {
+		count += len(cat.Packages)
+	}
+	pkgMap := repoPackageSet(site)
+	for i := range site.Categories {
+		for j := range site.Categories[i].Packages {
+			pkg := &site.Categories[i].Packages[j]
+			// Only a package added or removed changes how the dependencies of the others resolve.
+			if count != site.PackageCount || (pkg.Category == category && pkg.Name == name) {
+				resolvePackageDeps(pkg, pkgMap)
+			}
+			pkg.VirtualDeps, pkg.ReverseVirtuals, pkg.Equivalents = nil, nil, nil
+		}
+	}
+	site.PackageCount = count
+	extractVirtualDeps(site)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+// portageConfig holds the variables Portage reads from the make.defaults files of the active profile
+// stack and from make.conf.
+type portageConfig struct {
+	// Profile is the profile stack selected by make.profile, parents first; empty without a profile.
+	Profile []string
+	// Vars maps each variable to its value. Later files replace the values of earlier ones, except for
+	// incremental variables such as ACCEPT_KEYWORDS, whose values are joined in order.
+	Vars map[string]string
+}
+
+// loadPortageConfig reads the profile stack of the make.profile link under configRoot and make.conf at
+// makeConfPath, which may be a directory of files. A missing make.profile or make.conf is not an error.
+func loadPortageConfig(repos []*g2.RepoInfo, configRoot, makeConfPath string) (*portageConfig, error) {
+	c := &portageConfig{Vars: map[string]string{}}
+	if configRoot != "" {
+		dir, err := filepath.EvalSymlinks(filepath.Join(configRoot, "make.profile"))
+		switch {
+		case err == nil:
+			locations := make(map[string]string)
+			for _, repo := range repos {
+				locations[repo.RepoName] = repo.Location
+			}
+			if c.Profile, err = g2.ProfileStack(dir, locations); err != nil {
+				return nil, err
+			}
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("resolving make.profile: %w", err)
+		}
+	}
+	for _, dir := range c.Profile {
+		if err := c.merge(filepath.Join(dir, "make.defaults")); err != nil {
+			return nil, err
+		}
+	}
+	if makeConfPath == "" {
+		return c, nil
+	}
+	info, err := os.Stat(makeConfPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+	if !info.IsDir() {
+		return c, c.merge(makeConfPath)
+	}
+	entries, err := os.ReadDir(makeConfPath)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".bak") {
+			continue
+		}
+		if err := c.merge(filepath.Join(makeConfPath, name)); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// merge adds the variables of the make.conf style file at path, which may be missing.
+func (c *portageConfig) merge(path string) error {
+	vars, err := g2.ParseMakeConf(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for k, v := range vars {
+		if prev := c.Vars[k]; prev != "" && g2.IsIncrementalMakeConfVar(k) {
+			v = prev + " " + v
+		}
+		c.Vars[k] = v
+	}
+	return nil
+}
+
+// AcceptKeywords returns the ACCEPT_KEYWORDS tokens in order, or ARCH when ACCEPT_KEYWORDS is not set.
+// Tokens such as -* and -amd64 are kept for g2.KeywordsAccepted to apply.
+func (c *portageConfig) AcceptKeywords() []string {
+	if accept := strings.Fields(c.Vars["ACCEPT_KEYWORDS"]); len(accept) > 0 {
+		return accept
+	}
+	if arch := c.Vars["ARCH"]; arch != "" {
+		log.Printf("Warning: ACCEPT_KEYWORDS is not set; accepting ARCH %s", arch)
+		return []string{arch}
+	}
+	return nil
+}
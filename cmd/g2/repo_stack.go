@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+// readRepoName determines the repository identity for a checkout directory, falling back to its base name.
+func readRepoName(repoDir string) string {
+	if data, err := os.ReadFile(filepath.Join(repoDir, "profiles", "repo_name")); err == nil {
+		if name := strings.TrimSpace(string(data)); name != "" {
+			return name
+		}
+	}
+	if lc, err := g2.ParseLayoutConf(filepath.Join(repoDir, "metadata", "layout.conf")); err == nil {
+		if name := lc.RepoName(); name != "" {
+			return name
+		}
+	}
+	return filepath.Base(filepath.Clean(repoDir))
+}
+
+// resolveRepoStack returns the repositories to operate on. Explicit repository directories take precedence;
+// otherwise every enabled repository in reposConf is used.
+func resolveRepoStack(repoDirs []string, reposConf string) ([]*g2.RepoInfo, error) {
+	if len(repoDirs) > 0 {
+		var repos []*g2.RepoInfo
+		for _, dir := range repoDirs {
+			info, err := os.Stat(dir)
+			if err != nil {
+				return nil, fmt.Errorf("stat repository %s: %w", dir, err)
+			}
+			if !info.IsDir() {
+				return nil, fmt.Errorf("repository %s is not a directory", dir)
+			}
+			name := readRepoName(dir)
+			repos = append(repos, &g2.RepoInfo{Name: name, RepoName: name, Location: dir})
+		}
+		return repos, nil
+	}
+
+	repos, err := g2.ListConfiguredRepos(reposConf)
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no repositories configured in %s; use -repo to specify one", reposConf)
+	}
+	return repos, nil
+}
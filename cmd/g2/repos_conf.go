@@ -19,6 +19,10 @@ func (cfg *MainArgConfig) cmdReposConf(args []string) error {
 		fmt.Printf("\tdisable <repo>\t\t\tDisable a repository section\n")
 		fmt.Printf("\tenable <repo>\t\t\tEnable a repository section\n")
 		fmt.Printf("\tmove <repo> <file>\t\tMove a repository section to a different file (useful for directory formats)\n")
+		fmt.Printf("\tadd <name>...\t\t\tAdd repositories listed in repositories.xml\n")
+		fmt.Printf("\tadd-custom <name> <uri>\t\tAdd a repository that is not listed in repositories.xml\n")
+		fmt.Printf("\tremove [-purge] <name>...\tRemove repository sections, and with -purge their checkouts\n")
+		fmt.Printf("\tsearch <text>\t\t\tSearch repositories.xml by name, description and owner\n")
 	}
 
 	locationOpt := fs.String("location", "/etc/portage/repos.conf", "Path to repos.conf file or directory")
@@ -46,6 +50,14 @@ func (cfg *MainArgConfig) cmdReposConf(args []string) error {
 		return cfg.cmdReposConfEnable(*locationOpt, fs.Args()[1:])
 	case "move":
 		return cfg.cmdReposConfMove(*locationOpt, fs.Args()[1:])
+	case "add":
+		return cfg.cmdReposConfAdd(*locationOpt, fs.Args()[1:])
+	case "add-custom":
+		return cfg.cmdReposConfAddCustom(*locationOpt, fs.Args()[1:])
+	case "remove":
+		return cfg.cmdReposConfRemove(*locationOpt, fs.Args()[1:])
+	case "search":
+		return cfg.cmdReposConfSearch(fs.Args()[1:])
 	default:
 		fs.Usage()
 		return fmt.Errorf("unknown repos-conf subcommand: %s", subcmd)
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/arran4/g2"
+	"github.com/arran4/g2/cmd/g2/internal/cacheconfig"
+)
+
+// defaultRepositoriesXMLURL is where the official list of repositories is published.
+const defaultRepositoriesXMLURL = "https://api.gentoo.org/overlays/repositories.xml"
+
+// reposConfAddOptions are the flags shared by add and add-custom.
+type reposConfAddOptions struct {
+	File         string
+	LocationBase string
+	Priority     string
+}
+
+func (o *reposConfAddOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.File, "file", "", "File to write the section to (default: <repo>.conf in a repos.conf directory, else repos.conf itself)")
+	fs.StringVar(&o.LocationBase, "location-base", "/var/db/repos", "Directory the repository checkout is placed in")
+	fs.StringVar(&o.Priority, "priority", "", "Repository priority (default: from repositories.xml, if any)")
+}
+
+// loadRepositoriesXML reads repositories.xml from a file or URL. Without a source, a cached copy is used
+// and downloaded first when missing or when refresh is set.
+func loadRepositoriesXML(source string, refresh bool) (*g2.Repositories, error) {
+	if source == "" {
+		source = filepath.Join(cacheconfig.GetCacheDir(), "g2", "repositories.xml")
+		if _, err := os.Stat(source); err != nil || refresh {
+			data, err := fetchURL(defaultRepositoriesXMLURL)
+			if err != nil {
+				return nil, fmt.Errorf("fetching repositories.xml: %w", err)
+			}
+			if err := g2.SafeWriteFileAtomic(source, data, 0644); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = fetchURL(source)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading repositories.xml: %w", err)
+	}
+	repos, err := g2.ParseRepositoriesBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repositories.xml: %w", err)
+	}
+	return repos, nil
+}
+
+func fetchURL(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// findReposConfSection returns the file and section for repo, or nils.
+func findReposConfSection(rc *g2.ReposConf, repo string) (*g2.ReposConfFile, *g2.ReposConfSection) {
+	for _, f := range rc.Files {
+		for _, s := range f.Sections {
+			if s.Name == repo {
+				return f, s
+			}
+		}
+	}
+	return nil, nil
+}
+
+// addReposConfSection writes a new section for repo with the given settings.
+func addReposConfSection(location, repo string, opts reposConfAddOptions, settings [][2]string) (string, error) {
+	if err := g2.ValidateRepoName(repo); err != nil {
+		return "", err
+	}
+	rc, err := g2.ParseReposConf(location)
+	if err != nil {
+		return "", fmt.Errorf("parsing %s: %w", location, err)
+	}
+	if f, _ := findReposConfSection(rc, repo); f != nil {
+		return "", fmt.Errorf("repository %s is already configured in %s", repo, f.Path)
+	}
+
+	targetPath := location
+	if rc.IsDir {
+		targetPath = filepath.Join(location, repo+".conf")
+	}
+	if opts.File != "" {
+		targetPath = opts.File
+		if rc.IsDir && !filepath.IsAbs(targetPath) {
+			targetPath = filepath.Join(location, targetPath)
+		}
+	}
+
+	var target *g2.ReposConfFile
+	for _, f := range rc.Files {
+		if f.Path == targetPath {
+			target = f
+		}
+	}
+	if target == nil {
+		target = &g2.ReposConfFile{Path: targetPath}
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return "", err
+		}
+	}
+	if n := len(target.Sections); n > 0 {
+		last := target.Sections[n-1]
+		if len(last.Lines) == 0 || strings.TrimSpace(last.Lines[len(last.Lines)-1]) != "" {
+			last.Lines = append(last.Lines, "")
+		}
+	}
+
+	section := &g2.ReposConfSection{Name: repo}
+	section.Set("location", filepath.Join(opts.LocationBase, repo))
+	for _, kv := range settings {
+		if kv[1] != "" {
+			section.Set(kv[0], kv[1])
+		}
+	}
+	if opts.Priority != "" {
+		section.Set("priority", opts.Priority)
+	}
+	section.Lines = append(section.Lines, "")
+	target.Sections = append(target.Sections, section)
+
+	if err := target.Write(); err != nil {
+		return "", err
+	}
+	return target.Path, nil
+}
+
+func (cfg *MainArgConfig) cmdReposConfAdd(location string, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	var opts reposConfAddOptions
+	opts.register(fs)
+	reposXML := fs.String("repositories-xml", "", "Path or URL of repositories.xml (default: cached copy of "+defaultRepositoriesXMLURL+")")
+	refresh := fs.Bool("refresh", false, "Download a fresh copy of the cached repositories.xml")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: g2 repos-conf add [flags] <name>...")
+	}
+
+	repos, err := loadRepositoriesXML(*reposXML, *refresh)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range fs.Args() {
+		repo := repos.Find(name)
+		if repo == nil {
+			return fmt.Errorf("repository %s not found in repositories.xml", name)
+		}
+		src, ok := repo.PreferredSource()
+		if !ok {
+			return fmt.Errorf("repository %s has no source of a supported type", name)
+		}
+		repoOpts := opts
+		if repoOpts.Priority == "" {
+			repoOpts.Priority = repo.Priority
+		}
+		file, err := addReposConfSection(location, name, repoOpts, [][2]string{
+			{"sync-type", src.Type},
+			{"sync-uri", src.Text},
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Added %s (%s %s) to %s\n", name, src.Type, src.Text, file)
+	}
+	return nil
+}
+
+// detectSyncType guesses the Portage sync-type for a repository URI.
+func detectSyncType(uri string) string {
+	switch {
+	case strings.HasPrefix(uri, "rsync://"):
+		return "rsync"
+	case strings.HasPrefix(uri, "svn://") || strings.HasPrefix(uri, "svn+"):
+		return "svn"
+	case strings.HasPrefix(uri, "hg+") || strings.HasPrefix(uri, "hg://"):
+		return "mercurial"
+	default:
+		return "git"
+	}
+}
+
+func (cfg *MainArgConfig) cmdReposConfAddCustom(location string, args []string) error {
+	fs := flag.NewFlagSet("add-custom", flag.ExitOnError)
+	var opts reposConfAddOptions
+	opts.register(fs)
+	syncType := fs.String("sync-type", "", "Sync type (default: detected from the URI)")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: g2 repos-conf add-custom [flags] <name> <uri>")
+	}
+	name, uri := fs.Arg(0), fs.Arg(1)
+	if *syncType == "" {
+		*syncType = detectSyncType(uri)
+	}
+	if opts.Priority != "" {
+		if _, err := strconv.Atoi(opts.Priority); err != nil {
+			return fmt.Errorf("invalid priority %q", opts.Priority)
+		}
+	}
+
+	file, err := addReposConfSection(location, name, opts, [][2]string{
+		{"sync-type", *syncType},
+		{"sync-uri", uri},
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Added %s (%s %s) to %s\n", name, *syncType, uri, file)
+	return nil
+}
+
+func (cfg *MainArgConfig) cmdReposConfRemove(location string, args []string) error {
+	fs := flag.NewFlagSet("remove", flag.ExitOnError)
+	purge := fs.Bool("purge", false, "Also delete the repository checkout")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: g2 repos-conf remove [-purge] <name>...")
+	}
+
+	for _, name := range fs.Args() {
+		rc, err := g2.ParseReposConf(location)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", location, err)
+		}
+		f, section := findReposConfSection(rc, name)
+		if f == nil {
+			return fmt.Errorf("repository section '%s' not found", name)
+		}
+		repoLocation := section.Get("location")
+		purgeDir := ""
+		if *purge {
+			if purgeDir, err = purgeableRepoLocation(name, repoLocation); err != nil {
+				return &ExitError{Code: 1, Err: fmt.Errorf("refusing to purge %s: %w", name, err)}
+			}
+		}
+
+		for i, s := range f.Sections {
+			if s == section {
+				f.Sections = append(f.Sections[:i], f.Sections[i+1:]...)
+				break
+			}
+		}
+		if rc.IsDir && len(f.Sections) == 0 && strings.TrimSpace(strings.Join(f.HeaderLines, "")) == "" {
+			if err := os.Remove(f.Path); err != nil {
+				return err
+			}
+		} else if err := f.Write(); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s from %s\n", name, f.Path)
+
+		if purgeDir == "" {
+			continue
+		}
+		if err := os.RemoveAll(purgeDir); err != nil {
+			return fmt.Errorf("purging %s: %w", purgeDir, err)
+		}
+		fmt.Printf("Deleted %s\n", purgeDir)
+	}
+	return nil
+}
+
+// protectedPurgeDirs are system directories never deleted as a repository checkout.
+var protectedPurgeDirs = []string{
+	"/", "/bin", "/boot", "/dev", "/etc", "/home", "/lib", "/lib32", "/lib64", "/media", "/mnt", "/opt",
+	"/proc", "/root", "/run", "/sbin", "/srv", "/sys", "/tmp", "/usr", "/usr/local", "/usr/portage",
+	"/usr/share", "/var", "/var/cache", "/var/db", "/var/db/repos", "/var/lib", "/var/tmp",
+}
+
+// purgeableRepoLocation returns the checkout of repository name at location that remove -purge may
+// delete, or "" when it does not exist. The location must be an absolute path other than a system
+// directory, the home directory or one of its parents, and its profiles/repo_name or the repo-name of
+// its metadata/layout.conf must name the repository.
+func purgeableRepoLocation(name, location string) (string, error) {
+	if location == "" || !filepath.IsAbs(location) {
+		return "", fmt.Errorf("location %q is not an absolute path", location)
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Clean(location))
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Printf("Warning: not purging %s: %s does not exist", name, location)
+			return "", nil
+		}
+		return "", err
+	}
+	protected := protectedPurgeDirs
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		protected = append(protected[:len(protected):len(protected)], filepath.Clean(home))
+	}
+	for _, p := range protected {
+		if rel, err := filepath.Rel(dir, p); err == nil && (rel == "." || !strings.HasPrefix(rel, "..")) {
+			return "", fmt.Errorf("%s is or contains the system directory %s", dir, p)
+		}
+	}
+	// readRepoName falls back to the directory name, so only trust it for a directory that names its repository.
+	if _, err := os.Stat(filepath.Join(dir, "profiles", "repo_name")); err != nil {
+		if _, err := os.Stat(filepath.Join(dir, "metadata", "layout.conf")); err != nil {
+			return "", fmt.Errorf("%s is not a repository checkout: %w", dir, err)
+		}
+	}
+	if got := readRepoName(dir); got != name {
+		return "", fmt.Errorf("%s holds repository %q, not %q", dir, got, name)
+	}
+	return dir, nil
+}
+
+func (cfg *MainArgConfig) cmdReposConfSearch(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	reposXML := fs.String("repositories-xml", "", "Path or URL of repositories.xml (default: cached copy of "+defaultRepositoriesXMLURL+")")
+	refresh := fs.Bool("refresh", false, "Download a fresh copy of the cached repositories.xml")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: g2 repos-conf search <text>")
+	}
+
+	repos, err := loadRepositoriesXML(*reposXML, *refresh)
+	if err != nil {
+		return err
+	}
+	matches := repos.Search(fs.Arg(0))
+	if len(matches) == 0 {
+		return &ExitError{Code: 1, Err: fmt.Errorf("no repositories match %q", fs.Arg(0))}
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range matches {
+		var owners []string
+		for _, o := range r.Owners {
+			owners = append(owners, o.Email)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", r.Name, strings.Join(strings.Fields(r.Description()), " "), strings.Join(owners, ", "))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdReposConfAddRemove(t *testing.T) {
+	dir := t.TempDir()
+	reposXML := filepath.Join("..", "..", "testdata", "repositories", "repositories.xml")
+	writeTestFiles(t, dir, map[string]string{
+		"repos.conf/gentoo.conf":               "[gentoo]\nlocation = /var/db/repos/gentoo\n",
+		"repos/foo-overlay/README":             "checkout\n",
+		"repos/foo-overlay/profiles/repo_name": "foo-overlay\n",
+	})
+	location := filepath.Join(dir, "repos.conf")
+	cfg := &MainArgConfig{}
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		return captureOutput(t, func() error {
+			return cfg.cmdReposConf(append([]string{"-location", location}, args...))
+		})
+	}
+
+	if _, err := run("add", "-repositories-xml", reposXML, "-location-base", filepath.Join(dir, "repos"), "foo-overlay"); err != nil {
+		t.Fatalf("add failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(location, "foo-overlay.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[foo-overlay]\nlocation = " + filepath.Join(dir, "repos", "foo-overlay") +
+		"\nsync-type = git\nsync-uri = https://git.example.com/foo.git\npriority = 50\n"
+	if !strings.HasPrefix(string(data), want) {
+		t.Errorf("unexpected foo-overlay.conf:\n%s\nwant:\n%s", data, want)
+	}
+	if _, err := run("add", "-repositories-xml", reposXML, "foo-overlay"); err == nil {
+		t.Error("expected adding a configured repository to fail")
+	}
+	if _, err := run("add", "-repositories-xml", reposXML, "missing"); err == nil {
+		t.Error("expected adding an unknown repository to fail")
+	}
+
+	if _, err := run("add-custom", "-file", "gentoo.conf", "mine", "rsync://example.com/mine"); err != nil {
+		t.Fatalf("add-custom failed: %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(location, "gentoo.conf"))
+	if !strings.Contains(string(data), "/var/db/repos/gentoo\n\n[mine]\nlocation = /var/db/repos/mine\nsync-type = rsync\nsync-uri = rsync://example.com/mine\n") {
+		t.Errorf("unexpected gentoo.conf:\n%s", data)
+	}
+
+	writeTestFiles(t, dir, map[string]string{"repos/foo-overlay/profiles/repo_name": "other\n"})
+	if _, err := run("remove", "-purge", "foo-overlay"); err == nil {
+		t.Error("expected purging a checkout of another repository to fail")
+	}
+	if _, err := os.Stat(filepath.Join(location, "foo-overlay.conf")); err != nil {
+		t.Errorf("refused purge removed the repository from repos.conf: %v", err)
+	}
+	// The repository may be named by metadata/layout.conf alone.
+	if err := os.Remove(filepath.Join(dir, "repos", "foo-overlay", "profiles", "repo_name")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFiles(t, dir, map[string]string{"repos/foo-overlay/metadata/layout.conf": "repo-name = foo-overlay\nmasters = gentoo\n"})
+	if _, err := run("remove", "-purge", "foo-overlay"); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(location, "foo-overlay.conf")); !os.IsNotExist(err) {
+		t.Errorf("expected foo-overlay.conf to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "repos", "foo-overlay")); !os.IsNotExist(err) {
+		t.Errorf("expected checkout to be purged, got %v", err)
+	}
+
+	out, err := run("search", "-repositories-xml", reposXML, "bar person")
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if !strings.Contains(out, "foo-overlay") || strings.Contains(out, "gentoo") {
+		t.Errorf("unexpected search output: %q", out)
+	}
+}
@@ -0,0 +1,461 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/arran4/g2"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func (cfg *MainArgConfig) cmdRepos(args []string) error {
+	fs := flag.NewFlagSet("repos", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: g2 repos <subcommand>\n")
+		fmt.Printf("\t\t %s \t\t %s\n", "sync [names...]", "clone or fast-forward git repositories configured in repos.conf")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing subcommand for repos")
+	}
+	switch fs.Arg(0) {
+	case "sync":
+		return cfg.cmdReposSync(fs.Args()[1:])
+	case "help", "-help", "--help":
+		fs.Usage()
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown repos subcommand: %s", fs.Arg(0))
+	}
+}
+
+// repoSyncConfig holds the repos.conf settings used to sync a single repository.
+type repoSyncConfig struct {
+	Name          string
+	Location      string
+	SyncType      string
+	SyncURI       string
+	Depth         int
+	Branch        string
+	SingleBranch  bool
+	NoTags        bool
+	Submodules    bool
+	VerifyCommits bool
+	KeyPath       string
+}
+
+// repoSyncResult is the outcome of syncing a repository.
+type repoSyncResult struct {
+	Name           string
+	Action         string
+	From, To       plumbing.Hash
+	CacheGenerated bool
+	Err            error
+}
+
+// readRepoSyncConfigs returns the sync settings of every enabled repository in repos.conf, falling back to the
+// DEFAULT section for unset keys as Portage does.
+func readRepoSyncConfigs(location string) ([]*repoSyncConfig, error) {
+	rc, err := g2.ParseReposConf(location)
+	if err != nil {
+		return nil, fmt.Errorf("parsing repos.conf at %s: %w", location, err)
+	}
+	var defaults *g2.ReposConfSection
+	for _, f := range rc.Files {
+		for _, s := range f.Sections {
+			if strings.EqualFold(s.Name, "DEFAULT") {
+				defaults = s
+			}
+		}
+	}
+
+	var res []*repoSyncConfig
+	for _, f := range rc.Files {
+		for _, s := range f.Sections {
+			if s == defaults || strings.EqualFold(s.Name, "DEFAULT") || s.IsDisabled() {
+				continue
+			}
+			get := func(key string) string {
+				if v := s.Get(key); v != "" {
+					return v
+				}
+				if defaults != nil {
+					return defaults.Get(key)
+				}
+				return ""
+			}
+			c := &repoSyncConfig{
+				Name:          s.Name,
+				Location:      get("location"),
+				SyncType:      get("sync-type"),
+				SyncURI:       get("sync-uri"),
+				Depth:         1,
+				VerifyCommits: isTrueSetting(get("sync-git-verify-commit-signature")),
+				KeyPath:       get("sync-openpgp-key-path"),
+			}
+			depth := get("sync-depth")
+			if depth == "" {
+				depth = get("clone-depth")
+			}
+			if depth != "" {
+				n, err := strconv.Atoi(depth)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid sync-depth %q for repository %s", depth, s.Name)
+				}
+				c.Depth = n
+			}
+			c.applyCloneOpts(strings.Fields(get("sync-git-clone-extra-opts")))
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func isTrueSetting(v string) bool {
+	switch strings.ToLower(v) {
+	case "yes", "true", "1":
+		return true
+	}
+	return false
+}
+
+// applyCloneOpts translates the git clone options that go-git supports; others are reported and ignored.
+func (c *repoSyncConfig) applyCloneOpts(opts []string) {
+	for i := 0; i < len(opts); i++ {
+		opt, value, hasValue := strings.Cut(opts[i], "=")
+		next := func() string {
+			if hasValue {
+				return value
+			}
+			if i+1 < len(opts) {
+				i++
+				return opts[i]
+			}
+			return ""
+		}
+		switch opt {
+		case "--depth":
+			if n, err := strconv.Atoi(next()); err == nil && n >= 0 {
+				c.Depth = n
+			}
+		case "-b", "--branch":
+			c.Branch = next()
+		case "--single-branch":
+			c.SingleBranch = true
+		case "--no-single-branch":
+			c.SingleBranch = false
+		case "--no-tags":
+			c.NoTags = true
+		case "--recurse-submodules", "--recursive":
+			c.Submodules = true
+		default:
+			log.Printf("Warning: %s: clone option %s is not supported and is ignored", c.Name, opts[i])
+		}
+	}
+}
+
+func (cfg *MainArgConfig) cmdReposSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	reposConf := fs.String("repos-conf", "/etc/portage/repos.conf", "Path to repos.conf file or directory")
+	jobs := fs.Int("jobs", 4, "Number of repositories to sync at the same time")
+	noCache := fs.Bool("no-cache", false, "Do not regenerate the metadata cache of repositories that ship no metadata/md5-cache")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if *jobs < 1 {
+		*jobs = 1
+	}
+
+	configs, err := readRepoSyncConfigs(*reposConf)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		byName := make(map[string]*repoSyncConfig)
+		for _, c := range configs {
+			byName[c.Name] = c
+		}
+		configs = nil
+		for _, name := range fs.Args() {
+			c, ok := byName[name]
+			if !ok {
+				return fmt.Errorf("repository %q not found or not enabled in %s", name, *reposConf)
+			}
+			if c.SyncType != "git" {
+				return fmt.Errorf("repository %s has sync-type %q; only git is supported", name, c.SyncType)
+			}
+			configs = append(configs, c)
+		}
+	}
+
+	results := make([]repoSyncResult, len(configs))
+	sem := make(chan struct{}, *jobs)
+	var wg sync.WaitGroup
+	for i, c := range configs {
+		if c.SyncType != "git" {
+			results[i] = repoSyncResult{Name: c.Name, Action: fmt.Sprintf("skipped (sync-type %q)", c.SyncType)}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, c *repoSyncConfig) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = syncGitRepo(context.Background(), c, !*noCache)
+		}(i, c)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, r := range results {
+		switch {
+		case r.Err != nil:
+			failed++
+			fmt.Printf("%s: error: %v\n", r.Name, r.Err)
+		case r.From.IsZero() && !r.To.IsZero():
+			fmt.Printf("%s: %s at %s\n", r.Name, r.Action, r.To.String()[:12])
+		case r.From != r.To:
+			fmt.Printf("%s: %s %s..%s\n", r.Name, r.Action, r.From.String()[:12], r.To.String()[:12])
+		default:
+			fmt.Printf("%s: %s\n", r.Name, r.Action)
+		}
+		if r.CacheGenerated {
+			fmt.Printf("%s: regenerated metadata cache\n", r.Name)
+		}
+	}
+	if failed > 0 {
+		return &ExitError{Code: 1, Err: fmt.Errorf("%d of %d repositories failed to sync", failed, len(results))}
+	}
+	return nil
+}
+
+// syncGitRepo clones or fast-forwards a single repository and regenerates its cache when it ships none.
+func syncGitRepo(ctx context.Context, c *repoSyncConfig, regenCache bool) repoSyncResult {
+	res := repoSyncResult{Name: c.Name}
+	if c.Location == "" || c.SyncURI == "" {
+		res.Err = fmt.Errorf("location and sync-uri must be set")
+		return res
+	}
+	if strings.HasPrefix(c.SyncURI, "-") {
+		res.Err = fmt.Errorf("invalid sync-uri %q", c.SyncURI)
+		return res
+	}
+
+	var keyRing string
+	if c.VerifyCommits {
+		if c.KeyPath == "" {
+			res.Err = fmt.Errorf("sync-git-verify-commit-signature is set but sync-openpgp-key-path is not")
+			return res
+		}
+		data, err := os.ReadFile(c.KeyPath)
+		if err != nil {
+			res.Err = fmt.Errorf("reading OpenPGP keys: %w", err)
+			return res
+		}
+		keyRing = string(data)
+	}
+
+	log.Printf("Syncing %s from %s", c.Name, c.SyncURI)
+	var repo *git.Repository
+	if _, err := os.Stat(filepath.Join(c.Location, ".git")); err == nil {
+		repo, res.Err = pullGitRepo(ctx, c, keyRing, &res)
+	} else {
+		repo, res.Err = cloneGitRepo(ctx, c, keyRing, &res)
+	}
+	if res.Err != nil || !regenCache || res.From == res.To {
+		return res
+	}
+
+	if shipsMD5Cache(repo) {
+		return res
+	}
+	if err := g2.GenerateCache(c.Location, nil, false); err != nil {
+		res.Err = fmt.Errorf("regenerating cache: %w", err)
+		return res
+	}
+	res.CacheGenerated = true
+	return res
+}
+
+func cloneGitRepo(ctx context.Context, c *repoSyncConfig, keyRing string, res *repoSyncResult) (*git.Repository, error) {
+	if entries, err := os.ReadDir(c.Location); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("%s exists and is not a git checkout", c.Location)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Location), 0755); err != nil {
+		return nil, err
+	}
+	opts := &git.CloneOptions{
+		URL:          c.SyncURI,
+		Depth:        c.Depth,
+		SingleBranch: c.SingleBranch,
+		NoCheckout:   keyRing != "",
+	}
+	if c.Branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(c.Branch)
+	}
+	if c.NoTags {
+		opts.Tags = git.NoTags
+	}
+	if c.Submodules {
+		opts.RecurseSubmodules = git.DefaultSubmoduleRecursionDepth
+	}
+	repo, err := git.PlainCloneContext(ctx, c.Location, false, opts)
+	if err != nil {
+		_ = os.RemoveAll(c.Location)
+		return nil, fmt.Errorf("cloning: %w", err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	if keyRing != "" {
+		if err := verifyGitCommit(repo, head.Hash(), keyRing); err != nil {
+			_ = os.RemoveAll(c.Location)
+			return nil, err
+		}
+		wt, err := repo.Worktree()
+		if err != nil {
+			return nil, err
+		}
+		if err := wt.Reset(&git.ResetOptions{Commit: head.Hash(), Mode: git.HardReset}); err != nil {
+			return nil, fmt.Errorf("checking out: %w", err)
+		}
+	}
+	res.Action = "cloned"
+	res.To = head.Hash()
+	return repo, nil
+}
+
+func pullGitRepo(ctx context.Context, c *repoSyncConfig, keyRing string, res *repoSyncResult) (*git.Repository, error) {
+	repo, err := git.PlainOpen(c.Location)
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", c.Location, err)
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	if !head.Name().IsBranch() {
+		return nil, fmt.Errorf("%s is not on a branch", c.Location)
+	}
+	branch := head.Name().Short()
+	if c.Branch != "" && c.Branch != branch {
+		return nil, fmt.Errorf("%s is on branch %s, but %s is configured", c.Location, branch, c.Branch)
+	}
+	res.From = head.Hash()
+
+	remoteRef := plumbing.NewRemoteReferenceName("origin", branch)
+	fetch := &git.FetchOptions{
+		RemoteName: "origin",
+		RemoteURL:  c.SyncURI,
+		RefSpecs:   []config.RefSpec{config.RefSpec("+" + head.Name().String() + ":" + remoteRef.String())},
+		Depth:      c.Depth,
+		Force:      true,
+	}
+	if c.NoTags {
+		fetch.Tags = git.NoTags
+	}
+	if err := repo.FetchContext(ctx, fetch); err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("fetching: %w", err)
+	}
+	ref, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s: %w", remoteRef, err)
+	}
+	res.To = ref.Hash()
+	if res.To == res.From {
+		res.Action = "up to date"
+		return repo, nil
+	}
+
+	if keyRing != "" {
+		if err := verifyGitCommit(repo, res.To, keyRing); err != nil {
+			res.To = res.From
+			return nil, err
+		}
+	}
+	// Shallow checkouts lack the history to prove a fast-forward; like Portage, they simply follow the remote.
+	if c.Depth == 0 {
+		current, err := repo.CommitObject(res.From)
+		if err != nil {
+			return nil, err
+		}
+		target, err := repo.CommitObject(res.To)
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := current.IsAncestor(target); err != nil || !ok {
+			res.To = res.From
+			return nil, fmt.Errorf("local branch %s has diverged from %s; not fast-forwarding", branch, c.SyncURI)
+		}
+	}
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(head.Name(), res.To)); err != nil {
+		return nil, err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	if err := wt.Reset(&git.ResetOptions{Commit: res.To, Mode: git.MergeReset}); err != nil {
+		return nil, fmt.Errorf("updating worktree: %w", err)
+	}
+	if c.Submodules {
+		subs, err := wt.Submodules()
+		if err == nil {
+			err = subs.UpdateContext(ctx, &git.SubmoduleUpdateOptions{Init: true, RecurseSubmodules: git.DefaultSubmoduleRecursionDepth})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("updating submodules: %w", err)
+		}
+	}
+	res.Action = "updated"
+	return repo, nil
+}
+
+// verifyGitCommit checks that the commit carries a valid signature from one of the armored keys.
+func verifyGitCommit(repo *git.Repository, hash plumbing.Hash, keyRing string) error {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	if commit.PGPSignature == "" {
+		return fmt.Errorf("commit %s is not signed", hash)
+	}
+	if _, err := commit.Verify(keyRing); err != nil {
+		return fmt.Errorf("commit %s has a bad signature: %w", hash, err)
+	}
+	return nil
+}
+
+// shipsMD5Cache reports whether metadata/md5-cache is tracked at HEAD, in which case it is kept up to date upstream.
+func shipsMD5Cache(repo *git.Repository) bool {
+	head, err := repo.Head()
+	if err != nil {
+		return false
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return false
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return false
+	}
+	_, err = tree.Tree("metadata/md5-cache")
+	return err == nil
+}
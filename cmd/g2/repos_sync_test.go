@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testUpstream is a working repository pushing to a bare repository served over file://.
+type testUpstream struct {
+	t    *testing.T
+	work string
+	repo *git.Repository
+	URI  string
+}
+
+func newTestUpstream(t *testing.T) *testUpstream {
+	t.Helper()
+	root := t.TempDir()
+	bare := filepath.Join(root, "upstream.git")
+	if _, err := git.PlainInit(bare, true); err != nil {
+		t.Fatal(err)
+	}
+	work := filepath.Join(root, "work")
+	repo, err := git.PlainInit(work, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"file://" + bare}}); err != nil {
+		t.Fatal(err)
+	}
+	return &testUpstream{t: t, work: work, repo: repo, URI: "file://" + bare}
+}
+
+// commit writes files, commits them, optionally signed, and pushes.
+func (u *testUpstream) commit(files map[string]string, key *openpgp.Entity) {
+	u.t.Helper()
+	writeTestFiles(u.t, u.work, files)
+	wt, err := u.repo.Worktree()
+	if err != nil {
+		u.t.Fatal(err)
+	}
+	if err := wt.AddGlob("."); err != nil {
+		u.t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	if _, err := wt.Commit("update", &git.CommitOptions{Author: sig, SignKey: key}); err != nil {
+		u.t.Fatal(err)
+	}
+	if err := u.repo.Push(&git.PushOptions{RemoteName: "origin"}); err != nil {
+		u.t.Fatal(err)
+	}
+}
+
+func runReposSync(t *testing.T, reposConf string, args ...string) (string, error) {
+	t.Helper()
+	cfg := &MainArgConfig{}
+	return captureOutput(t, func() error {
+		return cfg.cmdRepos(append([]string{"sync", "-repos-conf", reposConf}, args...))
+	})
+}
+
+func TestCmdReposSync(t *testing.T) {
+	upstream := newTestUpstream(t)
+	upstream.commit(map[string]string{
+		"profiles/repo_name":        "test\n",
+		"metadata/layout.conf":      "masters = \n",
+		"app-misc/foo/foo-1.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\n",
+	}, nil)
+
+	dir := t.TempDir()
+	checkout := filepath.Join(dir, "repos", "test")
+	writeTestFiles(t, dir, map[string]string{
+		"repos.conf/test.conf":  "[test]\nlocation = " + checkout + "\nsync-type = git\nsync-uri = " + upstream.URI + "\nsync-depth = 0\n",
+		"repos.conf/other.conf": "[other]\nlocation = " + filepath.Join(dir, "repos", "other") + "\nsync-type = rsync\nsync-uri = rsync://example.com/other\n",
+	})
+	reposConf := filepath.Join(dir, "repos.conf")
+
+	out, err := runReposSync(t, reposConf)
+	if err != nil {
+		t.Fatalf("first sync failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "test: cloned at") || !strings.Contains(out, `other: skipped (sync-type "rsync")`) {
+		t.Errorf("unexpected output of first sync:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(checkout, "metadata", "md5-dict", "app-misc", "foo-1")); err != nil {
+		t.Errorf("expected md5-cache to be generated: %v", err)
+	}
+
+	upstream.commit(map[string]string{"app-misc/foo/foo-2.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\n"}, nil)
+	out, err = runReposSync(t, reposConf, "test")
+	if err != nil {
+		t.Fatalf("second sync failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "test: updated") {
+		t.Errorf("unexpected output of second sync:\n%s", out)
+	}
+	if _, err := os.Stat(filepath.Join(checkout, "app-misc", "foo", "foo-2.ebuild")); err != nil {
+		t.Errorf("expected new ebuild after sync: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(checkout, "metadata", "md5-dict", "app-misc", "foo-2")); err != nil {
+		t.Errorf("expected md5-cache to be regenerated: %v", err)
+	}
+
+	out, err = runReposSync(t, reposConf, "test")
+	if err != nil || !strings.Contains(out, "test: up to date") {
+		t.Errorf("unexpected third sync: %v\n%s", err, out)
+	}
+
+	if _, err := runReposSync(t, reposConf, "other"); err == nil {
+		t.Error("expected syncing a non-git repository by name to fail")
+	}
+}
+
+func TestCmdReposSyncVerifySignature(t *testing.T) {
+	key, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var armored bytes.Buffer
+	w, err := armor.Encode(&armored, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	upstream := newTestUpstream(t)
+	upstream.commit(map[string]string{"profiles/repo_name": "signed\n", "metadata/md5-cache/.keep": ""}, key)
+
+	dir := t.TempDir()
+	checkout := filepath.Join(dir, "signed")
+	writeTestFiles(t, dir, map[string]string{
+		"key.asc": armored.String(),
+		"repos.conf": "[signed]\nlocation = " + checkout + "\nsync-type = git\nsync-uri = " + upstream.URI +
+			"\nsync-git-verify-commit-signature = yes\nsync-openpgp-key-path = " + filepath.Join(dir, "key.asc") + "\n",
+	})
+	reposConf := filepath.Join(dir, "repos.conf")
+
+	if out, err := runReposSync(t, reposConf); err != nil {
+		t.Fatalf("sync of signed repository failed: %v\n%s", err, out)
+	}
+	repo, err := git.PlainOpen(checkout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := repo.Head()
+
+	upstream.commit(map[string]string{"README": "unsigned\n"}, nil)
+	out, err := runReposSync(t, reposConf)
+	if err == nil || !strings.Contains(out, "is not signed") {
+		t.Errorf("expected unsigned commit to be rejected, got %v\n%s", err, out)
+	}
+	after, _ := repo.Head()
+	if before.Hash() != after.Hash() {
+		t.Errorf("HEAD moved to unverified commit %s", after.Hash())
+	}
+	if _, err := os.Stat(filepath.Join(checkout, "README")); !os.IsNotExist(err) {
+		t.Errorf("unverified file was checked out: %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arran4/g2"
+)
+
+// CmdSbomArgConfig holds the options shared by the sbom subcommands.
+type CmdSbomArgConfig struct {
+	*MainArgConfig
+	Format     string
+	Output     string
+	Name       string
+	LicenseMap string
+	ReposConf  string
+	RepoDirs   StringSliceFlag
+	Vdb        string
+}
+
+func (cfg *MainArgConfig) cmdSbom(args []string) error {
+	c := &CmdSbomArgConfig{MainArgConfig: cfg}
+	fs := flag.NewFlagSet("sbom", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: g2 sbom [flags] <subcommand>\n")
+		fmt.Printf("\t\t %s \t\t %s\n", "overlay <dir> [<atom>...]", "describe every package version of a repository, or those matching the atoms")
+		fmt.Printf("\t\t %s \t\t %s\n", "installed [<atom>...]", "describe the packages installed in the vdb, or those matching the atoms")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&c.Format, "format", "spdx", "Output format: spdx (SPDX 2.3 JSON) or cyclonedx (CycloneDX 1.5 JSON)")
+	fs.StringVar(&c.Output, "o", "", "Output file (default: stdout)")
+	fs.StringVar(&c.Name, "name", "", "Document name (default: the repository name, or the host name for installed)")
+	fs.StringVar(&c.LicenseMap, "license-map", "", "Extra Gentoo to SPDX license map, applied after "+g2.SPDXLicenseMapFile+" of each repository")
+	fs.StringVar(&c.ReposConf, "repos-conf", "/etc/portage/repos.conf", "Path to repos.conf, used to find the distfiles of installed packages")
+	fs.Var(&c.RepoDirs, "repo", "Repository used to find the distfiles of installed packages (repeatable)")
+	fs.StringVar(&c.Vdb, "vdb", "/var/db/pkg", "Installed package database for the installed subcommand")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if c.Format != "spdx" && c.Format != "cyclonedx" {
+		return fmt.Errorf("unknown sbom format %q, want spdx or cyclonedx", c.Format)
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing subcommand for sbom")
+	}
+
+	var repos []*g2.RepoInfo
+	var sbom *g2.SBOM
+	switch fs.Arg(0) {
+	case "overlay":
+		if fs.NArg() < 2 {
+			return fmt.Errorf("usage: g2 sbom overlay <dir> [<atom>...]")
+		}
+		dir := fs.Arg(1)
+		name := readRepoName(dir)
+		repos = []*g2.RepoInfo{{Name: name, RepoName: name, Location: dir}}
+		atoms, err := parseSbomAtoms(fs.Args()[2:])
+		if err != nil {
+			return err
+		}
+		pkgs, err := repoSbomPackages(repos[0], atoms)
+		if err != nil {
+			return err
+		}
+		sbom = &g2.SBOM{Name: name, Packages: pkgs}
+	case "installed":
+		atoms, err := parseSbomAtoms(fs.Args()[1:])
+		if err != nil {
+			return err
+		}
+		repos, err = resolveRepoStack(c.RepoDirs, c.ReposConf)
+		if err != nil {
+			log.Printf("Warning: distfiles will not be listed: %v", err)
+		}
+		pkgs, err := installedSbomPackages(c.Vdb, repos, atoms)
+		if err != nil {
+			return err
+		}
+		name, _ := os.Hostname()
+		sbom = &g2.SBOM{Name: name, Packages: pkgs}
+	case "help", "-help", "--help":
+		fs.Usage()
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown sbom subcommand: %s", fs.Arg(0))
+	}
+
+	if c.Name != "" {
+		sbom.Name = c.Name
+	}
+	sbom.Created = time.Now()
+	licenses, err := c.licenseMap(repos)
+	if err != nil {
+		return err
+	}
+	sbom.Licenses = licenses
+	sbom.LicenseTexts = unmappedLicenseTexts(sbom.Packages, licenses, repos)
+
+	var out []byte
+	if c.Format == "cyclonedx" {
+		out, err = sbom.CycloneDX()
+	} else {
+		out, err = sbom.SPDX()
+	}
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	if c.Output == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	if err := g2.SafeWriteFileAtomic(c.Output, out, 0644); err != nil {
+		return err
+	}
+	log.Printf("Wrote %d packages to %s", len(sbom.Packages), c.Output)
+	return nil
+}
+
+func parseSbomAtoms(args []string) ([]g2.PackageAtom, error) {
+	var atoms []g2.PackageAtom
+	for _, arg := range args {
+		atom := g2.ParsePackageAtom(arg)
+		if atom.Category == "" || atom.Name == "" {
+			return nil, fmt.Errorf("invalid package atom %q", arg)
+		}
+		atoms = append(atoms, atom)
+	}
+	return atoms, nil
+}
+
+func sbomSelected(atoms []g2.PackageAtom, candidate g2.AtomCandidate) bool {
+	if len(atoms) == 0 {
+		return true
+	}
+	for _, a := range atoms {
+		if a.Matches(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// licenseMap returns the default license map with the override file of each repository and then the
+// -license-map file applied.
+func (c *CmdSbomArgConfig) licenseMap(repos []*g2.RepoInfo) (g2.SPDXLicenseMap, error) {
+	m := g2.DefaultSPDXLicenses
+	files := make([]string, 0, len(repos)+1)
+	for _, repo := range repos {
+		files = append(files, filepath.Join(repo.Location, g2.SPDXLicenseMapFile))
+	}
+	if c.LicenseMap != "" {
+		files = append(files, c.LicenseMap)
+	}
+	for i, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			if os.IsNotExist(err) && i < len(repos) {
+				continue
+			}
+			return nil, err
+		}
+		overrides, err := g2.ParseSPDXLicenseMap(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		m = m.Merge(overrides)
+	}
+	return m, nil
+}
+
+// unmappedLicenseTexts reads the licenses/ files of the licenses that have no SPDX identifier.
+func unmappedLicenseTexts(pkgs []g2.SBOMPackage, licenses g2.SPDXLicenseMap, repos []*g2.RepoInfo) map[string]string {
+	texts := make(map[string]string)
+	for _, p := range pkgs {
+		for _, lic := range g2.ParseLicense(p.License) {
+			if _, done := texts[lic]; done || !strings.HasPrefix(licenses.Expression(lic), "LicenseRef-") {
+				continue
+			}
+			texts[lic] = ""
+			for _, repo := range repos {
+				if data, err := os.ReadFile(filepath.Join(repo.Location, "licenses", lic)); err == nil {
+					texts[lic] = string(data)
+					break
+				}
+			}
+		}
+	}
+	return texts
+}
+
+// repoSbomPackages returns every ebuild of the repository matching atoms, or all ebuilds without atoms.
+func repoSbomPackages(repo *g2.RepoInfo, atoms []g2.PackageAtom) ([]g2.SBOMPackage, error) {
+	fsys := os.DirFS(repo.Location)
+	var categories []string
+	if data, err := fs.ReadFile(fsys, "profiles/categories"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if cat := strings.TrimSpace(line); cat != "" && !strings.HasPrefix(cat, "#") {
+				categories = append(categories, cat)
+			}
+		}
+	} else {
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.IsDir() && !isIgnoredDir(e.Name()) && (strings.Contains(e.Name(), "-") || e.Name() == "virtual") {
+				categories = append(categories, e.Name())
+			}
+		}
+	}
+	sort.Strings(categories)
+
+	var res []g2.SBOMPackage
+	for _, cat := range categories {
+		pkgDirs, err := fs.ReadDir(fsys, cat)
+		if err != nil {
+			continue
+		}
+		for _, pkgDir := range pkgDirs {
+			if !pkgDir.IsDir() || strings.HasPrefix(pkgDir.Name(), ".") {
+				continue
+			}
+			pkgs, err := repoPackageSbom(fsys, repo.RepoName, cat, pkgDir.Name(), "", atoms)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, pkgs...)
+		}
+	}
+	return res, nil
+}
+
+// repoPackageSbom describes the ebuilds of category/name in a repository, only version when it is set,
+// together with their distfiles from the package Manifest.
+func repoPackageSbom(fsys fs.FS, repoName, category, name, version string, atoms []g2.PackageAtom) ([]g2.SBOMPackage, error) {
+	dir := path.Join(category, name)
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var manifest *g2.Manifest
+	if m, err := parseManifestFromFS(fsys, path.Join(dir, "Manifest")); err == nil {
+		manifest = m
+	}
+	var remoteIDs []g2.RemoteID
+	if md, err := parseMetadataFromFS(fsys, path.Join(dir, "metadata.xml")); err == nil {
+		if pkgMd, ok := md.(*g2.PkgMetadata); ok && pkgMd.Upstream != nil {
+			remoteIDs = pkgMd.Upstream.RemoteID
+		}
+	}
+
+	var res []g2.SBOMPackage
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".ebuild") {
+			continue
+		}
+		vars := g2.ParseEbuildVariables(f.Name())
+		if vars == nil || vars["PN"] != name || (version != "" && vars["PVR"] != version) {
+			continue
+		}
+		eb, err := g2.ParseEbuild(fsys, path.Join(dir, f.Name()), g2.ParseFull)
+		if err != nil {
+			log.Printf("Warning: skipping %s::%s: %v", path.Join(dir, f.Name()), repoName, err)
+			continue
+		}
+		slot := eb.Vars["SLOT"]
+		if slot == "" {
+			slot = "0"
+		}
+		if !sbomSelected(atoms, g2.AtomCandidate{Category: category, Name: name, Version: vars["PVR"], Slot: slot, Repo: repoName}) {
+			continue
+		}
+		res = append(res, g2.SBOMPackage{
+			Category:    category,
+			Name:        name,
+			Version:     vars["PVR"],
+			Slot:        slot,
+			Repository:  repoName,
+			Description: eb.Vars["DESCRIPTION"],
+			Homepage:    eb.Vars["HOMEPAGE"],
+			License:     eb.Vars["LICENSE"],
+			RemoteIDs:   remoteIDs,
+			Distfiles:   sbomDistfiles(eb.SrcUri, manifest),
+		})
+	}
+	return res, nil
+}
+
+// sbomDistfiles pairs the SRC_URI entries of an ebuild with their Manifest DIST entries.
+func sbomDistfiles(uris []g2.URIEntry, manifest *g2.Manifest) []g2.SBOMDistfile {
+	if manifest == nil {
+		return nil
+	}
+	var res []g2.SBOMDistfile
+	seen := make(map[string]bool)
+	for _, u := range uris {
+		if seen[u.Filename] {
+			continue
+		}
+		entry := manifest.GetEntry(u.Filename)
+		if entry == nil || entry.Type != "DIST" {
+			continue
+		}
+		seen[u.Filename] = true
+		url := u.URL
+		if strings.HasPrefix(url, "mirror://") {
+			// Mirror URLs are not resolvable outside Portage.
+			url = ""
+		}
+		res = append(res, g2.SBOMDistfile{Filename: u.Filename, URL: url, Size: entry.Size, Hashes: entry.Hashes})
+	}
+	return res
+}
+
+// installedSbomPackages describes the installed packages matching atoms, looking up their distfiles in
+// the repository they were installed from when it is available.
+func installedSbomPackages(vdb string, repos []*g2.RepoInfo, atoms []g2.PackageAtom) ([]g2.SBOMPackage, error) {
+	installed, err := g2.ListInstalledPackages(vdb)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*g2.RepoInfo)
+	for _, repo := range repos {
+		byName[repo.RepoName] = repo
+	}
+
+	var res []g2.SBOMPackage
+	for _, p := range installed {
+		if !sbomSelected(atoms, p.Candidate()) {
+			continue
+		}
+		pkg := g2.SBOMPackage{
+			Category:    p.Category,
+			Name:        p.Name,
+			Version:     p.Version,
+			Slot:        p.Slot,
+			Repository:  p.Repository,
+			Description: p.Metadata["DESCRIPTION"],
+			Homepage:    p.Metadata["HOMEPAGE"],
+			License:     p.Metadata["LICENSE"],
+			Use:         append([]string{}, p.Use...),
+		}
+		if repo := byName[p.Repository]; repo != nil {
+			found, err := repoPackageSbom(os.DirFS(repo.Location), repo.RepoName, p.Category, p.Name, p.Version, nil)
+			if err != nil {
+				return nil, err
+			}
+			if len(found) == 1 {
+				pkg.RemoteIDs = found[0].RemoteIDs
+				pkg.Distfiles = found[0].Distfiles
+			}
+		}
+		res = append(res, pkg)
+	}
+	return res, nil
+}
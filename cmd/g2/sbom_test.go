@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeSbomTestRepo(t *testing.T, root string) string {
+	t.Helper()
+	repo := filepath.Join(root, "repo")
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":     "example\n",
+		"profiles/categories":    "app-misc\n",
+		"metadata/spdx-licenses": "Example-EULA LicenseRef-Example-EULA\n",
+		"licenses/custom":        "Custom licence text\n",
+		"app-misc/foo/foo-1.0.ebuild": `EAPI=8
+DESCRIPTION="Foo tool"
+HOMEPAGE="https://foo.example.org"
+SRC_URI="https://foo.example.org/${P}.tar.gz"
+LICENSE="MIT ssl? ( custom )"
+SLOT="0"
+KEYWORDS="amd64"
+IUSE="ssl"
+`,
+		"app-misc/foo/foo-2.0.ebuild": `EAPI=8
+DESCRIPTION="Foo tool"
+HOMEPAGE="https://foo.example.org"
+SRC_URI="https://foo.example.org/${P}.tar.gz"
+LICENSE="Example-EULA"
+SLOT="0"
+`,
+		"app-misc/foo/Manifest": "DIST foo-1.0.tar.gz 10 BLAKE2B aa SHA512 bb\nDIST foo-2.0.tar.gz 20 BLAKE2B cc SHA512 dd\n",
+		"app-misc/foo/metadata.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<pkgmetadata><upstream><remote-id type="github">example/foo</remote-id></upstream></pkgmetadata>
+`,
+	})
+	return repo
+}
+
+func TestSbomOverlay(t *testing.T) {
+	root := t.TempDir()
+	repo := writeSbomTestRepo(t, root)
+	cfg := &MainArgConfig{}
+	out := filepath.Join(root, "sbom.json")
+
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdSbom([]string{"-o", out, "overlay", repo, "=app-misc/foo-1.0"})
+	}); err != nil {
+		t.Fatalf("sbom overlay: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Name     string
+		Packages []struct {
+			Name             string
+			VersionInfo      string
+			LicenseDeclared  string
+			DownloadLocation string
+			Checksums        []struct{ Algorithm, ChecksumValue string }
+		}
+		HasExtractedLicensingInfos []struct{ LicenseID, ExtractedText string }
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid SPDX JSON: %v\n%s", err, data)
+	}
+	if doc.Name != "example" || len(doc.Packages) != 2 {
+		t.Fatalf("unexpected document:\n%s", data)
+	}
+	foo, dist := doc.Packages[0], doc.Packages[1]
+	if foo.VersionInfo != "1.0" || foo.LicenseDeclared != "MIT AND LicenseRef-Gentoo-custom" {
+		t.Errorf("foo = %+v", foo)
+	}
+	if dist.DownloadLocation != "https://foo.example.org/foo-1.0.tar.gz" || len(dist.Checksums) != 2 || dist.Checksums[1].ChecksumValue != "bb" {
+		t.Errorf("distfile = %+v", dist)
+	}
+	if len(doc.HasExtractedLicensingInfos) != 1 || doc.HasExtractedLicensingInfos[0].ExtractedText != "Custom licence text\n" {
+		t.Errorf("extracted licenses = %+v", doc.HasExtractedLicensingInfos)
+	}
+
+	// The repository's license map applies to the whole overlay.
+	stdout, err := captureOutput(t, func() error {
+		return cfg.cmdSbom([]string{"-format", "cyclonedx", "overlay", repo})
+	})
+	if err != nil {
+		t.Fatalf("sbom overlay cyclonedx: %v", err)
+	}
+	if !strings.Contains(stdout, `"specVersion": "1.5"`) || !strings.Contains(stdout, `"expression": "LicenseRef-Example-EULA"`) {
+		t.Errorf("unexpected CycloneDX output:\n%s", stdout)
+	}
+}
+
+func TestSbomInstalled(t *testing.T) {
+	root := t.TempDir()
+	repo := writeSbomTestRepo(t, root)
+	vdb := filepath.Join(root, "vdb")
+	writeTestFiles(t, vdb, map[string]string{
+		"app-misc/foo-1.0/SLOT":        "0\n",
+		"app-misc/foo-1.0/LICENSE":     "MIT ssl? ( custom )\n",
+		"app-misc/foo-1.0/USE":         "amd64\n",
+		"app-misc/foo-1.0/repository":  "example\n",
+		"app-misc/foo-1.0/DESCRIPTION": "Foo tool\n",
+	})
+	cfg := &MainArgConfig{}
+
+	stdout, err := captureOutput(t, func() error {
+		return cfg.cmdSbom([]string{"-vdb", vdb, "-repo", repo, "-name", "host", "installed"})
+	})
+	if err != nil {
+		t.Fatalf("sbom installed: %v", err)
+	}
+	for _, want := range []string{`"name": "host"`, `"licenseConcluded": "MIT"`, `"packageFileName": "foo-1.0.tar.gz"`, `"pkg:github/example/foo"`} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output missing %s:\n%s", want, stdout)
+		}
+	}
+}
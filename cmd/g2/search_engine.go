@@ -1,13 +1,27 @@
 package main
 
 import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/arran4/g2"
 )
 
+// SearchEngine answers package search queries from an inverted index of search documents. The index
+// is built on the first search after documents are loaded, or mapped from an index file written by
+// generateSearchData.
 type SearchEngine struct {
 	documents []SearchDocument
+
+	// mu guards documents and index: searches hold it for reading while they use them, and loading
+	// documents or an index file holds it for writing.
+	mu    sync.RWMutex
+	index *searchIndex
 }
 
 func NewSearchEngine() *SearchEngine {
@@ -15,53 +29,334 @@ func NewSearchEngine() *SearchEngine {
 }
 
 func (e *SearchEngine) LoadDocuments(docs []SearchDocument) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.index != nil && len(e.index.docs) > 0 {
+		e.documents = e.storedDocuments()
+	}
+	e.closeIndex()
 	e.documents = append(e.documents, docs...)
 }
 
-func (e *SearchEngine) Search(query string) []SearchDocument {
-	if strings.TrimSpace(query) == "" {
-		return e.documents
+// LoadIndexFile replaces the documents of the engine with those of the index file at path, which is
+// memory mapped rather than read.
+func (e *SearchEngine) LoadIndexFile(path string) error {
+	idx, err := loadSearchIndexFile(path)
+	if err != nil {
+		return err
+	}
+	if !idx.hasDocuments() {
+		_ = idx.close()
+		return fmt.Errorf("%s: search index holds no documents", path)
 	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.closeIndex()
+	e.documents = nil
+	e.index = idx
+	return nil
+}
 
-	parser := NewSearchParser(query)
-	ast := parser.Parse()
+// Close releases a mapped index file.
+func (e *SearchEngine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.closeIndex()
+}
 
+func (e *SearchEngine) closeIndex() error {
+	if e.index == nil {
+		return nil
+	}
+	err := e.index.close()
+	e.index = nil
+	return err
+}
+
+// readIndex returns the index, building it from the loaded documents when needed, with mu held for
+// reading. The caller must call e.mu.RUnlock once it is done with the index and the documents.
+func (e *SearchEngine) readIndex() *searchIndex {
+	e.mu.RLock()
+	for e.index == nil {
+		e.mu.RUnlock()
+		e.mu.Lock()
+		if e.index == nil {
+			idx, err := openSearchIndex(encodeSearchIndex(e.documents, nil), nil)
+			if err != nil {
+				panic(fmt.Sprintf("opening a search index just built: %v", err))
+			}
+			e.index = idx
+		}
+		e.mu.Unlock()
+		e.mu.RLock()
+	}
+	return e.index
+}
+
+// storedDocuments decodes every document of a mapped index file.
+func (e *SearchEngine) storedDocuments() []SearchDocument {
+	docs := make([]SearchDocument, 0, e.index.nDocs)
+	for d := 0; d < e.index.nDocs; d++ {
+		doc, err := e.index.document(uint32(d))
+		if err != nil {
+			log.Printf("Warning: decoding search document %d: %v", d, err)
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// document returns document d of idx.
+func (e *SearchEngine) document(idx *searchIndex, d uint32) (SearchDocument, bool) {
+	if len(idx.docs) > 0 {
+		doc, err := idx.document(d)
+		if err != nil {
+			log.Printf("Warning: decoding search document %d: %v", d, err)
+			return SearchDocument{}, false
+		}
+		return doc, true
+	}
+	return e.documents[d], true
+}
+
+// Search returns the documents matching query, most relevant first. Matches are scored with BM25 over
+// the fields a term matched in, and a term equal to a package name or category/name boosts that
+// package above the rest. An empty query returns every document in the order loaded.
+func (e *SearchEngine) Search(query string) []SearchDocument {
+	var ast *ASTNode
+	if strings.TrimSpace(query) != "" {
+		ast = NewSearchParser(query).Parse()
+	}
+	idx := e.readIndex()
+	defer e.mu.RUnlock()
 	if ast == nil {
-		return e.documents
+		if len(idx.docs) > 0 {
+			return e.storedDocuments()
+		}
+		return slices.Clone(e.documents)
 	}
 
-	var results []SearchDocument
-	for _, doc := range e.documents {
-		if e.evaluateAST(doc, ast) {
+	q := &searchQuery{engine: e, idx: idx, scores: map[uint32]float64{}}
+	matches := q.eval(ast, true)
+	sort.SliceStable(matches, func(i, j int) bool { return q.scores[matches[i]] > q.scores[matches[j]] })
+
+	results := make([]SearchDocument, 0, len(matches))
+	for _, d := range matches {
+		if doc, ok := e.document(idx, d); ok {
 			results = append(results, doc)
 		}
 	}
-
 	return results
 }
 
-func (e *SearchEngine) evaluateAST(doc SearchDocument, ast *ASTNode) bool {
-	if ast == nil {
-		return true
+// searchNameBoost is added to the score of a package whose name or category/name equals a query term.
+const searchNameBoost = 25
+
+// searchQuery evaluates a query AST as operations on sorted postings lists.
+type searchQuery struct {
+	engine *SearchEngine
+	idx    *searchIndex
+	scores map[uint32]float64
+	all    []uint32
+}
+
+func (q *searchQuery) allDocs() []uint32 {
+	if q.all == nil {
+		q.all = make([]uint32, q.idx.nDocs)
+		for i := range q.all {
+			q.all[i] = uint32(i)
+		}
 	}
+	return q.all
+}
 
+// eval returns the documents matching ast. Only matches outside a NOT add to the scores.
+func (q *searchQuery) eval(ast *ASTNode, score bool) []uint32 {
+	if ast == nil {
+		return q.allDocs()
+	}
 	switch ast.Type {
 	case AND:
-		return e.evaluateAST(doc, ast.Left) && e.evaluateAST(doc, ast.Right)
+		if ast.Right != nil && ast.Right.Type == NOT {
+			return differencePostings(q.eval(ast.Left, score), q.eval(ast.Right.Expr, false))
+		}
+		if ast.Left != nil && ast.Left.Type == NOT {
+			return differencePostings(q.eval(ast.Right, score), q.eval(ast.Left.Expr, false))
+		}
+		return intersectPostings(q.eval(ast.Left, score), q.eval(ast.Right, score))
 	case OR:
-		return e.evaluateAST(doc, ast.Left) || e.evaluateAST(doc, ast.Right)
+		return unionPostings(q.eval(ast.Left, score), q.eval(ast.Right, score))
 	case NOT:
-		return !e.evaluateAST(doc, ast.Expr)
+		return differencePostings(q.allDocs(), q.eval(ast.Expr, false))
 	case GROUP:
-		return e.evaluateAST(doc, ast.Expr)
+		return q.eval(ast.Expr, score)
 	case TERM:
-		return e.matchTerm(doc, ast.Value)
+		return q.term(ast.Value, score)
 	case FIELD:
-		return e.matchField(doc, ast.Field, ast.Value)
+		return q.field(ast.Field, ast.Value, score)
 	case SEQUENCE:
-		return e.matchSequence(doc, ast.Value)
+		return q.sequence(ast.Value, score)
 	default:
-		return false
+		return nil
+	}
+}
+
+func (q *searchQuery) term(value string, score bool) []uint32 {
+	docs := q.match(searchFieldText, strings.ToLower(value), score, func(doc SearchDocument) bool {
+		return q.engine.matchTerm(doc, value)
+	})
+	if score && len(docs) > 0 {
+		q.scoreField(searchFieldPackage, strings.ToLower(value), docs)
+		q.boostName(strings.ToLower(value), docs)
+	}
+	return docs
+}
+
+func (q *searchQuery) field(field, value string, score bool) []uint32 {
+	valLower := strings.ToLower(value)
+	verify := func(doc SearchDocument) bool { return q.engine.matchField(doc, field, value) }
+	switch field {
+	case "category":
+		return q.match(searchFieldCategory, valLower, score, verify)
+	case "package":
+		docs := q.match(searchFieldPackage, valLower, score, verify)
+		if score {
+			q.boostName(valLower, docs)
+		}
+		return docs
+	case "name", "fullname":
+		docs := q.match(searchFieldFullName, valLower, score, verify)
+		if score {
+			q.boostName(valLower, docs)
+		}
+		return docs
+	case "desc", "description":
+		return q.match(searchFieldDescription, valLower, score, verify)
+	case "license":
+		return q.match(searchFieldLicense, valLower, score, verify)
+	case "use":
+		return q.match(searchFieldUse, valLower, score, verify)
+	case "keyword", "keywords", "arch", "arches":
+		return q.match(searchFieldKeyword, valLower, score, verify)
+	case "mask":
+		return q.exact(searchFieldMask, valLower)
+	case "eapi":
+		return q.exact(searchFieldEAPI, value)
+	case "depend", "depends", "rdepend", "rdepends":
+		return q.match(searchFieldDepend, valLower, score, verify)
+	case "overlay":
+		return q.match(searchFieldOverlay, valLower, score, verify)
+	case "version":
+		var docs []uint32
+		for d := 0; d < q.idx.nDocs; d++ {
+			if matchVersionKey(q.idx.versionKey(uint32(d)), value) {
+				docs = append(docs, uint32(d))
+			}
+		}
+		return docs
+	default:
+		return q.term(value, score)
+	}
+}
+
+// exact returns the documents whose field f is value.
+func (q *searchQuery) exact(f searchField, value string) []uint32 {
+	fi := &q.idx.fields[f]
+	if t := fi.lookup(value); t >= 0 {
+		return fi.docs([]int{t})
+	}
+	return nil
+}
+
+// match returns the documents whose field f matches the lower case pattern p as matchWildcard does.
+// Terms of the dictionary are matched in place of the documents where that gives the same answer;
+// otherwise the dictionary narrows the documents down to those holding every literal part of p, and
+// verify decides.
+func (q *searchQuery) match(f searchField, p string, score bool, verify func(SearchDocument) bool) []uint32 {
+	fi := &q.idx.fields[f]
+	wildcard := strings.ContainsAny(p, "*?")
+	if p != "" && !strings.ContainsFunc(p, unicode.IsSpace) && (!wildcard || !f.multiWord()) {
+		var terms []int
+		if wildcard {
+			terms = fi.matchTerms(func(term []byte) bool { return q.engine.matchWildcard(string(term), p) })
+		} else {
+			terms = fi.termsContaining(p)
+		}
+		docs := fi.docs(terms)
+		if score {
+			q.bm25(f, terms, docs, p)
+		}
+		return docs
+	}
+
+	parts := strings.FieldsFunc(p, func(r rune) bool { return r == '*' || r == '?' || unicode.IsSpace(r) })
+	return q.verified(f, parts, score, verify)
+}
+
+// verified returns the documents holding each of parts in field f that verify accepts, or of all
+// documents when parts is empty.
+func (q *searchQuery) verified(f searchField, parts []string, score bool, verify func(SearchDocument) bool) []uint32 {
+	fi := &q.idx.fields[f]
+	candidates := q.allDocs()
+	var allTerms [][]int
+	for _, part := range parts {
+		terms := fi.termsContaining(part)
+		allTerms = append(allTerms, terms)
+		candidates = intersectPostings(candidates, fi.docs(terms))
+	}
+	var docs []uint32
+	for _, d := range candidates {
+		if doc, ok := q.engine.document(q.idx, d); ok && verify(doc) {
+			docs = append(docs, d)
+		}
+	}
+	if score {
+		for i, terms := range allTerms {
+			q.bm25(f, terms, docs, parts[i])
+		}
+	}
+	return docs
+}
+
+func (q *searchQuery) sequence(seq string, score bool) []uint32 {
+	return q.verified(searchFieldText, strings.Fields(strings.ToLower(seq)), score, func(doc SearchDocument) bool {
+		return q.engine.matchSequence(doc, seq)
+	})
+}
+
+// bm25 scores the documents docs for the terms of field f that matched p. A term counts in proportion
+// to how much of it p covers, so whole word matches rank above matches inside longer words.
+func (q *searchQuery) bm25(f searchField, terms []int, docs []uint32, p string) {
+	literal := len(strings.NewReplacer("*", "", "?", "").Replace(p))
+	q.idx.fields[f].bm25(q.scores, q.idx.nDocs, terms, docs, func(term []byte) float64 {
+		cover := 1.0
+		if len(term) > 0 && literal < len(term) {
+			cover = float64(literal) / float64(len(term))
+		}
+		return searchFieldWeights[f] * cover
+	})
+}
+
+// scoreField adds the scores of the terms of field f containing p, for docs matched in another field.
+func (q *searchQuery) scoreField(f searchField, p string, docs []uint32) {
+	if p == "" || strings.ContainsAny(p, "*?") || strings.ContainsFunc(p, unicode.IsSpace) {
+		return
+	}
+	q.bm25(f, q.idx.fields[f].termsContaining(p), docs, p)
+}
+
+// boostName raises the documents of docs whose package name or category/name is exactly p.
+func (q *searchQuery) boostName(p string, docs []uint32) {
+	for _, f := range []searchField{searchFieldPackage, searchFieldFullName} {
+		fi := &q.idx.fields[f]
+		t := fi.lookup(p)
+		if t < 0 {
+			continue
+		}
+		for _, d := range intersectPostings(fi.docs([]int{t}), docs) {
+			q.scores[d] += searchNameBoost
+		}
 	}
 }
 
@@ -170,6 +465,8 @@ func (e *SearchEngine) matchField(doc SearchDocument, field string, value string
 		return false
 	case "mask":
 		return doc.Mask == valLower // Already lowercase
+	case "eapi":
+		return doc.EAPI == value
 	case "depend", "depends", "rdepend", "rdepends":
 		for _, d := range doc.Depends {
 			if e.matchWildcard(d, valLower) {
@@ -210,12 +507,12 @@ func (e *SearchEngine) matchSequence(doc SearchDocument, seq string) bool {
 }
 
 func (e *SearchEngine) matchVersion(doc SearchDocument, queryVersion string) bool {
-	v, op := g2.SplitVersionOp(queryVersion)
+	return matchVersionKey(searchVersionKey(&doc), queryVersion)
+}
 
-	docVersionPadded := doc.VersionSortKey
-	if docVersionPadded == "" {
-		docVersionPadded = g2.PadVersion(doc.Version)
-	}
+// matchVersionKey compares the version sort key of a document with a version: query such as ">=1.2".
+func matchVersionKey(docVersionPadded, queryVersion string) bool {
+	v, op := g2.SplitVersionOp(queryVersion)
 	queryVersionPadded := g2.PadVersion(v)
 
 	switch op {
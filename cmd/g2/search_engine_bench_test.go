@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -15,3 +16,27 @@ func BenchmarkSearchEngine_MatchVersion(b *testing.B) {
 		engine.matchVersion(doc, ">1.0.0")
 	}
 }
+
+func BenchmarkSearchEngine_Search(b *testing.B) {
+	var docs []SearchDocument
+	for i := 0; i < 20000; i++ {
+		name := fmt.Sprintf("dev-misc/pkg%d", i)
+		docs = append(docs, SearchDocument{
+			ID:         i + 1,
+			FullName:   name,
+			Package:    fmt.Sprintf("pkg%d", i),
+			Category:   "dev-misc",
+			Mask:       "none",
+			Licenses:   []string{"mit"},
+			SearchText: fmt.Sprintf("%s package number %d for benchmarking", name, i),
+		})
+	}
+	engine := NewSearchEngine()
+	engine.LoadDocuments(docs)
+	engine.Search("pkg1")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.Search("pkg123 license:mit -mask:hard")
+	}
+}
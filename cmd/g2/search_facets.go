@@ -0,0 +1,62 @@
+package main
+
+import "sort"
+
+// SearchFacet is the number of documents holding each value of a field, for narrowing a search.
+type SearchFacet struct {
+	Name   string             `json:"name"`
+	Field  string             `json:"field"`
+	Values []SearchFacetValue `json:"values"`
+}
+
+// SearchFacetValue is a value of a facet and the number of documents that have it.
+type SearchFacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// searchFacetFields are the facets counted by countSearchFacets, with the query field each narrows by.
+var searchFacetFields = []struct {
+	name, field string
+	values      func(doc *SearchDocument) []string
+}{
+	{"Category", "category", func(doc *SearchDocument) []string { return []string{doc.Category} }},
+	{"Overlay", "overlay", func(doc *SearchDocument) []string { return []string{doc.Overlay} }},
+	{"License", "license", func(doc *SearchDocument) []string { return doc.Licenses }},
+	{"EAPI", "eapi", func(doc *SearchDocument) []string { return []string{doc.EAPI} }},
+	{"Arch", "arch", func(doc *SearchDocument) []string { return doc.Arches }},
+	{"Mask", "mask", func(doc *SearchDocument) []string { return []string{doc.Mask} }},
+}
+
+// countSearchFacets counts the documents holding each value of the category, overlay, license, EAPI,
+// arch and mask fields of docs. A document counts once per distinct value and empty values are skipped.
+// Values are ordered by count, most common first, then by value.
+func countSearchFacets(docs []SearchDocument) []SearchFacet {
+	facets := make([]SearchFacet, 0, len(searchFacetFields))
+	for _, ff := range searchFacetFields {
+		counts := map[string]int{}
+		for i := range docs {
+			seen := map[string]bool{}
+			for _, v := range ff.values(&docs[i]) {
+				if v == "" || seen[v] {
+					continue
+				}
+				seen[v] = true
+				counts[v]++
+			}
+		}
+		facet := SearchFacet{Name: ff.name, Field: ff.field, Values: make([]SearchFacetValue, 0, len(counts))}
+		for v, c := range counts {
+			facet.Values = append(facet.Values, SearchFacetValue{Value: v, Count: c})
+		}
+		sort.Slice(facet.Values, func(i, j int) bool {
+			a, b := facet.Values[i], facet.Values[j]
+			if a.Count != b.Count {
+				return a.Count > b.Count
+			}
+			return a.Value < b.Value
+		})
+		facets = append(facets, facet)
+	}
+	return facets
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// searchSuggestions is the most "did you mean" queries Suggest returns.
+const searchSuggestions = 3
+
+// searchSuggestFields are the dictionaries searched for the correction of a field: term.
+var searchSuggestFields = map[string]searchField{
+	"category":    searchFieldCategory,
+	"package":     searchFieldPackage,
+	"name":        searchFieldFullName,
+	"fullname":    searchFieldFullName,
+	"overlay":     searchFieldOverlay,
+	"license":     searchFieldLicense,
+	"use":         searchFieldUse,
+	"desc":        searchFieldDescription,
+	"description": searchFieldDescription,
+}
+
+// Suggest returns "did you mean" queries for a query that finds nothing. Each plain term or field term
+// that matches nothing by itself is replaced by the closest term of its field within a few edits,
+// counting a swap of neighbouring letters as one; plain terms are corrected to package names before
+// other words. The first suggestion uses the best correction of every term and the others try the
+// next corrections of the first misspelled term.
+func (e *SearchEngine) Suggest(query string) []string {
+	if strings.TrimSpace(query) == "" {
+		return nil
+	}
+	ast := NewSearchParser(query).Parse()
+	if ast == nil {
+		return nil
+	}
+	idx := e.readIndex()
+	defer e.mu.RUnlock()
+	q := &searchQuery{engine: e, idx: idx, scores: map[uint32]float64{}}
+
+	type correction struct {
+		old     string
+		choices []string
+	}
+	var corrections []correction
+	var walk func(n *ASTNode)
+	walk = func(n *ASTNode) {
+		if n == nil {
+			return
+		}
+		switch n.Type {
+		case AND, OR:
+			walk(n.Left)
+			walk(n.Right)
+		case GROUP:
+			walk(n.Expr)
+		case TERM:
+			if len(q.term(n.Value, false)) > 0 {
+				return
+			}
+			choices := q.closestTerms(searchFieldPackage, n.Value)
+			if len(choices) == 0 {
+				choices = q.closestTerms(searchFieldText, n.Value)
+			}
+			if len(choices) > 0 {
+				corrections = append(corrections, correction{old: n.Value, choices: choices})
+			}
+		case FIELD:
+			f, ok := searchSuggestFields[n.Field]
+			if !ok || len(q.field(n.Field, n.Value, false)) > 0 {
+				return
+			}
+			var choices []string
+			for _, c := range q.closestTerms(f, n.Value) {
+				choices = append(choices, n.Field+":"+c)
+			}
+			if len(choices) > 0 {
+				corrections = append(corrections, correction{old: n.Field + ":" + n.Value, choices: choices})
+			}
+		}
+	}
+	walk(ast)
+	if len(corrections) == 0 {
+		return nil
+	}
+
+	var out []string
+	seen := map[string]bool{query: true}
+	for k := 0; k < len(corrections[0].choices) && len(out) < searchSuggestions; k++ {
+		s := query
+		for i, c := range corrections {
+			choice := c.choices[0]
+			if i == 0 {
+				choice = c.choices[k]
+			}
+			s = replaceQueryTerm(s, c.old, choice)
+		}
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// closestTerms returns the terms of field f within searchMaxEdits of value ignoring case, closest and
+// then most common first.
+func (q *searchQuery) closestTerms(f searchField, value string) []string {
+	value = strings.ToLower(value)
+	if len(value) < 3 || strings.ContainsAny(value, "*?") || strings.ContainsFunc(value, unicode.IsSpace) {
+		return nil
+	}
+	maxEdits := searchMaxEdits(value)
+	type candidate struct {
+		term     string
+		distance int
+		df       int
+	}
+	var candidates []candidate
+	fi := &q.idx.fields[f]
+	v := []rune(value)
+	for i := 0; i < fi.nTerms; i++ {
+		term := fi.term(i)
+		if n := len(term) - len(value); n > maxEdits || n < -maxEdits {
+			continue
+		}
+		if d := editDistance(v, []rune(strings.ToLower(string(term))), maxEdits); d <= maxEdits {
+			candidates = append(candidates, candidate{term: string(term), distance: d, df: len(fi.postingsOf(i)) / 8})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.df != b.df {
+			return a.df > b.df
+		}
+		return a.term < b.term
+	})
+	var out []string
+	for _, c := range candidates {
+		if len(out) == searchSuggestions {
+			break
+		}
+		out = append(out, c.term)
+	}
+	return out
+}
+
+// searchMaxEdits is the edit distance allowed when correcting a term of the length of value.
+func searchMaxEdits(value string) int {
+	switch n := len(value); {
+	case n <= 4:
+		return 1
+	case n <= 8:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// editDistance returns the optimal string alignment distance of a and b, the Levenshtein distance with
+// the transposition of two adjacent runes as one edit, or max+1 once it must exceed max.
+func editDistance(a, b []rune, max int) int {
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// replaceQueryTerm replaces the first whitespace separated term of query equal to old, ignoring case
+// and any leading negation or parentheses around it, with replacement.
+func replaceQueryTerm(query, old, replacement string) string {
+	fields := strings.Fields(query)
+	for i, f := range fields {
+		core := strings.TrimLeft(f, "-!(")
+		prefix := f[:len(f)-len(core)]
+		trimmed := strings.TrimRight(core, ")")
+		suffix := core[len(trimmed):]
+		if strings.EqualFold(trimmed, old) {
+			fields[i] = prefix + replacement + suffix
+			return strings.Join(fields, " ")
+		}
+	}
+	return query
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"systemd", "systemd", 2, 0},
+		{"sytsemd", "systemd", 2, 1},
+		{"sysemd", "systemd", 2, 1},
+		{"ollama", "olama", 2, 1},
+		{"kitten", "sitting", 3, 3},
+		{"kitten", "sitting", 1, 2},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b), tt.max); got != tt.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tt.a, tt.b, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestSearchEngineSuggest(t *testing.T) {
+	engine := NewSearchEngine()
+	engine.LoadDocuments(searchTestDocuments())
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"sytsemd", []string{"systemd"}},
+		{"go-tols -mask:hard", []string{"go-tools -mask:hard"}},
+		{"(sytsemd OR cargo-go)", []string{"(systemd OR cargo-go)"}},
+		{"category:dev-lnag", []string{"category:dev-lang"}},
+		{"license:LGLP-2.1", []string{"license:LGPL-2.1"}},
+		{"progamming", []string{"programming"}},
+		// Terms that match, or are too short or too far from any term, are left alone.
+		{"systemd", nil},
+		{"xyz", nil},
+		{"zzzzzzzz", nil},
+		{"sys*xx", nil},
+	}
+	for _, tt := range tests {
+		if got := engine.Suggest(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Suggest(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCountSearchFacets(t *testing.T) {
+	facets := countSearchFacets(searchTestDocuments())
+	want := map[string][]SearchFacetValue{
+		"category": {{"app-misc", 1}, {"dev-go", 1}, {"dev-lang", 1}, {"sys-apps", 1}},
+		"overlay":  {},
+		"license":  {{"bsd", 2}, {"LGPL-2.1", 1}, {"mit", 1}},
+		"eapi":     {},
+		"arch":     {{"amd64", 4}, {"arm64", 1}},
+		"mask":     {{"none", 2}, {"hard", 1}, {"soft", 1}},
+	}
+	if len(facets) != len(want) {
+		t.Fatalf("got %d facets, want %d", len(facets), len(want))
+	}
+	for _, f := range facets {
+		if !reflect.DeepEqual(f.Values, want[f.Field]) {
+			t.Errorf("%s facet = %v, want %v", f.Field, f.Values, want[f.Field])
+		}
+	}
+}
+
+func TestCmdSearchFuzzyAndFacets(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"data/manifest.json": `{"document_count":4,"data_files":[],"index_file":"index.g2s"}`})
+	if err := writeSearchIndexFile(filepath.Join(dir, "data", searchIndexFile), searchTestDocuments()); err != nil {
+		t.Fatalf("writeSearchIndexFile: %v", err)
+	}
+	cfg := &CmdPackageArgConfig{MainArgConfig: &MainArgConfig{}}
+
+	var buf, logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	if err := cfg.cmdSearch([]string{"-path", dir, "-facets", "progamming"}, &buf); err != nil {
+		t.Errorf("search without results: %v", err)
+	}
+	if !strings.Contains(logs.String(), "Found 0 results for 'progamming'") {
+		t.Errorf("search without results logged:\n%s", logs.String())
+	}
+	if buf.String() != "Did you mean: programming?\n" {
+		t.Errorf("search without results printed:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := cfg.cmdSearch([]string{"-path", dir, "-facets", "-fuzzy", "progamming"}, &buf); err != nil {
+		t.Fatalf("search -fuzzy: %v", err)
+	}
+	for _, want := range []string{"Did you mean: programming?\n", "dev-lang/go\n", "\nMask:\n  mask:none (1)\n"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -4,12 +4,11 @@ import (
 	"archive/zip"
 	"encoding/json"
 	"fmt"
-
-	"github.com/arran4/g2/templates"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
+	"sort"
 	"strings"
 
 	"github.com/arran4/g2"
@@ -48,16 +47,24 @@ type SearchDocument struct {
 	ManifestFiles   []string `json:"manifest_files"`
 	SearchText      string   `json:"search_text"`
 	PageURL         string   `json:"page_url"`
+	// The fields above are lower case for searching; these keep the original case, for display and for
+	// writing atoms.
+	DisplayName        string `json:"display_name,omitempty"` // category/name
+	DisplayOverlay     string `json:"display_overlay,omitempty"`
+	DisplayDescription string `json:"display_description,omitempty"`
 }
 
 type SearchManifest struct {
 	DocumentCount int      `json:"document_count"`
 	DataFiles     []string `json:"data_files"`
+	IndexFile     string   `json:"index_file,omitempty"` // inverted index of the documents, see encodeSearchIndex
 }
 
 var pkgRegex = regexp.MustCompile(`([a-zA-Z0-9_][a-zA-Z0-9_\-\+]*\/[a-zA-Z0-9_][a-zA-Z0-9_\-\+]+)`)
 
-func generateSearchData(outDir, outZip string, sites []*g2.SiteData, maxChunkSizeOverride ...int) error {
+// buildSearchDocuments returns a search document for every ebuild of sites, with the packages that
+// depend on it filled in.
+func buildSearchDocuments(sites []*g2.SiteData) []SearchDocument {
 	var documents []SearchDocument
 	docID := 0
 
@@ -300,37 +307,40 @@ func generateSearchData(outDir, outZip string, sites []*g2.SiteData, maxChunkSiz
 					}
 
 					doc := SearchDocument{
-						ID:              docID,
-						Overlay:         overlayNameLower,
-						Category:        catNameLower,
-						Package:         pkgNameLower,
-						FullName:        fullNameLower,
-						Version:         verStr,
-						Description:     descLower,
-						Urls:            urls,
-						Licenses:        licenses,
-						EAPI:            eapi,
-						Slot:            slot,
-						Inherits:        inherits,
-						Uses:            uses,
-						UseDescriptions: useDescriptions,
-						Keywords:        keywords,
-						Arches:          arches,
-						Mask:            mask,
-						Depends:         depends,
-						Rdepends:        rdepends,
-						Bdepends:        bdepends,
-						Pdepends:        pdepends,
-						RawDepends:      rawDepends,
-						RawRdepends:     rawRdepends,
-						RawBdepends:     rawBdepends,
-						RawPdepends:     rawPdepends,
-						RawRequiredUse:  rawRequiredUse,
-						DependedBy:      []string{},
-						RdependedBy:     []string{},
-						ManifestFiles:   manifestFiles,
-						SearchText:      searchText,
-						PageURL:         fmt.Sprintf("../repos/%s/categories/%s/packages/%s/ebuild/%s/index.html", site.RepoName, cat.Name, pkg.Name, verStr),
+						ID:                 docID,
+						Overlay:            overlayNameLower,
+						Category:           catNameLower,
+						Package:            pkgNameLower,
+						FullName:           fullNameLower,
+						Version:            verStr,
+						Description:        descLower,
+						Urls:               urls,
+						Licenses:           licenses,
+						EAPI:               eapi,
+						Slot:               slot,
+						Inherits:           inherits,
+						Uses:               uses,
+						UseDescriptions:    useDescriptions,
+						Keywords:           keywords,
+						Arches:             arches,
+						Mask:               mask,
+						Depends:            depends,
+						Rdepends:           rdepends,
+						Bdepends:           bdepends,
+						Pdepends:           pdepends,
+						RawDepends:         rawDepends,
+						RawRdepends:        rawRdepends,
+						RawBdepends:        rawBdepends,
+						RawPdepends:        rawPdepends,
+						RawRequiredUse:     rawRequiredUse,
+						DependedBy:         []string{},
+						RdependedBy:        []string{},
+						ManifestFiles:      manifestFiles,
+						SearchText:         searchText,
+						PageURL:            fmt.Sprintf("../repos/%s/categories/%s/packages/%s/ebuild/%s/index.html", site.RepoName, cat.Name, pkg.Name, verStr),
+						DisplayName:        fullName,
+						DisplayOverlay:     overlayName,
+						DisplayDescription: desc,
 					}
 
 					doc.VersionSortKey = g2.PadVersionTokens(verStr)
@@ -353,6 +363,15 @@ func generateSearchData(outDir, outZip string, sites []*g2.SiteData, maxChunkSiz
 			documents[i].RdependedBy = deps
 		}
 	}
+	return documents
+}
+
+// generateSearchData writes the search documents and the partitioned index of sites, read by the search
+// page, into the data directory below outDir and into the zip file outZip, when set. withIndex also
+// writes the index file the command line search maps into memory to outDir; it embeds every document
+// again, so it is left out of published sites.
+func generateSearchData(outDir, outZip string, sites []*g2.SiteData, withIndex bool, maxChunkSizeOverride ...int) error {
+	documents := buildSearchDocuments(sites)
 
 	// Build inverted index mapping token -> []int (doc IDs)
 	invertedIndex := make(map[string][]int)
@@ -427,6 +446,16 @@ func generateSearchData(outDir, outZip string, sites []*g2.SiteData, maxChunkSiz
 			}
 		}
 
+		for _, sf := range searchSummaryFiles(documents) {
+			w, err := z.Create("data/" + sf.name)
+			if err != nil {
+				return fmt.Errorf("creating %s in zip: %w", sf.name, err)
+			}
+			if err := json.NewEncoder(w).Encode(sf.value); err != nil {
+				return fmt.Errorf("encoding %s: %w", sf.name, err)
+			}
+		}
+
 		manifest := SearchManifest{
 			DocumentCount: len(documents),
 			DataFiles:     dataFiles,
@@ -500,10 +529,29 @@ func generateSearchData(outDir, outZip string, sites []*g2.SiteData, maxChunkSiz
 			}
 		}
 
+		for _, sf := range searchSummaryFiles(documents) {
+			b, err := json.Marshal(sf.value)
+			if err != nil {
+				return fmt.Errorf("encoding %s: %w", sf.name, err)
+			}
+			if err := os.WriteFile(filepath.Join(dataDir, sf.name), append(b, '\n'), 0644); err != nil {
+				return fmt.Errorf("writing %s: %w", sf.name, err)
+			}
+		}
+
 		manifest := SearchManifest{
 			DocumentCount: len(documents),
 			DataFiles:     dataFiles,
 		}
+		indexPath := filepath.Join(dataDir, searchIndexFile)
+		if withIndex {
+			if err := writeSearchIndexFile(indexPath, documents); err != nil {
+				return fmt.Errorf("writing search index: %w", err)
+			}
+			manifest.IndexFile = searchIndexFile
+		} else if err := os.Remove(indexPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing stale search index: %w", err)
+		}
 		manifestPath := filepath.Join(dataDir, "manifest.json")
 		mf, err := os.Create(manifestPath)
 		if err != nil {
@@ -528,13 +576,13 @@ func generateSearchIndex(outDir string, sites []*g2.SiteData) error {
 		return fmt.Errorf("creating search data directory: %w", err)
 	}
 
-	if err := generateSearchData(searchDir, "", sites); err != nil {
+	if err := generateSearchData(searchDir, "", sites, false); err != nil {
 		return fmt.Errorf("generating search data: %w", err)
 	}
 
 	jsFiles := []string{"search_parser.js", "search.js", "search_ui.js"}
 	for _, jsFile := range jsFiles {
-		content, err := templates.SiteFS.ReadFile("site/" + jsFile)
+		content, err := readSiteAsset("site/" + jsFile)
 		if err != nil {
 			return fmt.Errorf("reading template js %s: %w", jsFile, err)
 		}
@@ -576,6 +624,31 @@ func nextToken(s string) (token, rest string) {
 	return s[:end], s[end:]
 }
 
+// searchDataFile is a JSON file written below data/ of the search output.
+type searchDataFile struct {
+	name  string
+	value any
+}
+
+// searchSummaryFiles returns the data files the site search UI loads whole besides the index:
+// facets.json, the facet counts of every document, and names.json, the sorted package names that
+// "did you mean" suggestions are drawn from.
+func searchSummaryFiles(documents []SearchDocument) []searchDataFile {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, doc := range documents {
+		if !seen[doc.FullName] {
+			seen[doc.FullName] = true
+			names = append(names, doc.FullName)
+		}
+	}
+	sort.Strings(names)
+	return []searchDataFile{
+		{"facets.json", countSearchFacets(documents)},
+		{"names.json", names},
+	}
+}
+
 func getBucket(token string) string {
 	val := token
 	if idx := strings.Index(token, ":"); idx != -1 {
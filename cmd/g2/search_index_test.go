@@ -1,6 +1,7 @@
 package main
 
 import (
+	"archive/zip"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -90,6 +91,12 @@ func TestGenerateSearchIndex(t *testing.T) {
 	if manifest.DocumentCount != 1 {
 		t.Errorf("Expected 1 document, got %d", manifest.DocumentCount)
 	}
+	if manifest.IndexFile != "" {
+		t.Errorf("Expected the site manifest to name no index file, got %q", manifest.IndexFile)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, searchIndexFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected no %s in the published site: %v", searchIndexFile, err)
+	}
 
 	// Verify docs (ID should be 1)
 	docsPath := filepath.Join(dataDir, "docs", "1.json")
@@ -115,6 +122,9 @@ func TestGenerateSearchIndex(t *testing.T) {
 	if doc.Description != "a test package for testing" {
 		t.Errorf("Expected Description 'A test package for testing', got %s", doc.Description)
 	}
+	if doc.DisplayDescription != "A test package for testing" || doc.DisplayOverlay != "test-repo" {
+		t.Errorf("Expected the original case to be kept, got %q, %q", doc.DisplayDescription, doc.DisplayOverlay)
+	}
 	if len(doc.Licenses) < 3 || doc.Licenses[0] != "mit" || doc.Licenses[1] != "free" || doc.Licenses[2] != "osi-approved" {
 		t.Errorf("Expected license MIT, FREE, OSI-APPROVED, got %v", doc.Licenses)
 	}
@@ -127,4 +137,57 @@ func TestGenerateSearchIndex(t *testing.T) {
 	if doc.VersionSortKey == "" {
 		t.Errorf("Expected VersionSortKey to be populated, got empty string")
 	}
+
+	var names []string
+	if b, err := os.ReadFile(filepath.Join(dataDir, "names.json")); err != nil || json.Unmarshal(b, &names) != nil || len(names) != 1 || names[0] != "app-test/test-pkg" {
+		t.Errorf("names.json = %v (%v)", names, err)
+	}
+	var facets []SearchFacet
+	if b, err := os.ReadFile(filepath.Join(dataDir, "facets.json")); err != nil || json.Unmarshal(b, &facets) != nil || len(facets) != 6 ||
+		facets[0].Field != "category" || len(facets[0].Values) != 1 || facets[0].Values[0] != (SearchFacetValue{Value: "app-test", Count: 1}) {
+		t.Errorf("facets.json = %+v (%v)", facets, err)
+	}
+
+	// The command line index keeps an index file next to the documents and loads from it.
+	cliDir := t.TempDir()
+	if err := generateSearchData(cliDir, "", sites, true); err != nil {
+		t.Fatalf("generateSearchData: %v", err)
+	}
+	mBytes, err = os.ReadFile(filepath.Join(cliDir, "data", "manifest.json"))
+	if err != nil || json.Unmarshal(mBytes, &manifest) != nil || manifest.IndexFile != searchIndexFile {
+		t.Fatalf("command line manifest = %s (%v)", mBytes, err)
+	}
+	engine := NewSearchEngine()
+	defer func() { _ = engine.Close() }()
+	if err := LoadSearchEngine(cliDir, engine); err != nil {
+		t.Fatalf("LoadSearchEngine: %v", err)
+	}
+	if results := engine.Search("test-pkg"); len(results) != 1 {
+		t.Errorf("search of the command line index = %v", results)
+	}
+	siteEngine := NewSearchEngine()
+	defer func() { _ = siteEngine.Close() }()
+	if err := LoadSearchEngine(searchDir, siteEngine); err != nil {
+		t.Fatalf("LoadSearchEngine of the site: %v", err)
+	}
+	if results := siteEngine.Search("test-pkg"); len(results) != 1 {
+		t.Errorf("search of the site documents = %v", results)
+	}
+
+	zipPath := filepath.Join(tmpDir, "search.zip")
+	if err := generateSearchData("", zipPath, sites, false); err != nil {
+		t.Fatalf("generateSearchData zip: %v", err)
+	}
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = zr.Close() }()
+	for _, name := range []string{"data/names.json", "data/facets.json", "data/manifest.json"} {
+		if f, err := zr.Open(name); err != nil {
+			t.Errorf("zip lacks %s: %v", name, err)
+		} else {
+			_ = f.Close()
+		}
+	}
 }
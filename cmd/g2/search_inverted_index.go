@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+// searchField is a field of SearchDocument with its own term dictionary and postings in the index.
+type searchField int
+
+const (
+	searchFieldText        searchField = iota // SearchText, for unqualified terms
+	searchFieldFullName                       // name:, fullname:
+	searchFieldCategory                       // category:
+	searchFieldPackage                        // package:
+	searchFieldDescription                    // desc:, description:
+	searchFieldUse                            // use: flags and their descriptions
+	searchFieldLicense                        // license:
+	searchFieldKeyword                        // keyword:, arch: keywords and arches
+	searchFieldDepend                         // depend:, rdepend: atoms of DEPEND and RDEPEND
+	searchFieldOverlay                        // overlay:
+	searchFieldMask                           // mask:
+	searchFieldEAPI                           // eapi:
+	numSearchFields
+)
+
+// searchFieldWeights scale the BM25 score of a match in each field.
+var searchFieldWeights = [numSearchFields]float64{
+	searchFieldText:        1,
+	searchFieldFullName:    2,
+	searchFieldCategory:    1,
+	searchFieldPackage:     2,
+	searchFieldDescription: 1,
+	searchFieldUse:         0.5,
+	searchFieldLicense:     0.5,
+	searchFieldKeyword:     0.5,
+	searchFieldDepend:      0.5,
+	searchFieldOverlay:     0.5,
+	searchFieldMask:        0.5,
+	searchFieldEAPI:        0.5,
+}
+
+// multiWord reports whether the values of f hold several words, so that a pattern can match across
+// the boundary of two terms of its dictionary.
+func (f searchField) multiWord() bool {
+	return f == searchFieldText || f == searchFieldDescription || f == searchFieldUse
+}
+
+// searchFieldValues returns the values of f in doc; the terms of the field are their words.
+func searchFieldValues(doc *SearchDocument, f searchField) []string {
+	switch f {
+	case searchFieldText:
+		return []string{doc.SearchText}
+	case searchFieldFullName:
+		return []string{doc.FullName}
+	case searchFieldCategory:
+		return []string{doc.Category}
+	case searchFieldPackage:
+		return []string{doc.Package}
+	case searchFieldDescription:
+		return []string{doc.Description}
+	case searchFieldUse:
+		return append(append([]string(nil), doc.Uses...), doc.UseDescriptions...)
+	case searchFieldLicense:
+		return doc.Licenses
+	case searchFieldKeyword:
+		return append(append([]string(nil), doc.Keywords...), doc.Arches...)
+	case searchFieldDepend:
+		return append(append([]string(nil), doc.Depends...), doc.Rdepends...)
+	case searchFieldOverlay:
+		return []string{doc.Overlay}
+	case searchFieldMask:
+		return []string{doc.Mask}
+	case searchFieldEAPI:
+		return []string{doc.EAPI}
+	}
+	return nil
+}
+
+// The search index is a byte layout that is used in place, whether it was built in memory or mapped
+// from a file: an 8 byte magic, the document count and section count as uint32s, then the offset and
+// length of every section as uint64s. All integers are little endian and sections are 8 byte aligned.
+// The sections are the document offsets and JSON documents, the version sort key offsets and keys, and
+// for each field its term offsets and sorted terms, postings offsets, postings of (document, term
+// frequency) uint32 pairs, and the term count of each document.
+const (
+	searchIndexMagic       = "G2SIDX\x00\x02"
+	searchIndexDocSections = 4
+	searchIndexFieldSecs   = 5
+	searchIndexSections    = searchIndexDocSections + int(numSearchFields)*searchIndexFieldSecs
+)
+
+// searchIndexFile is the file name of the index below a search data directory.
+const searchIndexFile = "index.g2s"
+
+// searchIndex is an inverted index over search documents.
+type searchIndex struct {
+	data    []byte
+	release func() error
+	nDocs   int
+	docOff  []byte // nDocs+1 uint64 offsets into docs
+	docs    []byte
+	verOff  []byte // nDocs+1 uint32 offsets into vers
+	vers    []byte
+	fields  [numSearchFields]searchFieldIndex
+}
+
+// searchFieldIndex is the dictionary and postings of one field.
+type searchFieldIndex struct {
+	nTerms   int
+	termOff  []byte // nTerms+1 uint32 offsets into terms
+	terms    []byte
+	postOff  []byte // nTerms+1 uint32 entry offsets into postings
+	postings []byte // (doc, tf) uint32 pairs
+	docLen   []byte // nDocs uint32
+	avgLen   float64
+}
+
+type searchPosting struct {
+	doc uint32
+	tf  uint32
+}
+
+func u32(b []byte, i int) uint32 { return binary.LittleEndian.Uint32(b[i*4:]) }
+func u64(b []byte, i int) uint64 { return binary.LittleEndian.Uint64(b[i*8:]) }
+
+// encodeSearchIndex builds the index of docs, storing stored, their JSON encodings, when not nil. An
+// engine that holds the documents in memory leaves them out.
+func encodeSearchIndex(docs []SearchDocument, stored [][]byte) []byte {
+	le := binary.LittleEndian
+	sections := make([][]byte, 0, searchIndexSections)
+
+	docOff := le.AppendUint64(nil, 0)
+	var docBlob []byte
+	for i := range docs {
+		if stored != nil {
+			docBlob = append(docBlob, stored[i]...)
+		}
+		docOff = le.AppendUint64(docOff, uint64(len(docBlob)))
+	}
+	verOff := le.AppendUint32(nil, 0)
+	var verBlob []byte
+	for i := range docs {
+		verBlob = append(verBlob, searchVersionKey(&docs[i])...)
+		verOff = le.AppendUint32(verOff, uint32(len(verBlob)))
+	}
+	sections = append(sections, docOff, docBlob, verOff, verBlob)
+
+	for f := searchField(0); f < numSearchFields; f++ {
+		// Documents are visited in order, so every postings list is sorted by document.
+		postings := map[string][]searchPosting{}
+		docLen := make([]byte, 0, 4*len(docs))
+		for i := range docs {
+			counts := map[string]uint32{}
+			n := 0
+			for _, v := range searchFieldValues(&docs[i], f) {
+				for _, t := range strings.Fields(v) {
+					counts[t]++
+					n++
+				}
+			}
+			docLen = le.AppendUint32(docLen, uint32(n))
+			for t, tf := range counts {
+				postings[t] = append(postings[t], searchPosting{doc: uint32(i), tf: tf})
+			}
+		}
+		terms := make([]string, 0, len(postings))
+		for t := range postings {
+			terms = append(terms, t)
+		}
+		sort.Strings(terms)
+
+		termOff := le.AppendUint32(nil, 0)
+		postOff := le.AppendUint32(nil, 0)
+		var termBlob, postBlob []byte
+		entries := 0
+		for _, t := range terms {
+			termBlob = append(termBlob, t...)
+			termOff = le.AppendUint32(termOff, uint32(len(termBlob)))
+			for _, p := range postings[t] {
+				postBlob = le.AppendUint32(le.AppendUint32(postBlob, p.doc), p.tf)
+			}
+			entries += len(postings[t])
+			postOff = le.AppendUint32(postOff, uint32(entries))
+		}
+		sections = append(sections, termOff, termBlob, postOff, postBlob, docLen)
+	}
+
+	headerLen := len(searchIndexMagic) + 8 + 16*len(sections)
+	out := make([]byte, 0, headerLen)
+	out = append(out, searchIndexMagic...)
+	out = le.AppendUint32(le.AppendUint32(out, uint32(len(docs))), uint32(len(sections)))
+	offset := uint64(align8(headerLen))
+	for _, sec := range sections {
+		out = le.AppendUint64(le.AppendUint64(out, offset), uint64(len(sec)))
+		offset += uint64(align8(len(sec)))
+	}
+	for _, sec := range sections {
+		out = append(out, make([]byte, align8(len(out))-len(out))...)
+		out = append(out, sec...)
+	}
+	return out
+}
+
+func align8(n int) int { return (n + 7) &^ 7 }
+
+// openSearchIndex reads the index held in data; release, if not nil, is called by close.
+func openSearchIndex(data []byte, release func() error) (*searchIndex, error) {
+	headerLen := len(searchIndexMagic) + 8
+	if len(data) < headerLen || string(data[:len(searchIndexMagic)]) != searchIndexMagic {
+		return nil, fmt.Errorf("not a search index or an unsupported version")
+	}
+	nDocs := int(u32(data[len(searchIndexMagic):], 0))
+	nSections := int(u32(data[len(searchIndexMagic):], 1))
+	if nSections != searchIndexSections || len(data) < headerLen+16*nSections {
+		return nil, fmt.Errorf("search index has %d sections, want %d", nSections, searchIndexSections)
+	}
+	sections := make([][]byte, nSections)
+	for i := range sections {
+		off, n := u64(data[headerLen:], 2*i), u64(data[headerLen:], 2*i+1)
+		if off > uint64(len(data)) || n > uint64(len(data))-off {
+			return nil, fmt.Errorf("search index section %d is out of range", i)
+		}
+		sections[i] = data[off : off+n]
+	}
+
+	idx := &searchIndex{data: data, release: release, nDocs: nDocs,
+		docOff: sections[0], docs: sections[1], verOff: sections[2], vers: sections[3]}
+	if len(idx.docOff) != 8*(nDocs+1) || len(idx.verOff) != 4*(nDocs+1) {
+		return nil, fmt.Errorf("search index document tables do not match %d documents", nDocs)
+	}
+	for f := range idx.fields {
+		s := sections[searchIndexDocSections+f*searchIndexFieldSecs:]
+		fi := searchFieldIndex{termOff: s[0], terms: s[1], postOff: s[2], postings: s[3], docLen: s[4]}
+		fi.nTerms = len(fi.termOff)/4 - 1
+		if fi.nTerms < 0 || len(fi.postOff) != len(fi.termOff) || len(fi.docLen) != 4*nDocs ||
+			int(u32(fi.postOff, fi.nTerms))*8 != len(fi.postings) || int(u32(fi.termOff, fi.nTerms)) != len(fi.terms) {
+			return nil, fmt.Errorf("search index field %d is malformed", f)
+		}
+		total := 0.0
+		for d := 0; d < nDocs; d++ {
+			total += float64(u32(fi.docLen, d))
+		}
+		if nDocs > 0 {
+			fi.avgLen = total / float64(nDocs)
+		}
+		idx.fields[f] = fi
+	}
+	return idx, nil
+}
+
+// loadSearchIndexFile maps the index file at path into memory.
+func loadSearchIndexFile(path string) (*searchIndex, error) {
+	data, release, err := mmapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	idx, err := openSearchIndex(data, release)
+	if err != nil {
+		_ = release()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return idx, nil
+}
+
+// writeSearchIndexFile writes the index of docs, including the documents, to path.
+func writeSearchIndexFile(path string, docs []SearchDocument) error {
+	stored := make([][]byte, len(docs))
+	for i := range docs {
+		b, err := json.Marshal(&docs[i])
+		if err != nil {
+			return fmt.Errorf("encoding search doc %d: %w", docs[i].ID, err)
+		}
+		stored[i] = b
+	}
+	return g2.SafeWriteFileAtomic(path, encodeSearchIndex(docs, stored), 0644)
+}
+
+func (idx *searchIndex) close() error {
+	if idx.release == nil {
+		return nil
+	}
+	release := idx.release
+	idx.release, idx.data = nil, nil
+	return release()
+}
+
+// hasDocuments reports whether the index stores the documents themselves.
+func (idx *searchIndex) hasDocuments() bool {
+	return idx.nDocs == 0 || len(idx.docs) > 0
+}
+
+// document decodes the stored document d.
+func (idx *searchIndex) document(d uint32) (SearchDocument, error) {
+	var doc SearchDocument
+	err := json.Unmarshal(idx.docs[u64(idx.docOff, int(d)):u64(idx.docOff, int(d)+1)], &doc)
+	return doc, err
+}
+
+// versionKey returns the padded version sort key of document d.
+func (idx *searchIndex) versionKey(d uint32) string {
+	return string(idx.vers[u32(idx.verOff, int(d)):u32(idx.verOff, int(d)+1)])
+}
+
+func (fi *searchFieldIndex) term(i int) []byte {
+	return fi.terms[u32(fi.termOff, i):u32(fi.termOff, i+1)]
+}
+
+// postingsOf returns the postings of term i.
+func (fi *searchFieldIndex) postingsOf(i int) []byte {
+	return fi.postings[8*int(u32(fi.postOff, i)) : 8*int(u32(fi.postOff, i+1))]
+}
+
+// lookup returns the index of the term equal to t, or -1.
+func (fi *searchFieldIndex) lookup(t string) int {
+	key := []byte(t)
+	i := sort.Search(fi.nTerms, func(i int) bool { return bytes.Compare(fi.term(i), key) >= 0 })
+	if i < fi.nTerms && bytes.Equal(fi.term(i), key) {
+		return i
+	}
+	return -1
+}
+
+// matchTerms returns the terms for which match is true.
+func (fi *searchFieldIndex) matchTerms(match func(term []byte) bool) []int {
+	var out []int
+	for i := 0; i < fi.nTerms; i++ {
+		if match(fi.term(i)) {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// termsContaining returns the terms that contain s.
+func (fi *searchFieldIndex) termsContaining(s string) []int {
+	key := []byte(s)
+	return fi.matchTerms(func(term []byte) bool { return bytes.Contains(term, key) })
+}
+
+// docs returns the sorted documents holding any of terms.
+func (fi *searchFieldIndex) docs(terms []int) []uint32 {
+	var out []uint32
+	for _, t := range terms {
+		p := fi.postingsOf(t)
+		for j := 0; j < len(p)/8; j++ {
+			out = append(out, u32(p, 2*j))
+		}
+	}
+	if len(terms) > 1 {
+		slices.Sort(out)
+		out = slices.Compact(out)
+	}
+	return out
+}
+
+// bm25 adds to scores the BM25 score of terms for the documents of keep, scaled by weight.
+func (fi *searchFieldIndex) bm25(scores map[uint32]float64, nDocs int, terms []int, keep []uint32, weight func(term []byte) float64) {
+	const k1, b = 1.2, 0.75
+	for _, t := range terms {
+		p := fi.postingsOf(t)
+		df := float64(len(p) / 8)
+		idf := math.Log(1 + (float64(nDocs)-df+0.5)/(df+0.5))
+		w := idf * weight(fi.term(t))
+		k := 0
+		for j := 0; j < len(p)/8; j++ {
+			d, tf := u32(p, 2*j), float64(u32(p, 2*j+1))
+			for k < len(keep) && keep[k] < d {
+				k++
+			}
+			if k == len(keep) {
+				break
+			}
+			if keep[k] != d {
+				continue
+			}
+			norm := 1.0
+			if fi.avgLen > 0 {
+				norm = 1 - b + b*float64(u32(fi.docLen, int(d)))/fi.avgLen
+			}
+			scores[d] += w * tf * (k1 + 1) / (tf + k1*norm)
+		}
+	}
+}
+
+func intersectPostings(a, b []uint32) []uint32 {
+	out := make([]uint32, 0, min(len(a), len(b)))
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func unionPostings(a, b []uint32) []uint32 {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	out := make([]uint32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
+
+// differencePostings returns the documents of a that are not in b.
+func differencePostings(a, b []uint32) []uint32 {
+	out := make([]uint32, 0, len(a))
+	j := 0
+	for _, d := range a {
+		for j < len(b) && b[j] < d {
+			j++
+		}
+		if j == len(b) || b[j] != d {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// searchVersionKey returns the key that version: comparisons use for doc.
+func searchVersionKey(doc *SearchDocument) string {
+	if doc.VersionSortKey != "" {
+		return doc.VersionSortKey
+	}
+	return g2.PadVersion(doc.Version)
+}
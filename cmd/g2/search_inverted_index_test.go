@@ -0,0 +1,142 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func searchTestDocuments() []SearchDocument {
+	return []SearchDocument{
+		{ID: 1, FullName: "dev-go/go-tools", Category: "dev-go", Package: "go-tools", Version: "0.20.0", Description: "developer tools for the go language",
+			SearchText: "dev-go/go-tools developer tools for the go language", Licenses: []string{"bsd"}, Mask: "soft", Keywords: []string{"~amd64"}, Arches: []string{"amd64"}},
+		{ID: 2, FullName: "app-misc/cargo-go", Category: "app-misc", Package: "cargo-go", Version: "1.0", Description: "moves cargo around",
+			SearchText: "app-misc/cargo-go moves cargo around", Licenses: []string{"mit"}, Mask: "none", Keywords: []string{"amd64"}, Arches: []string{"amd64"}},
+		{ID: 3, FullName: "dev-lang/go", Category: "dev-lang", Package: "go", Version: "1.22.1", Description: "the go programming language",
+			SearchText: "dev-lang/go the go programming language", Licenses: []string{"bsd"}, Mask: "none", Keywords: []string{"amd64", "~arm64"}, Arches: []string{"amd64", "arm64"},
+			Uses: []string{"cgo"}, UseDescriptions: []string{"build with c support"}},
+		{ID: 4, FullName: "sys-apps/systemd", Category: "sys-apps", Package: "systemd", Version: "254", Description: "system and service manager",
+			SearchText: "sys-apps/systemd system and service manager", Licenses: []string{"LGPL-2.1"}, Mask: "hard", Keywords: []string{"-amd64"}, Arches: []string{"amd64"},
+			Depends: []string{"dev-lang/go"}},
+	}
+}
+
+func searchResultIDs(docs []SearchDocument) []int {
+	ids := make([]int, len(docs))
+	for i, d := range docs {
+		ids[i] = d.ID
+	}
+	return ids
+}
+
+func TestSearchEngineRanking(t *testing.T) {
+	engine := NewSearchEngine()
+	engine.LoadDocuments(searchTestDocuments())
+
+	tests := []struct {
+		query   string
+		want    []int
+		ordered bool // otherwise compared as a set
+	}{
+		// The exact package name ranks first, then name matches above description matches.
+		{"go", []int{3, 1, 2}, true},
+		{"package:go", []int{3, 1, 2}, true},
+		{"language", []int{3, 1}, true},
+		{"go -mask:soft", []int{3, 2}, true},
+		// Wildcards may span words of multi-word fields, but not of single values.
+		{"developer*language", []int{1}, false},
+		{"category:dev-*", []int{1, 3}, false},
+		{"use:\"with c\"", []int{3}, false},
+		{"'tools go'", []int{1}, false},
+		{"license:bsd OR depends:dev-lang/go", []int{1, 3, 4}, false},
+		{"version:>=1.0 arch:arm64", []int{3}, false},
+		{"mask:hard", []int{4}, false},
+		{"nothing-matches", []int{}, false},
+	}
+	for _, tt := range tests {
+		got := searchResultIDs(engine.Search(tt.query))
+		if !tt.ordered {
+			slices.Sort(got)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	if got := searchResultIDs(engine.Search("")); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("empty query = %v", got)
+	}
+}
+
+func TestSearchIndexFile(t *testing.T) {
+	docs := searchTestDocuments()
+	path := filepath.Join(t.TempDir(), searchIndexFile)
+	if err := writeSearchIndexFile(path, docs); err != nil {
+		t.Fatalf("writeSearchIndexFile: %v", err)
+	}
+
+	mapped := NewSearchEngine()
+	if err := mapped.LoadIndexFile(path); err != nil {
+		t.Fatalf("LoadIndexFile: %v", err)
+	}
+	defer func() { _ = mapped.Close() }()
+	memory := NewSearchEngine()
+	memory.LoadDocuments(docs)
+
+	for _, q := range []string{"", "go", "developer*language", "'go language'", "!mask:none", "version:<100", "keyword:~*"} {
+		got, want := mapped.Search(q), memory.Search(q)
+		if !slices.Equal(searchResultIDs(got), searchResultIDs(want)) {
+			t.Errorf("Search(%q) from file = %v, in memory %v", q, searchResultIDs(got), searchResultIDs(want))
+		}
+	}
+	if res := mapped.Search("systemd"); len(res) != 1 || res[0].Description != "system and service manager" || res[0].Depends[0] != "dev-lang/go" {
+		t.Errorf("stored document = %+v", res)
+	}
+
+	// Loading more documents keeps those of the file.
+	mapped.LoadDocuments([]SearchDocument{{ID: 5, FullName: "app-misc/extra", Package: "extra", SearchText: "app-misc/extra"}})
+	if got := searchResultIDs(mapped.Search("")); len(got) != 5 || got[4] != 5 {
+		t.Errorf("after LoadDocuments = %v", got)
+	}
+
+	if _, err := openSearchIndex([]byte("not an index"), nil); err == nil {
+		t.Error("expected an error for a file that is not an index")
+	}
+}
+
+func TestSearchEngineConcurrentLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), searchIndexFile)
+	if err := writeSearchIndexFile(path, searchTestDocuments()); err != nil {
+		t.Fatal(err)
+	}
+	engine := NewSearchEngine()
+	defer func() { _ = engine.Close() }()
+	if err := engine.LoadIndexFile(path); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				engine.Search("go")
+				engine.Search("")
+				engine.Suggest("sytsemd")
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			engine.LoadDocuments([]SearchDocument{{ID: 10 + i, FullName: "app-misc/extra", Package: "extra", SearchText: "app-misc/extra"}})
+		}
+	}()
+	wg.Wait()
+	if got := len(engine.Search("")); got != len(searchTestDocuments())+50 {
+		t.Errorf("got %d documents after concurrent loads", got)
+	}
+}
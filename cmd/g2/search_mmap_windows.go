@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+)
+
+// mmapFile reads the file at path into memory; there is nothing to release.
+func mmapFile(path string) (data []byte, release func() error, err error) {
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+// CmdSetsArgConfig holds the locations used by the sets subcommands.
+type CmdSetsArgConfig struct {
+	*MainArgConfig
+	ConfigRoot string
+	ReposConf  string
+	RepoDirs   StringSliceFlag
+	WorldFile  string
+	WorldSets  string
+}
+
+func (cfg *MainArgConfig) cmdSets(args []string) error {
+	c := &CmdSetsArgConfig{MainArgConfig: cfg}
+	fs := flag.NewFlagSet("sets", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Printf("Usage: g2 sets [flags] <subcommand>\n")
+		fmt.Printf("\t\t %s \t\t %s\n", "list", "list user sets and whether they are in world_sets")
+		fmt.Printf("\t\t %s \t\t %s\n", "show <set>", "print the atoms of a set, including @world and @system")
+		fmt.Printf("\t\t %s \t\t %s\n", "add [-world] <set> [<atom>...]", "add atoms to a set, and with -world the set to world_sets")
+		fmt.Printf("\t\t %s \t\t %s\n", "remove [-world] <set> [<atom>...]", "remove atoms from a set, or the whole set and its world_sets entry")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&c.ConfigRoot, "config-root", "/etc/portage", "Path to portage config root")
+	fs.StringVar(&c.ReposConf, "repos-conf", "", "Path to repos.conf (default: <config-root>/repos.conf)")
+	fs.Var(&c.RepoDirs, "repo", "Path to a repository for the @system profile lookup (repeatable)")
+	fs.StringVar(&c.WorldFile, "world", "/var/lib/portage/world", "Path to the world file")
+	fs.StringVar(&c.WorldSets, "world-sets", "/var/lib/portage/world_sets", "Path to the world_sets file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if c.ReposConf == "" {
+		c.ReposConf = filepath.Join(c.ConfigRoot, "repos.conf")
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing subcommand for sets")
+	}
+
+	rest := fs.Args()[1:]
+	switch fs.Arg(0) {
+	case "list":
+		return c.list()
+	case "show":
+		if len(rest) != 1 {
+			return fmt.Errorf("usage: g2 sets show <set>")
+		}
+		return c.show(strings.TrimPrefix(rest[0], "@"))
+	case "add", "remove":
+		return c.edit(fs.Arg(0), rest)
+	case "help", "-help", "--help":
+		fs.Usage()
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown sets subcommand: %s", fs.Arg(0))
+	}
+}
+
+func (c *CmdSetsArgConfig) setPath(name string) string {
+	return filepath.Join(c.ConfigRoot, "sets", name)
+}
+
+// validSetName reports whether name can be used as a file under the sets directory.
+func validSetName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\\ \t") &&
+		name != "world" && name != "system" && name != "selected"
+}
+
+// readSetLines reads a set or world_sets file, returning nothing when it does not exist.
+func readSetLines(path string) ([]string, error) {
+	lines, err := readWorldFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// setEntries returns the entries of a set file without comments and blank lines.
+func setEntries(lines []string) []string {
+	var res []string
+	for _, line := range lines {
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+func (c *CmdSetsArgConfig) list() error {
+	worldSets, err := readSetLines(c.WorldSets)
+	if err != nil {
+		return err
+	}
+	selected := make(map[string]bool)
+	for _, e := range setEntries(worldSets) {
+		selected[strings.TrimPrefix(e, "@")] = true
+	}
+
+	entries, err := os.ReadDir(filepath.Join(c.ConfigRoot, "sets"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || strings.HasSuffix(e.Name(), "~") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if selected[name] {
+			fmt.Printf("@%s (world)\n", name)
+		} else {
+			fmt.Printf("@%s\n", name)
+		}
+		delete(selected, name)
+	}
+	var missing []string
+	for name := range selected {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	for _, name := range missing {
+		if name != "system" && name != "world" && name != "selected" {
+			log.Printf("Warning: @%s is listed in %s but has no set file", name, c.WorldSets)
+		}
+	}
+	return nil
+}
+
+func (c *CmdSetsArgConfig) show(name string) error {
+	switch name {
+	case "system":
+		repos, err := resolveRepoStack(c.RepoDirs, c.ReposConf)
+		if err != nil {
+			return err
+		}
+		atoms, err := loadSystemSet(repos, c.ConfigRoot, "")
+		if err != nil {
+			return err
+		}
+		for _, a := range atoms {
+			fmt.Println(a.String())
+		}
+		return nil
+	case "world", "selected":
+		lines, err := readSetLines(c.WorldFile)
+		if err != nil {
+			return err
+		}
+		for _, e := range setEntries(lines) {
+			fmt.Println(e)
+		}
+		if name == "world" {
+			sets, err := readSetLines(c.WorldSets)
+			if err != nil {
+				return err
+			}
+			for _, e := range setEntries(sets) {
+				fmt.Println(e)
+			}
+		}
+		return nil
+	}
+	if !validSetName(name) {
+		return fmt.Errorf("invalid set name %q", name)
+	}
+	lines, err := readWorldFile(c.setPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("set @%s does not exist", name)
+		}
+		return err
+	}
+	for _, e := range setEntries(lines) {
+		fmt.Println(e)
+	}
+	return nil
+}
+
+// parseSetEntry validates an atom or set reference for a set file.
+func parseSetEntry(entry string) error {
+	if strings.HasPrefix(entry, "@") {
+		if !validSetName(entry[1:]) && entry != "@system" && entry != "@world" {
+			return fmt.Errorf("invalid set reference %q", entry)
+		}
+		return nil
+	}
+	atom := g2.ParsePackageAtom(entry)
+	if atom.Category == "" || atom.Name == "" {
+		return fmt.Errorf("invalid package atom %q", entry)
+	}
+	return nil
+}
+
+// matchesSetEntry reports whether entry, an atom or set reference in a set file, is selected by want.
+func matchesSetEntry(entry, want string) bool {
+	if entry == want {
+		return true
+	}
+	if strings.HasPrefix(entry, "@") || strings.HasPrefix(want, "@") {
+		return false
+	}
+	// A bare package name selects every atom for that package.
+	wantAtom := g2.ParsePackageAtom(want)
+	if wantAtom.Operator != "" || wantAtom.Version != "" || wantAtom.Slot != "" || wantAtom.Repo != "" {
+		return false
+	}
+	atom := g2.ParsePackageAtom(entry)
+	return wantAtom.MatchesName(atom.Category, atom.Name)
+}
+
+func (c *CmdSetsArgConfig) edit(action string, args []string) error {
+	fs := flag.NewFlagSet(action, flag.ExitOnError)
+	world := fs.Bool("world", false, "Also add the set to, or only remove it from, world_sets")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: g2 sets %s [-world] <set> [<atom>...]", action)
+	}
+	name := strings.TrimPrefix(fs.Arg(0), "@")
+	if !validSetName(name) {
+		return fmt.Errorf("invalid set name %q", fs.Arg(0))
+	}
+	atoms := fs.Args()[1:]
+	path := c.setPath(name)
+
+	lines, err := readSetLines(path)
+	if err != nil {
+		return err
+	}
+	_, statErr := os.Stat(path)
+	exists := statErr == nil
+
+	if action == "add" {
+		present := make(map[string]bool)
+		for _, e := range setEntries(lines) {
+			present[e] = true
+		}
+		added := 0
+		for _, a := range atoms {
+			if err := parseSetEntry(a); err != nil {
+				return err
+			}
+			if !present[a] {
+				present[a] = true
+				lines = append(lines, a)
+				added++
+			}
+		}
+		if added > 0 || !exists {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := writeWorldFile(path, lines); err != nil {
+				return err
+			}
+			fmt.Printf("Added %d entries to @%s\n", added, name)
+		}
+		if *world {
+			return c.updateWorldSets(name, true)
+		}
+		return nil
+	}
+
+	if !exists && !*world {
+		return fmt.Errorf("set @%s does not exist", name)
+	}
+	if len(atoms) == 0 {
+		if !*world {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			fmt.Printf("Removed set @%s\n", name)
+		}
+		return c.updateWorldSets(name, false)
+	}
+
+	var kept []string
+	removed := 0
+	for _, line := range lines {
+		drop := false
+		if entries := setEntries([]string{line}); len(entries) == 1 {
+			for _, a := range atoms {
+				if matchesSetEntry(entries[0], a) {
+					drop = true
+				}
+			}
+		}
+		if drop {
+			removed++
+		} else {
+			kept = append(kept, line)
+		}
+	}
+	if removed == 0 {
+		fmt.Printf("No matching entries in @%s\n", name)
+		return nil
+	}
+	if err := writeWorldFile(path, kept); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d entries from @%s\n", removed, name)
+	return nil
+}
+
+// updateWorldSets adds or removes @name in the world_sets file.
+func (c *CmdSetsArgConfig) updateWorldSets(name string, add bool) error {
+	lines, err := readSetLines(c.WorldSets)
+	if err != nil {
+		return err
+	}
+	ref := "@" + name
+	var kept []string
+	found := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == ref {
+			found = true
+			if !add {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	switch {
+	case add && !found:
+		kept = append(kept, ref)
+		fmt.Printf("Added %s to %s\n", ref, c.WorldSets)
+	case !add && found:
+		fmt.Printf("Removed %s from %s\n", ref, c.WorldSets)
+	default:
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.WorldSets), 0755); err != nil {
+		return err
+	}
+	return writeWorldFile(c.WorldSets, kept)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdSets(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"etc/sets/desktop": "# desktop apps\napp-editors/vim\n",
+		"world_sets":       "@desktop\n@gone\n",
+	})
+	cfg := &MainArgConfig{}
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := captureOutput(t, func() error {
+			return cfg.cmdSets(append([]string{"-config-root", filepath.Join(dir, "etc"), "-world-sets", filepath.Join(dir, "world_sets")}, args...))
+		})
+		if err != nil {
+			t.Fatalf("sets %v failed: %v", args, err)
+		}
+		return out
+	}
+
+	run("add", "-world", "dev", "dev-lang/go", ">=dev-vcs/git-2.40", "@desktop")
+	run("add", "desktop", "media-video/mpv:0", "app-editors/vim")
+	if out := run("list"); out != "@desktop (world)\n@dev (world)\n" {
+		t.Errorf("unexpected list output: %q", out)
+	}
+	if out := run("show", "@dev"); out != "dev-lang/go\n>=dev-vcs/git-2.40\n@desktop\n" {
+		t.Errorf("unexpected show output: %q", out)
+	}
+
+	run("remove", "dev", "dev-vcs/git")
+	data, _ := os.ReadFile(filepath.Join(dir, "etc", "sets", "dev"))
+	if string(data) != "dev-lang/go\n@desktop\n" {
+		t.Errorf("unexpected dev set: %q", data)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "etc", "sets", "desktop"))
+	if string(data) != "# desktop apps\napp-editors/vim\nmedia-video/mpv:0\n" {
+		t.Errorf("unexpected desktop set: %q", data)
+	}
+
+	run("remove", "dev")
+	if _, err := os.Stat(filepath.Join(dir, "etc", "sets", "dev")); !os.IsNotExist(err) {
+		t.Errorf("expected dev set to be removed: %v", err)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "world_sets"))
+	if strings.Contains(string(data), "@dev") {
+		t.Errorf("expected @dev to be removed from world_sets: %q", data)
+	}
+
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdSets([]string{"-config-root", filepath.Join(dir, "etc"), "add", "dev", "not-an-atom"})
+	}); err == nil {
+		t.Error("expected invalid atom to be rejected")
+	}
+}
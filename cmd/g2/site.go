@@ -90,10 +90,22 @@ func (cfg *MainArgConfig) cmdOverlay(args []string) error {
 	includeGentoo := fs.Bool("include-gentoo", false, "Include the base Gentoo repository")
 	includeGuru := fs.Bool("include-guru", false, "Include the Guru repository")
 	reposConfOpt := fs.String("repos-conf", "", "Path to repos.conf file or directory")
+	templatesDir := fs.String("templates", "", "Directory of site templates overriding the defaults by file name")
+	staticDir := fs.String("static", "", "Directory of extra static assets to copy into the site")
+	emitAPI := fs.Bool("api", false, "Also write a static JSON API below api/v1/")
+	history := fs.Bool("history", false, "Read the git history of each repository into package history pages, recent changes feeds and ChangeLogs")
+	historyDepth := fs.Int("history-depth", 1000, "Number of commits of history to read per repository, or 0 for the whole history")
 
 	if err := fs.Parse(args[2:]); err != nil {
 		return fmt.Errorf("parsing flags: %w", err)
 	}
+	var historyOpts *g2.HistoryOptions
+	if *history {
+		historyOpts = &g2.HistoryOptions{MaxCommits: *historyDepth}
+	}
+	if err := configureSiteOverrides(*templatesDir, *staticDir); err != nil {
+		return fmt.Errorf("loading site templates: %w", err)
+	}
 	recentDuration, recentDurationStr, err := parseDuration(*recentDurOpt)
 	if err != nil {
 		return fmt.Errorf("invalid recent-duration: %w", err)
@@ -187,7 +199,7 @@ func (cfg *MainArgConfig) cmdOverlay(args []string) error {
 				defer cancel()
 
 				t0 := time.Now()
-				if err := FetchRepo(ctx, task.Location, tmpDir, *useZip, *workMode, 0); err != nil {
+				if err := FetchRepo(ctx, task.Location, tmpDir, *useZip && historyOpts == nil, *workMode, 0, historyFetchDepth(historyOpts)); err != nil {
 					return fmt.Errorf("cloning repository %s: %w", task.Name, err)
 				}
 				checkoutTime := time.Since(t0)
@@ -206,6 +218,11 @@ func (cfg *MainArgConfig) cmdOverlay(args []string) error {
 					return fmt.Errorf("parsing repo %s: %w", task.Name, err)
 				}
 				processTime := time.Since(t1)
+				if historyOpts != nil {
+					if err := attachRepoHistory(parseLocation, siteData, *historyOpts); err != nil {
+						log.Printf("Warning: reading git history of %s: %v", task.Name, err)
+					}
+				}
 
 				siteData.CheckoutTime = checkoutTime.String()
 				siteData.ProcessTime = processTime.String()
@@ -228,6 +245,11 @@ func (cfg *MainArgConfig) cmdOverlay(args []string) error {
 					return fmt.Errorf("parsing repo %s: %w", task.Name, err)
 				}
 				processTime := time.Since(t1)
+				if historyOpts != nil {
+					if err := attachRepoHistory(parseLocation, siteData, *historyOpts); err != nil {
+						log.Printf("Warning: reading git history of %s: %v", task.Name, err)
+					}
+				}
 
 				siteData.ProcessTime = processTime.String()
 				siteData.GitSize = gitSize
@@ -260,7 +282,7 @@ func (cfg *MainArgConfig) cmdOverlay(args []string) error {
 		return allSites[i].RepoName < allSites[j].RepoName
 	})
 
-	genInfo := GenerationInfo{Args: cfg.Args, FastGit: *fastGit, RecentDuration: recentDurationStr}
+	genInfo := GenerationInfo{Args: cfg.Args, FastGit: *fastGit, RecentDuration: recentDurationStr, API: *emitAPI}
 	profiler := NewProfiler(*profileSiteGen, *profileOut)
 	genInfo.Profiler = profiler
 	if err := generateSite(*outDir, allSites, recentDuration, recentDurationStr, genInfo); err != nil {
@@ -306,11 +328,19 @@ func (cfg *MainArgConfig) cmdOverlays(args []string) error {
 	persistentDir := fs.String("persistent-dir", getDefaultCacheDir(), "Directory to persistently store checked out repositories instead of a temporary directory")
 	tempDir := fs.String("temp-dir", "", "Directory to use for temporary files instead of the default")
 	reposConfOpt := fs.String("repos-conf", "", "Path to repos.conf file or directory")
+	templatesDir := fs.String("templates", "", "Directory of site templates overriding the defaults by file name")
+	staticDir := fs.String("static", "", "Directory of extra static assets to copy into the site")
+	emitAPI := fs.Bool("api", false, "Also write a static JSON API below api/v1/")
 	mode := fs.String("mode", "standard", "Processing mode: 'standard' or 'pipeline'")
+	history := fs.Bool("history", false, "Clone each repository with its git history and read it into package history pages, recent changes feeds and ChangeLogs")
+	historyDepth := fs.Int("history-depth", 1000, "Number of commits of history to clone and read per repository, or 0 for the whole history")
 
 	if err := fs.Parse(args[2:]); err != nil {
 		return fmt.Errorf("parsing flags: %w", err)
 	}
+	if err := configureSiteOverrides(*templatesDir, *staticDir); err != nil {
+		return fmt.Errorf("loading site templates: %w", err)
+	}
 	recentDuration, recentDurationStr, err := parseDuration(*recentDurOpt)
 	if err != nil {
 		return fmt.Errorf("invalid recent-duration: %w", err)
@@ -332,7 +362,11 @@ func (cfg *MainArgConfig) cmdOverlays(args []string) error {
 	}
 
 	log.Printf("Generating site (v%s) from remote repositories: %s into %s", version, location, *outDir)
-	return cfg.cmdSiteRemote(location, *outDir, recentDuration, recentDurationStr, *fastGit, *useZip, *concurrency, *retries, *continueOnError, *persistentDir, *reposConfOpt, *tempDir, *workMode, *mode, *profileSiteGen, *profileOut, *smartMode)
+	var historyOpts *g2.HistoryOptions
+	if *history {
+		historyOpts = &g2.HistoryOptions{MaxCommits: *historyDepth}
+	}
+	return cfg.cmdSiteRemote(location, *outDir, recentDuration, recentDurationStr, *fastGit, *useZip, *concurrency, *retries, *continueOnError, *persistentDir, *reposConfOpt, *tempDir, *workMode, *mode, *profileSiteGen, *profileOut, *smartMode, *emitAPI, historyOpts)
 }
 
 func parseLayoutConfFromFS(sysFS fs.FS, path string) (*g2.LayoutConf, error) {
@@ -1961,6 +1995,9 @@ func prepareAggregatedData(sites []*g2.SiteData) *AggregatedData {
 }
 
 func renderPage(path string, tmpl *template.Template, name string, data interface{}) error {
+	if build := activeSiteBuild.Load(); build != nil && !build.needsRender(path) {
+		return nil
+	}
 	f, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("creating file %s: %w", path, err)
@@ -1980,7 +2017,12 @@ func renderPage(path string, tmpl *template.Template, name string, data interfac
 	return nil
 }
 
-func (cfg *MainArgConfig) cmdSiteRemote(repositoriesFile string, outDir string, recentDuration time.Duration, recentDurationStr string, fastGit bool, useZip bool, concurrency int, retries int, continueOnError bool, persistentDir string, reposConfPath string, tempDir string, workMode string, mode string, profileSiteGen bool, profileOut string, smartMode bool) error {
+func (cfg *MainArgConfig) cmdSiteRemote(repositoriesFile string, outDir string, recentDuration time.Duration, recentDurationStr string, fastGit bool, useZip bool, concurrency int, retries int, continueOnError bool, persistentDir string, reposConfPath string, tempDir string, workMode string, mode string, profileSiteGen bool, profileOut string, smartMode bool, emitAPI bool, history *g2.HistoryOptions) error {
+	if history != nil && (smartMode || useZip) {
+		log.Printf("Reading git history: cloning repositories to disk instead of using smart mode or zip archives")
+		smartMode, useZip = false, false
+	}
+
 	var repos g2.Repositories
 
 	if reposConfPath != "" {
@@ -2169,6 +2211,11 @@ func (cfg *MainArgConfig) cmdSiteRemote(repositoriesFile string, outDir string,
 						defer memManager.Release(defaultAlloc)
 						siteData, err = parseRepo(parseFS, ".", task.repo.Name, fastGit, &repoCopy, SourceURL(task.gitUrl))
 					}()
+					if err == nil && history != nil && task.sysFS == nil {
+						if err := attachRepoHistory(task.repoPath, siteData, *history); err != nil {
+							log.Printf("Warning: reading git history of %s: %v", task.repo.Name, err)
+						}
+					}
 
 					cleanCh <- cleanTask{repoPath: task.repoPath}
 
@@ -2313,7 +2360,7 @@ func (cfg *MainArgConfig) cmdSiteRemote(repositoriesFile string, outDir string,
 					}
 
 					if sysFS == nil {
-						err = FetchRepo(ctx, task.gitUrl, repoPath, useZip, workMode, retries)
+						err = FetchRepo(ctx, task.gitUrl, repoPath, useZip, workMode, retries, historyFetchDepth(history))
 					}
 
 					cancel()
@@ -2430,7 +2477,7 @@ func (cfg *MainArgConfig) cmdSiteRemote(repositoriesFile string, outDir string,
 				}
 
 				if sysFS == nil {
-					err = FetchRepo(ctx, gitUrl, repoPath, useZip, workMode, retries)
+					err = FetchRepo(ctx, gitUrl, repoPath, useZip, workMode, retries, historyFetchDepth(history))
 				}
 
 				if err != nil && sysFS == nil {
@@ -2470,6 +2517,11 @@ func (cfg *MainArgConfig) cmdSiteRemote(repositoriesFile string, outDir string,
 					log.Printf("Failed to parse repo %s: %v", repo.Name, err)
 					return nil
 				}
+				if history != nil && sysFS == nil {
+					if err := attachRepoHistory(repoPath, siteData, *history); err != nil {
+						log.Printf("Warning: reading git history of %s: %v", repo.Name, err)
+					}
+				}
 				processTime := time.Since(t1)
 				nodeCount := 0
 				if siteData != nil {
@@ -2613,6 +2665,7 @@ func (cfg *MainArgConfig) cmdSiteRemote(repositoriesFile string, outDir string,
 		Args:           cfg.Args,
 		FastGit:        fastGit,
 		RecentDuration: recentDurationStr,
+		API:            emitAPI,
 	}
 	if err := generateSite(outDir, allSites, recentDuration, recentDurationStr, genInfo); err != nil {
 		return fmt.Errorf("generating integrated site: %w", err)
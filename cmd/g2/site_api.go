@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arran4/g2"
+	"github.com/arran4/g2/templates"
+)
+
+// The static JSON API is written below api/v1/ of a generated site. Within a version fields are only
+// added, never renamed or removed; the JSON Schemas of the documents are published below api/schema/v1/.
+const (
+	siteAPIVersion   = 1
+	siteAPIDir       = "api/v1"
+	siteAPISchemaDir = "api/schema/v1"
+)
+
+// siteAPIDependencyVars are the ebuild variables listed under the dependencies of a version.
+var siteAPIDependencyVars = []string{"DEPEND", "RDEPEND", "BDEPEND", "PDEPEND", "IDEPEND"}
+
+type apiRepoList struct {
+	APIVersion int       `json:"api_version"`
+	Repos      []apiRepo `json:"repos"`
+}
+
+type apiRepo struct {
+	Name         string   `json:"name"`
+	Title        string   `json:"title"`
+	Description  string   `json:"description,omitempty"`
+	Homepage     string   `json:"homepage,omitempty"`
+	Quality      string   `json:"quality,omitempty"`
+	Status       string   `json:"status,omitempty"`
+	SourceURL    string   `json:"source_url,omitempty"`
+	EAPI         string   `json:"eapi,omitempty"`
+	Masters      []string `json:"masters"`
+	PackageCount int      `json:"package_count"`
+	Categories   []string `json:"categories"`
+}
+
+type apiRepoCategories struct {
+	APIVersion int               `json:"api_version"`
+	Repo       string            `json:"repo"`
+	Categories []apiRepoCategory `json:"categories"`
+}
+
+type apiRepoCategory struct {
+	Name     string   `json:"name"`
+	Packages []string `json:"packages"`
+}
+
+type apiPackage struct {
+	APIVersion  int             `json:"api_version"`
+	Repo        string          `json:"repo"`
+	Category    string          `json:"category"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Homepage    string          `json:"homepage,omitempty"`
+	License     string          `json:"license,omitempty"`
+	Maintainers []apiMaintainer `json:"maintainers"`
+	UseFlags    []apiUseFlag    `json:"use_flags"`
+	Masked      *apiNotice      `json:"masked,omitempty"`
+	Deprecated  *apiNotice      `json:"deprecated,omitempty"`
+	Versions    []apiVersion    `json:"versions"`
+	Distfiles   []apiDistfile   `json:"distfiles"`
+}
+
+type apiMaintainer struct {
+	Email   string `json:"email"`
+	Name    string `json:"name,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Proxied string `json:"proxied,omitempty"`
+}
+
+type apiUseFlag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Source      string `json:"source"`
+}
+
+// apiNotice is a package.mask or package.deprecated entry.
+type apiNotice struct {
+	Reason      string `json:"reason"`
+	Date        string `json:"date,omitempty"`
+	Author      string `json:"author,omitempty"`
+	AuthorEmail string `json:"author_email,omitempty"`
+}
+
+type apiVersion struct {
+	Version      string                   `json:"version"`
+	EAPI         string                   `json:"eapi"`
+	Slot         string                   `json:"slot"`
+	Keywords     []string                 `json:"keywords"`
+	IUSE         []string                 `json:"iuse"`
+	License      string                   `json:"license,omitempty"`
+	RequiredUse  string                   `json:"required_use,omitempty"`
+	Inherits     []string                 `json:"inherits"`
+	Dependencies map[string]apiDependency `json:"dependencies"`
+	Distfiles    []string                 `json:"distfiles"`
+	Masked       *apiNotice               `json:"masked,omitempty"`
+	Deprecated   *apiNotice               `json:"deprecated,omitempty"`
+}
+
+type apiDependency struct {
+	Raw   string   `json:"raw"`
+	Atoms []string `json:"atoms"`
+}
+
+type apiDistfile struct {
+	Name     string            `json:"name"`
+	Size     int64             `json:"size"`
+	Hashes   map[string]string `json:"hashes"`
+	URLs     []string          `json:"urls"`
+	Versions []string          `json:"versions"`
+}
+
+type apiCategoryList struct {
+	APIVersion int           `json:"api_version"`
+	Categories []apiCategory `json:"categories"`
+}
+
+type apiCategory struct {
+	Name     string               `json:"name"`
+	Packages []apiCategoryPackage `json:"packages"`
+}
+
+type apiCategoryPackage struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Repos       []string `json:"repos"`
+}
+
+type apiLicenseList struct {
+	APIVersion int          `json:"api_version"`
+	Licenses   []apiLicense `json:"licenses"`
+}
+
+type apiLicense struct {
+	Name       string   `json:"name"`
+	Provided   bool     `json:"provided"`
+	ProvidedBy []string `json:"provided_by"`
+	Aliases    []string `json:"aliases"`
+	Packages   []string `json:"packages"`
+}
+
+// generateSiteAPI writes the JSON API of sites below outDir. The tree is written anew on every run, so
+// that documents of removed packages do not linger.
+func generateSiteAPI(outDir string, sites []*g2.SiteData, data *AggregatedData) error {
+	apiRoot := filepath.Join(outDir, filepath.FromSlash(siteAPIDir))
+	if err := os.RemoveAll(apiRoot); err != nil {
+		return fmt.Errorf("clearing %s: %w", apiRoot, err)
+	}
+
+	count := 0
+	write := func(rel string, v any) error {
+		count++
+		return writeAPIDocument(filepath.Join(apiRoot, filepath.FromSlash(rel)), v)
+	}
+
+	for _, site := range sites {
+		for _, cat := range site.Categories {
+			for i := range cat.Packages {
+				pkg := &cat.Packages[i]
+				if err := write(path.Join(site.RepoName, cat.Name, pkg.Name+".json"), apiPackageOf(site, pkg)); err != nil {
+					return err
+				}
+			}
+		}
+		if err := write(path.Join(site.RepoName, "categories.json"), apiRepoCategoriesOf(site)); err != nil {
+			return err
+		}
+	}
+	if err := write("repos.json", apiRepoListOf(sites)); err != nil {
+		return err
+	}
+
+	if err := write("categories.json", apiCategoryListOf(data.Categories)); err != nil {
+		return err
+	}
+
+	licenses := apiLicenseList{APIVersion: siteAPIVersion, Licenses: []apiLicense{}}
+	for _, list := range [][]*AggLicense{data.Licenses, data.UnsupportedLicenses} {
+		for _, l := range list {
+			al := apiLicense{
+				Name:       l.Name,
+				Provided:   len(l.ProvidedByRepos) > 0,
+				ProvidedBy: sortedRepoNames(l.ProvidedByRepos),
+				Aliases:    append([]string{}, l.Aliases...),
+				Packages:   []string{},
+			}
+			for _, pkg := range l.Packages {
+				al.Packages = append(al.Packages, pkg.Category+"/"+pkg.Name)
+			}
+			sort.Strings(al.Packages)
+			al.Packages = deduplicateStrings(al.Packages)
+			licenses.Licenses = append(licenses.Licenses, al)
+		}
+	}
+	sort.Slice(licenses.Licenses, func(i, j int) bool { return licenses.Licenses[i].Name < licenses.Licenses[j].Name })
+	if err := write("licenses.json", licenses); err != nil {
+		return err
+	}
+
+	if err := writeSiteAPISchemas(outDir); err != nil {
+		return err
+	}
+	log.Printf("[API] Wrote %d JSON documents to %s", count, apiRoot)
+	return nil
+}
+
+// writeSiteAPISchemas publishes the JSON Schemas of the API documents.
+func writeSiteAPISchemas(outDir string) error {
+	schemaDir := filepath.Join(outDir, filepath.FromSlash(siteAPISchemaDir))
+	if err := os.MkdirAll(schemaDir, 0755); err != nil {
+		return err
+	}
+	names, err := fs.Glob(templates.APIFS, "api/v1/*.schema.json")
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		b, err := templates.APIFS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(schemaDir, path.Base(name)), b, 0644); err != nil {
+			return fmt.Errorf("writing API schema %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func writeAPIDocument(dest string, v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("encoding %s: %w", dest, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(dest, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", dest, err)
+	}
+	return nil
+}
+
+func sortedRepoNames(repos map[string]*g2.SiteData) []string {
+	names := make([]string, 0, len(repos))
+	for name := range repos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// apiRepoListOf returns the repos.json document of sites.
+func apiRepoListOf(sites []*g2.SiteData) apiRepoList {
+	repos := apiRepoList{APIVersion: siteAPIVersion, Repos: []apiRepo{}}
+	for _, site := range sites {
+		repos.Repos = append(repos.Repos, apiRepoOf(site))
+	}
+	sort.Slice(repos.Repos, func(i, j int) bool { return repos.Repos[i].Name < repos.Repos[j].Name })
+	return repos
+}
+
+// apiRepoCategoriesOf returns the <repo>/categories.json document of site.
+func apiRepoCategoriesOf(site *g2.SiteData) apiRepoCategories {
+	listing := apiRepoCategories{APIVersion: siteAPIVersion, Repo: site.RepoName, Categories: []apiRepoCategory{}}
+	for _, cat := range site.Categories {
+		c := apiRepoCategory{Name: cat.Name, Packages: []string{}}
+		for _, pkg := range cat.Packages {
+			c.Packages = append(c.Packages, pkg.Name)
+		}
+		listing.Categories = append(listing.Categories, c)
+	}
+	return listing
+}
+
+// apiCategoryListOf returns the categories.json document of the aggregated categories.
+func apiCategoryListOf(aggCategories []*AggCategory) apiCategoryList {
+	categories := apiCategoryList{APIVersion: siteAPIVersion, Categories: []apiCategory{}}
+	for _, cat := range aggCategories {
+		c := apiCategory{Name: cat.Name, Packages: []apiCategoryPackage{}}
+		for _, pkg := range cat.Packages {
+			c.Packages = append(c.Packages, apiCategoryPackage{
+				Name:        pkg.Name,
+				Description: pkg.DominantDescription,
+				Repos:       sortedRepoNames(pkg.Repos),
+			})
+		}
+		categories.Categories = append(categories.Categories, c)
+	}
+	return categories
+}
+
+func apiRepoOf(site *g2.SiteData) apiRepo {
+	r := apiRepo{
+		Name:       site.RepoName,
+		Title:      site.Title,
+		SourceURL:  site.SourceURL,
+		EAPI:       site.EAPI,
+		Masters:    []string{},
+		Categories: []string{},
+	}
+	if site.Repository != nil {
+		r.Description = site.Repository.Description()
+		r.Homepage = site.Repository.Homepage
+		r.Quality = site.Repository.Quality
+		r.Status = site.Repository.Status
+	}
+	if site.LayoutConf != nil {
+		r.Masters = append(r.Masters, site.LayoutConf.Masters()...)
+	}
+	for _, cat := range site.Categories {
+		r.Categories = append(r.Categories, cat.Name)
+		r.PackageCount += len(cat.Packages)
+	}
+	return r
+}
+
+func apiNoticeOf(reason, date, author, email string) *apiNotice {
+	return &apiNotice{Reason: reason, Date: date, Author: author, AuthorEmail: email}
+}
+
+func apiMaskedOf(m *g2.PackageMasked) *apiNotice {
+	if m == nil {
+		return nil
+	}
+	return apiNoticeOf(m.Reason, m.Date, m.Author, m.AuthorEmail)
+}
+
+func apiDeprecatedOf(d *g2.PackageDeprecated) *apiNotice {
+	if d == nil {
+		return nil
+	}
+	return apiNoticeOf(d.Reason, d.Date, d.Author, d.AuthorEmail)
+}
+
+func apiPackageOf(site *g2.SiteData, pkg *g2.PackageData) apiPackage {
+	p := apiPackage{
+		APIVersion:  siteAPIVersion,
+		Repo:        site.RepoName,
+		Category:    pkg.Category,
+		Name:        pkg.Name,
+		Description: pkg.DominantDescription,
+		Homepage:    pkg.DominantHomepage,
+		License:     pkg.DominantLicense,
+		Maintainers: []apiMaintainer{},
+		UseFlags:    []apiUseFlag{},
+		Masked:      apiMaskedOf(pkg.Masked),
+		Deprecated:  apiDeprecatedOf(pkg.Deprecated),
+		Versions:    []apiVersion{},
+		Distfiles:   []apiDistfile{},
+	}
+	if pkg.Metadata != nil {
+		for _, m := range pkg.Metadata.Maintainers {
+			p.Maintainers = append(p.Maintainers, apiMaintainer{Email: m.Email, Name: m.Name, Type: m.Type, Proxied: m.Proxied})
+		}
+	}
+	for _, u := range pkg.PkgUseFlags {
+		p.UseFlags = append(p.UseFlags, apiUseFlag{Name: u.Name, Description: u.Desc, Source: u.Source})
+	}
+
+	distfilesOf := map[string][]string{}
+	for _, m := range pkg.ManifestData {
+		if m.Entry == nil || m.Entry.Type != "DIST" {
+			continue
+		}
+		d := apiDistfile{
+			Name:     m.Entry.Filename,
+			Size:     m.Entry.Size,
+			Hashes:   map[string]string{},
+			URLs:     append([]string{}, m.URLs...),
+			Versions: append([]string{}, m.Versions...),
+		}
+		for _, h := range m.Entry.Hashes {
+			d.Hashes[h.Type] = h.Value
+		}
+		for _, v := range m.Versions {
+			distfilesOf[v] = append(distfilesOf[v], d.Name)
+		}
+		p.Distfiles = append(p.Distfiles, d)
+	}
+
+	for _, ver := range pkg.Versions {
+		v := apiVersion{
+			Version:      ver.Version,
+			Keywords:     []string{},
+			IUSE:         []string{},
+			Inherits:     []string{},
+			Dependencies: map[string]apiDependency{},
+			Distfiles:    append([]string{}, distfilesOf[ver.Version]...),
+			Masked:       apiMaskedOf(ver.Masked),
+			Deprecated:   apiDeprecatedOf(ver.Deprecated),
+		}
+		if ver.Ebuild != nil && ver.Ebuild.Vars != nil {
+			vars := ver.Ebuild.Vars
+			v.EAPI = vars["EAPI"]
+			v.Slot = vars["SLOT"]
+			v.License = vars["LICENSE"]
+			v.RequiredUse = vars["REQUIRED_USE"]
+			v.Keywords = append(v.Keywords, strings.Fields(vars["KEYWORDS"])...)
+			v.IUSE = append(v.IUSE, strings.Fields(vars["IUSE"])...)
+			v.Inherits = append(v.Inherits, strings.Fields(vars["INHERITED"])...)
+			for _, name := range siteAPIDependencyVars {
+				raw := strings.TrimSpace(vars[name])
+				if raw == "" {
+					continue
+				}
+				v.Dependencies[strings.ToLower(name)] = apiDependency{
+					Raw:   raw,
+					Atoms: deduplicateStrings(pkgRegex.FindAllString(raw, -1)),
+				}
+			}
+		}
+		if v.EAPI == "" {
+			v.EAPI = "0"
+		}
+		p.Versions = append(p.Versions, v)
+	}
+	return p
+}
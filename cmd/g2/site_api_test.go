@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arran4/g2"
+	"github.com/arran4/g2/templates"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// apiSchemaOf maps a document below api/v1/ to the name of its published schema.
+func apiSchemaOf(rel string) string {
+	switch {
+	case rel == "repos.json", rel == "categories.json", rel == "licenses.json":
+		return strings.TrimSuffix(rel, ".json") + ".schema.json"
+	case strings.Count(rel, "/") == 1 && path.Base(rel) == "categories.json":
+		return "repo_categories.schema.json"
+	case strings.Count(rel, "/") == 2:
+		return "package.schema.json"
+	}
+	return ""
+}
+
+func TestGenerateSiteAPI(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":    "api\n",
+		"profiles/categories":   "app-misc\ndev-libs\n",
+		"profiles/package.mask": "# Jane Doe <jane@example.org> (2026-01-02)\n# Broken build.\ndev-libs/bar\n",
+		"metadata/layout.conf":  "masters = gentoo\n",
+		"licenses/MIT":          "MIT License\n",
+		"app-misc/foo/foo-1.0.ebuild": `EAPI=8
+DESCRIPTION="Foo tool"
+HOMEPAGE="https://example.org/foo"
+SRC_URI="https://example.org/foo-1.0.tar.gz"
+LICENSE="MIT"
+SLOT="0"
+KEYWORDS="~amd64 x86"
+IUSE="+ssl test"
+DEPEND="ssl? ( dev-libs/bar:= ) >=dev-libs/openssl-3"
+RDEPEND="${DEPEND}"
+`,
+		"app-misc/foo/Manifest": "DIST foo-1.0.tar.gz 10 BLAKE2B aa SHA512 bb\n",
+		"app-misc/foo/metadata.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE pkgmetadata SYSTEM "https://www.gentoo.org/dtd/metadata.dtd">
+<pkgmetadata>
+	<maintainer type="person">
+		<email>jane@example.org</email>
+		<name>Jane Doe</name>
+	</maintainer>
+	<use>
+		<flag name="ssl">Enable TLS</flag>
+	</use>
+</pkgmetadata>
+`,
+		"dev-libs/bar/bar-2.ebuild": "EAPI=8\nDESCRIPTION=\"Bar\"\nLICENSE=\"GPL-2\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n",
+	})
+	site, err := parseRepo(os.DirFS(repo), ".", "API", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	outDir := t.TempDir()
+	if err := generateSite(outDir, []*g2.SiteData{site}, 90*24*time.Hour, "3 months", GenerationInfo{API: true}); err != nil {
+		t.Fatalf("generateSite: %v", err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	schemas := map[string]*jsonschema.Schema{}
+	names, err := fs.Glob(templates.APIFS, "api/v1/*.schema.json")
+	if err != nil || len(names) == 0 {
+		t.Fatalf("no API schemas: %v", err)
+	}
+	for _, name := range names {
+		published, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(siteAPISchemaDir), path.Base(name)))
+		if err != nil {
+			t.Fatalf("schema %s not published: %v", name, err)
+		}
+		doc, err := jsonschema.UnmarshalJSON(strings.NewReader(string(published)))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		url := "https://g2.example/" + siteAPISchemaDir + "/" + path.Base(name)
+		if err := compiler.AddResource(url, doc); err != nil {
+			t.Fatal(err)
+		}
+		if schemas[path.Base(name)], err = compiler.Compile(url); err != nil {
+			t.Fatalf("compiling %s: %v", name, err)
+		}
+	}
+
+	if err := schemas["repos.schema.json"].Validate(map[string]any{"api_version": 2.0}); err == nil {
+		t.Error("repos schema accepts a document of another API version")
+	}
+
+	apiRoot := filepath.Join(outDir, filepath.FromSlash(siteAPIDir))
+	docs := map[string][]byte{}
+	err = filepath.WalkDir(apiRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(apiRoot, p)
+		rel = filepath.ToSlash(rel)
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		docs[rel] = b
+		schema := schemas[apiSchemaOf(rel)]
+		if schema == nil {
+			t.Errorf("no schema for %s", rel)
+			return nil
+		}
+		v, err := jsonschema.UnmarshalJSON(strings.NewReader(string(b)))
+		if err != nil {
+			return err
+		}
+		if err := schema.Validate(v); err != nil {
+			t.Errorf("%s does not match %s: %v", rel, apiSchemaOf(rel), err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"repos.json", "categories.json", "licenses.json", "api/categories.json", "api/app-misc/foo.json", "api/dev-libs/bar.json"} {
+		if docs[rel] == nil {
+			t.Errorf("%s not written", rel)
+		}
+	}
+
+	var foo apiPackage
+	if err := json.Unmarshal(docs["api/app-misc/foo.json"], &foo); err != nil {
+		t.Fatal(err)
+	}
+	if len(foo.Maintainers) != 1 || foo.Maintainers[0].Email != "jane@example.org" {
+		t.Errorf("maintainers = %+v", foo.Maintainers)
+	}
+	if len(foo.Versions) != 1 {
+		t.Fatalf("versions = %+v", foo.Versions)
+	}
+	v := foo.Versions[0]
+	if strings.Join(v.Keywords, " ") != "~amd64 x86" || strings.Join(v.IUSE, " ") != "+ssl test" {
+		t.Errorf("keywords = %v, iuse = %v", v.Keywords, v.IUSE)
+	}
+	if got := strings.Join(v.Dependencies["depend"].Atoms, " "); got != "dev-libs/bar dev-libs/openssl-3" {
+		t.Errorf("depend atoms = %q", got)
+	}
+	if len(foo.Distfiles) != 1 || foo.Distfiles[0].Hashes["SHA512"] != "bb" || foo.Distfiles[0].Size != 10 {
+		t.Errorf("distfiles = %+v", foo.Distfiles)
+	}
+	if strings.Join(v.Distfiles, " ") != "foo-1.0.tar.gz" {
+		t.Errorf("version distfiles = %v", v.Distfiles)
+	}
+	if !strings.Contains(string(docs["api/app-misc/foo.json"]), `"name": "ssl"`) {
+		t.Error("USE flags missing from foo.json")
+	}
+
+	var bar apiPackage
+	if err := json.Unmarshal(docs["api/dev-libs/bar.json"], &bar); err != nil {
+		t.Fatal(err)
+	}
+	if bar.Masked == nil || !strings.Contains(bar.Masked.Reason, "Broken build") {
+		t.Errorf("bar masked = %+v", bar.Masked)
+	}
+
+	var licenses apiLicenseList
+	if err := json.Unmarshal(docs["licenses.json"], &licenses); err != nil {
+		t.Fatal(err)
+	}
+	provided := map[string]bool{}
+	for _, l := range licenses.Licenses {
+		provided[l.Name] = l.Provided
+	}
+	if !provided["MIT"] || provided["GPL-2"] {
+		t.Errorf("licenses = %+v", licenses.Licenses)
+	}
+
+	// Documents of removed packages are not kept.
+	if err := os.RemoveAll(filepath.Join(repo, "dev-libs")); err != nil {
+		t.Fatal(err)
+	}
+	site, err = parseRepo(os.DirFS(repo), ".", "API", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	if err := generateSite(outDir, []*g2.SiteData{site}, 90*24*time.Hour, "3 months", GenerationInfo{API: true}); err != nil {
+		t.Fatalf("generateSite: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(apiRoot, "api", "dev-libs", "bar.json")); !os.IsNotExist(err) {
+		t.Errorf("document of a removed package kept: %v", err)
+	}
+}
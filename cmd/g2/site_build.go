@@ -0,0 +1,340 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/arran4/g2"
+)
+
+// siteBuildManifestFile records, in the output directory, the input fingerprint of every page of a
+// generated site so that later runs only render the pages whose inputs changed.
+const siteBuildManifestFile = ".g2-build.json"
+
+type siteBuildManifest struct {
+	Version  string            `json:"version"`
+	Template string            `json:"template"`
+	Pages    map[string]string `json:"pages"`
+}
+
+// siteBuild tracks the pages rendered by one generateSite run. Package pages are fingerprinted by the
+// package in each repository that has it, the repository-wide data and a few aggregated inputs (moves,
+// licenses, the site title). The other pages of a repository depend on that repository and on its
+// packages in every repository that has them, as they are listed from the aggregated packages; the
+// repository front and news pages also on the date and the site-wide counts they show. Every other page
+// depends on all inputs, as it may list any of them.
+type siteBuild struct {
+	outDir   string
+	template string
+	prev     map[string]string
+
+	repoMeta map[string]string
+	repos    map[string]string   // repo -> repository-wide data and packages
+	packages map[string]string   // repo + "::" + category/name
+	pkgRepos map[string][]string // category/name -> repositories
+	light    string
+	daily    string
+	global   string
+
+	mu        sync.Mutex
+	pages     map[string]string
+	added     []string
+	changed   []string
+	unchanged int
+}
+
+// activeSiteBuild is consulted by renderPage while generateSite runs.
+var activeSiteBuild atomic.Pointer[siteBuild]
+
+var (
+	siteTemplateFingerprintOnce sync.Once
+	siteTemplateFingerprint     string
+)
+
+// templateFingerprint hashes the site templates, including those of the -templates directory, and the
+// g2 version, so that a new release or template change renders every page again.
+func templateFingerprint() string {
+	siteTemplateFingerprintOnce.Do(func() {
+		h := sha256.New()
+		_, _ = io.WriteString(h, version+"\x00")
+		files, err := siteTemplateFiles()
+		if err != nil {
+			// Unreadable templates fail the run elsewhere; never match a previous build.
+			_, _ = fmt.Fprintf(h, "%v %d", err, time.Now().UnixNano())
+		}
+		for _, f := range files {
+			_, _ = fmt.Fprintf(h, "%s\x00%d\x00", f.Path, len(f.Data))
+			_, _ = h.Write(f.Data)
+		}
+		siteTemplateFingerprint = hex.EncodeToString(h.Sum(nil))
+	})
+	return siteTemplateFingerprint
+}
+
+// newSiteBuild loads the build manifest of outDir and fingerprints the inputs of sites. The manifest is
+// removed until the run finishes, so that an interrupted run cannot leave stale pages marked current.
+func newSiteBuild(outDir string, sites []*g2.SiteData, data *AggregatedData, title, recentDurationStr string) *siteBuild {
+	outDir = filepath.Clean(outDir)
+	b := &siteBuild{
+		outDir:   outDir,
+		template: templateFingerprint(),
+		prev:     map[string]string{},
+		repoMeta: map[string]string{},
+		repos:    map[string]string{},
+		packages: map[string]string{},
+		pkgRepos: map[string][]string{},
+		pages:    map[string]string{},
+	}
+	manifestPath := filepath.Join(outDir, siteBuildManifestFile)
+	if raw, err := os.ReadFile(manifestPath); err == nil {
+		var m siteBuildManifest
+		if err := json.Unmarshal(raw, &m); err != nil {
+			log.Printf("Warning: ignoring unreadable %s: %v", manifestPath, err)
+		} else if m.Template == b.template && m.Pages != nil {
+			b.prev = m.Pages
+		}
+		if err := os.Remove(manifestPath); err != nil {
+			log.Printf("Warning: removing %s: %v", manifestPath, err)
+		}
+	}
+
+	h := sha256.New()
+	light := sha256.New()
+	writeFingerprint(light, title, data.Moves, data.ValidLicenses)
+	b.light = hex.EncodeToString(light.Sum(nil))
+	// Global pages and the repository front pages show recent updates and news, so they are refreshed
+	// at least daily.
+	daily := sha256.New()
+	writeFingerprint(daily, recentDurationStr, time.Now().UTC().Format("2006-01-02"),
+		len(data.Categories), data.TotalPackages, len(data.Licenses), len(data.Profiles))
+	b.daily = hex.EncodeToString(daily.Sum(nil))
+	writeFingerprint(h, b.light, b.daily)
+	for _, site := range sites {
+		b.repoMeta[site.RepoName] = repoFingerprint(site)
+		writeFingerprint(h, site.RepoName, b.repoMeta[site.RepoName])
+		for _, cat := range site.Categories {
+			for i := range cat.Packages {
+				pkg := &cat.Packages[i]
+				key := pkg.Category + "/" + pkg.Name
+				fp := packageFingerprint(pkg)
+				b.packages[site.RepoName+"::"+key] = fp
+				b.pkgRepos[key] = append(b.pkgRepos[key], site.RepoName)
+				writeFingerprint(h, key, fp)
+			}
+		}
+	}
+	b.global = hex.EncodeToString(h.Sum(nil))
+	for _, site := range sites {
+		rh := sha256.New()
+		writeFingerprint(rh, site.RepoName, b.repoMeta[site.RepoName])
+		for _, cat := range site.Categories {
+			for i := range cat.Packages {
+				key := cat.Packages[i].Category + "/" + cat.Packages[i].Name
+				for _, repo := range b.pkgRepos[key] {
+					writeFingerprint(rh, repo, key, b.packages[repo+"::"+key])
+				}
+			}
+		}
+		b.repos[site.RepoName] = hex.EncodeToString(rh.Sum(nil))
+	}
+	return b
+}
+
+// writeFingerprint writes the JSON encoding of values to h. Values that cannot be encoded make the
+// fingerprint unique, so that their pages are always rendered.
+func writeFingerprint(h hash.Hash, values ...any) {
+	enc := json.NewEncoder(h)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			_, _ = fmt.Fprintf(h, "unencodable %v %d", err, time.Now().UnixNano())
+		}
+	}
+}
+
+// repoFingerprint hashes the repository-wide data of site, leaving out its packages, the data derived
+// from other repositories and the timings of the run.
+func repoFingerprint(site *g2.SiteData) string {
+	s := *site
+	s.Categories = nil
+	s.AggEclasses, s.AggLicenses, s.AggUseFlags = nil, nil, nil
+	s.ParsedEclasses, s.Eclasses = nil, nil
+	s.GitSize, s.CheckoutTime, s.ProcessTime = "", "", ""
+	h := sha256.New()
+	writeFingerprint(h, s)
+	for _, list := range [][]*g2.Ebuild{site.ParsedEclasses, site.Eclasses} {
+		for _, e := range list {
+			if e != nil {
+				writeFingerprint(h, e.Path, e.RawText)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// packageFingerprint hashes the metadata, Manifest and ebuilds of a package. Modification times are left
+// out: they follow the content, and fall back to the current time for files outside git.
+func packageFingerprint(pkg *g2.PackageData) string {
+	p := *pkg
+	p.Versions = nil
+	p.MetadataError = nil
+	p.ModTime = time.Time{}
+	h := sha256.New()
+	writeFingerprint(h, p)
+	if pkg.MetadataError != nil {
+		writeFingerprint(h, pkg.MetadataError.Error())
+	}
+	for _, v := range pkg.Versions {
+		ebuild := v.Ebuild
+		v.Ebuild = nil
+		v.ModTime = time.Time{}
+		writeFingerprint(h, v)
+		if ebuild != nil {
+			writeFingerprint(h, ebuild.Path, ebuild.RawText, ebuild.Vars, ebuild.SrcUri, ebuild.ParseWarnings, ebuild.EbuildHeader)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fingerprint returns the input fingerprint of the page at rel, a slash separated path below the output
+// directory.
+func (b *siteBuild) fingerprint(rel string) string {
+	parts := strings.Split(rel, "/")
+	h := sha256.New()
+	switch {
+	case len(parts) >= 7 && parts[0] == "repos" && parts[2] == "categories" && parts[4] == "packages":
+		pkg, ok := b.packages[parts[1]+"::"+parts[3]+"/"+parts[5]]
+		if !ok {
+			return ""
+		}
+		writeFingerprint(h, b.template, "repo-package", b.repoMeta[parts[1]], pkg, b.light)
+	case len(parts) >= 4 && parts[0] == "packages":
+		key := parts[1] + "/" + parts[2]
+		writeFingerprint(h, b.template, "package", b.light)
+		for _, repo := range b.pkgRepos[key] {
+			writeFingerprint(h, repo, b.repoMeta[repo], b.packages[repo+"::"+key])
+		}
+	case len(parts) >= 3 && parts[0] == "repos" && b.repos[parts[1]] != "":
+		writeFingerprint(h, b.template, "repo", b.repos[parts[1]], b.light)
+		if page := strings.Join(parts[2:], "/"); page == "index.html" || page == "news/index.html" {
+			writeFingerprint(h, b.daily)
+		}
+	default:
+		writeFingerprint(h, b.template, "site", b.global)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// needsRender records the page at path and reports whether its inputs changed since the last run.
+func (b *siteBuild) needsRender(path string) bool {
+	rel, err := filepath.Rel(b.outDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	fp := b.fingerprint(rel)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, seen := b.pages[rel]; seen {
+		return true
+	}
+	b.pages[rel] = fp
+	prev, existed := b.prev[rel]
+	if existed && fp != "" && prev == fp {
+		if _, err := os.Stat(path); err == nil {
+			b.unchanged++
+			return false
+		}
+	}
+	if existed {
+		b.changed = append(b.changed, rel)
+	} else {
+		b.added = append(b.added, rel)
+	}
+	return true
+}
+
+// finish removes the pages of the previous run that were not generated again, writes the build manifest
+// and logs what changed.
+func (b *siteBuild) finish() error {
+	var removed []string
+	for rel := range b.prev {
+		if _, ok := b.pages[rel]; ok {
+			continue
+		}
+		path := filepath.Join(b.outDir, filepath.FromSlash(rel))
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing orphaned page %s: %w", rel, err)
+		}
+		removed = append(removed, rel)
+		// Drop the directories the page leaves empty; Remove fails on the first non-empty one.
+		for dir := filepath.Dir(path); dir != b.outDir && strings.HasPrefix(dir, b.outDir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+
+	pages := make(map[string]string, len(b.pages))
+	for rel, fp := range b.pages {
+		if fp != "" {
+			pages[rel] = fp
+		}
+	}
+	raw, err := json.Marshal(siteBuildManifest{Version: version, Template: b.template, Pages: pages})
+	if err != nil {
+		return err
+	}
+	if err := g2.SafeWriteFileAtomic(filepath.Join(b.outDir, siteBuildManifestFile), raw, 0644); err != nil {
+		return fmt.Errorf("writing build manifest: %w", err)
+	}
+
+	log.Printf("[BUILD] %d pages rendered (%d new, %d changed), %d unchanged, %d removed", len(b.added)+len(b.changed), len(b.added), len(b.changed), b.unchanged, len(removed))
+	type sectionCounts struct{ added, changed, removed int }
+	sections := map[string]*sectionCounts{}
+	section := func(rel string) *sectionCounts {
+		parts := strings.SplitN(rel, "/", 3)
+		name := parts[0]
+		if len(parts) == 1 {
+			name = "/"
+		} else if parts[0] == "repos" && len(parts) == 3 {
+			name = "repos/" + parts[1]
+		}
+		if sections[name] == nil {
+			sections[name] = &sectionCounts{}
+		}
+		return sections[name]
+	}
+	for _, rel := range b.added {
+		section(rel).added++
+	}
+	for _, rel := range b.changed {
+		section(rel).changed++
+	}
+	for _, rel := range removed {
+		section(rel).removed++
+	}
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := sections[name]
+		log.Printf("[BUILD]   %s: %d new, %d changed, %d removed", name, c.added, c.changed, c.removed)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/arran4/g2"
+)
+
+func TestGenerateSiteIncremental(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":        "incremental\n",
+		"profiles/categories":       "app-misc\n",
+		"metadata/layout.conf":      "masters = gentoo\n",
+		"app-misc/foo/foo-1.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+		"app-misc/bar/bar-1.ebuild": "EAPI=8\nDESCRIPTION=\"Bar\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+		"app-misc/bar/bar-2.ebuild": "EAPI=8\nDESCRIPTION=\"Bar\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n",
+	})
+	outDir := t.TempDir()
+	generate := func() {
+		t.Helper()
+		site, err := parseRepo(os.DirFS(repo), ".", "Incremental", false, nil)
+		if err != nil {
+			t.Fatalf("parseRepo: %v", err)
+		}
+		if err := generateSite(outDir, []*g2.SiteData{site}, 90*24*time.Hour, "3 months", GenerationInfo{}); err != nil {
+			t.Fatalf("generateSite: %v", err)
+		}
+	}
+	pkgPage := func(pkg string) string {
+		return filepath.Join(outDir, "repos", "incremental", "categories", "app-misc", "packages", pkg, "index.html")
+	}
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	age := func(paths ...string) {
+		t.Helper()
+		for _, p := range paths {
+			if err := os.Chtimes(p, old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	rendered := func(p string) bool {
+		t.Helper()
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("stat %s: %v", p, err)
+		}
+		return !info.ModTime().Equal(old)
+	}
+
+	generate()
+	raw, err := os.ReadFile(filepath.Join(outDir, siteBuildManifestFile))
+	if err != nil {
+		t.Fatalf("build manifest not written: %v", err)
+	}
+	var m siteBuildManifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	fooPage, barPage := pkgPage("foo"), pkgPage("bar")
+	bar2Page := filepath.Join(outDir, "repos", "incremental", "categories", "app-misc", "packages", "bar", "ebuild", "2", "index.html")
+	if m.Pages["repos/incremental/categories/app-misc/packages/foo/index.html"] == "" || m.Pages["index.html"] == "" {
+		t.Fatalf("build manifest lacks pages: %d entries", len(m.Pages))
+	}
+
+	// Nothing changed: package pages are left alone.
+	age(fooPage, barPage)
+	generate()
+	if rendered(fooPage) || rendered(barPage) {
+		t.Error("unchanged package pages were rendered again")
+	}
+
+	// A changed ebuild renders its package again, but not the other one.
+	writeTestFiles(t, repo, map[string]string{"app-misc/foo/foo-1.ebuild": "EAPI=8\nDESCRIPTION=\"Foo tool\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n"})
+	generate()
+	if !rendered(fooPage) || rendered(barPage) {
+		t.Errorf("after changing foo: foo rendered = %v, bar rendered = %v", rendered(fooPage), rendered(barPage))
+	}
+
+	// Pages that are no longer generated are removed.
+	if err := os.Remove(filepath.Join(repo, "app-misc", "bar", "bar-2.ebuild")); err != nil {
+		t.Fatal(err)
+	}
+	generate()
+	if _, err := os.Stat(bar2Page); !os.IsNotExist(err) {
+		t.Errorf("orphaned page %s was kept: %v", bar2Page, err)
+	}
+	if _, err := os.Stat(filepath.Dir(bar2Page)); !os.IsNotExist(err) {
+		t.Errorf("empty directory of the orphaned page was kept: %v", err)
+	}
+	if _, err := os.Stat(barPage); err != nil {
+		t.Errorf("bar page removed: %v", err)
+	}
+
+	// A page deleted from the output is rendered again even though its inputs did not change.
+	if err := os.Remove(fooPage); err != nil {
+		t.Fatal(err)
+	}
+	generate()
+	if _, err := os.Stat(fooPage); err != nil {
+		t.Errorf("deleted page not rendered again: %v", err)
+	}
+}
+
+func TestGenerateSiteIncrementalRepoPages(t *testing.T) {
+	repos := map[string]string{"one": t.TempDir(), "two": t.TempDir()}
+	for name, dir := range repos {
+		writeTestFiles(t, dir, map[string]string{
+			"profiles/repo_name":                          name + "\n",
+			"profiles/categories":                         "app-misc\n",
+			"metadata/layout.conf":                        "masters = gentoo\n",
+			"app-misc/" + name + "/" + name + "-1.ebuild": "EAPI=8\nDESCRIPTION=\"Tool\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+		})
+	}
+	outDir := t.TempDir()
+	generate := func() {
+		t.Helper()
+		var sites []*g2.SiteData
+		for _, name := range []string{"one", "two"} {
+			site, err := parseRepo(os.DirFS(repos[name]), ".", name, false, nil)
+			if err != nil {
+				t.Fatalf("parseRepo: %v", err)
+			}
+			sites = append(sites, site)
+		}
+		if err := generateSite(outDir, sites, 90*24*time.Hour, "3 months", GenerationInfo{}); err != nil {
+			t.Fatalf("generateSite: %v", err)
+		}
+	}
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	pages := []string{
+		filepath.Join(outDir, "index.html"),
+		filepath.Join(outDir, "repos", "one", "categories", "index.html"),
+		filepath.Join(outDir, "repos", "two", "categories", "index.html"),
+		filepath.Join(outDir, "repos", "two", "index.html"),
+	}
+
+	generate()
+	for _, p := range pages {
+		if err := os.Chtimes(p, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeTestFiles(t, repos["one"], map[string]string{"app-misc/one/one-1.ebuild": "EAPI=8\nDESCRIPTION=\"Another tool\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n"})
+	generate()
+	for i, want := range []bool{true, true, false, false} {
+		info, err := os.Stat(pages[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := !info.ModTime().Equal(old); got != want {
+			t.Errorf("after changing repository one: %s rendered = %v, want %v", pages[i], got, want)
+		}
+	}
+}
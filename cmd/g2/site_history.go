@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/arran4/g2"
+)
+
+// siteRecentChanges is the number of changes shown on the recent changes page and feeds of a repository.
+const siteRecentChanges = 50
+
+// attachRepoHistory reads the git history of the repository at dir into the packages of site and its
+// recent changes.
+func attachRepoHistory(dir string, site *g2.SiteData, opts g2.HistoryOptions) error {
+	history, err := g2.ReadRepoHistory(dir, opts)
+	if err != nil {
+		return err
+	}
+	var recent []g2.PackageChange
+	for i := range site.Categories {
+		for j := range site.Categories[i].Packages {
+			pkg := &site.Categories[i].Packages[j]
+			pkg.History = history[pkg.Category+"/"+pkg.Name]
+			recent = append(recent, pkg.History...)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool {
+		if !recent[i].Date.Equal(recent[j].Date) {
+			return recent[i].Date.After(recent[j].Date)
+		}
+		return recent[i].Package < recent[j].Package
+	})
+	if len(recent) > siteRecentChanges {
+		recent = recent[:siteRecentChanges]
+	}
+	site.RecentChanges = recent
+	return nil
+}
+
+// generateRepoHistoryPages renders the history page of each package with a git history, the recent
+// changes page and feeds of the repository, and the ChangeLog of each package when the layout.conf of the
+// repository asks for them.
+func generateRepoHistoryPages(repoDir string, tmpl *template.Template, site *g2.SiteData, title, version string, genInfo GenerationInfo) error {
+	changelogs := site.LayoutConf != nil && site.LayoutConf.UpdateChangelog()
+	for _, c := range site.Categories {
+		for _, pkg := range c.Packages {
+			if len(pkg.History) == 0 {
+				continue
+			}
+			pkgDir := filepath.Join(repoDir, "categories", pkg.Category, "packages", pkg.Name)
+			historyDir := filepath.Join(pkgDir, "history")
+			if err := os.MkdirAll(historyDir, 0755); err != nil {
+				return fmt.Errorf("creating directory %s: %w", historyDir, err)
+			}
+			if err := renderPage(filepath.Join(historyDir, "index.html"), tmpl, "repo_package_history.html", GenericPageContext{
+				Title:       fmt.Sprintf("%s - %s/%s - History", site.RepoName, pkg.Category, pkg.Name),
+				BaseURL:     "../../../../../../../",
+				Breadcrumbs: []g2.Breadcrumb{{Name: title, URL: "../../../../../../../"}, {Name: site.RepoName, URL: "../../../../../"}, {Name: "Categories", URL: "../../../../"}, {Name: pkg.Category, URL: "../../../"}, {Name: pkg.Name, URL: "../"}, {Name: "History"}},
+				Repo:        site,
+				RepoPackage: &pkg,
+				Changes:     pkg.History,
+				Version:     version,
+				GenInfo:     genInfo,
+			}); err != nil {
+				return fmt.Errorf("rendering page: %w", err)
+			}
+			if changelogs {
+				if err := writeChangeLogFile(filepath.Join(pkgDir, "ChangeLog"), pkg.Category+"/"+pkg.Name, pkg.History); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if len(site.RecentChanges) == 0 {
+		return nil
+	}
+	changesDir := filepath.Join(repoDir, "changes")
+	if err := os.MkdirAll(changesDir, 0755); err != nil {
+		return fmt.Errorf("creating directory %s: %w", changesDir, err)
+	}
+	if err := renderPage(filepath.Join(changesDir, "index.html"), tmpl, "repo_changes.html", GenericPageContext{
+		Title:       site.RepoName + " - Recent Changes",
+		BaseURL:     "../../../",
+		Breadcrumbs: []g2.Breadcrumb{{Name: title, URL: "../../../"}, {Name: "Overlays", URL: "../../../overlays/"}, {Name: site.RepoName, URL: "../"}, {Name: "Recent Changes"}},
+		AlternateFeeds: []AlternateFeed{
+			{Type: "application/rss+xml", Title: site.RepoName + " Recent Changes RSS Feed", Href: "index.rss"},
+			{Type: "application/atom+xml", Title: site.RepoName + " Recent Changes Atom Feed", Href: "index.atom"},
+		},
+		Repo:    site,
+		Changes: site.RecentChanges,
+		Version: version,
+		GenInfo: genInfo,
+	}); err != nil {
+		return fmt.Errorf("rendering page: %w", err)
+	}
+	if err := renderFeeds(changesDir, changesFeed(site)); err != nil {
+		return fmt.Errorf("generating change feeds for repository %q: %w", site.RepoName, err)
+	}
+	return nil
+}
+
+// changesFeed returns the feed of the recent changes of site, linking each change to the history page of
+// its package.
+func changesFeed(site *g2.SiteData) g2.Feed {
+	items := make([]g2.FeedItem, 0, len(site.RecentChanges))
+	for _, c := range site.RecentChanges {
+		var kinds []string
+		for _, k := range c.Kinds {
+			kinds = append(kinds, string(k))
+		}
+		items = append(items, g2.FeedItem{
+			ID:          stableURN("change", site.RepoName, c.Hash, c.Package),
+			Title:       c.Package + ": " + c.Subject,
+			Link:        "../categories/" + c.Category() + "/packages/" + c.Name() + "/history/",
+			Description: fmt.Sprintf("Package: %s\nChange: %s\nAuthor: %s <%s>\n\n%s", c.Package, strings.Join(kinds, ", "), c.Author, c.Email, c.Message),
+			PubDate:     c.Date.Format(time.RFC1123Z),
+			Updated:     c.Date.Format(time.RFC3339),
+		})
+	}
+	return g2.Feed{
+		Title:       site.RepoName + " Recent Changes",
+		Link:        "./",
+		Description: "Recent package changes in " + site.RepoName,
+		Items:       items,
+	}
+}
+
+func writeChangeLogFile(path, pkg string, changes []g2.PackageChange) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("creating ChangeLog %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+	if err := g2.WriteChangeLog(f, pkg, changes); err != nil {
+		return fmt.Errorf("writing ChangeLog %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing ChangeLog %s: %w", path, err)
+	}
+	return nil
+}
+
+// historyFetchDepth returns the number of commits to clone a remote repository with: the commits history
+// reads, or only the last one without history.
+func historyFetchDepth(history *g2.HistoryOptions) int {
+	if history == nil {
+		return 1
+	}
+	return history.MaxCommits
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arran4/g2"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newHistoryTestRepo returns a git repository holding app-misc/foo, bumped to 1.1 in a second commit, and
+// app-misc/bar, with update-changelog set in layout.conf.
+func newHistoryTestRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	gitRepo, err := git.PlainInit(repo, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := gitRepo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	commit := func(msg string, files map[string]string) {
+		t.Helper()
+		writeTestFiles(t, repo, files)
+		if _, err := wt.Add("."); err != nil {
+			t.Fatal(err)
+		}
+		when = when.Add(24 * time.Hour)
+		sig := &object.Signature{Name: "Jane Doe", Email: "jane@example.org", When: when}
+		if _, err := wt.Commit(msg, &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const ebuild = "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n"
+	commit("app-misc/foo: new package", map[string]string{
+		"profiles/repo_name":          "hist\n",
+		"profiles/categories":         "app-misc\n",
+		"metadata/layout.conf":        "masters = gentoo\nupdate-changelog = true\n",
+		"app-misc/foo/foo-1.0.ebuild": ebuild,
+		"app-misc/bar/bar-1.ebuild":   ebuild,
+	})
+	commit("app-misc/foo: add 1.1", map[string]string{"app-misc/foo/foo-1.1.ebuild": ebuild})
+	return repo
+}
+
+// parseHistoryTestRepo parses repo with its git history.
+func parseHistoryTestRepo(t *testing.T, repo string) *g2.SiteData {
+	t.Helper()
+	site, err := parseRepo(os.DirFS(repo), ".", "History", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	if err := attachRepoHistory(repo, site, g2.HistoryOptions{}); err != nil {
+		t.Fatalf("attachRepoHistory: %v", err)
+	}
+	return site
+}
+
+func TestGenerateSiteHistory(t *testing.T) {
+	site := parseHistoryTestRepo(t, newHistoryTestRepo(t))
+	if len(site.RecentChanges) != 3 || site.RecentChanges[0].Package != "app-misc/foo" || site.RecentChanges[0].Subject != "app-misc/foo: add 1.1" {
+		t.Fatalf("recent changes = %+v", site.RecentChanges)
+	}
+
+	outDir := t.TempDir()
+	if err := generateSite(outDir, []*g2.SiteData{site}, 90*24*time.Hour, "3 months", GenerationInfo{}); err != nil {
+		t.Fatalf("generateSite: %v", err)
+	}
+	read := func(rel string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	pkgDir := "repos/hist/categories/app-misc/packages/foo/"
+	if page := read(pkgDir + "history/index.html"); !strings.Contains(page, "app-misc/foo: add 1.1") || !strings.Contains(page, "+1.1") {
+		t.Errorf("history page lacks the bump: %s", page)
+	}
+	if page := read(pkgDir + "index.html"); !strings.Contains(page, `href="history/"`) {
+		t.Error("package page does not link its history")
+	}
+	if page := read("repos/hist/changes/index.html"); !strings.Contains(page, "app-misc/bar") || !strings.Contains(page, `href="index.atom"`) {
+		t.Errorf("recent changes page: %s", page)
+	}
+	if feed := read("repos/hist/changes/index.rss"); strings.Count(feed, "<item>") != 3 {
+		t.Errorf("RSS feed: %s", feed)
+	}
+	if feed := read("repos/hist/changes/index.atom"); !strings.Contains(feed, "categories/app-misc/packages/foo/history/") {
+		t.Errorf("Atom feed: %s", feed)
+	}
+	if log := read(pkgDir + "ChangeLog"); !strings.HasPrefix(log, "# ChangeLog for app-misc/foo\n") || !strings.Contains(log, "*foo-1.1 (03 Mar 2026)") {
+		t.Errorf("ChangeLog: %s", log)
+	}
+}
+
+func TestSiteServeHistory(t *testing.T) {
+	server, err := newSiteServer([]*g2.SiteData{parseHistoryTestRepo(t, newHistoryTestRepo(t))}, GenerationInfo{})
+	if err != nil {
+		t.Fatalf("newSiteServer: %v", err)
+	}
+	pkgURL := "/repos/hist/categories/app-misc/packages/foo/"
+	if rec := getTestPage(t, server, pkgURL+"history/"); rec.Code != 200 || !strings.Contains(rec.Body.String(), "+1.1") {
+		t.Errorf("history page: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := getTestPage(t, server, pkgURL+"ChangeLog"); !strings.HasPrefix(rec.Body.String(), "# ChangeLog for app-misc/foo\n") {
+		t.Errorf("ChangeLog: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := getTestPage(t, server, "/repos/hist/changes/"); rec.Code != 200 || !strings.Contains(rec.Body.String(), "app-misc/bar") {
+		t.Errorf("recent changes page: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := getTestPage(t, server, "/repos/hist/changes/index.rss"); strings.Count(rec.Body.String(), "<item>") != 3 {
+		t.Errorf("RSS feed: %s", rec.Body.String())
+	}
+	if rec := getTestPage(t, server, "/repos/hist/changes/index.atom"); rec.Header().Get("Content-Type") != "application/atom+xml; charset=utf-8" {
+		t.Errorf("Atom feed content type = %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestUpdateChangeLogs(t *testing.T) {
+	repo := newHistoryTestRepo(t)
+	if err := os.RemoveAll(filepath.Join(repo, "app-misc", "bar")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := updateChangeLogs(repo, g2.HistoryOptions{})
+	if err != nil {
+		t.Fatalf("updateChangeLogs: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("wrote %d ChangeLogs, want 1 for the package still in the tree", n)
+	}
+	b, err := os.ReadFile(filepath.Join(repo, "app-misc", "foo", "ChangeLog"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log := string(b); !strings.HasPrefix(log, "# ChangeLog for app-misc/foo\n") || !strings.Contains(log, "*foo-1.1 (03 Mar 2026)") {
+		t.Errorf("ChangeLog: %s", log)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "app-misc", "bar", "ChangeLog")); !os.IsNotExist(err) {
+		t.Errorf("ChangeLog written for a removed package: %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+
+	"github.com/arran4/g2"
+	"github.com/arran4/g2/templates"
+)
+
+var (
+	// siteTemplatesDir holds files that replace the embedded site templates of the same base name, or add
+	// new ones. It is set with -templates.
+	siteTemplatesDir string
+	// siteStaticDir holds extra assets copied into generated sites and served by site serve. It is set
+	// with -static.
+	siteStaticDir string
+)
+
+// siteTemplateFile is one site template, embedded or read from the override directory.
+type siteTemplateFile struct {
+	Path     string // slash separated, as in templates.SiteFS
+	Data     []byte
+	Override string // the file in the override directory, if any
+}
+
+// isSiteTemplateFile reports whether name is a kind of file the site templates are made of.
+func isSiteTemplateFile(name string) bool {
+	switch filepath.Ext(name) {
+	case ".html", ".xml", ".js":
+		return true
+	}
+	return false
+}
+
+// configureSiteOverrides sets the template and static asset override directories and loads the site
+// templates, so that broken overrides fail before any repository is fetched or parsed.
+func configureSiteOverrides(templatesDir, staticDir string) error {
+	for _, dir := range []string{templatesDir, staticDir} {
+		if dir == "" {
+			continue
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+	}
+	siteTemplatesDir, siteStaticDir = templatesDir, staticDir
+	siteTmplOnce = sync.Once{}
+	siteTemplates, siteTmplErr = nil, nil
+	siteTemplateFingerprintOnce = sync.Once{}
+	xmlTemplatesMu.Lock()
+	xmlTemplates = make(map[string]*texttemplate.Template)
+	xmlTemplatesMu.Unlock()
+
+	if _, err := GetSiteTemplates(); err != nil {
+		return err
+	}
+	files, err := siteTemplateFiles()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if f.Override != "" && strings.HasPrefix(f.Path, "site/") && strings.HasSuffix(f.Path, ".xml") {
+			if _, err := getXMLTemplate(path.Base(f.Path)); err != nil {
+				return fmt.Errorf("%s: %w", f.Override, err)
+			}
+		}
+	}
+	if templatesDir != "" {
+		log.Printf("Using site templates from %s", templatesDir)
+	}
+	return nil
+}
+
+// siteTemplateFiles returns the embedded site templates with the files of the override directory laid
+// over them. An override replaces the embedded file of the same base name wherever it sits in the
+// directory; other files are added under their relative path.
+func siteTemplateFiles() ([]siteTemplateFile, error) {
+	var files []siteTemplateFile
+	byBase := map[string]int{}
+	err := fs.WalkDir(templates.SiteFS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(templates.SiteFS, p)
+		if err != nil {
+			return err
+		}
+		byBase[path.Base(p)] = len(files)
+		files = append(files, siteTemplateFile{Path: p, Data: b})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if siteTemplatesDir == "" {
+		return files, nil
+	}
+
+	err = filepath.WalkDir(siteTemplatesDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isSiteTemplateFile(p) {
+			return err
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if i, ok := byBase[d.Name()]; ok {
+			files[i].Data, files[i].Override = b, p
+			return nil
+		}
+		rel, err := filepath.Rel(siteTemplatesDir, p)
+		if err != nil {
+			return err
+		}
+		byBase[d.Name()] = len(files)
+		files = append(files, siteTemplateFile{Path: filepath.ToSlash(rel), Data: b, Override: p})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading site templates from %s: %w", siteTemplatesDir, err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// readSiteAsset returns the site template at name, a path in templates.SiteFS such as site/rss.xml,
+// taking overrides into account.
+func readSiteAsset(name string) ([]byte, error) {
+	files, err := siteTemplateFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if f.Path == name {
+			return f.Data, nil
+		}
+	}
+	return nil, fmt.Errorf("open %s: %w", name, fs.ErrNotExist)
+}
+
+// copySiteStatic copies the static asset directory into outDir, replacing generated files of the same
+// name.
+func copySiteStatic(outDir string) error {
+	if siteStaticDir == "" {
+		return nil
+	}
+	count := 0
+	err := filepath.WalkDir(siteStaticDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(siteStaticDir, p)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(outDir, rel)
+		if d.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		count++
+		return g2.SafeWriteFileAtomic(dest, b, 0644)
+	})
+	if err != nil {
+		return fmt.Errorf("copying static assets from %s: %w", siteStaticDir, err)
+	}
+	log.Printf("Copied %d static assets from %s", count, siteStaticDir)
+	return nil
+}
+
+// serveSiteStatic serves the file of the static asset directory that the request names, if there is one.
+func serveSiteStatic(w http.ResponseWriter, r *http.Request) bool {
+	if siteStaticDir == "" {
+		return false
+	}
+	p := filepath.Join(siteStaticDir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	info, err := os.Stat(p)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	http.ServeFile(w, r, p)
+	return true
+}
+
+func (cfg *MainArgConfig) cmdSiteTemplates(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing subcommand for site templates (e.g., export)")
+	}
+	switch args[0] {
+	case "export":
+		return cfg.cmdSiteTemplatesExport(args[1:])
+	case "help", "-help", "--help":
+		fmt.Printf("Usage: %s site templates <subcommand>\n", os.Args[0])
+		fmt.Printf("\t\t %s \t\t %s\n", "export [-force] <dir>", "write the default site templates to dir as a starting point for -templates")
+		return nil
+	default:
+		return fmt.Errorf("unknown site templates subcommand: %s", args[0])
+	}
+}
+
+func (cfg *MainArgConfig) cmdSiteTemplatesExport(args []string) error {
+	fs := flag.NewFlagSet("site templates export", flag.ExitOnError)
+	force := fs.Bool("force", false, "Overwrite existing files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: site templates export [-force] <dir>")
+	}
+	dir := fs.Arg(0)
+
+	var paths []string
+	if err := walkEmbeddedSiteTemplates(func(p string) { paths = append(paths, p) }); err != nil {
+		return err
+	}
+	if !*force {
+		for _, p := range paths {
+			dest := filepath.Join(dir, filepath.FromSlash(p))
+			if _, err := os.Stat(dest); err == nil {
+				return &ExitError{Code: 1, Err: fmt.Errorf("%s already exists; use -force to overwrite", dest)}
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	for _, p := range paths {
+		b, err := templates.SiteFS.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dest, b, 0644); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Exported %d site templates to %s\n", len(paths), dir)
+	return nil
+}
+
+// walkEmbeddedSiteTemplates calls fn with the path of every embedded site template.
+func walkEmbeddedSiteTemplates(fn func(p string)) error {
+	return fs.WalkDir(templates.SiteFS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fn(p)
+		return nil
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arran4/g2"
+)
+
+func TestSiteTemplateOverrides(t *testing.T) {
+	t.Cleanup(func() {
+		if err := configureSiteOverrides("", ""); err != nil {
+			t.Errorf("restoring embedded templates: %v", err)
+		}
+	})
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":        "themed\n",
+		"profiles/categories":       "app-misc\n",
+		"metadata/layout.conf":      "masters = gentoo\n",
+		"app-misc/foo/foo-1.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+	})
+	theme := t.TempDir()
+	writeTestFiles(t, theme, map[string]string{
+		"app/layout_footer.html": `{{template "theme_note.html" .}}</body></html>`,
+		"theme_note.html":        `<p class="theme-note">Themed by the overlay</p>`,
+		"README":                 "not a template",
+	})
+	static := t.TempDir()
+	writeTestFiles(t, static, map[string]string{"css/theme.css": "body { color: teal; }\n"})
+
+	if err := configureSiteOverrides(theme, static); err != nil {
+		t.Fatalf("configureSiteOverrides: %v", err)
+	}
+	site, err := parseRepo(os.DirFS(repo), ".", "Themed", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	outDir := t.TempDir()
+	if err := generateSite(outDir, []*g2.SiteData{site}, 90*24*time.Hour, "3 months", GenerationInfo{}); err != nil {
+		t.Fatalf("generateSite: %v", err)
+	}
+	page, err := os.ReadFile(filepath.Join(outDir, "repos", "themed", "categories", "app-misc", "packages", "foo", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "Themed by the overlay") {
+		t.Error("package page does not use the overriding footer")
+	}
+	if !strings.HasPrefix(string(page), "<!DOCTYPE html>") {
+		t.Error("package page lost the embedded header")
+	}
+	if b, err := os.ReadFile(filepath.Join(outDir, "css", "theme.css")); err != nil || !strings.Contains(string(b), "teal") {
+		t.Errorf("static asset not copied: %q, %v", b, err)
+	}
+
+	server, err := newSiteServer([]*g2.SiteData{site}, GenerationInfo{})
+	if err != nil {
+		t.Fatalf("newSiteServer: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/css/theme.css", nil))
+	if !strings.Contains(rec.Body.String(), "teal") {
+		t.Errorf("site serve does not serve the static asset: %d %q", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), "Themed by the overlay") {
+		t.Error("site serve does not use the overriding footer")
+	}
+
+	if err := os.Remove(filepath.Join(theme, "theme_note.html")); err != nil {
+		t.Fatal(err)
+	}
+	err = configureSiteOverrides(theme, "")
+	if err == nil || !strings.Contains(err.Error(), `references missing template "theme_note.html"`) {
+		t.Errorf("override referencing a missing template: %v", err)
+	}
+}
+
+func TestSiteTemplatesExport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "theme")
+	cfg := &MainArgConfig{}
+	out, err := captureOutput(t, func() error {
+		return cfg.cmdSite([]string{"templates", "export", dir})
+	})
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if !strings.Contains(out, "Exported") {
+		t.Errorf("unexpected output: %s", out)
+	}
+	for _, name := range []string{"app/layout_header.html", "app/layout_footer.html", "views/dashboard.html", "site/rss.xml", "site/search.js"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+			t.Errorf("%s not exported: %v", name, err)
+		}
+	}
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdSite([]string{"templates", "export", dir})
+	}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("export over existing files: %v", err)
+	}
+	if _, err := captureOutput(t, func() error {
+		return cfg.cmdSite([]string{"templates", "export", "-force", dir})
+	}); err != nil {
+		t.Errorf("export -force: %v", err)
+	}
+
+	// The exported templates are a complete override set.
+	t.Cleanup(func() { _ = configureSiteOverrides("", "") })
+	if err := configureSiteOverrides(dir, ""); err != nil {
+		t.Errorf("exported templates do not load: %v", err)
+	}
+}
@@ -22,13 +22,15 @@ import (
 
 func (cfg *MainArgConfig) cmdSite(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("missing subcommand for site (e.g., serve)")
+		return fmt.Errorf("missing subcommand for site (e.g., serve, templates)")
 	}
 	subcmd := args[0]
 
 	switch subcmd {
 	case "serve":
 		return cfg.cmdSiteServe(args[1:])
+	case "templates":
+		return cfg.cmdSiteTemplates(args[1:])
 	default:
 		return fmt.Errorf("unknown site subcommand: %s", subcmd)
 	}
@@ -40,10 +42,23 @@ func (cfg *MainArgConfig) cmdSiteServe(args []string) error {
 	concurrency := fs.Int("concurrency", 4, "Maximum number of concurrent repository fetches/parses")
 	smartMode := fs.Bool("smart-mode", true, "Use smart mode for parsing repositories in memory without disk access")
 	reposConfOpt := fs.String("repos-conf", "", "Path to repos.conf file or directory")
+	templatesDir := fs.String("templates", "", "Directory of site templates overriding the defaults by file name")
+	staticDir := fs.String("static", "", "Directory of extra static assets to serve with the site")
+	watch := fs.Bool("watch", false, "Parse changed packages again as their files change and reload open pages")
+	watchInterval := fs.Duration("watch-interval", 0, "Poll the repositories at this interval instead of using OS file notifications for -watch")
+	history := fs.Bool("history", false, "Read the git history of each repository into package history pages, recent changes feeds and ChangeLogs")
+	historyDepth := fs.Int("history-depth", 1000, "Number of commits of history to read per repository, or 0 for the whole history")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	var historyOpts *g2.HistoryOptions
+	if *history {
+		historyOpts = &g2.HistoryOptions{MaxCommits: *historyDepth}
+	}
+	if err := configureSiteOverrides(*templatesDir, *staticDir); err != nil {
+		return fmt.Errorf("loading site templates: %w", err)
+	}
 
 	location := "."
 	if fs.NArg() > 0 {
@@ -52,6 +67,7 @@ func (cfg *MainArgConfig) cmdSiteServe(args []string) error {
 
 	// Determine if location is a single overlay or we need to fall back to repos.conf / /var/db/repos
 	var sites []*g2.SiteData
+	repoDirs := make(map[string]string)
 
 	limit := *concurrency
 	if limit <= 0 {
@@ -70,7 +86,13 @@ func (cfg *MainArgConfig) cmdSiteServe(args []string) error {
 		if err != nil {
 			return fmt.Errorf("parsing repo %s: %w", location, err)
 		}
+		if historyOpts != nil {
+			if err := attachRepoHistory(location, siteData, *historyOpts); err != nil {
+				log.Printf("Warning: reading git history of %s: %v", location, err)
+			}
+		}
 		sites = append(sites, siteData)
+		repoDirs[siteData.RepoName] = location
 	} else {
 		var repoPaths []string
 		var repoNames []string
@@ -141,6 +163,11 @@ func (cfg *MainArgConfig) cmdSiteServe(args []string) error {
 						log.Printf("Warning: failed to parse repo %s: %v", repoName, err)
 						return nil // Don't fail entire group
 					}
+					if historyOpts != nil {
+						if err := attachRepoHistory(repoPath, siteData, *historyOpts); err != nil {
+							log.Printf("Warning: reading git history of %s: %v", repoName, err)
+						}
+					}
 
 					freeSpace, err := getFreeSpace(repoPath)
 					appFreeSpace, appErr := getFreeSpace(".")
@@ -208,6 +235,7 @@ func (cfg *MainArgConfig) cmdSiteServe(args []string) error {
 
 					sitesMu.Lock()
 					sites = append(sites, siteData)
+					repoDirs[siteData.RepoName] = repoPath
 					sitesMu.Unlock()
 					return nil
 				})
@@ -229,17 +257,58 @@ func (cfg *MainArgConfig) cmdSiteServe(args []string) error {
 
 	log.Printf("Pre-calculating site data (v%s) for %d repositories", version, len(sites))
 	genInfo := GenerationInfo{Args: cfg.Args}
+	addr := fmt.Sprintf(":%d", *port)
+	if *watch {
+		if *watchInterval < 0 {
+			return fmt.Errorf("-watch-interval must not be negative")
+		}
+		watcher, err := newSiteWatcher(sites, repoDirs, genInfo)
+		if err != nil {
+			return fmt.Errorf("initializing site server: %w", err)
+		}
+		watcher.history = historyOpts
+		go watcher.run(context.Background(), *watchInterval)
+		log.Printf("Starting live site server (v%s) at http://localhost%s, watching %d repositories for changes", version, addr, len(sites))
+		return http.ListenAndServe(addr, watcher)
+	}
+
 	handler, err := newSiteServer(sites, genInfo)
 	if err != nil {
 		return fmt.Errorf("initializing site server: %w", err)
 	}
+	handler.RepoDirs = repoDirs
 
-	addr := fmt.Sprintf(":%d", *port)
 	log.Printf("Starting live site server (v%s) at http://localhost%s", version, addr)
 
 	return http.ListenAndServe(addr, handler)
 }
 
+// serveFeed writes feed as the RSS or Atom feed named by name, index.rss or index.atom.
+func (s *SiteServer) serveFeed(w http.ResponseWriter, name string, feed g2.Feed) {
+	templateName, contentType, suffix := "rss.xml", "application/rss+xml", " RSS Feed"
+	if name == "index.atom" {
+		templateName, contentType, suffix = "atom.xml", "application/atom+xml", " Atom Feed"
+	}
+	feedTemplate, err := getXMLTemplate(templateName)
+	if err != nil {
+		log.Printf("Error: loading feed template %s: %v", templateName, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	feed = completeFeed(feed)
+	feed.Title += suffix
+	var buf bytes.Buffer
+	if err := feedTemplate.Execute(&buf, feed); err != nil {
+		log.Printf("Error: executing %s template: %v", templateName, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Printf("Error writing feed %s: %v", name, err)
+	}
+}
+
 func isOverlayDir(dir string) bool {
 	// A basic check to see if a directory looks like a Gentoo overlay.
 	// We'll check for profiles/repo_name or just profiles directory.
@@ -267,6 +336,14 @@ type SiteServer struct {
 	AggUseFlags []*AggUseFlag
 	ProjMap     map[string]*AggProject
 	RepoMap     map[string]*g2.SiteData
+
+	// RepoDirs maps repository names to the directories they were parsed from, for the lint endpoint.
+	RepoDirs map[string]string
+	// LiveReload adds a script to every page that reloads it when the watched repositories change.
+	LiveReload bool
+
+	searchOnce   sync.Once
+	searchEngine *SearchEngine
 }
 
 type ParsedIUSEFlag struct {
@@ -513,12 +590,19 @@ func (s *SiteServer) renderPageHTTP(w http.ResponseWriter, name string, data map
 		return
 	}
 
-	if _, err := w.Write(buf.Bytes()); err != nil {
+	page := buf.Bytes()
+	if s.LiveReload {
+		page = injectLiveReload(page)
+	}
+	if _, err := w.Write(page); err != nil {
 		log.Printf("Error writing response for %s: %v", name, err)
 	}
 }
 
 func (s *SiteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if serveSiteStatic(w, r) {
+		return
+	}
 	path := strings.TrimPrefix(r.URL.Path, "/")
 	path = strings.TrimSuffix(path, "/")
 	path = strings.TrimSuffix(path, "/index.html")
@@ -552,6 +636,10 @@ func (s *SiteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Route based on first part
 	switch parts[0] {
+	case "api":
+		s.serveAPI(w, r, parts[1:])
+		return
+
 	case "overlays":
 		if len(parts) == 1 {
 			s.renderPageHTTP(w, "overlays.html", map[string]interface{}{
@@ -1007,6 +1095,31 @@ func (s *SiteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 								"ValidLicenses": validLicenses,
 							})
 							return
+						} else if len(parts) == 7 && parts[6] == "history" && len(pkgData.History) > 0 {
+							s.renderPageHTTP(w, "repo_package_history.html", map[string]interface{}{
+								"Title":   fmt.Sprintf("%s - %s/%s - History", site.RepoName, pkgData.Category, pkgData.Name),
+								"BaseURL": baseURL,
+								"Breadcrumbs": []g2.Breadcrumb{
+									{Name: s.Title, URL: baseURL},
+									{Name: site.RepoName, URL: "../../../../../"},
+									{Name: "Categories", URL: "../../../../"},
+									{Name: pkgData.Category, URL: "../../../"},
+									{Name: pkgData.Name, URL: "../"},
+									{Name: "History"},
+								},
+								"Repo":        site,
+								"RepoPackage": pkgData,
+								"Changes":     pkgData.History,
+								"Version":     version,
+								"GenInfo":     s.GenInfo,
+							})
+							return
+						} else if len(parts) == 7 && parts[6] == "ChangeLog" && len(pkgData.History) > 0 && site.LayoutConf != nil && site.LayoutConf.UpdateChangelog() {
+							w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+							if err := g2.WriteChangeLog(w, pkgData.Category+"/"+pkgData.Name, pkgData.History); err != nil {
+								log.Printf("Error writing ChangeLog of %s/%s: %v", pkgData.Category, pkgData.Name, err)
+							}
+							return
 						} else if len(parts) == 7 && parts[6] == "ebuild" {
 							s.renderPageHTTP(w, "repo_package_ebuilds.html", map[string]interface{}{
 								"Title":   fmt.Sprintf("%s - %s/%s - Ebuilds", site.RepoName, pkgData.Category, pkgData.Name),
@@ -1109,6 +1222,29 @@ func (s *SiteServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 					}
 
+				case "changes":
+					if len(site.RecentChanges) == 0 {
+						break
+					}
+					if len(parts) == 3 {
+						s.renderPageHTTP(w, "repo_changes.html", map[string]interface{}{
+							"Title":       site.RepoName + " - Recent Changes",
+							"BaseURL":     baseURL,
+							"Breadcrumbs": []g2.Breadcrumb{{Name: s.Title, URL: baseURL}, {Name: "Overlays", URL: baseURL + "overlays/"}, {Name: site.RepoName, URL: "../"}, {Name: "Recent Changes"}},
+							"AlternateFeeds": []AlternateFeed{
+								{Type: "application/rss+xml", Title: site.RepoName + " Recent Changes RSS Feed", Href: "index.rss"},
+								{Type: "application/atom+xml", Title: site.RepoName + " Recent Changes Atom Feed", Href: "index.atom"},
+							},
+							"Repo":    site,
+							"Changes": site.RecentChanges,
+							"Version": version,
+							"GenInfo": s.GenInfo,
+						})
+						return
+					} else if len(parts) == 4 && (parts[3] == "index.rss" || parts[3] == "index.atom") {
+						s.serveFeed(w, parts[3], changesFeed(site))
+						return
+					}
 				case "packages":
 					if len(parts) == 3 {
 						var repoPkgs []g2.PackageData
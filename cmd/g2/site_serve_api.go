@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/arran4/g2"
+	"github.com/arran4/g2/lints"
+)
+
+// siteServeSearchLimit is the number of search results returned when the request does not set limit.
+const siteServeSearchLimit = 100
+
+type apiSearchResults struct {
+	APIVersion  int              `json:"api_version"`
+	Query       string           `json:"query"`
+	Total       int              `json:"total"`
+	Results     []SearchDocument `json:"results"`
+	Suggestions []string         `json:"suggestions,omitempty"`
+	Facets      []SearchFacet    `json:"facets"`
+}
+
+type apiLintResults struct {
+	APIVersion int                `json:"api_version"`
+	Repo       string             `json:"repo"`
+	Package    string             `json:"package"`
+	Results    []lints.LintResult `json:"results"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// serveAPI answers the JSON endpoints below /api/:
+//
+//	search?q=<query>&limit=<n>          ebuilds matching a search query, with facet counts over every
+//	                                    match and "did you mean" queries when nothing matches
+//	v1/repos.json                       the documents of the static JSON API, built from the served data
+//	v1/categories.json
+//	v1/<repo>/categories.json
+//	v1/<repo>/<category>/<package>.json
+//	lint/<repo>/<category>/<package>    lint results of a package, for repositories served from disk
+func (s *SiteServer) serveAPI(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 1 && parts[0] == "search":
+		s.serveAPISearch(w, r)
+	case len(parts) == 2 && parts[0] == "v1" && parts[1] == "repos.json":
+		writeAPIJSON(w, http.StatusOK, apiRepoListOf(s.Sites))
+	case len(parts) == 2 && parts[0] == "v1" && parts[1] == "categories.json":
+		writeAPIJSON(w, http.StatusOK, apiCategoryListOf(s.AggCategories))
+	case len(parts) == 3 && parts[0] == "v1" && parts[2] == "categories.json":
+		site, ok := s.RepoMap[parts[1]]
+		if !ok {
+			writeAPIJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown repository %q", parts[1])})
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, apiRepoCategoriesOf(site))
+	case len(parts) == 4 && parts[0] == "v1" && strings.HasSuffix(parts[3], ".json"):
+		site, pkg := s.findPackage(parts[1], parts[2], strings.TrimSuffix(parts[3], ".json"))
+		if pkg == nil {
+			writeAPIJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("no package %s/%s in %s", parts[2], strings.TrimSuffix(parts[3], ".json"), parts[1])})
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, apiPackageOf(site, pkg))
+	case len(parts) == 4 && parts[0] == "lint":
+		s.serveAPILint(w, parts[1], parts[2], parts[3])
+	default:
+		writeAPIJSON(w, http.StatusNotFound, apiError{Error: "unknown API endpoint " + r.URL.Path})
+	}
+}
+
+func (s *SiteServer) serveAPISearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	limit := siteServeSearchLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 {
+			writeAPIJSON(w, http.StatusBadRequest, apiError{Error: fmt.Sprintf("invalid limit %q", l)})
+			return
+		}
+		limit = n
+	}
+
+	s.searchOnce.Do(func() {
+		s.searchEngine = NewSearchEngine()
+		s.searchEngine.LoadDocuments(buildSearchDocuments(s.Sites))
+	})
+	results := s.searchEngine.Search(query)
+	res := apiSearchResults{APIVersion: siteAPIVersion, Query: query, Total: len(results), Results: []SearchDocument{}, Facets: countSearchFacets(results)}
+	if len(results) == 0 {
+		res.Suggestions = s.searchEngine.Suggest(query)
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	res.Results = append(res.Results, results...)
+	writeAPIJSON(w, http.StatusOK, res)
+}
+
+func (s *SiteServer) serveAPILint(w http.ResponseWriter, repoName, catName, pkgName string) {
+	site, pkg := s.findPackage(repoName, catName, pkgName)
+	if pkg == nil {
+		writeAPIJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("no package %s/%s in %s", catName, pkgName, repoName)})
+		return
+	}
+	repoDir, ok := s.RepoDirs[site.RepoName]
+	if !ok {
+		writeAPIJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("repository %s is not served from disk", repoName)})
+		return
+	}
+	pkgCopy := *pkg
+	res := apiLintResults{
+		APIVersion: siteAPIVersion,
+		Repo:       site.RepoName,
+		Package:    catName + "/" + pkgName,
+		Results:    []lints.LintResult{},
+	}
+	res.Results = append(res.Results, lints.PerformLintingResults(repoDir, &pkgCopy)...)
+	writeAPIJSON(w, http.StatusOK, res)
+}
+
+// findPackage returns the package catName/pkgName of the repository repoName.
+func (s *SiteServer) findPackage(repoName, catName, pkgName string) (*g2.SiteData, *g2.PackageData) {
+	site, ok := s.RepoMap[repoName]
+	if !ok {
+		return nil, nil
+	}
+	for i := range site.Categories {
+		if site.Categories[i].Name != catName {
+			continue
+		}
+		for j := range site.Categories[i].Packages {
+			if site.Categories[i].Packages[j].Name == pkgName {
+				return site, &site.Categories[i].Packages[j]
+			}
+		}
+	}
+	return site, nil
+}
+
+func writeAPIJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Error writing API response: %v", err)
+	}
+}
@@ -0,0 +1,527 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arran4/g2"
+	"github.com/fsnotify/fsnotify"
+)
+
+// siteWatchPollInterval is how often site serve -watch polls the repositories when OS file notifications
+// are unavailable and -watch-interval is not given.
+const siteWatchPollInterval = 10 * time.Second
+
+// siteWatchSettle is how long the watcher waits after a file notification for related changes, such as
+// the other files of a package being written, before parsing again.
+const siteWatchSettle = 200 * time.Millisecond
+
+// siteWatchEventsPath is the server-sent events stream that tells open pages to reload.
+const siteWatchEventsPath = "/_g2/events"
+
+// liveReloadScript is added to pages served in watch mode.
+const liveReloadScript = `<script>new EventSource("` + siteWatchEventsPath + `").addEventListener("reload", function () { location.reload(); });</script>`
+
+// injectLiveReload adds the live reload script to the end of the body of page.
+func injectLiveReload(page []byte) []byte {
+	i := bytes.LastIndex(page, []byte("</body>"))
+	if i < 0 {
+		return append(page, liveReloadScript...)
+	}
+	out := make([]byte, 0, len(page)+len(liveReloadScript))
+	out = append(out, page[:i]...)
+	out = append(out, liveReloadScript...)
+	return append(out, page[i:]...)
+}
+
+type fileStamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// watchedRepo is a repository served by site serve -watch, with the files it had on the last poll.
+type watchedRepo struct {
+	Dir   string
+	Files map[string]fileStamp
+}
+
+// siteWatcher serves the site of repositories on disk, parsing again the packages whose files change and
+// telling open pages to reload. Changes are found through OS file notifications, or by polling the files
+// of the repositories where those are unavailable, as on some network file systems.
+type siteWatcher struct {
+	genInfo GenerationInfo
+	// history, when set, is read again into each repository that is parsed again.
+	history *g2.HistoryOptions
+
+	mu     sync.RWMutex
+	sites  []*g2.SiteData
+	repos  []*watchedRepo // parallel to sites
+	server *SiteServer
+
+	clientsMu sync.Mutex
+	clients   map[chan struct{}]bool
+}
+
+// newSiteWatcher records the files of the repositories in dirs, which sites were parsed from.
+func newSiteWatcher(sites []*g2.SiteData, dirs map[string]string, genInfo GenerationInfo) (*siteWatcher, error) {
+	w := &siteWatcher{genInfo: genInfo, sites: sites, clients: map[chan struct{}]bool{}}
+	for _, site := range sites {
+		dir, ok := dirs[site.RepoName]
+		if !ok {
+			return nil, fmt.Errorf("no directory for repository %s", site.RepoName)
+		}
+		files, err := scanRepoFiles(dir)
+		if err != nil {
+			return nil, fmt.Errorf("scanning %s: %w", dir, err)
+		}
+		w.repos = append(w.repos, &watchedRepo{Dir: dir, Files: files})
+	}
+	server, err := w.newServer()
+	if err != nil {
+		return nil, err
+	}
+	w.server = server
+	return w, nil
+}
+
+func (w *siteWatcher) newServer() (*SiteServer, error) {
+	server, err := newSiteServer(w.sites, w.genInfo)
+	if err != nil {
+		return nil, err
+	}
+	server.RepoDirs = map[string]string{}
+	for i, site := range w.sites {
+		server.RepoDirs[site.RepoName] = w.repos[i].Dir
+	}
+	server.LiveReload = true
+	return server, nil
+}
+
+// scanRepoFiles returns the modification time and size of the files of the repository at dir, by slash
+// separated path. Hidden directories and the directories that are not parsed are left out.
+func scanRepoFiles(dir string) (map[string]fileStamp, error) {
+	files := map[string]fileStamp{}
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && unwatchedRepoDir(rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[rel] = fileStamp{ModTime: info.ModTime(), Size: info.Size()}
+		return nil
+	})
+	return files, err
+}
+
+// unwatchedRepoDir reports whether changes below the directory at rel, a slash separated path relative to
+// the repository root, are ignored: hidden directories and the directories that are not parsed.
+func unwatchedRepoDir(rel string) bool {
+	return strings.HasPrefix(path.Base(rel), ".") || rel == "distfiles" || rel == "packages" || rel == "scripts" || rel == "metadata/md5-cache"
+}
+
+// unwatchedRepoPath reports whether a change of the file or directory at rel is ignored.
+func unwatchedRepoPath(rel string) bool {
+	for dir := rel; dir != "."; dir = path.Dir(dir) {
+		if unwatchedRepoDir(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// changedRepoFiles returns the paths that were added, removed or modified between two scans.
+func changedRepoFiles(before, after map[string]fileStamp) []string {
+	var changed []string
+	for p, st := range after {
+		if prev, ok := before[p]; !ok || !prev.ModTime.Equal(st.ModTime) || prev.Size != st.Size {
+			changed = append(changed, p)
+		}
+	}
+	for p := range before {
+		if _, ok := after[p]; !ok {
+			changed = append(changed, p)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// affectedPackages maps changed repository files to the category/name of the packages they belong to.
+// It reports whole when a repository-wide file changed, such as a profile, eclass or license, which
+// affects every package.
+func affectedPackages(changed []string) (pkgs []string, whole bool) {
+	seen := map[string]bool{}
+	for _, p := range changed {
+		parts := strings.Split(p, "/")
+		switch {
+		case isIgnoredDir(parts[0]):
+			whole = true
+		case len(parts) >= 3:
+			key := parts[0] + "/" + parts[1]
+			if !seen[key] {
+				seen[key] = true
+				pkgs = append(pkgs, key)
+			}
+		}
+	}
+	return pkgs, whole
+}
+
+// repoChange is what changed in a watched repository since it was last parsed.
+type repoChange struct {
+	index int
+	files int
+	pkgs  []string
+	whole bool
+}
+
+// poll scans the watched repositories and parses again what changed. It reports whether the served data
+// changed.
+func (w *siteWatcher) poll() bool {
+	var changes []repoChange
+	for i, repo := range w.repos {
+		files, err := scanRepoFiles(repo.Dir)
+		if err != nil {
+			log.Printf("Warning: scanning %s: %v", repo.Dir, err)
+			continue
+		}
+		changed := changedRepoFiles(repo.Files, files)
+		repo.Files = files
+		if pkgs, whole := affectedPackages(changed); whole || len(pkgs) > 0 {
+			changes = append(changes, repoChange{index: i, files: len(changed), pkgs: pkgs, whole: whole})
+		}
+	}
+	return w.apply(changes)
+}
+
+// apply parses again what changes holds and serves the result. The repositories are parsed into copies
+// of their data while the current data is still served; only the swap stops serving. It reports whether
+// the served data changed.
+func (w *siteWatcher) apply(changes []repoChange) bool {
+	if len(changes) == 0 {
+		return false
+	}
+	// Only the goroutine applying changes replaces the sites, so they can be read here without the lock.
+	sites := append([]*g2.SiteData(nil), w.sites...)
+	updated := false
+	for _, c := range changes {
+		repo, site := w.repos[c.index], sites[c.index]
+		start := time.Now()
+		if c.whole {
+			parsed, err := parseRepo(os.DirFS(repo.Dir), ".", site.RepoName, false, site.Repository)
+			if err != nil {
+				log.Printf("Warning: parsing %s again: %v", repo.Dir, err)
+				continue
+			}
+			w.attachHistory(repo.Dir, parsed)
+			sites[c.index] = parsed
+			updated = true
+			log.Printf("[WATCH] Parsed %s again in %s after %d changed files", parsed.RepoName, time.Since(start).Round(time.Millisecond), c.files)
+			continue
+		}
+		site = cloneSitePackages(site)
+		for _, key := range c.pkgs {
+			reparseRepoPackage(os.DirFS(repo.Dir), ".", site, path.Dir(key), path.Base(key))
+		}
+		w.attachHistory(repo.Dir, site)
+		sites[c.index] = site
+		updated = true
+		log.Printf("[WATCH] Parsed %s again in %s", strings.Join(c.pkgs, ", "), time.Since(start).Round(time.Millisecond))
+	}
+	if !updated {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	previous := w.sites
+	w.sites = sites
+	server, err := w.newServer()
+	if err != nil {
+		log.Printf("Warning: rebuilding site data: %v", err)
+		w.sites = previous
+		return false
+	}
+	w.server = server
+	w.notify()
+	return true
+}
+
+// attachHistory reads the git history of the repository at dir into site again when the watcher serves
+// history.
+func (w *siteWatcher) attachHistory(dir string, site *g2.SiteData) {
+	if w.history == nil {
+		return
+	}
+	if err := attachRepoHistory(dir, site, *w.history); err != nil {
+		log.Printf("Warning: reading git history of %s: %v", dir, err)
+	}
+}
+
+// cloneSitePackages returns a copy of site whose categories, packages and versions can be changed without
+// changing site.
+func cloneSitePackages(site *g2.SiteData) *g2.SiteData {
+	c := *site
+	c.Categories = make([]g2.CategoryData, len(site.Categories))
+	for i, cat := range site.Categories {
+		cat.Packages = append([]g2.PackageData(nil), cat.Packages...)
+		for j := range cat.Packages {
+			cat.Packages[j].Versions = append([]g2.VersionData(nil), cat.Packages[j].Versions...)
+		}
+		c.Categories[i] = cat
+	}
+	return &c
+}
+
+// run watches the repositories until ctx is done. A positive interval polls them at that interval;
+// otherwise OS file notifications are used, falling back to polling every siteWatchPollInterval when they
+// cannot be set up.
+func (w *siteWatcher) run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		err := w.watch(ctx)
+		if err == nil {
+			return
+		}
+		log.Printf("Warning: watching the repositories for changes: %v; polling every %s instead", err, siteWatchPollInterval)
+		interval = siteWatchPollInterval
+		// Changes made while the notifications were set up are found by the first poll.
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+// watch parses again what changes in the watched repositories, as reported by OS file notifications,
+// until ctx is done. It returns an error when the notifications cannot be set up.
+func (w *siteWatcher) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = watcher.Close() }()
+	for i := range w.repos {
+		if _, err := addRepoWatches(watcher, w.repos[i].Dir, w.repos[i].Dir); err != nil {
+			return err
+		}
+	}
+
+	pending := map[int]map[string]bool{}
+	wholeRepos := map[int]bool{}
+	var settle <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("Warning: watching the repositories: %v", err)
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			i, rel, ok := w.repoPath(ev.Name)
+			if !ok || unwatchedRepoPath(rel) {
+				continue
+			}
+			if pending[i] == nil {
+				pending[i] = map[string]bool{}
+			}
+			settle = time.After(siteWatchSettle)
+			isDir := false
+			if ev.Has(fsnotify.Create) {
+				if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+					isDir = true
+					// Files written before the directory is watched are only found by its walk.
+					added, err := addRepoWatches(watcher, w.repos[i].Dir, ev.Name)
+					if err != nil {
+						log.Printf("Warning: watching %s: %v", ev.Name, err)
+					}
+					for _, p := range added {
+						pending[i][p] = true
+					}
+				}
+			}
+			category, name, _ := strings.Cut(rel, "/")
+			switch {
+			case name == "" && ev.Has(fsnotify.Remove|fsnotify.Rename) && siteCategoryIndex(w.sites[i], category) >= 0:
+				// A category directory went away with its packages.
+				wholeRepos[i] = true
+			case name != "" && !strings.Contains(name, "/") && (isDir || sitePackageExists(w.sites[i], category, name)):
+				// A package directory itself changed; affectedPackages finds packages by their files.
+				rel += "/"
+			}
+			pending[i][rel] = true
+		case <-settle:
+			settle = nil
+			var changes []repoChange
+			for i, files := range pending {
+				changed := make([]string, 0, len(files))
+				for p := range files {
+					changed = append(changed, p)
+				}
+				sort.Strings(changed)
+				pkgs, whole := affectedPackages(changed)
+				if whole = whole || wholeRepos[i]; whole || len(pkgs) > 0 {
+					changes = append(changes, repoChange{index: i, files: len(changed), pkgs: pkgs, whole: whole})
+				}
+			}
+			sort.Slice(changes, func(a, b int) bool { return changes[a].index < changes[b].index })
+			pending, wholeRepos = map[int]map[string]bool{}, map[int]bool{}
+			w.apply(changes)
+		}
+	}
+}
+
+// siteCategoryIndex returns the index of category in the sorted categories of site, or -1.
+func siteCategoryIndex(site *g2.SiteData, category string) int {
+	i := sort.Search(len(site.Categories), func(i int) bool { return site.Categories[i].Name >= category })
+	if i < len(site.Categories) && site.Categories[i].Name == category {
+		return i
+	}
+	return -1
+}
+
+// sitePackageExists reports whether site has the package category/name.
+func sitePackageExists(site *g2.SiteData, category, name string) bool {
+	ci := siteCategoryIndex(site, category)
+	if ci < 0 {
+		return false
+	}
+	for _, pkg := range site.Categories[ci].Packages {
+		if pkg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// repoPath returns the watched repository holding the file at name and the slash separated path of the
+// file relative to its root.
+func (w *siteWatcher) repoPath(name string) (int, string, bool) {
+	for i, repo := range w.repos {
+		rel, err := filepath.Rel(repo.Dir, name)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return i, filepath.ToSlash(rel), true
+	}
+	return 0, "", false
+}
+
+// addRepoWatches watches dir and the directories below it that are parsed, in the repository at root. It
+// returns the files found below dir, relative to root.
+func addRepoWatches(watcher *fsnotify.Watcher, root, dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !d.IsDir() {
+			files = append(files, rel)
+			return nil
+		}
+		if rel != "." && unwatchedRepoDir(rel) {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(p); err != nil {
+			return fmt.Errorf("watching %s: %w", p, err)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// notify tells every open page to reload.
+func (w *siteWatcher) notify() {
+	w.clientsMu.Lock()
+	defer w.clientsMu.Unlock()
+	for ch := range w.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+			// A reload is already pending for this page.
+		}
+	}
+}
+
+func (w *siteWatcher) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == siteWatchEventsPath {
+		w.serveEvents(rw, r)
+		return
+	}
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	w.server.ServeHTTP(rw, r)
+}
+
+// serveEvents streams a reload event to the page each time the served data changes.
+func (w *siteWatcher) serveEvents(rw http.ResponseWriter, r *http.Request) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan struct{}, 1)
+	w.clientsMu.Lock()
+	w.clients[ch] = true
+	w.clientsMu.Unlock()
+	defer func() {
+		w.clientsMu.Lock()
+		delete(w.clients, ch)
+		w.clientsMu.Unlock()
+	}()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	_, _ = fmt.Fprint(rw, ": watching\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			if _, err := fmt.Fprint(rw, "event: reload\ndata: {}\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arran4/g2"
+)
+
+// touchTestFile writes content to name below root with a modification time that a poll cannot miss.
+func touchTestFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	p := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(p, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getTestPage(t *testing.T, h http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+	return rec
+}
+
+func TestSiteWatcher(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":        "watched\n",
+		"profiles/categories":       "app-misc\nvirtual\n",
+		"metadata/layout.conf":      "masters = gentoo\n",
+		"app-misc/foo/foo-1.ebuild": "EAPI=8\nDESCRIPTION=\"Old foo\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+		"app-misc/bar/bar-1.ebuild": "EAPI=8\nDESCRIPTION=\"Bar\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+		"virtual/tool/tool-1.ebuild": "EAPI=8\nDESCRIPTION=\"Virtual\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n" +
+			"RDEPEND=\"|| ( app-misc/foo app-misc/baz )\"\n",
+	})
+	site, err := parseRepo(os.DirFS(repo), ".", "Watched", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	w, err := newSiteWatcher([]*g2.SiteData{site}, map[string]string{"watched": repo}, GenerationInfo{})
+	if err != nil {
+		t.Fatalf("newSiteWatcher: %v", err)
+	}
+	if w.poll() {
+		t.Error("poll reports changes in an unchanged repository")
+	}
+
+	page := getTestPage(t, w, "/repos/watched/categories/app-misc/").Body.String()
+	if !strings.Contains(page, "Old foo") || !strings.Contains(page, siteWatchEventsPath) {
+		t.Fatalf("category page lacks the description or the reload script: %s", page)
+	}
+
+	touchTestFile(t, repo, "app-misc/foo/foo-1.ebuild", "EAPI=8\nDESCRIPTION=\"New foo\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n")
+	touchTestFile(t, repo, "app-misc/baz/baz-2.ebuild", "EAPI=8\nDESCRIPTION=\"Baz\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n")
+	if err := os.RemoveAll(filepath.Join(repo, "app-misc", "bar")); err != nil {
+		t.Fatal(err)
+	}
+	if !w.poll() {
+		t.Fatal("poll missed the changed packages")
+	}
+	if page := getTestPage(t, w, "/repos/watched/categories/app-misc/").Body.String(); !strings.Contains(page, "New foo") {
+		t.Error("category page still shows the old description")
+	}
+	if rec := getTestPage(t, w, "/repos/watched/categories/app-misc/packages/baz/"); rec.Code != http.StatusOK {
+		t.Errorf("added package: %d", rec.Code)
+	}
+	if rec := getTestPage(t, w, "/repos/watched/categories/app-misc/packages/bar/"); rec.Code != http.StatusNotFound {
+		t.Errorf("removed package: %d", rec.Code)
+	}
+	if w.sites[0].PackageCount != 3 {
+		t.Errorf("package count = %d", w.sites[0].PackageCount)
+	}
+	var baz *g2.PackageData
+	for i := range w.sites[0].Categories {
+		for j := range w.sites[0].Categories[i].Packages {
+			if p := &w.sites[0].Categories[i].Packages[j]; p.Name == "baz" {
+				baz = p
+			}
+		}
+	}
+	if baz == nil || strings.Join(baz.ReverseVirtuals, " ") != "virtual/tool" {
+		t.Errorf("virtuals of the added package not linked: %+v", baz)
+	}
+
+	// A repository-wide change parses the whole repository again.
+	touchTestFile(t, repo, "profiles/package.mask", "# Jane Doe <jane@example.org> (2026-01-02)\n# Broken build.\napp-misc/foo\n")
+	if !w.poll() {
+		t.Fatal("poll missed the repository-wide change")
+	}
+	if len(w.sites[0].Masked) == 0 {
+		t.Error("package.mask not parsed again")
+	}
+}
+
+func TestSiteWatcherEvents(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":        "events\n",
+		"profiles/categories":       "app-misc\n",
+		"app-misc/foo/foo-1.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\n",
+	})
+	site, err := parseRepo(os.DirFS(repo), ".", "Events", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	w, err := newSiteWatcher([]*g2.SiteData{site}, map[string]string{"events": repo}, GenerationInfo{})
+	if err != nil {
+		t.Fatalf("newSiteWatcher: %v", err)
+	}
+	srv := httptest.NewServer(w)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+siteWatchEventsPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() || !strings.HasPrefix(lines.Text(), ":") {
+		t.Fatalf("event stream did not open: %q", lines.Text())
+	}
+
+	touchTestFile(t, repo, "app-misc/foo/foo-1.ebuild", "EAPI=8\nDESCRIPTION=\"Changed\"\nSLOT=\"0\"\n")
+	if !w.poll() {
+		t.Fatal("poll missed the change")
+	}
+	for lines.Scan() {
+		if lines.Text() == "event: reload" {
+			return
+		}
+	}
+	t.Errorf("no reload event: %v", lines.Err())
+}
+
+func TestSiteWatcherNotifications(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":        "notified\n",
+		"profiles/categories":       "app-misc\n",
+		"app-misc/foo/foo-1.ebuild": "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\n",
+	})
+	site, err := parseRepo(os.DirFS(repo), ".", "Notified", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	w, err := newSiteWatcher([]*g2.SiteData{site}, map[string]string{"notified": repo}, GenerationInfo{})
+	if err != nil {
+		t.Fatalf("newSiteWatcher: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.run(ctx, 0)
+
+	// The watches are set up in the background; keep writing, slower than the watcher settles, until the
+	// change is seen.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		writeTestFiles(t, repo, map[string]string{"app-misc/bar/bar-1.ebuild": "EAPI=8\nDESCRIPTION=\"Bar\"\nSLOT=\"0\"\n"})
+		if rec := getTestPage(t, w, "/repos/notified/categories/app-misc/packages/bar/"); rec.Code == http.StatusOK {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the new package was not parsed")
+		}
+		time.Sleep(3 * siteWatchSettle)
+	}
+	if len(site.Categories[0].Packages) != 1 {
+		t.Errorf("the served data was changed in place: %d packages", len(site.Categories[0].Packages))
+	}
+}
+
+func TestSiteServerAPI(t *testing.T) {
+	repo := t.TempDir()
+	writeTestFiles(t, repo, map[string]string{
+		"profiles/repo_name":  "live\n",
+		"profiles/categories": "app-misc\ndev-libs\n",
+		"app-misc/foo/foo-1.0.ebuild": "EAPI=8\nDESCRIPTION=\"Foo tool\"\nLICENSE=\"MIT\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n" +
+			"RDEPEND=\"dev-libs/bar\"\n",
+		"dev-libs/bar/bar-2.ebuild": "EAPI=8\nDESCRIPTION=\"Bar library\"\nLICENSE=\"MIT\"\nSLOT=\"0\"\nKEYWORDS=\"amd64\"\n",
+	})
+	site, err := parseRepo(os.DirFS(repo), ".", "Live", false, nil)
+	if err != nil {
+		t.Fatalf("parseRepo: %v", err)
+	}
+	server, err := newSiteServer([]*g2.SiteData{site}, GenerationInfo{})
+	if err != nil {
+		t.Fatalf("newSiteServer: %v", err)
+	}
+	server.RepoDirs = map[string]string{"live": repo}
+
+	rec := getTestPage(t, server, "/api/search?q=library")
+	var search apiSearchResults
+	if err := json.Unmarshal(rec.Body.Bytes(), &search); err != nil {
+		t.Fatalf("search response %q: %v", rec.Body.String(), err)
+	}
+	if search.Total != 1 || len(search.Results) != 1 || search.Results[0].FullName != "dev-libs/bar" {
+		t.Errorf("search = %+v", search)
+	}
+	rec = getTestPage(t, server, "/api/search?limit=1")
+	search = apiSearchResults{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &search); err != nil || search.Total != 2 || len(search.Results) != 1 {
+		t.Errorf("limited search = %s", rec.Body.String())
+	}
+	var arches []SearchFacetValue
+	for _, f := range search.Facets {
+		if f.Field == "arch" {
+			arches = f.Values
+		}
+	}
+	if !reflect.DeepEqual(arches, []SearchFacetValue{{"amd64", 2}}) {
+		t.Errorf("arch facet of a limited search = %v", arches)
+	}
+	rec = getTestPage(t, server, "/api/search?q=libary")
+	search = apiSearchResults{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &search); err != nil || search.Total != 0 || len(search.Suggestions) != 1 || search.Suggestions[0] != "library" {
+		t.Errorf("misspelt search = %s", rec.Body.String())
+	}
+	if rec := getTestPage(t, server, "/api/search?limit=x"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid limit: %d", rec.Code)
+	}
+
+	rec = getTestPage(t, server, "/api/v1/live/app-misc/foo.json")
+	var foo apiPackage
+	if err := json.Unmarshal(rec.Body.Bytes(), &foo); err != nil {
+		t.Fatalf("package response %q: %v", rec.Body.String(), err)
+	}
+	if foo.Name != "foo" || len(foo.Versions) != 1 || foo.Versions[0].Dependencies["rdepend"].Atoms[0] != "dev-libs/bar" {
+		t.Errorf("package = %+v", foo)
+	}
+	if rec := getTestPage(t, server, "/api/v1/repos.json"); !strings.Contains(rec.Body.String(), `"name": "live"`) {
+		t.Errorf("repos = %s", rec.Body.String())
+	}
+	if rec := getTestPage(t, server, "/api/v1/live/app-misc/missing.json"); rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Errorf("missing package: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = getTestPage(t, server, "/api/lint/live/app-misc/foo")
+	var lintRes apiLintResults
+	if err := json.Unmarshal(rec.Body.Bytes(), &lintRes); err != nil {
+		t.Fatalf("lint response %q: %v", rec.Body.String(), err)
+	}
+	if lintRes.Package != "app-misc/foo" || len(lintRes.Results) == 0 {
+		t.Errorf("lint = %+v", lintRes)
+	}
+	server.RepoDirs = nil
+	if rec := getTestPage(t, server, "/api/lint/live/app-misc/foo"); rec.Code != http.StatusNotFound {
+		t.Errorf("lint without the repository on disk: %d", rec.Code)
+	}
+}
@@ -3,12 +3,11 @@ package main
 import (
 	"fmt"
 	"html/template"
-	"io/fs"
-	"path/filepath"
+	"path"
+	"sort"
 	"strings"
 	"sync"
-
-	"github.com/arran4/g2/templates"
+	"text/template/parse"
 )
 
 var (
@@ -17,45 +16,91 @@ var (
 	siteTmplOnce  sync.Once
 )
 
-// GetSiteTemplates loads templates from templates/app, templates/partials, and templates/views
-// once, and returns the parsed template registry.
+// GetSiteTemplates loads templates from templates/app, templates/partials, and templates/views,
+// with the files of the -templates directory laid over them, once, and returns the parsed template
+// registry.
 func GetSiteTemplates() (*template.Template, error) {
 	siteTmplOnce.Do(func() {
 		tmpl := template.New("").Funcs(getTemplateFuncMap())
 
-		err := fs.WalkDir(templates.SiteFS, ".", func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
+		files, err := siteTemplateFiles()
+		if err != nil {
+			siteTmplErr = err
+			return
+		}
+		sources := map[string]string{}
+		for _, f := range files {
+			if !strings.HasSuffix(f.Path, ".html") {
+				continue
 			}
-			if d.IsDir() {
-				return nil
+			source := f.Path
+			if f.Override != "" {
+				source = f.Override
 			}
 
-			if strings.HasSuffix(path, ".html") {
-				b, err := fs.ReadFile(templates.SiteFS, path)
-				if err != nil {
-					return err
-				}
-
-				name := filepath.Base(path)
-
-				// Optional: if we want to name them by path for partials
-				// For now, base name is used as before, but we can change this later if collisions happen
-
-				_, err = tmpl.New(name).Parse(string(b))
-				if err != nil {
-					return fmt.Errorf("parsing template %s: %w", path, err)
-				}
+			// Templates are named by base name, so an override replaces the embedded file wherever it sits.
+			name := path.Base(f.Path)
+			if _, err := tmpl.New(name).Parse(string(f.Data)); err != nil {
+				siteTmplErr = fmt.Errorf("parsing template %s: %w", source, err)
+				return
 			}
+			sources[name] = source
+		}
 
-			return nil
-		})
-
-		if err != nil {
+		if err := checkTemplateReferences(tmpl, sources); err != nil {
 			siteTmplErr = err
-		} else {
-			siteTemplates = tmpl
+			return
 		}
+		siteTemplates = tmpl
 	})
 	return siteTemplates, siteTmplErr
 }
+
+// checkTemplateReferences reports {{template}} actions that name a template which does not exist, which
+// would otherwise only fail when a page using it is rendered.
+func checkTemplateReferences(tmpl *template.Template, sources map[string]string) error {
+	var problems []string
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil || t.Tree.Root == nil {
+			continue
+		}
+		source := sources[t.Name()]
+		if source == "" {
+			source = t.Name()
+		}
+		walkTemplateNodes(t.Tree.Root, func(n *parse.TemplateNode) {
+			if tmpl.Lookup(n.Name) == nil {
+				problems = append(problems, fmt.Sprintf("%s references missing template %q", source, n.Name))
+			}
+		})
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("site templates: %s", strings.Join(problems, "; "))
+}
+
+// walkTemplateNodes calls fn for every {{template}} action below n.
+func walkTemplateNodes(n parse.Node, fn func(*parse.TemplateNode)) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walkTemplateNodes(c, fn)
+		}
+	case *parse.TemplateNode:
+		fn(n)
+	case *parse.IfNode:
+		walkTemplateNodes(n.List, fn)
+		walkTemplateNodes(n.ElseList, fn)
+	case *parse.RangeNode:
+		walkTemplateNodes(n.List, fn)
+		walkTemplateNodes(n.ElseList, fn)
+	case *parse.WithNode:
+		walkTemplateNodes(n.List, fn)
+		walkTemplateNodes(n.ElseList, fn)
+	}
+}
@@ -156,6 +156,8 @@ func NewGenericPageContext(opts ...GenericPageContextOption) GenericPageContext
 		Eclass:         &AggEclass{},
 		UseExpandDesc:  &g2.UseExpandDesc{},
 		GlobalCategory: &AggCategory{},
+		EbuildDiff:     &g2.EbuildDiff{},
+		KeywordMatrix:  &g2.KeywordMatrix{},
 	}
 	for _, opt := range opts {
 		opt(&ctx)
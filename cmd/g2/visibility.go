@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+// repoEbuild is an ebuild available in one of the repositories of the stack.
+type repoEbuild struct {
+	Repo      *g2.RepoInfo
+	Path      string // Path relative to the repository root
+	Candidate g2.AtomCandidate
+	Keywords  []string
+}
+
+// listRepoEbuilds returns every ebuild of category/name across repos, with SLOT and KEYWORDS parsed.
+func listRepoEbuilds(repos []*g2.RepoInfo, category, name string) ([]repoEbuild, error) {
+	var res []repoEbuild
+	for _, repo := range repos {
+		fsys := os.DirFS(repo.Location)
+		dir := path.Join(category, name)
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("reading %s in %s: %w", dir, repo.RepoName, err)
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".ebuild") {
+				continue
+			}
+			p := path.Join(dir, e.Name())
+			eb, err := g2.ParseEbuild(fsys, p, g2.ParseVariables)
+			if err != nil {
+				log.Printf("Warning: skipping %s::%s: %v", p, repo.RepoName, err)
+				continue
+			}
+			slot := eb.Vars["SLOT"]
+			if slot == "" {
+				slot = "0"
+			}
+			res = append(res, repoEbuild{
+				Repo:      repo,
+				Path:      p,
+				Candidate: g2.AtomCandidate{Category: category, Name: name, Version: eb.Vars["PVR"], Slot: slot, Repo: repo.RepoName},
+				Keywords:  strings.Fields(eb.Vars["KEYWORDS"]),
+			})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return g2.CompareVersions(res[i].Candidate.Version, res[j].Candidate.Version) < 0
+	})
+	return res, nil
+}
+
+// visibility holds the configuration that decides whether an ebuild may be installed.
+type visibility struct {
+	AcceptKeywords []string
+	Arch           string
+	// PackageKeywords are the package.accept_keywords entries, each accepting its keywords for the
+	// packages its atom matches.
+	PackageKeywords []packageKeywords
+	Masks           []g2.PackageAtom
+	Unmasks         []g2.PackageAtom
+}
+
+// packageKeywords is an entry of package.accept_keywords.
+type packageKeywords struct {
+	Atom     g2.PackageAtom
+	Keywords []string
+}
+
+// loadVisibility collects ARCH and ACCEPT_KEYWORDS from the make.defaults files of the make.profile stack
+// and make.conf (unless acceptOverride is set), package.accept_keywords under configRoot, the package.mask
+// files of every repository and of the profile stack, and the package.mask and package.unmask files under
+// configRoot.
+func loadVisibility(repos []*g2.RepoInfo, configRoot, makeConfPath, acceptOverride string) (*visibility, error) {
+	conf, err := loadPortageConfig(repos, configRoot, makeConfPath)
+	if err != nil {
+		return nil, err
+	}
+	v := &visibility{Arch: conf.Vars["ARCH"]}
+	if acceptOverride != "" {
+		v.AcceptKeywords = strings.Fields(acceptOverride)
+	} else {
+		v.AcceptKeywords = conf.AcceptKeywords()
+	}
+	if len(v.AcceptKeywords) == 0 {
+		log.Printf("Warning: neither ACCEPT_KEYWORDS nor ARCH is set; keywords will not be considered")
+		v.AcceptKeywords = []string{"**"}
+	}
+
+	readAtoms := func(p string) ([]g2.PackageAtom, error) {
+		entries, err := g2.ReadUserConfigEntries(p)
+		if err != nil {
+			return nil, err
+		}
+		var atoms []g2.PackageAtom
+		for _, e := range entries {
+			atoms = append(atoms, e.Atom)
+		}
+		return atoms, nil
+	}
+
+	for _, repo := range repos {
+		atoms, err := readAtoms(filepath.Join(repo.Location, "profiles", "package.mask"))
+		if err != nil {
+			return nil, err
+		}
+		v.Masks = append(v.Masks, atoms...)
+	}
+	atoms, err := g2.ReadProfilePackageMask(conf.Profile)
+	if err != nil {
+		return nil, err
+	}
+	v.Masks = append(v.Masks, atoms...)
+	if configRoot != "" {
+		atoms, err := readAtoms(filepath.Join(configRoot, "package.mask"))
+		if err != nil {
+			return nil, err
+		}
+		v.Masks = append(v.Masks, atoms...)
+		if v.Unmasks, err = readAtoms(filepath.Join(configRoot, "package.unmask")); err != nil {
+			return nil, err
+		}
+		entries, err := g2.ReadUserConfigEntries(filepath.Join(configRoot, "package.accept_keywords"))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			line := e.RawLine
+			if i := strings.Index(line, "#"); i != -1 {
+				line = line[:i]
+			}
+			v.PackageKeywords = append(v.PackageKeywords, packageKeywords{Atom: e.Atom, Keywords: strings.Fields(line)[1:]})
+		}
+	}
+	return v, nil
+}
+
+// acceptKeywords returns the keywords accepted for c: ACCEPT_KEYWORDS followed by the keywords of every
+// matching package.accept_keywords entry. An entry without keywords accepts ~ARCH, as in Portage.
+func (v *visibility) acceptKeywords(c g2.AtomCandidate) []string {
+	accept := v.AcceptKeywords
+	for _, pk := range v.PackageKeywords {
+		if !pk.Atom.Matches(c) {
+			continue
+		}
+		accept = append(accept[:len(accept):len(accept)], pk.Keywords...)
+		if len(pk.Keywords) == 0 && v.Arch != "" {
+			accept = append(accept, "~"+v.Arch)
+		}
+	}
+	return accept
+}
+
+// Masked reports whether c is masked by package.mask and not unmasked by package.unmask.
+func (v *visibility) Masked(c g2.AtomCandidate) bool {
+	masked := false
+	for _, m := range v.Masks {
+		if m.Matches(c) {
+			masked = true
+			break
+		}
+	}
+	if !masked {
+		return false
+	}
+	for _, u := range v.Unmasks {
+		if u.Matches(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Visible reports whether e is accepted by the keyword configuration and is not masked.
+func (v *visibility) Visible(e repoEbuild) bool {
+	return g2.KeywordsAccepted(e.Keywords, v.acceptKeywords(e.Candidate)) && !v.Masked(e.Candidate)
+}
+
+// bestVisible returns the highest visible ebuild among candidates, which must be sorted by version.
+func (v *visibility) bestVisible(candidates []repoEbuild) *repoEbuild {
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if v.Visible(candidates[i]) {
+			return &candidates[i]
+		}
+	}
+	return nil
+}
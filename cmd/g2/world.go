@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/arran4/g2"
 )
 
 func (cfg *MainArgConfig) cmdWorld(args []string) error {
@@ -13,13 +15,14 @@ func (cfg *MainArgConfig) cmdWorld(args []string) error {
 	fs.Usage = func() {
 		fmt.Printf("Usage:\n")
 		fmt.Printf("\t%s\n", strings.Join(cfg.Args, " "))
-		fmt.Printf("\t\t %s \t\t %s\n", "tui", "Open the terminal UI to manage the world file")
+		fmt.Printf("\t\t %s \t\t %s\n", "tui [-index <path>]", "Open the terminal UI to manage the world file")
 		fmt.Printf("\t\t %s \t\t %s\n", "list", "List all entries in the world file")
 		fmt.Printf("\t\t %s \t\t %s\n", "query", "Query entries in the world file")
 		fmt.Printf("\t\t %s \t\t %s\n", "delete", "Delete an entry from the world file")
 		fmt.Printf("\t\t %s \t\t %s\n", "add", "Add an entry to the world file")
 		fmt.Printf("\t\t %s \t\t %s\n", "enable", "Enable (uncomment) an entry in the world file")
 		fmt.Printf("\t\t %s \t\t %s\n", "disable", "Disable (comment) an entry in the world file")
+		fmt.Printf("\t\t %s \t\t %s\n", "check", "Report entries that are invalid, duplicated, moved, unavailable or in @system")
 	}
 
 	locationOpt := fs.String("location", "/var/lib/portage/world", "Path to world file")
@@ -50,7 +53,12 @@ func (cfg *MainArgConfig) cmdWorld(args []string) error {
 
 	switch cmd {
 	case "tui":
-		return runWorldTUI(path, lines)
+		tuiFlags := flag.NewFlagSet("tui", flag.ExitOnError)
+		indexPath := tuiFlags.String("index", "", "Path to the package index for the package picker (default: the index written by 'g2 package index')")
+		if err := tuiFlags.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+		return runWorldTUI(path, lines, loadWorldSearchEngine(*indexPath))
 	case "list":
 		for _, line := range lines {
 			fmt.Println(line)
@@ -62,12 +70,20 @@ func (cfg *MainArgConfig) cmdWorld(args []string) error {
 			return fmt.Errorf("missing query string")
 		}
 		q := queryArgs[0]
+		// Queries that name a package are matched as atoms, anything else as text.
+		qAtom := g2.ParsePackageAtom(q)
 		for _, line := range lines {
-			if strings.Contains(line, q) {
+			if qAtom.Category != "" && qAtom.Name != "" {
+				if atom, ok := worldEntryAtom(line); ok && qAtom.MatchesName(atom.Category, atom.Name) {
+					fmt.Println(line)
+				}
+			} else if strings.Contains(line, q) {
 				fmt.Println(line)
 			}
 		}
 		return nil
+	case "check":
+		return cfg.cmdWorldCheck(path, lines, fs.Args()[1:])
 	case "delete":
 		delArgs := fs.Args()[1:]
 		if len(delArgs) == 0 {
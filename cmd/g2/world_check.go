@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/arran4/g2"
+)
+
+// worldProblem is an issue found with a world file entry.
+type worldProblem struct {
+	Line    int
+	Entry   string
+	Message string
+}
+
+// worldEntryAtom parses a world file line, returning false for blank lines, comments and set references.
+func worldEntryAtom(line string) (g2.PackageAtom, bool) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "@") {
+		return g2.PackageAtom{}, false
+	}
+	return g2.ParsePackageAtom(trimmed), true
+}
+
+// loadSystemSet returns the @system atoms of the profile at profileDir, or of the make.profile link under
+// configRoot when profileDir is empty.
+func loadSystemSet(repos []*g2.RepoInfo, configRoot, profileDir string) ([]g2.PackageAtom, error) {
+	if profileDir == "" {
+		dir, err := filepath.EvalSymlinks(filepath.Join(configRoot, "make.profile"))
+		if err != nil {
+			return nil, err
+		}
+		profileDir = dir
+	}
+	locations := make(map[string]string)
+	for _, repo := range repos {
+		locations[repo.RepoName] = repo.Location
+	}
+	stack, err := g2.ProfileStack(profileDir, locations)
+	if err != nil {
+		return nil, err
+	}
+	return g2.ReadSystemSet(stack)
+}
+
+func (cfg *MainArgConfig) cmdWorldCheck(path string, lines []string, args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	configRoot := fs.String("config-root", "/etc/portage", "Path to portage config root")
+	reposConf := fs.String("repos-conf", "", "Path to repos.conf (default: <config-root>/repos.conf)")
+	var repoDirs StringSliceFlag
+	fs.Var(&repoDirs, "repo", "Path to a repository to check against (repeatable, default: all repositories in repos.conf)")
+	profile := fs.String("profile", "", "Profile directory for @system (default: <config-root>/make.profile)")
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("parsing flags: %w", err)
+	}
+	if *reposConf == "" {
+		*reposConf = filepath.Join(*configRoot, "repos.conf")
+	}
+
+	repos, err := resolveRepoStack(repoDirs, *reposConf)
+	if err != nil {
+		return err
+	}
+	updates, err := loadRepoUpdates(repos)
+	if err != nil {
+		return err
+	}
+	system, err := loadSystemSet(repos, *configRoot, *profile)
+	if err != nil {
+		log.Printf("Warning: not checking against @system: %v", err)
+	}
+
+	problems, err := checkWorldEntries(lines, repos, updates, system)
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		fmt.Printf("%s:%d: %s: %s\n", path, p.Line, p.Entry, p.Message)
+	}
+	if len(problems) > 0 {
+		return &ExitError{Code: 1, Err: fmt.Errorf("%d problems found in %s", len(problems), path)}
+	}
+	fmt.Printf("No problems found in %s\n", path)
+	return nil
+}
+
+// checkWorldEntries reports invalid, duplicate, moved and unavailable entries and those already in @system.
+func checkWorldEntries(lines []string, repos []*g2.RepoInfo, updates []g2.RepoPackageUpdate, system []g2.PackageAtom) ([]worldProblem, error) {
+	var problems []worldProblem
+	seen := make(map[string]int)
+	for i, line := range lines {
+		atom, ok := worldEntryAtom(line)
+		if !ok {
+			continue
+		}
+		entry := strings.TrimSpace(line)
+		report := func(format string, args ...any) {
+			problems = append(problems, worldProblem{Line: i + 1, Entry: entry, Message: fmt.Sprintf(format, args...)})
+		}
+		if atom.Category == "" || atom.Name == "" || atom.IsBlocker() {
+			report("not a valid package atom")
+			continue
+		}
+
+		key := atom.String()
+		if first, ok := seen[key]; ok {
+			report("duplicate of line %d", first)
+			continue
+		}
+		seen[key] = i + 1
+
+		if moved, ok := g2.ApplyRepoUpdatesToAtom(updates, atom); ok {
+			report("moved to %s by profiles/updates", moved.String())
+			continue
+		}
+
+		ebuilds, err := listRepoEbuilds(repos, atom.Category, atom.Name)
+		if err != nil {
+			return nil, err
+		}
+		matched := false
+		for _, e := range ebuilds {
+			if atom.Matches(e.Candidate) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("matches no package in the configured repositories")
+		}
+
+		for _, s := range system {
+			if s.MatchesName(atom.Category, atom.Name) {
+				report("already pulled in by @system (%s)", s.String())
+				break
+			}
+		}
+	}
+	return problems, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCmdWorldCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"repo/profiles/repo_name":                  "gentoo\n",
+		"repo/profiles/updates/1Q-2024":            "move app-misc/old app-misc/new\n",
+		"repo/profiles/base/packages":              "*sys-apps/portage\n",
+		"repo/app-misc/new/new-1.ebuild":           "EAPI=8\nSLOT=\"0\"\n",
+		"repo/app-editors/vim/vim-9.1.ebuild":      "EAPI=8\nSLOT=\"0\"\n",
+		"repo/sys-apps/portage/portage-3.0.ebuild": "EAPI=8\nSLOT=\"0\"\n",
+		"world": strings.Join([]string{
+			"app-editors/vim",
+			"# app-misc/commented",
+			"app-misc/old",
+			"app-editors/vim",
+			">=app-editors/vim-10",
+			"app-misc/gone",
+			"sys-apps/portage",
+			"not-an-atom",
+		}, "\n") + "\n",
+	})
+	world := filepath.Join(dir, "world")
+
+	cfg := &MainArgConfig{}
+	out, err := captureOutput(t, func() error {
+		return cfg.cmdWorld([]string{"-location", world, "check",
+			"-repo", filepath.Join(dir, "repo"),
+			"-profile", filepath.Join(dir, "repo", "profiles", "base")})
+	})
+	if err == nil {
+		t.Fatal("expected check to report problems")
+	}
+	for _, want := range []string{
+		":3: app-misc/old: moved to app-misc/new by profiles/updates",
+		":4: app-editors/vim: duplicate of line 1",
+		":5: >=app-editors/vim-10: matches no package in the configured repositories",
+		":6: app-misc/gone: matches no package in the configured repositories",
+		":7: sys-apps/portage: already pulled in by @system (sys-apps/portage)",
+		":8: not-an-atom: not a valid package atom",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, ":1:") || strings.Contains(out, ":2:") {
+		t.Errorf("unexpected problems reported:\n%s", out)
+	}
+
+	if err := os.WriteFile(world, []byte("app-editors/vim\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err = captureOutput(t, func() error {
+		return cfg.cmdWorld([]string{"-location", world, "query", "app-editors/*"})
+	})
+	if err != nil || out != "app-editors/vim\n" {
+		t.Errorf("query = %q, %v", out, err)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/arran4/g2"
+	"github.com/arran4/g2/cmd/g2/internal/cacheconfig"
+)
+
+// pickerPackage is a package offered by the world TUI package picker, built from the search
+// documents of its versions.
+type pickerPackage struct {
+	FullName    string
+	Description string
+	Versions    []pickerVersion
+	Slots       []string
+	Repos       []string
+}
+
+// pickerVersion is a single version of a picker package.
+type pickerVersion struct {
+	Version  string
+	Repo     string
+	Keywords []string
+	Mask     string
+}
+
+// loadWorldSearchEngine loads the local package index for the world TUI. A missing index is not an
+// error; the picker is simply unavailable.
+func loadWorldSearchEngine(indexPath string) *SearchEngine {
+	if indexPath == "" {
+		indexPath = fmt.Sprintf("%s/g2/search", cacheconfig.GetCacheDir())
+		if _, err := os.Stat(indexPath); err != nil {
+			log.Printf("No package index at %s; run 'g2 package index' to enable the package picker", indexPath)
+			return nil
+		}
+	}
+	engine := NewSearchEngine()
+	if err := LoadSearchEngine(indexPath, engine); err != nil {
+		log.Printf("Warning: loading package index %s: %v", indexPath, err)
+		return nil
+	}
+	return engine
+}
+
+// groupSearchResults folds per-version search documents into packages, keeping the order in which
+// packages first appear and sorting their versions. Names, repositories and descriptions keep their
+// original case, as world entries must, except from an index written before documents recorded it.
+func groupSearchResults(docs []SearchDocument) []*pickerPackage {
+	var res []*pickerPackage
+	byName := make(map[string]*pickerPackage)
+	for _, doc := range docs {
+		name := orString(doc.DisplayName, doc.FullName)
+		repo := orString(doc.DisplayOverlay, doc.Overlay)
+		p, ok := byName[doc.FullName]
+		if !ok {
+			p = &pickerPackage{FullName: name, Description: orString(doc.DisplayDescription, doc.Description)}
+			byName[doc.FullName] = p
+			res = append(res, p)
+		}
+		p.Versions = append(p.Versions, pickerVersion{Version: doc.Version, Repo: repo, Keywords: doc.Keywords, Mask: doc.Mask})
+		if doc.Slot != "" && !containsString(p.Slots, doc.Slot) {
+			p.Slots = append(p.Slots, doc.Slot)
+		}
+		if repo != "" && !containsString(p.Repos, repo) {
+			p.Repos = append(p.Repos, repo)
+		}
+	}
+	for _, p := range res {
+		sort.SliceStable(p.Versions, func(i, j int) bool {
+			return g2.CompareVersions(p.Versions[i].Version, p.Versions[j].Version) < 0
+		})
+		sort.Strings(p.Slots)
+		sort.Strings(p.Repos)
+	}
+	return res
+}
+
+// orString returns s, or fallback when s is empty.
+func orString(s, fallback string) string {
+	if s != "" {
+		return s
+	}
+	return fallback
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Qualifiers returns the world entries that can be added for the package: the plain name, then one per
+// slot and one per repository when there is a choice to make.
+func (p *pickerPackage) Qualifiers() []string {
+	res := []string{p.FullName}
+	for _, slot := range p.Slots {
+		// Sub-slots are not used in world entries.
+		slot, _, _ = strings.Cut(slot, "/")
+		if entry := p.FullName + ":" + slot; !containsString(res, entry) {
+			res = append(res, entry)
+		}
+	}
+	for _, repo := range p.Repos {
+		res = append(res, p.FullName+"::"+repo)
+	}
+	return res
+}
+
+// VersionSummary lists the versions, marking masked ones with [M] and testing ones with ~.
+func (p *pickerPackage) VersionSummary() string {
+	var parts []string
+	for _, v := range p.Versions {
+		s := v.Version
+		switch {
+		case v.Mask == "hard" || v.Mask == "soft":
+			s += "[M]"
+		case !hasStableKeyword(v.Keywords):
+			s = "~" + s
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Latest returns the newest version, or nil when there is none.
+func (p *pickerPackage) Latest() *pickerVersion {
+	if len(p.Versions) == 0 {
+		return nil
+	}
+	return &p.Versions[len(p.Versions)-1]
+}
+
+func hasStableKeyword(keywords []string) bool {
+	for _, k := range keywords {
+		if k != "" && k[0] != '~' && k[0] != '-' {
+			return true
+		}
+	}
+	return false
+}
+
+// knownPackages returns the lower-case names of every package in the search engine.
+func knownPackages(engine *SearchEngine) map[string]bool {
+	res := make(map[string]bool)
+	for _, doc := range engine.Search("") {
+		res[doc.FullName] = true
+	}
+	return res
+}
+
+// unresolvedWorldEntries returns the indices of world lines naming packages the index does not know.
+func unresolvedWorldEntries(lines []string, known map[string]bool) map[int]bool {
+	res := make(map[int]bool)
+	if len(known) == 0 {
+		return res
+	}
+	for i, line := range lines {
+		if idx := strings.Index(line, "#"); idx > 0 {
+			line = line[:idx]
+		}
+		atom, ok := worldEntryAtom(line)
+		if !ok || atom.Category == "" || atom.Name == "" {
+			continue
+		}
+		if !known[strings.ToLower(atom.Category+"/"+atom.Name)] {
+			res[i] = true
+		}
+	}
+	return res
+}
+
+// insertWorldLine inserts entry before the selected line, or appends it when nothing is selected.
+func insertWorldLine(lines []string, filteredIndices []int, cursor int, entry string) []string {
+	if len(filteredIndices) == 0 || cursor >= len(filteredIndices) {
+		return append(lines, entry)
+	}
+	realIdx := filteredIndices[cursor]
+	lines = append(lines, "")
+	copy(lines[realIdx+1:], lines[realIdx:])
+	lines[realIdx] = entry
+	return lines
+}
+
+// worldPicker is the package picker of the world TUI: a query line, the matching packages with the
+// details of the selected one, and a list of qualified entries to choose from.
+type worldPicker struct {
+	engine    *SearchEngine
+	Query     string
+	Results   []*pickerPackage
+	Cursor    int
+	Choosing  bool
+	Qualifier int
+}
+
+func newWorldPicker(engine *SearchEngine) *worldPicker {
+	p := &worldPicker{engine: engine}
+	p.search()
+	return p
+}
+
+// Reset leaves the qualifier list, keeping the last query and its results.
+func (p *worldPicker) Reset() {
+	p.Choosing = false
+	p.Qualifier = 0
+}
+
+func (p *worldPicker) search() {
+	p.Results = groupSearchResults(p.engine.Search(p.Query))
+	p.Cursor = 0
+}
+
+func (p *worldPicker) selected() *pickerPackage {
+	if p.Cursor < 0 || p.Cursor >= len(p.Results) {
+		return nil
+	}
+	return p.Results[p.Cursor]
+}
+
+// HandleInput processes one read from the terminal. It returns the world entry to add once one is
+// chosen, and whether the picker should be closed.
+func (p *worldPicker) HandleInput(buf []byte) (string, bool) {
+	if len(buf) >= 3 && buf[0] == 27 && buf[1] == 91 {
+		limit := len(p.Results)
+		cur := &p.Cursor
+		if p.Choosing {
+			limit = len(p.selected().Qualifiers())
+			cur = &p.Qualifier
+		}
+		switch buf[2] {
+		case 'A': // Up
+			if *cur > 0 {
+				*cur--
+			}
+		case 'B': // Down
+			if *cur < limit-1 {
+				*cur++
+			}
+		}
+		return "", false
+	}
+
+	queryChanged := false
+	for _, c := range buf {
+		switch {
+		case c == 27: // Esc
+			if p.Choosing {
+				p.Choosing = false
+				continue
+			}
+			return "", true
+		case c == 13: // Enter
+			pkg := p.selected()
+			if pkg == nil {
+				continue
+			}
+			if !p.Choosing {
+				p.Choosing = true
+				p.Qualifier = 0
+				continue
+			}
+			p.Choosing = false
+			return pkg.Qualifiers()[p.Qualifier], true
+		case p.Choosing:
+			switch c {
+			case 'j':
+				if p.Qualifier < len(p.selected().Qualifiers())-1 {
+					p.Qualifier++
+				}
+			case 'k':
+				if p.Qualifier > 0 {
+					p.Qualifier--
+				}
+			}
+		case c == 127 || c == 8: // Backspace
+			runes := []rune(p.Query)
+			if len(runes) > 0 {
+				p.Query = string(runes[:len(runes)-1])
+				queryChanged = true
+			}
+		case c >= 32:
+			// Bytes are appended as they are, so multi-byte UTF-8 input survives.
+			p.Query += string([]byte{c})
+			queryChanged = true
+		}
+	}
+	if queryChanged {
+		p.search()
+	}
+	return "", false
+}
+
+// Render draws the picker, using at most height lines.
+func (p *worldPicker) Render(height int) {
+	fmt.Print("Pick a package to add to the world file\r\n")
+	if p.Choosing {
+		fmt.Print("Enter: add entry | j/k: down/up | Esc: back\r\n")
+	} else {
+		fmt.Print("Type to search | Up/Down: select | Enter: choose | Esc: cancel\r\n")
+	}
+	fmt.Print(strings.Repeat("-", 60) + "\r\n")
+	fmt.Printf("Search: %s_\r\n", p.Query)
+
+	pkg := p.selected()
+	// Header (4 lines) and the details of the selected package (up to 8 lines) are always shown.
+	listHeight := height - 12
+	if listHeight < 1 {
+		listHeight = 1
+	}
+	start := 0
+	if p.Cursor >= listHeight {
+		start = p.Cursor - listHeight + 1
+	}
+	for i := start; i < len(p.Results) && i < start+listHeight; i++ {
+		r := p.Results[i]
+		prefix := "   "
+		if i == p.Cursor {
+			prefix = " > "
+		}
+		fmt.Printf("%s%s  \033[2m%s\033[0m\r\n", prefix, r.FullName, r.Description)
+	}
+	if len(p.Results) == 0 {
+		fmt.Print("   (no matching packages)\r\n")
+	}
+	if pkg == nil {
+		return
+	}
+
+	fmt.Print(strings.Repeat("-", 60) + "\r\n")
+	fmt.Printf("%s: %s\r\n", pkg.FullName, pkg.Description)
+	fmt.Printf("Versions: %s\r\n", pkg.VersionSummary())
+	if latest := pkg.Latest(); latest != nil {
+		fmt.Printf("Keywords (%s): %s\r\n", latest.Version, strings.Join(latest.Keywords, " "))
+		if latest.Mask == "hard" || latest.Mask == "soft" {
+			fmt.Printf("\033[31mMasked (%s)\033[0m\r\n", latest.Mask)
+		}
+	}
+	fmt.Printf("Slots: %s | Repositories: %s\r\n", strings.Join(pkg.Slots, " "), strings.Join(pkg.Repos, " "))
+	if p.Choosing {
+		for i, q := range pkg.Qualifiers() {
+			prefix := "   "
+			if i == p.Qualifier {
+				prefix = " > "
+			}
+			fmt.Printf("%s%s\r\n", prefix, q)
+		}
+	}
+}
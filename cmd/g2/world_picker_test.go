@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var pickerTestDocs = []SearchDocument{
+	{FullName: "dev-lang/python", Description: "python", Version: "3.12.1", Slot: "3.12", Overlay: "gentoo", Keywords: []string{"amd64"}},
+	{FullName: "app-editors/vim", Description: "vi improved", Version: "9.1", Slot: "0", Overlay: "gentoo", Keywords: []string{"~amd64"}},
+	{FullName: "dev-lang/python", Description: "python", Version: "3.11.8", Slot: "3.11", Overlay: "gentoo", Keywords: []string{"amd64"}},
+	{FullName: "dev-lang/python", Description: "python", Version: "3.13.0", Slot: "3.13/3.13t", Overlay: "guru", Mask: "hard"},
+}
+
+func TestGroupSearchResults(t *testing.T) {
+	pkgs := groupSearchResults(pickerTestDocs)
+	if len(pkgs) != 2 || pkgs[0].FullName != "dev-lang/python" || pkgs[1].FullName != "app-editors/vim" {
+		t.Fatalf("unexpected grouping: %+v", pkgs)
+	}
+	python := pkgs[0]
+	if got := python.VersionSummary(); got != "3.11.8 3.12.1 3.13.0[M]" {
+		t.Errorf("VersionSummary() = %q", got)
+	}
+	if got := pkgs[1].VersionSummary(); got != "~9.1" {
+		t.Errorf("VersionSummary() = %q", got)
+	}
+	want := []string{
+		"dev-lang/python",
+		"dev-lang/python:3.11",
+		"dev-lang/python:3.12",
+		"dev-lang/python:3.13",
+		"dev-lang/python::gentoo",
+		"dev-lang/python::guru",
+	}
+	if got := python.Qualifiers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Qualifiers() = %v, want %v", got, want)
+	}
+}
+
+func TestGroupSearchResultsOriginalCase(t *testing.T) {
+	pkgs := groupSearchResults([]SearchDocument{
+		{FullName: "dev-python/pyqt6", Description: "python bindings for qt6", Version: "6.7.0", Slot: "0", Overlay: "myrepo",
+			DisplayName: "dev-python/PyQt6", DisplayOverlay: "MyRepo", DisplayDescription: "Python bindings for Qt6"},
+	})
+	if len(pkgs) != 1 || pkgs[0].Description != "Python bindings for Qt6" {
+		t.Fatalf("unexpected grouping: %+v", pkgs)
+	}
+	want := []string{"dev-python/PyQt6", "dev-python/PyQt6:0", "dev-python/PyQt6::MyRepo"}
+	if got := pkgs[0].Qualifiers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Qualifiers() = %v, want %v", got, want)
+	}
+}
+
+func TestUnresolvedWorldEntries(t *testing.T) {
+	engine := NewSearchEngine()
+	engine.LoadDocuments(pickerTestDocs)
+	lines := []string{"app-editors/vim", "# app-misc/gone", "app-misc/gone # removed upstream", ">=dev-lang/Python-3.12:3.12", "@desktop"}
+	got := unresolvedWorldEntries(lines, knownPackages(engine))
+	if want := map[int]bool{2: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unresolvedWorldEntries() = %v, want %v", got, want)
+	}
+}
+
+func TestWorldPickerInput(t *testing.T) {
+	engine := NewSearchEngine()
+	engine.LoadDocuments(pickerTestDocs)
+	p := newWorldPicker(engine)
+
+	for _, in := range [][]byte{[]byte("name:*python*"), {13}, {27, 91, 'B'}, {'j'}} {
+		if entry, closed := p.HandleInput(in); entry != "" || closed {
+			t.Fatalf("HandleInput(%q) = %q, %v", in, entry, closed)
+		}
+	}
+	entry, closed := p.HandleInput([]byte{13})
+	if entry != "dev-lang/python:3.12" || !closed {
+		t.Errorf("HandleInput(Enter) = %q, %v", entry, closed)
+	}
+
+	p.Reset()
+	if _, closed := p.HandleInput([]byte{27}); !closed {
+		t.Error("expected Esc to close the picker")
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"strings"
 )
 
-func runWorldTUI(path string, lines []string) error {
+// runWorldTUI edits the world file interactively. When engine is set, packages can be picked from the
+// local index and entries it does not know are marked as unresolved.
+func runWorldTUI(path string, lines []string, engine *SearchEngine) error {
 	if !term.IsTerminal(int(os.Stdin.Fd())) {
 		return fmt.Errorf("stdin is not a terminal")
 	}
@@ -20,12 +22,17 @@ func runWorldTUI(path string, lines []string) error {
 	defer func() { _ = term.Restore(int(os.Stdin.Fd()), oldState) }()
 
 	cursor := 0
-	mode := "normal" // "normal", "insert", "filter", "edit_modal", "prompt_version", "prompt_comment_after", "prompt_comment_before", "prompt_replace"
+	mode := "normal" // "normal", "insert", "filter", "edit_modal", "picker", "prompt_version", "prompt_comment_after", "prompt_comment_before", "prompt_replace"
 	inputBuffer := ""
 	filterQuery := ""
 	scrollOffset := 0
 	editModalCursor := 0
 	promptRealIdx := 0
+	var picker *worldPicker
+	var known map[string]bool
+	if engine != nil {
+		known = knownPackages(engine)
+	}
 
 	editModalOptions := []string{
 		"Comment / Uncomment",
@@ -42,16 +49,20 @@ func runWorldTUI(path string, lines []string) error {
 		// Clear screen and reset cursor
 		fmt.Print("\033[2J\033[H")
 
-		fmt.Print("Manage Portage World List\r\n")
-		fmt.Print("q: quit | s: save | j/k: down/up | c/Space: toggle comment | d: delete | a: add | e: edit | /: filter\r\n")
-		fmt.Print(strings.Repeat("-", 60) + "\r\n")
-
 		termWidth, termHeight, err := term.GetSize(int(os.Stdin.Fd()))
 		if err != nil {
 			termHeight = 24
 			termWidth = 80
 		}
-		_ = termWidth
+
+		if mode == "picker" {
+			picker.Render(termHeight)
+			return
+		}
+
+		fmt.Print("Manage Portage World List\r\n")
+		fmt.Print("q: quit | s: save | j/k: down/up | c/Space: toggle comment | d: delete | a: add | p: pick package | e: edit | /: filter\r\n")
+		fmt.Print(strings.Repeat("-", 60) + "\r\n")
 
 		// 3 header lines, 1 or 2 footer lines depending on mode
 		listHeight = termHeight - 4
@@ -85,6 +96,7 @@ func runWorldTUI(path string, lines []string) error {
 			scrollOffset = 0
 		}
 
+		unresolved := unresolvedWorldEntries(lines, known)
 		for i, realIdx := range filteredIndices {
 			if i < scrollOffset || i >= scrollOffset+listHeight {
 				continue
@@ -98,6 +110,8 @@ func runWorldTUI(path string, lines []string) error {
 			line := lines[realIdx]
 			if strings.HasPrefix(strings.TrimSpace(line), "#") {
 				fmt.Printf("\033[32m%s\033[0m\r\n", line) // Green for comments
+			} else if unresolved[realIdx] {
+				fmt.Printf("\033[31m%s\033[0m  [unresolved]\r\n", line) // Red for packages missing from the index
 			} else {
 				fmt.Printf("%s\r\n", line)
 			}
@@ -217,7 +231,15 @@ func runWorldTUI(path string, lines []string) error {
 				return err
 			}
 
-			if mode == "edit_modal" {
+			if mode == "picker" {
+				entry, closed := picker.HandleInput(buf[:n])
+				if entry != "" {
+					lines = insertWorldLine(lines, filteredIndices, cursor, entry)
+				}
+				if closed {
+					mode = "normal"
+				}
+			} else if mode == "edit_modal" {
 				if n == 1 {
 					switch buf[0] {
 					case 27, 'q': // Esc or q
@@ -307,15 +329,7 @@ func runWorldTUI(path string, lines []string) error {
 					case 13: // Enter
 						if mode == "insert" {
 							if strings.TrimSpace(inputBuffer) != "" {
-								if len(filteredIndices) == 0 || cursor >= len(filteredIndices) {
-									lines = append(lines, inputBuffer)
-								} else {
-									realIdx := filteredIndices[cursor]
-									// Add to lines and move cursor
-									lines = append(lines, "")
-									copy(lines[realIdx+1:], lines[realIdx:])
-									lines[realIdx] = inputBuffer
-								}
+								lines = insertWorldLine(lines, filteredIndices, cursor, inputBuffer)
 							}
 						} else if mode == "prompt_version" {
 							line := lines[promptRealIdx]
@@ -452,6 +466,14 @@ func runWorldTUI(path string, lines []string) error {
 						}
 					case 'a': // Add
 						mode = "insert"
+					case 'p': // Pick a package from the index
+						if engine != nil {
+							if picker == nil {
+								picker = newWorldPicker(engine)
+							}
+							picker.Reset()
+							mode = "picker"
+						}
 					case 'e': // Edit
 						if len(filteredIndices) > 0 && cursor < len(filteredIndices) {
 							editModalCursor = 0
@@ -0,0 +1,343 @@
+package g2
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DependencyClasses are the dependency variables of an ebuild, in the order they are reported.
+var DependencyClasses = []string{"DEPEND", "RDEPEND", "BDEPEND", "PDEPEND", "IDEPEND"}
+
+// diffVariables are the variables whose value, rather than their items, is compared by DiffEbuilds.
+var diffVariables = []string{"DESCRIPTION", "HOMEPAGE", "LICENSE", "SLOT", "RESTRICT", "PROPERTIES"}
+
+// SetChange is the items added to and removed from a list.
+type SetChange struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Empty reports whether nothing was added or removed.
+func (c SetChange) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// ValueChange is a value that changed.
+type ValueChange struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// DependencyChange is the atoms added to and removed from a dependency class. Atoms below USE
+// conditionals are prefixed by their conditions, as in "ssl? dev-libs/openssl".
+type DependencyChange struct {
+	Class string `json:"class"`
+	SetChange
+}
+
+// KeywordChange is the keyword of an arch that changed. Old is empty for an added arch and New for a
+// removed one.
+type KeywordChange struct {
+	Arch string `json:"arch"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// VariableChange is a variable whose value changed.
+type VariableChange struct {
+	Name string `json:"name"`
+	ValueChange
+}
+
+// FunctionChange is a function added, removed or modified, with the line diff of its body.
+type FunctionChange struct {
+	Name   string `json:"name"`
+	Action string `json:"action"` // FileAdded, FileRemoved or FileModified
+	Diff   string `json:"diff,omitempty"`
+}
+
+// EbuildDiff is the semantic difference between two ebuilds, usually two versions of a package.
+type EbuildDiff struct {
+	OldVersion   string             `json:"old_version"`
+	NewVersion   string             `json:"new_version"`
+	EAPI         *ValueChange       `json:"eapi,omitempty"`
+	Dependencies []DependencyChange `json:"dependencies,omitempty"`
+	Keywords     []KeywordChange    `json:"keywords,omitempty"`
+	IUSE         SetChange          `json:"iuse"`
+	RequiredUse  *ValueChange       `json:"required_use,omitempty"`
+	Inherited    SetChange          `json:"inherited"`
+	SrcURI       SetChange          `json:"src_uri"`
+	Distfiles    SetChange          `json:"distfiles"`
+	Variables    []VariableChange   `json:"variables,omitempty"`
+	Functions    []FunctionChange   `json:"functions,omitempty"`
+}
+
+// Empty reports whether the ebuilds differ in nothing compared.
+func (d *EbuildDiff) Empty() bool {
+	return d.EAPI == nil && len(d.Dependencies) == 0 && len(d.Keywords) == 0 && d.IUSE.Empty() &&
+		d.RequiredUse == nil && d.Inherited.Empty() && d.SrcURI.Empty() && d.Distfiles.Empty() &&
+		len(d.Variables) == 0 && len(d.Functions) == 0
+}
+
+// DiffEbuilds compares two parsed ebuilds. Values are compared after variable resolution, so that a
+// reference to ${PV} that resolves differently counts as a change. Function bodies are compared as
+// text.
+func DiffEbuilds(old, new *Ebuild) *EbuildDiff {
+	d := &EbuildDiff{OldVersion: old.Vars["PVR"], NewVersion: new.Vars["PVR"]}
+	if d.OldVersion == "" {
+		d.OldVersion = old.Vars["PV"]
+	}
+	if d.NewVersion == "" {
+		d.NewVersion = new.Vars["PV"]
+	}
+
+	if o, n := old.Vars["EAPI"], new.Vars["EAPI"]; o != n {
+		d.EAPI = &ValueChange{Old: o, New: n}
+	}
+	for _, class := range DependencyClasses {
+		c := diffSets(FlattenDependencies(old.Vars[class]), FlattenDependencies(new.Vars[class]))
+		if !c.Empty() {
+			d.Dependencies = append(d.Dependencies, DependencyChange{Class: class, SetChange: c})
+		}
+	}
+	d.Keywords = diffKeywords(old.Vars["KEYWORDS"], new.Vars["KEYWORDS"])
+	d.IUSE = diffSets(strings.Fields(old.Vars["IUSE"]), strings.Fields(new.Vars["IUSE"]))
+	if o, n := strings.Join(strings.Fields(old.Vars["REQUIRED_USE"]), " "), strings.Join(strings.Fields(new.Vars["REQUIRED_USE"]), " "); o != n {
+		d.RequiredUse = &ValueChange{Old: o, New: n}
+	}
+	d.Inherited = diffSets(strings.Fields(old.Vars["INHERITED"]), strings.Fields(new.Vars["INHERITED"]))
+
+	var oldURLs, newURLs, oldFiles, newFiles []string
+	for _, u := range old.SrcUri {
+		oldURLs, oldFiles = append(oldURLs, u.URL), append(oldFiles, u.Filename)
+	}
+	for _, u := range new.SrcUri {
+		newURLs, newFiles = append(newURLs, u.URL), append(newFiles, u.Filename)
+	}
+	d.SrcURI = diffSets(oldURLs, newURLs)
+	d.Distfiles = diffSets(oldFiles, newFiles)
+
+	for _, name := range diffVariables {
+		if o, n := strings.TrimSpace(old.Vars[name]), strings.TrimSpace(new.Vars[name]); o != n {
+			d.Variables = append(d.Variables, VariableChange{Name: name, ValueChange: ValueChange{Old: o, New: n}})
+		}
+	}
+
+	names := map[string]bool{}
+	for name := range old.Functions {
+		names[name] = true
+	}
+	for name := range new.Functions {
+		names[name] = true
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		o, inOld := old.Functions[name]
+		n, inNew := new.Functions[name]
+		switch {
+		case !inOld:
+			d.Functions = append(d.Functions, FunctionChange{Name: name, Action: FileAdded, Diff: LineDiff("", n.Value)})
+		case !inNew:
+			d.Functions = append(d.Functions, FunctionChange{Name: name, Action: FileRemoved, Diff: LineDiff(o.Value, "")})
+		case strings.TrimSpace(o.Value) != strings.TrimSpace(n.Value):
+			d.Functions = append(d.Functions, FunctionChange{Name: name, Action: FileModified, Diff: LineDiff(o.Value, n.Value)})
+		}
+	}
+	return d
+}
+
+// Summary describes the diff in one line per changed aspect, suitable for a commit message body.
+func (d *EbuildDiff) Summary() []string {
+	var lines []string
+	if d.EAPI != nil {
+		lines = append(lines, fmt.Sprintf("EAPI %s -> %s", d.EAPI.Old, d.EAPI.New))
+	}
+	for _, c := range d.Dependencies {
+		lines = append(lines, c.Class+": "+c.SetChange.String())
+	}
+	if len(d.Keywords) > 0 {
+		var parts []string
+		for _, k := range d.Keywords {
+			switch {
+			case k.Old == "":
+				parts = append(parts, "+"+k.New)
+			case k.New == "":
+				parts = append(parts, "-"+k.Old)
+			default:
+				parts = append(parts, k.Old+" -> "+k.New)
+			}
+		}
+		lines = append(lines, "KEYWORDS: "+strings.Join(parts, ", "))
+	}
+	if !d.IUSE.Empty() {
+		lines = append(lines, "IUSE: "+d.IUSE.String())
+	}
+	if d.RequiredUse != nil {
+		lines = append(lines, fmt.Sprintf("REQUIRED_USE: %q -> %q", d.RequiredUse.Old, d.RequiredUse.New))
+	}
+	if !d.Inherited.Empty() {
+		lines = append(lines, "inherit: "+d.Inherited.String())
+	}
+	if !d.Distfiles.Empty() {
+		lines = append(lines, "distfiles: "+d.Distfiles.String())
+	} else if !d.SrcURI.Empty() {
+		lines = append(lines, "SRC_URI: "+d.SrcURI.String())
+	}
+	for _, v := range d.Variables {
+		lines = append(lines, fmt.Sprintf("%s: %q -> %q", v.Name, v.Old, v.New))
+	}
+	for _, f := range d.Functions {
+		lines = append(lines, fmt.Sprintf("%s(): %s", f.Name, f.Action))
+	}
+	return lines
+}
+
+// String lists the added items prefixed by + and the removed ones prefixed by -.
+func (c SetChange) String() string {
+	parts := make([]string, 0, len(c.Added)+len(c.Removed))
+	for _, a := range c.Added {
+		parts = append(parts, "+"+a)
+	}
+	for _, r := range c.Removed {
+		parts = append(parts, "-"+r)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// diffSets returns the distinct items of new missing from old and of old missing from new, in their
+// order of appearance.
+func diffSets(old, new []string) SetChange {
+	inOld, inNew := map[string]bool{}, map[string]bool{}
+	for _, s := range old {
+		inOld[s] = true
+	}
+	for _, s := range new {
+		inNew[s] = true
+	}
+	var c SetChange
+	for _, s := range new {
+		if !inOld[s] {
+			c.Added = append(c.Added, s)
+			inOld[s] = true
+		}
+	}
+	for _, s := range old {
+		if !inNew[s] {
+			c.Removed = append(c.Removed, s)
+			inNew[s] = true
+		}
+	}
+	return c
+}
+
+// keywordArch returns the arch of a keyword: amd64 for amd64, ~amd64 and -amd64.
+func keywordArch(kw string) string {
+	return strings.TrimLeft(kw, "~-")
+}
+
+// diffKeywords compares KEYWORDS by arch, sorted by arch.
+func diffKeywords(old, new string) []KeywordChange {
+	oldByArch, newByArch := map[string]string{}, map[string]string{}
+	for _, kw := range strings.Fields(old) {
+		oldByArch[keywordArch(kw)] = kw
+	}
+	for _, kw := range strings.Fields(new) {
+		newByArch[keywordArch(kw)] = kw
+	}
+	var changes []KeywordChange
+	for arch, n := range newByArch {
+		if o := oldByArch[arch]; o != n {
+			changes = append(changes, KeywordChange{Arch: arch, Old: o, New: n})
+		}
+	}
+	for arch, o := range oldByArch {
+		if _, ok := newByArch[arch]; !ok {
+			changes = append(changes, KeywordChange{Arch: arch, Old: o})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Arch < changes[j].Arch })
+	return changes
+}
+
+// FlattenDependencies returns the atoms of a dependency string, each prefixed by the USE conditionals
+// it sits below, as in "ssl? dev-libs/openssl". Members of an any-of group are prefixed by "||".
+func FlattenDependencies(deps string) []string {
+	var (
+		atoms   []string
+		stack   []string // the condition each open group was opened with, "" for a plain group
+		pending string
+	)
+	for _, tok := range strings.Fields(deps) {
+		switch {
+		case tok == "(":
+			stack = append(stack, pending)
+			pending = ""
+		case tok == ")":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case tok == "||" || strings.HasSuffix(tok, "?"):
+			pending = tok
+		default:
+			var conds []string
+			for _, c := range stack {
+				if c != "" {
+					conds = append(conds, c)
+				}
+			}
+			atoms = append(atoms, strings.Join(append(conds, tok), " "))
+			pending = ""
+		}
+	}
+	return atoms
+}
+
+// LineDiff returns a line diff of old and new: unchanged lines are prefixed by a space, removed lines by
+// - and added lines by +. It is meant for small texts such as function bodies.
+func LineDiff(old, new string) string {
+	a, b := diffLines(old), diffLines(new)
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString(" " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("-" + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+" + b[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
+
+func diffLines(s string) []string {
+	s = strings.Trim(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
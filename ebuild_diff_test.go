@@ -0,0 +1,92 @@
+package g2
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDiffEbuilds(t *testing.T) {
+	fsys := fstest.MapFS{
+		"foo-1.0.ebuild": {Data: []byte(`EAPI=7
+inherit autotools
+DESCRIPTION="Foo tool"
+SRC_URI="https://example.org/${P}.tar.gz"
+LICENSE="MIT"
+SLOT="0"
+KEYWORDS="~amd64 x86"
+IUSE="ssl"
+RDEPEND="ssl? ( dev-libs/openssl ) sys-libs/zlib"
+
+src_prepare() {
+	default
+	eautoreconf
+}
+
+src_test() {
+	emake check
+}
+`)},
+		"foo-1.1.ebuild": {Data: []byte(`EAPI=8
+inherit meson
+DESCRIPTION="Foo tool"
+SRC_URI="https://example.org/${P}.tar.gz"
+LICENSE="MIT"
+SLOT="0"
+KEYWORDS="amd64 ~arm64"
+IUSE="ssl test"
+REQUIRED_USE="test? ( ssl )"
+RDEPEND="ssl? ( dev-libs/openssl ) || ( app-arch/zstd app-arch/xz-utils )"
+
+src_prepare() {
+	default
+	sed -i 's/a/b/' meson.build || die
+}
+`)},
+	}
+	old, err := ParseEbuild(fsys, "foo-1.0.ebuild", ParseFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	new, err := ParseEbuild(fsys, "foo-1.1.ebuild", ParseFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := DiffEbuilds(old, new)
+	if d.OldVersion != "1.0" || d.NewVersion != "1.1" {
+		t.Errorf("versions = %s %s", d.OldVersion, d.NewVersion)
+	}
+	if d.EAPI == nil || d.EAPI.Old != "7" || d.EAPI.New != "8" {
+		t.Errorf("EAPI = %+v", d.EAPI)
+	}
+	if len(d.Dependencies) != 1 || d.Dependencies[0].Class != "RDEPEND" ||
+		d.Dependencies[0].String() != "+|| app-arch/zstd, +|| app-arch/xz-utils, -sys-libs/zlib" {
+		t.Errorf("dependencies = %+v", d.Dependencies)
+	}
+	if d.IUSE.String() != "+test" || d.RequiredUse == nil || d.Inherited.String() != "+meson, -autotools" {
+		t.Errorf("IUSE %s, REQUIRED_USE %+v, inherit %s", d.IUSE, d.RequiredUse, d.Inherited)
+	}
+	if d.Distfiles.String() != "+foo-1.1.tar.gz, -foo-1.0.tar.gz" {
+		t.Errorf("distfiles = %s", d.Distfiles)
+	}
+	if len(d.Variables) != 0 {
+		t.Errorf("variables = %+v", d.Variables)
+	}
+	if len(d.Functions) != 2 || d.Functions[0].Name != "src_prepare" || d.Functions[0].Action != FileModified || d.Functions[1].Action != FileRemoved {
+		t.Fatalf("functions = %+v", d.Functions)
+	}
+	if diff := d.Functions[0].Diff; !strings.Contains(diff, "-\teautoreconf\n") || !strings.Contains(diff, "+\tsed -i") || !strings.Contains(diff, " \tdefault\n") {
+		t.Errorf("src_prepare diff:\n%s", diff)
+	}
+
+	summary := strings.Join(d.Summary(), "\n")
+	for _, want := range []string{"EAPI 7 -> 8", "KEYWORDS: ~amd64 -> amd64, +~arm64, -x86", "src_test(): removed"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary lacks %q:\n%s", want, summary)
+		}
+	}
+	if !DiffEbuilds(old, old).Empty() {
+		t.Error("an ebuild differs from itself")
+	}
+}
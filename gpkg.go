@@ -0,0 +1,379 @@
+package g2
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// GpkgFormatFile is the marker member that starts every GLEP 78 binary package.
+const GpkgFormatFile = "gpkg-1"
+
+// GpkgDecompressors maps the compression suffix of a gpkg member, such as "gz" in "image.tar.gz", to a
+// function returning a decompressing reader, which is closed after use when it is an io.Closer. The
+// BINPKG_COMPRESS formats zstd, Portage's default, xz, gzip and bzip2 are registered; callers can add
+// others, such as lz4.
+var GpkgDecompressors = map[string]func(io.Reader) (io.Reader, error){
+	"": func(r io.Reader) (io.Reader, error) { return r, nil },
+	"gz": func(r io.Reader) (io.Reader, error) {
+		return gzip.NewReader(r)
+	},
+	"bz2": func(r io.Reader) (io.Reader, error) {
+		return bzip2.NewReader(r), nil
+	},
+	"xz": func(r io.Reader) (io.Reader, error) {
+		return xz.NewReader(r)
+	},
+	"zst": func(r io.Reader) (io.Reader, error) {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	},
+}
+
+// GpkgMemberHashes are the hashes computed for every member, covering those Portage writes to the
+// gpkg Manifest.
+var GpkgMemberHashes = []string{HashBlake2b, HashSha256, HashSha512, HashSha3_256, HashSha3_512}
+
+// GpkgMember is a file of the outer gpkg archive.
+type GpkgMember struct {
+	Name      string // Name below the top-level directory, e.g. "image.tar.xz"
+	Size      int64
+	Checksums map[string]string
+	offset    int64
+}
+
+// GpkgImageEntry is a file of the image archive, the files the package installs.
+type GpkgImageEntry struct {
+	Path     string // Absolute install path, e.g. "/usr/bin/foo"
+	Mode     fs.FileMode
+	Size     int64
+	Linkname string
+}
+
+// Gpkg is a GLEP 78 binary package.
+type Gpkg struct {
+	// Dir is the top-level directory of the archive, conventionally the package's file name without
+	// the .gpkg.tar suffix.
+	Dir     string
+	Members []GpkgMember
+	// Metadata holds the vdb-style keys of the metadata archive, such as CATEGORY, PF, SLOT and USE.
+	Metadata map[string]string
+	Image    []GpkgImageEntry
+	// Signatures maps signed members to their detached OpenPGP signatures.
+	Signatures map[string][]byte
+	// Manifest lists the sizes and hashes of the other members. ManifestSigned reports whether it was
+	// clearsigned.
+	Manifest       *Manifest
+	ManifestSigned bool
+	manifestRaw    []byte
+	// Undecoded lists the metadata and image members whose compression has no registered decompressor.
+	Undecoded []string
+
+	src io.ReaderAt
+}
+
+// countingReader tracks how much of the outer archive has been read, giving the offset of each member.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadGpkg reads a GLEP 78 binary package of the given size. The reader is kept so that members can be
+// reopened, for example to verify signatures.
+func ReadGpkg(r io.ReaderAt, size int64) (*Gpkg, error) {
+	g := &Gpkg{Signatures: make(map[string][]byte), src: r}
+	cr := &countingReader{r: io.NewSectionReader(r, 0, size)}
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading gpkg archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			if hdr.Typeflag == tar.TypeDir {
+				continue
+			}
+			return nil, fmt.Errorf("unexpected non-file member %s", hdr.Name)
+		}
+		dir, name, ok := strings.Cut(strings.TrimPrefix(hdr.Name, "./"), "/")
+		if !ok || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("member %s is not inside a single top-level directory", hdr.Name)
+		}
+		if g.Dir == "" {
+			if name != GpkgFormatFile {
+				return nil, fmt.Errorf("not a GLEP 78 binary package: first member is %s, not %s", hdr.Name, GpkgFormatFile)
+			}
+			g.Dir = dir
+		} else if dir != g.Dir {
+			return nil, fmt.Errorf("member %s is outside %s/", hdr.Name, g.Dir)
+		}
+
+		member := GpkgMember{Name: name, Size: hdr.Size, offset: cr.n}
+		var data bytes.Buffer
+		var body io.Reader = tr
+		keep := name == "Manifest" || strings.HasSuffix(name, ".sig")
+		if keep {
+			body = io.TeeReader(tr, &data)
+		}
+		sums, err := ChecksumReader(body, GpkgMemberHashes)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", hdr.Name, err)
+		}
+		member.Checksums = sums.Hashes
+		g.Members = append(g.Members, member)
+
+		switch {
+		case name == "Manifest":
+			if err := g.parseManifest(data.Bytes()); err != nil {
+				return nil, err
+			}
+		case strings.HasSuffix(name, ".sig"):
+			g.Signatures[strings.TrimSuffix(name, ".sig")] = data.Bytes()
+		}
+	}
+	if g.Dir == "" {
+		return nil, fmt.Errorf("not a GLEP 78 binary package: archive is empty")
+	}
+
+	for _, m := range g.Members {
+		kind, compression, ok := gpkgTarMember(m.Name)
+		if !ok {
+			continue
+		}
+		decompress, known := GpkgDecompressors[compression]
+		if !known {
+			g.Undecoded = append(g.Undecoded, m.Name)
+			continue
+		}
+		rd, err := decompress(io.NewSectionReader(r, m.offset, m.Size))
+		if err != nil {
+			return nil, fmt.Errorf("decompressing %s: %w", m.Name, err)
+		}
+		switch kind {
+		case "metadata":
+			err = g.readMetadata(rd)
+		case "image":
+			err = g.readImage(rd)
+		}
+		if c, ok := rd.(io.Closer); ok {
+			_ = c.Close()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", m.Name, err)
+		}
+	}
+	return g, nil
+}
+
+// gpkgTarMember splits names such as "image.tar.xz" into the archive kind and its compression suffix.
+func gpkgTarMember(name string) (kind, compression string, ok bool) {
+	for _, k := range []string{"metadata", "image"} {
+		if name == k+".tar" {
+			return k, "", true
+		}
+		if rest, found := strings.CutPrefix(name, k+".tar."); found && !strings.HasSuffix(rest, "sig") {
+			return k, rest, true
+		}
+	}
+	return "", "", false
+}
+
+func (g *Gpkg) parseManifest(raw []byte) error {
+	g.manifestRaw = raw
+	content := raw
+	if block, _ := clearsign.Decode(raw); block != nil {
+		content = block.Plaintext
+		g.ManifestSigned = true
+	}
+	m, err := ParseManifestContent(string(content))
+	if err != nil {
+		return fmt.Errorf("parsing Manifest: %w", err)
+	}
+	g.Manifest = m
+	return nil
+}
+
+func (g *Gpkg) readMetadata(r io.Reader) error {
+	g.Metadata = make(map[string]string)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		key := path.Base(hdr.Name)
+		// The saved build environment is compressed and of no use as a key.
+		if key == "environment.bz2" {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		g.Metadata[key] = strings.TrimRight(string(data), "\n")
+	}
+}
+
+func (g *Gpkg) readImage(r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		_, rel, _ := strings.Cut(strings.TrimPrefix(hdr.Name, "./"), "/")
+		rel = strings.TrimSuffix(rel, "/")
+		if rel == "" {
+			continue
+		}
+		g.Image = append(g.Image, GpkgImageEntry{
+			Path:     "/" + rel,
+			Mode:     hdr.FileInfo().Mode(),
+			Size:     hdr.Size,
+			Linkname: hdr.Linkname,
+		})
+	}
+}
+
+// Member returns the member called name, or nil.
+func (g *Gpkg) Member(name string) *GpkgMember {
+	for i := range g.Members {
+		if g.Members[i].Name == name {
+			return &g.Members[i]
+		}
+	}
+	return nil
+}
+
+// OpenMember returns the raw, still compressed, content of a member.
+func (g *Gpkg) OpenMember(name string) (io.Reader, error) {
+	m := g.Member(name)
+	if m == nil {
+		return nil, fmt.Errorf("no member %s", name)
+	}
+	return io.NewSectionReader(g.src, m.offset, m.Size), nil
+}
+
+// CPV returns category/package-version from the metadata.
+func (g *Gpkg) CPV() string {
+	if g.Metadata == nil || g.Metadata["CATEGORY"] == "" || g.Metadata["PF"] == "" {
+		return ""
+	}
+	return g.Metadata["CATEGORY"] + "/" + g.Metadata["PF"]
+}
+
+// VerifyManifest checks every member other than the Manifest and its signature against the Manifest.
+func (g *Gpkg) VerifyManifest() []error {
+	if g.Manifest == nil {
+		return []error{errors.New("package has no Manifest")}
+	}
+	var errs []error
+	listed := make(map[string]bool)
+	for _, e := range g.Manifest.Entries {
+		if e.Type != "DATA" {
+			continue
+		}
+		listed[e.Filename] = true
+		m := g.Member(e.Filename)
+		if m == nil {
+			errs = append(errs, fmt.Errorf("%s: listed in Manifest but missing", e.Filename))
+			continue
+		}
+		if m.Size != e.Size {
+			errs = append(errs, fmt.Errorf("%s: size %d, Manifest says %d", e.Filename, m.Size, e.Size))
+		}
+		checked := 0
+		for _, h := range e.Hashes {
+			got, ok := m.Checksums[h.Type]
+			if !ok {
+				continue
+			}
+			checked++
+			if !strings.EqualFold(got, h.Value) {
+				errs = append(errs, fmt.Errorf("%s: %s mismatch", e.Filename, h.Type))
+			}
+		}
+		if checked == 0 {
+			errs = append(errs, fmt.Errorf("%s: no supported hash in Manifest", e.Filename))
+		}
+	}
+	for _, m := range g.Members {
+		if m.Name != "Manifest" && m.Name != "Manifest.sig" && !listed[m.Name] {
+			errs = append(errs, fmt.Errorf("%s: not listed in Manifest", m.Name))
+		}
+	}
+	return errs
+}
+
+// VerifySignatures checks the Manifest clearsignature and the detached signatures of members against
+// keyring. It reports an error for each signature that does not verify; unsigned packages have none.
+func (g *Gpkg) VerifySignatures(keyring openpgp.KeyRing) []error {
+	var errs []error
+	if g.ManifestSigned {
+		block, _ := clearsign.Decode(g.manifestRaw)
+		if _, err := block.VerifySignature(keyring, nil); err != nil {
+			errs = append(errs, fmt.Errorf("Manifest: bad signature: %w", err))
+		}
+	}
+	names := make([]string, 0, len(g.Signatures))
+	for name := range g.Signatures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := g.OpenMember(name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s.sig: %w", name, err))
+			continue
+		}
+		sig := g.Signatures[name]
+		if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN")) {
+			_, err = openpgp.CheckArmoredDetachedSignature(keyring, data, bytes.NewReader(sig), nil)
+		} else {
+			_, err = openpgp.CheckDetachedSignature(keyring, data, bytes.NewReader(sig), nil)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: bad signature: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// Signed reports whether the package carries any signature.
+func (g *Gpkg) Signed() bool {
+	return g.ManifestSigned || len(g.Signatures) > 0
+}
@@ -0,0 +1,273 @@
+package g2
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+type tarFile struct {
+	Name string
+	Body string
+}
+
+func writeTar(t *testing.T, files []tarFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.Name, Mode: 0644, Size: int64(len(f.Body)), Typeflag: tar.TypeReg}
+		if strings.HasSuffix(f.Name, "/") {
+			hdr = &tar.Header{Name: f.Name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.Body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// buildTestGpkg returns a gpkg for the given metadata keys. The Manifest is clearsigned when signer is
+// set, and tamper changes the image after the Manifest was written.
+func buildTestGpkg(t *testing.T, dir string, metadata map[string]string, signer *openpgp.Entity, tamper bool) []byte {
+	t.Helper()
+	var keys []string
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	metaFiles := []tarFile{{Name: "metadata/"}}
+	for _, k := range keys {
+		metaFiles = append(metaFiles, tarFile{Name: "metadata/" + k, Body: metadata[k] + "\n"})
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	if _, err := zw.Write(writeTar(t, metaFiles)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	image := writeTar(t, []tarFile{{Name: "image/"}, {Name: "image/usr/"}, {Name: "image/usr/bin/"}, {Name: "image/usr/bin/foo", Body: "#!/bin/sh\n"}})
+
+	members := []tarFile{
+		{Name: dir + "/" + GpkgFormatFile, Body: "gpkg-1\n"},
+		{Name: dir + "/metadata.tar.gz", Body: gz.String()},
+		{Name: dir + "/image.tar", Body: string(image)},
+	}
+	var manifest strings.Builder
+	for _, m := range members {
+		name := strings.TrimPrefix(m.Name, dir+"/")
+		sums, err := ChecksumReader(strings.NewReader(m.Body), []string{HashBlake2b, HashSha512})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&manifest, "DATA %s %d BLAKE2B %s SHA512 %s\n", name, len(m.Body), sums.Hashes[HashBlake2b], sums.Hashes[HashSha512])
+	}
+	content := manifest.String()
+	if signer != nil {
+		var signed bytes.Buffer
+		w, err := clearsign.Encode(&signed, signer.PrivateKey, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		content = signed.String()
+	}
+	if tamper {
+		members[2].Body = string(writeTar(t, []tarFile{{Name: "image/"}, {Name: "image/usr/bin/foo", Body: "evil\n"}}))
+	}
+	members = append(members, tarFile{Name: dir + "/Manifest", Body: content})
+	return writeTar(t, append([]tarFile{{Name: dir + "/"}}, members...))
+}
+
+var testGpkgMetadata = map[string]string{
+	"CATEGORY":    "app-misc",
+	"PF":          "foo-1.0-r1",
+	"SLOT":        "0",
+	"EAPI":        "8",
+	"KEYWORDS":    "amd64 ~arm64",
+	"IUSE":        "+ssl test",
+	"USE":         "amd64 elibc_glibc ssl",
+	"RDEPEND":     "dev-libs/openssl:=\n\tsys-libs/zlib",
+	"DESCRIPTION": "A test package",
+	"CHOST":       "x86_64-pc-linux-gnu",
+	"BUILD_ID":    "2",
+	"repository":  "gentoo",
+}
+
+func TestReadGpkg(t *testing.T) {
+	data := buildTestGpkg(t, "foo-1.0-r1-2", testGpkgMetadata, nil, false)
+	g, err := ReadGpkg(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("ReadGpkg: %v", err)
+	}
+	if g.Dir != "foo-1.0-r1-2" || g.CPV() != "app-misc/foo-1.0-r1" {
+		t.Errorf("Dir = %q, CPV() = %q", g.Dir, g.CPV())
+	}
+	if got := g.Metadata["RDEPEND"]; got != "dev-libs/openssl:=\n\tsys-libs/zlib" {
+		t.Errorf("Metadata[RDEPEND] = %q", got)
+	}
+	var paths []string
+	for _, e := range g.Image {
+		paths = append(paths, e.Path)
+	}
+	if got := strings.Join(paths, " "); got != "/usr /usr/bin /usr/bin/foo" {
+		t.Errorf("Image paths = %s", got)
+	}
+	if errs := g.VerifyManifest(); len(errs) != 0 {
+		t.Errorf("VerifyManifest() = %v", errs)
+	}
+	if g.Signed() {
+		t.Error("unsigned package reported as signed")
+	}
+
+	tampered := buildTestGpkg(t, "foo-1.0-r1-2", testGpkgMetadata, nil, true)
+	g, err = ReadGpkg(bytes.NewReader(tampered), int64(len(tampered)))
+	if err != nil {
+		t.Fatalf("ReadGpkg: %v", err)
+	}
+	if errs := g.VerifyManifest(); len(errs) == 0 {
+		t.Error("VerifyManifest() accepted a tampered image")
+	}
+
+	notGpkg := writeTar(t, []tarFile{{Name: "foo/image.tar", Body: "x"}})
+	if _, err := ReadGpkg(bytes.NewReader(notGpkg), int64(len(notGpkg))); err == nil {
+		t.Error("expected an archive without gpkg-1 to be rejected")
+	}
+}
+
+func TestGpkgVerifySignatures(t *testing.T) {
+	signer, err := openpgp.NewEntity("Builder", "", "builder@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := openpgp.NewEntity("Other", "", "other@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buildTestGpkg(t, "foo-1.0-r1-2", testGpkgMetadata, signer, false)
+	g, err := ReadGpkg(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("ReadGpkg: %v", err)
+	}
+	if !g.ManifestSigned {
+		t.Fatal("clearsigned Manifest not detected")
+	}
+	if errs := g.VerifyManifest(); len(errs) != 0 {
+		t.Errorf("VerifyManifest() = %v", errs)
+	}
+	if errs := g.VerifySignatures(openpgp.EntityList{signer}); len(errs) != 0 {
+		t.Errorf("VerifySignatures(signer) = %v", errs)
+	}
+	if errs := g.VerifySignatures(openpgp.EntityList{other}); len(errs) == 0 {
+		t.Error("VerifySignatures accepted a signature from an unknown key")
+	}
+}
+
+func TestBuildPackagesIndex(t *testing.T) {
+	dir := t.TempDir()
+	bar := map[string]string{"CATEGORY": "app-misc", "PF": "bar-2", "EAPI": "8", "SLOT": "2", "CHOST": "x86_64-pc-linux-gnu", "BUILD_ID": "1"}
+	for path, data := range map[string][]byte{
+		"app-misc/foo/foo-1.0-r1-2.gpkg.tar": buildTestGpkg(t, "foo-1.0-r1-2", testGpkgMetadata, nil, false),
+		"app-misc/bar/bar-2-1.gpkg.tar":      buildTestGpkg(t, "bar-2-1", bar, nil, false),
+	} {
+		p := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	idx, err := BuildPackagesIndex(dir)
+	if err != nil {
+		t.Fatalf("BuildPackagesIndex: %v", err)
+	}
+	if len(idx.Packages) != 2 || idx.Packages[0]["CPV"] != "app-misc/bar-2" {
+		t.Fatalf("Packages = %v", idx.Packages)
+	}
+	if idx.Header["PACKAGES"] != "2" || idx.Header["CHOST"] != "x86_64-pc-linux-gnu" {
+		t.Errorf("Header = %v", idx.Header)
+	}
+	foo := idx.Packages[1]
+	want := map[string]string{
+		"PATH":    "app-misc/foo/foo-1.0-r1-2.gpkg.tar",
+		"USE":     "ssl",
+		"RDEPEND": "dev-libs/openssl:= sys-libs/zlib",
+		"SIZE":    fmt.Sprint(len(buildTestGpkg(t, "foo-1.0-r1-2", testGpkgMetadata, nil, false))),
+	}
+	for k, v := range want {
+		if foo[k] != v {
+			t.Errorf("foo[%s] = %q, want %q", k, foo[k], v)
+		}
+	}
+	if _, ok := foo["SLOT"]; ok {
+		t.Error("default SLOT 0 should be left out")
+	}
+	if foo["MD5"] == "" || foo["SHA1"] == "" || foo["MTIME"] == "" {
+		t.Errorf("missing file checksums: %v", foo)
+	}
+
+	out := idx.String()
+	if !strings.Contains(out, "VERSION: 0\n\nBUILD_ID: 1\nCHOST: x86_64-pc-linux-gnu\nCPV: app-misc/bar-2\n") {
+		t.Errorf("unexpected Packages layout:\n%s", out)
+	}
+}
+
+func TestReadGpkgCompression(t *testing.T) {
+	metadata := writeTar(t, []tarFile{{Name: "metadata/"}, {Name: "metadata/CATEGORY", Body: "app-misc\n"}, {Name: "metadata/PF", Body: "foo-1.0\n"}})
+	compressors := map[string]func(w io.Writer) (io.WriteCloser, error){
+		"zst": func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+		"xz":  func(w io.Writer) (io.WriteCloser, error) { return xz.NewWriter(w) },
+	}
+	for suffix, compress := range compressors {
+		var buf bytes.Buffer
+		w, err := compress(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(metadata); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		data := writeTar(t, []tarFile{
+			{Name: "foo-1.0/"},
+			{Name: "foo-1.0/" + GpkgFormatFile, Body: "gpkg-1\n"},
+			{Name: "foo-1.0/metadata.tar." + suffix, Body: buf.String()},
+		})
+		g, err := ReadGpkg(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: ReadGpkg: %v", suffix, err)
+		}
+		if len(g.Undecoded) != 0 || g.CPV() != "app-misc/foo-1.0" {
+			t.Errorf("%s: Undecoded = %v, CPV() = %q", suffix, g.Undecoded, g.CPV())
+		}
+	}
+}
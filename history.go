@@ -0,0 +1,435 @@
+package g2
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// ChangeKind classifies what a commit did to a package.
+type ChangeKind string
+
+const (
+	// ChangeBump adds an ebuild, a new version or revision.
+	ChangeBump ChangeKind = "bump"
+	// ChangeDrop removes an ebuild.
+	ChangeDrop ChangeKind = "drop"
+	// ChangeKeywording changes nothing but the KEYWORDS of an ebuild.
+	ChangeKeywording ChangeKind = "keywording"
+	// ChangeMetadata changes metadata.xml.
+	ChangeMetadata ChangeKind = "metadata"
+	// ChangeUpdate edits an ebuild in place.
+	ChangeUpdate ChangeKind = "update"
+	// ChangeOther touches only other files of the package, such as the Manifest or files/.
+	ChangeOther ChangeKind = "other"
+)
+
+// File actions of a PackageFileChange.
+const (
+	FileAdded    = "added"
+	FileRemoved  = "removed"
+	FileModified = "modified"
+)
+
+// PackageFileChange is a file of a package directory changed by a commit.
+type PackageFileChange struct {
+	Path   string `json:"path"` // relative to the package directory
+	Action string `json:"action"`
+}
+
+// PackageChange is a commit that touched a package directory.
+type PackageChange struct {
+	Package   string       `json:"package"` // category/name
+	Hash      string       `json:"hash"`
+	Author    string       `json:"author"`
+	Email     string       `json:"email"`
+	Date      time.Time    `json:"date"`
+	Subject   string       `json:"subject"`
+	Message   string       `json:"message"`
+	Kinds     []ChangeKind `json:"kinds"`
+	Added     []string     `json:"added,omitempty"`     // versions
+	Removed   []string     `json:"removed,omitempty"`   // versions
+	Keyworded []string     `json:"keyworded,omitempty"` // versions
+	// NewTesting holds, for each modified ebuild version, the arches the commit keyworded ~arch that
+	// had no stable or testing keyword before.
+	NewTesting map[string][]string `json:"new_testing,omitempty"`
+	Files      []PackageFileChange `json:"files"`
+}
+
+// Category returns the category of the changed package.
+func (c PackageChange) Category() string {
+	cat, _, _ := strings.Cut(c.Package, "/")
+	return cat
+}
+
+// Name returns the name of the changed package.
+func (c PackageChange) Name() string {
+	return path.Base(c.Package)
+}
+
+// HasKind reports whether the change is of kind k.
+func (c PackageChange) HasKind(k ChangeKind) bool {
+	for _, kind := range c.Kinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+// HistoryOptions bounds the history read by ReadRepoHistory.
+type HistoryOptions struct {
+	// MaxCommits stops the walk after this many commits; 0 reads the whole history.
+	MaxCommits int
+	// Since stops the walk at the first commit older than this, if set.
+	Since time.Time
+}
+
+// historyIgnoredDirs are the top-level directories of a repository that hold no packages.
+var historyIgnoredDirs = map[string]bool{
+	"profiles": true, "metadata": true, "eclass": true, "licenses": true, "scripts": true,
+	"distfiles": true, "packages": true,
+}
+
+// ReadRepoHistory walks the first-parent history of the git repository holding dir back from HEAD and
+// returns the changes to each package below dir, newest first, keyed by category/name. dir may be a
+// subdirectory of the work tree. The walk ends at the boundary of a shallow clone.
+func ReadRepoHistory(dir string, opts HistoryOptions) (map[string][]PackageChange, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("opening git repository at %s: %w", dir, err)
+	}
+	prefix, err := historyPrefix(repo, dir)
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("reading HEAD of %s: %w", dir, err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	history := map[string][]PackageChange{}
+	for n := 0; commit != nil && (opts.MaxCommits <= 0 || n < opts.MaxCommits); n++ {
+		if !opts.Since.IsZero() && commit.Committer.When.Before(opts.Since) {
+			break
+		}
+		var parent *object.Commit
+		if commit.NumParents() > 0 {
+			parent, err = commit.Parent(0)
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				// The boundary of a shallow clone: the changes of this commit are unknown.
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading parent of %s: %w", commit.Hash, err)
+			}
+		}
+		changes, err := commitPackageChanges(commit, parent, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", commit.Hash, err)
+		}
+		for _, c := range changes {
+			history[c.Package] = append(history[c.Package], c)
+		}
+		commit = parent
+	}
+	return history, nil
+}
+
+// historyPrefix returns the slash separated path of dir within the work tree of repo.
+func historyPrefix(repo *git.Repository, dir string) (string, error) {
+	wt, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("opening work tree: %w", err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	root := wt.Filesystem.Root()
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is outside the work tree %s", dir, root)
+	}
+	if rel == "." {
+		return "", nil
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// commitTree returns the tree of c below prefix, or nil when c is nil or has no such directory.
+func commitTree(c *object.Commit, prefix string) (*object.Tree, error) {
+	if c == nil {
+		return nil, nil
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	if prefix == "" {
+		return tree, nil
+	}
+	sub, err := tree.Tree(prefix)
+	if errors.Is(err, object.ErrDirectoryNotFound) {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// commitPackageChanges classifies the changes commit made to each package, compared to parent.
+func commitPackageChanges(commit, parent *object.Commit, prefix string) ([]PackageChange, error) {
+	to, err := commitTree(commit, prefix)
+	if err != nil {
+		return nil, err
+	}
+	from, err := commitTree(parent, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if to == nil && from == nil {
+		return nil, nil
+	}
+	diff, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byPkg := map[string]*PackageChange{}
+	var order []string
+	for _, ch := range diff {
+		name := ch.To.Name
+		if name == "" {
+			name = ch.From.Name
+		}
+		parts := strings.SplitN(name, "/", 3)
+		if len(parts) < 3 || historyIgnoredDirs[parts[0]] || strings.HasPrefix(parts[0], ".") {
+			continue
+		}
+		key := parts[0] + "/" + parts[1]
+		pc := byPkg[key]
+		if pc == nil {
+			subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+			pc = &PackageChange{
+				Package: key,
+				Hash:    commit.Hash.String(),
+				Author:  commit.Author.Name,
+				Email:   commit.Author.Email,
+				Date:    commit.Author.When,
+				Subject: strings.TrimSpace(subject),
+				Message: strings.TrimSpace(commit.Message),
+			}
+			byPkg[key] = pc
+			order = append(order, key)
+		}
+
+		action, err := ch.Action()
+		if err != nil {
+			return nil, err
+		}
+		file := parts[2]
+		fc := PackageFileChange{Path: file, Action: FileModified}
+		switch action {
+		case merkletrie.Insert:
+			fc.Action = FileAdded
+		case merkletrie.Delete:
+			fc.Action = FileRemoved
+		}
+		pc.Files = append(pc.Files, fc)
+
+		switch {
+		case !strings.Contains(file, "/") && strings.HasSuffix(file, ".ebuild"):
+			version := strings.TrimSuffix(strings.TrimPrefix(file, parts[1]+"-"), ".ebuild")
+			switch fc.Action {
+			case FileAdded:
+				pc.Added = append(pc.Added, version)
+			case FileRemoved:
+				pc.Removed = append(pc.Removed, version)
+			default:
+				keywordsOnly, newTesting, err := ebuildKeywordChange(ch)
+				if err != nil {
+					return nil, err
+				}
+				if len(newTesting) > 0 {
+					if pc.NewTesting == nil {
+						pc.NewTesting = map[string][]string{}
+					}
+					pc.NewTesting[version] = newTesting
+				}
+				if keywordsOnly {
+					pc.Keyworded = append(pc.Keyworded, version)
+				} else {
+					pc.addKind(ChangeUpdate)
+				}
+			}
+		case file == "metadata.xml":
+			pc.addKind(ChangeMetadata)
+		}
+	}
+
+	changes := make([]PackageChange, 0, len(order))
+	for _, key := range order {
+		pc := byPkg[key]
+		if len(pc.Added) > 0 {
+			pc.addKind(ChangeBump)
+		}
+		if len(pc.Removed) > 0 {
+			pc.addKind(ChangeDrop)
+		}
+		if len(pc.Keyworded) > 0 {
+			pc.addKind(ChangeKeywording)
+		}
+		if len(pc.Kinds) == 0 {
+			pc.addKind(ChangeOther)
+		}
+		sort.Slice(pc.Kinds, func(i, j int) bool { return changeKindOrder(pc.Kinds[i]) < changeKindOrder(pc.Kinds[j]) })
+		changes = append(changes, *pc)
+	}
+	return changes, nil
+}
+
+func (c *PackageChange) addKind(k ChangeKind) {
+	if !c.HasKind(k) {
+		c.Kinds = append(c.Kinds, k)
+	}
+}
+
+func changeKindOrder(k ChangeKind) int {
+	for i, kind := range []ChangeKind{ChangeBump, ChangeDrop, ChangeKeywording, ChangeMetadata, ChangeUpdate, ChangeOther} {
+		if kind == k {
+			return i
+		}
+	}
+	return len(k)
+}
+
+// ebuildKeywordChange reports whether a modified ebuild differs only in its KEYWORDS assignment, and
+// the arches it newly keywords ~arch.
+func ebuildKeywordChange(ch *object.Change) (keywordsOnly bool, newTesting []string, err error) {
+	from, to, err := ch.Files()
+	if err != nil {
+		return false, nil, err
+	}
+	if from == nil || to == nil {
+		return false, nil, nil
+	}
+	a, err := from.Contents()
+	if err != nil {
+		return false, nil, err
+	}
+	b, err := to.Contents()
+	if err != nil {
+		return false, nil, err
+	}
+	restA, kwA := splitKeywordsLine(a)
+	restB, kwB := splitKeywordsLine(b)
+	if kwA != kwB {
+		before, after := keywordsLineValue(kwA), keywordsLineValue(kwB)
+		for _, kw := range strings.Fields(after) {
+			arch, testing := strings.CutPrefix(kw, "~")
+			if !testing || arch == "*" || ArchKeywordStatus(after, arch) != KeywordTesting {
+				continue
+			}
+			if status := ArchKeywordStatus(before, arch); status != KeywordStable && status != KeywordTesting {
+				newTesting = append(newTesting, arch)
+			}
+		}
+	}
+	return restA == restB && kwA != kwB, newTesting, nil
+}
+
+// keywordsLineValue returns the keywords assigned by KEYWORDS lines, without quotes.
+func keywordsLineValue(lines string) string {
+	var values []string
+	for _, line := range strings.Split(lines, "\n") {
+		if _, v, ok := strings.Cut(strings.TrimSpace(line), "KEYWORDS="); ok {
+			values = append(values, strings.Trim(v, `"'`))
+		}
+	}
+	return strings.Join(values, " ")
+}
+
+// splitKeywordsLine returns the ebuild without its KEYWORDS lines, and those lines.
+func splitKeywordsLine(ebuild string) (rest, keywords string) {
+	var r, k strings.Builder
+	for _, line := range strings.SplitAfter(ebuild, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "KEYWORDS=") {
+			k.WriteString(line)
+		} else {
+			r.WriteString(line)
+		}
+	}
+	return r.String(), k.String()
+}
+
+// WriteChangeLog writes the changes of the package pkg, newest first, in the ChangeLog format that
+// egencache --update-changelogs produces from git history.
+func WriteChangeLog(w io.Writer, pkg string, changes []PackageChange) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# ChangeLog for %s\n# (auto-generated from git log)\n", pkg)
+	name := path.Base(pkg)
+	for _, c := range changes {
+		date := c.Date.UTC().Format("02 Jan 2006")
+		bw.WriteString("\n")
+		for _, v := range c.Added {
+			fmt.Fprintf(bw, "*%s-%s (%s)\n\n", name, v, date)
+		}
+		var files []string
+		for _, f := range c.Files {
+			switch f.Action {
+			case FileAdded:
+				files = append(files, "+"+f.Path)
+			case FileRemoved:
+				files = append(files, "-"+f.Path)
+			default:
+				files = append(files, f.Path)
+			}
+		}
+		head := fmt.Sprintf("%s; %s <%s> %s:", date, c.Author, c.Email, strings.Join(files, ", "))
+		for _, line := range wrapChangeLogText(head, 78) {
+			fmt.Fprintf(bw, "  %s\n", line)
+		}
+		for _, line := range wrapChangeLogText(c.Message, 78) {
+			if line == "" {
+				bw.WriteString("\n")
+				continue
+			}
+			fmt.Fprintf(bw, "  %s\n", line)
+		}
+	}
+	return bw.Flush()
+}
+
+// wrapChangeLogText wraps the paragraphs of text at width columns, keeping blank lines between them.
+func wrapChangeLogText(text string, width int) []string {
+	var lines []string
+	for _, para := range strings.Split(text, "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		lines = append(lines, wrapWords(words, width, "", "")...)
+	}
+	return lines
+}
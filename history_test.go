@@ -0,0 +1,166 @@
+package g2
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type historyTestRepo struct {
+	t    *testing.T
+	dir  string
+	wt   *git.Worktree
+	when time.Time
+}
+
+func newHistoryTestRepo(t *testing.T) *historyTestRepo {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &historyTestRepo{t: t, dir: dir, wt: wt, when: time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)}
+}
+
+// commit writes files, removes those mapped to "", and commits the result.
+func (r *historyTestRepo) commit(msg string, files map[string]string) {
+	r.t.Helper()
+	for name, content := range files {
+		p := filepath.Join(r.dir, filepath.FromSlash(name))
+		if content == "" {
+			if _, err := r.wt.Remove(name); err != nil {
+				r.t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+		if _, err := r.wt.Add(name); err != nil {
+			r.t.Fatal(err)
+		}
+	}
+	r.when = r.when.Add(24 * time.Hour)
+	sig := &object.Signature{Name: "Jane Doe", Email: "jane@example.org", When: r.when}
+	if _, err := r.wt.Commit(msg, &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+func TestReadRepoHistory(t *testing.T) {
+	const ebuild = "EAPI=8\nDESCRIPTION=\"Foo\"\nSLOT=\"0\"\nKEYWORDS=\"~amd64\"\n"
+	r := newHistoryTestRepo(t)
+	r.commit("app-misc/foo: new package", map[string]string{
+		"profiles/repo_name":          "hist\n",
+		"app-misc/foo/foo-1.0.ebuild": ebuild,
+		"app-misc/foo/metadata.xml":   "<pkgmetadata/>\n",
+	})
+	r.commit("app-misc/foo: stabilize 1.0 for amd64", map[string]string{
+		"app-misc/foo/foo-1.0.ebuild": strings.Replace(ebuild, "~amd64", "amd64", 1),
+	})
+	r.commit("app-misc/foo: add 1.1, drop 1.0\n\nThe old version no longer builds.", map[string]string{
+		"app-misc/foo/foo-1.1.ebuild": ebuild,
+		"app-misc/foo/foo-1.0.ebuild": "",
+	})
+	r.commit("app-misc/foo: update maintainer", map[string]string{
+		"app-misc/foo/metadata.xml": "<pkgmetadata><maintainer/></pkgmetadata>\n",
+		"profiles/repo_name":        "history\n",
+	})
+	r.commit("app-misc/foo: fix build", map[string]string{
+		"app-misc/foo/foo-1.1.ebuild":  strings.Replace(ebuild, "~amd64", "~amd64 ~arm64", 1) + "PATCHES=( fix.patch )\n",
+		"app-misc/foo/files/fix.patch": "--- a\n+++ b\n",
+	})
+
+	history, err := ReadRepoHistory(r.dir, HistoryOptions{})
+	if err != nil {
+		t.Fatalf("ReadRepoHistory: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("packages = %v", history)
+	}
+	changes := history["app-misc/foo"]
+	var kinds []string
+	for _, c := range changes {
+		var ks []string
+		for _, k := range c.Kinds {
+			ks = append(ks, string(k))
+		}
+		kinds = append(kinds, strings.Join(ks, "+"))
+	}
+	if got := strings.Join(kinds, " "); got != "update metadata bump+drop keywording bump+metadata" {
+		t.Errorf("kinds = %s", got)
+	}
+	if c := changes[2]; strings.Join(c.Added, ",") != "1.1" || strings.Join(c.Removed, ",") != "1.0" || c.Subject != "app-misc/foo: add 1.1, drop 1.0" {
+		t.Errorf("bump = %+v", c)
+	}
+	if c := changes[3]; strings.Join(c.Keyworded, ",") != "1.0" || len(c.NewTesting) != 0 {
+		t.Errorf("keywording = %+v", c)
+	}
+	if c := changes[0]; len(c.NewTesting) != 1 || strings.Join(c.NewTesting["1.1"], ",") != "arm64" {
+		t.Errorf("new testing keywords = %v", c.NewTesting)
+	}
+	if c := changes[0]; len(c.Files) != 2 || c.Files[0].Path != "files/fix.patch" || c.Files[0].Action != FileAdded {
+		t.Errorf("files = %+v", c.Files)
+	}
+
+	limited, err := ReadRepoHistory(r.dir, HistoryOptions{MaxCommits: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(limited["app-misc/foo"]) != 2 {
+		t.Errorf("MaxCommits 2 read %d changes", len(limited["app-misc/foo"]))
+	}
+	since, err := ReadRepoHistory(r.dir, HistoryOptions{Since: r.when.Add(-36 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(since["app-misc/foo"]) != 2 {
+		t.Errorf("Since read %d changes", len(since["app-misc/foo"]))
+	}
+
+	// A repository in a subdirectory of the work tree.
+	sub := newHistoryTestRepo(t)
+	sub.commit("add overlay", map[string]string{"overlay/app-misc/bar/bar-1.ebuild": ebuild, "README": "x\n"})
+	subHistory, err := ReadRepoHistory(filepath.Join(sub.dir, "overlay"), HistoryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subHistory["app-misc/bar"]) != 1 || !subHistory["app-misc/bar"][0].HasKind(ChangeBump) {
+		t.Errorf("subdirectory history = %+v", subHistory)
+	}
+
+	var sb strings.Builder
+	if err := WriteChangeLog(&sb, "app-misc/foo", changes); err != nil {
+		t.Fatal(err)
+	}
+	log := sb.String()
+	for _, want := range []string{
+		"# ChangeLog for app-misc/foo\n",
+		"*foo-1.1 (05 Jan 2026)\n\n  05 Jan 2026; Jane Doe <jane@example.org> -foo-1.0.ebuild, +foo-1.1.ebuild:\n  app-misc/foo: add 1.1, drop 1.0\n\n  The old version no longer builds.\n",
+		"  04 Jan 2026; Jane Doe <jane@example.org> foo-1.0.ebuild:\n",
+	} {
+		if !strings.Contains(log, want) {
+			t.Errorf("ChangeLog lacks %q:\n%s", want, log)
+		}
+	}
+}
+
+func TestWrapChangeLogText(t *testing.T) {
+	got := wrapChangeLogText("Ärger über Öl und Ähnliches\n\nsecond", 12)
+	want := []string{"Ärger über", "Öl und", "Ähnliches", "", "second"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("wrapChangeLogText = %q, want %q", got, want)
+	}
+}
@@ -80,12 +80,75 @@ func (d *DownloadProgress) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// NewHash returns a hash for one of the Manifest hash names in AllHashes.
+func NewHash(name string) (hash.Hash, error) {
+	switch name {
+	case HashBlake2b:
+		return blake2b.New512(nil)
+	case HashBlake2s:
+		return blake2s.New256(nil)
+	case HashMd5:
+		return md5.New(), nil
+	case HashRmd160:
+		return ripemd160.New(), nil //nolint:staticcheck
+	case HashSha1:
+		return sha1.New(), nil
+	case HashSha256:
+		return sha256.New(), nil
+	case HashSha3_256:
+		return sha3.New256(), nil //nolint:govet
+	case HashSha3_512:
+		return sha3.New512(), nil //nolint:govet
+	case HashSha512:
+		return sha512.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported hash %q", name)
+}
+
+// ChecksumReader reads r to the end and returns its size and the requested hashes.
+func ChecksumReader(r io.Reader, hashes []string) (*Checksums, error) {
+	hashers := make(map[string]hash.Hash)
+	var writers []io.Writer
+	for _, name := range hashes {
+		h, err := NewHash(name)
+		if err != nil {
+			return nil, err
+		}
+		hashers[name] = h
+		writers = append(writers, h)
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), r)
+	if err != nil {
+		return nil, err
+	}
+	checksums := &Checksums{Size: size, Hashes: make(map[string]string)}
+	for k, h := range hashers {
+		checksums.Hashes[k] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return checksums, nil
+}
+
 type Checksums struct {
 	Size   int64
 	Hashes map[string]string
 }
 
+// DownloadAndChecksum downloads url and returns its size and those of hashes that are supported; the
+// others are skipped with a warning. It fails without downloading when none of hashes is supported.
 func DownloadAndChecksum(url string, hashes []string) (*Checksums, error) {
+	hashers := make(map[string]hash.Hash)
+	for _, name := range hashes {
+		h, err := NewHash(name)
+		if err != nil {
+			log.Printf("Warning: skipping %s for %s: %v", name, url, err)
+			continue
+		}
+		hashers[name] = h
+	}
+	if len(hashers) == 0 {
+		return nil, fmt.Errorf("no supported hash among %q", hashes)
+	}
+
 	resp, err := http.Get(url)
 	if err != nil {
 		return nil, err
@@ -102,53 +165,8 @@ func DownloadAndChecksum(url string, hashes []string) (*Checksums, error) {
 	}
 
 	writers := []io.Writer{&DownloadProgress{Resp: resp}}
-	hashers := make(map[string]hash.Hash)
-
-	for _, h := range hashes {
-		switch h {
-		case HashBlake2b:
-			blake2bHash, err := blake2b.New512(nil)
-			if err != nil {
-				return nil, fmt.Errorf("bad blake2b initialization: %w", err)
-			}
-			writers = append(writers, blake2bHash)
-			hashers[HashBlake2b] = blake2bHash
-		case HashBlake2s:
-			blake2sHash, err := blake2s.New256(nil)
-			if err != nil {
-				return nil, fmt.Errorf("bad blake2s initialization: %w", err)
-			}
-			writers = append(writers, blake2sHash)
-			hashers[HashBlake2s] = blake2sHash
-		case HashMd5:
-			h := md5.New()
-			writers = append(writers, h)
-			hashers[HashMd5] = h
-		case HashRmd160:
-			h := ripemd160.New() //nolint:staticcheck
-			writers = append(writers, h)
-			hashers[HashRmd160] = h
-		case HashSha1:
-			h := sha1.New()
-			writers = append(writers, h)
-			hashers[HashSha1] = h
-		case HashSha256:
-			h := sha256.New()
-			writers = append(writers, h)
-			hashers[HashSha256] = h
-		case HashSha3_256:
-			h := sha3.New256() //nolint:govet
-			writers = append(writers, h)
-			hashers[HashSha3_256] = h
-		case HashSha3_512:
-			h := sha3.New512() //nolint:govet
-			writers = append(writers, h)
-			hashers[HashSha3_512] = h
-		case HashSha512:
-			h := sha512.New()
-			writers = append(writers, h)
-			hashers[HashSha512] = h
-		}
+	for _, h := range hashers {
+		writers = append(writers, h)
 	}
 
 	multiWriter := io.MultiWriter(writers...)
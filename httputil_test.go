@@ -0,0 +1,31 @@
+package g2
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDownloadAndChecksum(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("hello\n"))
+	}))
+	defer srv.Close()
+
+	sums, err := DownloadAndChecksum(srv.URL, []string{"WHIRLPOOL", HashSha256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sums.Size != 6 || len(sums.Hashes) != 1 || sums.Hashes[HashSha256] != "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03" {
+		t.Errorf("checksums = %+v", sums)
+	}
+
+	if _, err := DownloadAndChecksum(srv.URL, []string{"WHIRLPOOL"}); err == nil {
+		t.Error("expected an error without a supported hash")
+	}
+	if requests != 1 {
+		t.Errorf("made %d requests, want 1", requests)
+	}
+}
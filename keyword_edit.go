@@ -0,0 +1,298 @@
+package g2
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// KeywordOpKind is the kind of change a KeywordOp makes to KEYWORDS.
+type KeywordOpKind int
+
+const (
+	KeywordOpStable       KeywordOpKind = iota // arch
+	KeywordOpTesting                           // ~arch
+	KeywordOpDisable                           // -arch
+	KeywordOpDrop                              // ^arch
+	KeywordOpDisableAll                        // -*
+	KeywordOpStableAll                         // all: every ~arch that can be stable becomes arch
+	KeywordOpTestingAll                        // ~all: every arch becomes ~arch
+	KeywordOpDropDisabled                      // ^-*
+)
+
+// KeywordOp is an ekeyword style operation on the KEYWORDS of an ebuild.
+type KeywordOp struct {
+	Kind KeywordOpKind
+	Arch string
+}
+
+// String returns the operation as it is written on the command line.
+func (op KeywordOp) String() string {
+	switch op.Kind {
+	case KeywordOpTesting:
+		return "~" + op.Arch
+	case KeywordOpDisable:
+		return "-" + op.Arch
+	case KeywordOpDrop:
+		return "^" + op.Arch
+	case KeywordOpDisableAll:
+		return "-*"
+	case KeywordOpStableAll:
+		return "all"
+	case KeywordOpTestingAll:
+		return "~all"
+	case KeywordOpDropDisabled:
+		return "^-*"
+	}
+	return op.Arch
+}
+
+var keywordArchRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ParseKeywordOp parses an operation: arch, ~arch, -arch, ^arch, -*, ^-*, all or ~all.
+func ParseKeywordOp(s string) (KeywordOp, error) {
+	switch s {
+	case "-*":
+		return KeywordOp{Kind: KeywordOpDisableAll}, nil
+	case "^-*":
+		return KeywordOp{Kind: KeywordOpDropDisabled}, nil
+	case "all":
+		return KeywordOp{Kind: KeywordOpStableAll}, nil
+	case "~all":
+		return KeywordOp{Kind: KeywordOpTestingAll}, nil
+	}
+	op := KeywordOp{Kind: KeywordOpStable, Arch: s}
+	switch {
+	case strings.HasPrefix(s, "~"):
+		op = KeywordOp{Kind: KeywordOpTesting, Arch: s[1:]}
+	case strings.HasPrefix(s, "-"):
+		op = KeywordOp{Kind: KeywordOpDisable, Arch: s[1:]}
+	case strings.HasPrefix(s, "^"):
+		op = KeywordOp{Kind: KeywordOpDrop, Arch: strings.TrimLeft(s[1:], "~-")}
+	}
+	if !keywordArchRegex.MatchString(op.Arch) {
+		return KeywordOp{}, fmt.Errorf("invalid keyword operation %q", s)
+	}
+	return op, nil
+}
+
+// ParseKeywordOps parses each of args with ParseKeywordOp.
+func ParseKeywordOps(args []string) ([]KeywordOp, error) {
+	ops := make([]KeywordOp, 0, len(args))
+	for _, a := range args {
+		op, err := ParseKeywordOp(a)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// KeywordEditor applies KeywordOps to KEYWORDS, ordering arches as arch.list does and validating the
+// arches it keywords against arch.list and profiles.desc. A nil ArchList or empty Profiles skips that
+// check; without an ArchList arches are sorted by name.
+type KeywordEditor struct {
+	ArchList *ArchList
+	Profiles []ProfileDescEntry
+}
+
+// Apply returns keywords with ops applied in order and sorted. Keywords the operations did not touch
+// are kept as they are, so an ebuild with an unknown arch can still be edited; likewise all leaves the
+// ~arches it cannot stabilize, such as prefix arches without a stable profile, in testing.
+func (e *KeywordEditor) Apply(keywords []string, ops []KeywordOp) ([]string, error) {
+	out := append([]string(nil), keywords...)
+	changed := map[string]bool{}
+	set := func(arch, kw string) {
+		out = removeKeywordArch(out, arch)
+		if kw != "" {
+			out = append(out, kw)
+		}
+		changed[arch] = true
+	}
+	for _, op := range ops {
+		switch op.Kind {
+		case KeywordOpStable:
+			set(op.Arch, op.Arch)
+		case KeywordOpTesting:
+			set(op.Arch, "~"+op.Arch)
+		case KeywordOpDisable:
+			set(op.Arch, "-"+op.Arch)
+		case KeywordOpDrop:
+			out = removeKeywordArch(out, op.Arch)
+		case KeywordOpDisableAll:
+			if !containsString(out, "-*") {
+				out = append(out, "-*")
+			}
+		case KeywordOpDropDisabled:
+			out = removeKeywordArch(out, "*")
+		case KeywordOpStableAll, KeywordOpTestingAll:
+			for i, kw := range out {
+				if op.Kind == KeywordOpStableAll && strings.HasPrefix(kw, "~") && kw != "~*" {
+					if e.validate(kw[1:], true) != nil {
+						continue
+					}
+					out[i] = kw[1:]
+					changed[kw[1:]] = true
+				} else if op.Kind == KeywordOpTestingAll && !strings.HasPrefix(kw, "~") && !strings.HasPrefix(kw, "-") {
+					out[i] = "~" + kw
+				}
+			}
+		}
+	}
+	for _, kw := range out {
+		arch := strings.TrimLeft(kw, "~-")
+		if changed[arch] && arch != "*" {
+			if err := e.validate(arch, ArchKeywordStatus(strings.Join(out, " "), arch) == KeywordStable); err != nil {
+				return nil, err
+			}
+		}
+	}
+	e.Sort(out)
+	return out, nil
+}
+
+func (e *KeywordEditor) validate(arch string, stable bool) error {
+	if e.ArchList != nil && len(e.ArchList.Arches) > 0 && !containsString(e.ArchList.Arches, arch) {
+		return fmt.Errorf("unknown arch %q: not in profiles/arch.list", arch)
+	}
+	if !stable || len(e.Profiles) == 0 {
+		return nil
+	}
+	for _, p := range e.Profiles {
+		if p.Arch == arch && p.Status == "stable" {
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot stabilize on %s: profiles/profiles.desc has no stable profile for it", arch)
+}
+
+// Sort orders keywords in place: -* first, then by arch in arch.list order, with arches missing from
+// arch.list last by name.
+func (e *KeywordEditor) Sort(keywords []string) {
+	index := map[string]int{}
+	if e.ArchList != nil {
+		for i, a := range e.ArchList.Arches {
+			index[a] = i
+		}
+	}
+	rank := func(kw string) (int, string) {
+		arch := strings.TrimLeft(kw, "~-")
+		if arch == "*" {
+			return -1, arch
+		}
+		if i, ok := index[arch]; ok {
+			return i, arch
+		}
+		return len(index), arch
+	}
+	sort.SliceStable(keywords, func(i, j int) bool {
+		ri, ai := rank(keywords[i])
+		rj, aj := rank(keywords[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return ai < aj
+	})
+}
+
+// removeKeywordArch drops every keyword of arch, in any of its stable, testing or disabled forms.
+func removeKeywordArch(keywords []string, arch string) []string {
+	out := keywords[:0]
+	for _, kw := range keywords {
+		if strings.TrimLeft(kw, "~-") != arch {
+			out = append(out, kw)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrNoKeywords is returned by RewriteKeywords for an ebuild that does not assign KEYWORDS.
+var ErrNoKeywords = errors.New("no KEYWORDS assignment")
+
+var keywordsAssignRegex = regexp.MustCompile(`(?m)^([ \t]*)KEYWORDS=("[^"]*"|'[^']*'|[^ \t\n#;]*)`)
+
+// RewriteKeywords applies ops to the KEYWORDS assignment of the ebuild source content, changing nothing
+// else. The quote character of the value is kept, and an unquoted value is given double quotes once it
+// holds more than one keyword; the whitespace around and between the keywords is reused, so a value
+// split over lines stays split. When the ebuild assigns
+// KEYWORDS more than once, as live ebuilds do, only the non-empty assignments are rewritten. It reports
+// whether the content changed.
+func (e *KeywordEditor) RewriteKeywords(content string, ops []KeywordOp) (string, bool, error) {
+	matches := keywordsAssignRegex.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return content, false, ErrNoKeywords
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		value := content[m[4]:m[5]]
+		quote := ""
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote = value[:1]
+			value = value[1 : len(value)-1]
+		}
+		if len(matches) > 1 && strings.TrimSpace(value) == "" {
+			continue
+		}
+		keywords, err := e.Apply(strings.Fields(value), ops)
+		if err != nil {
+			return content, false, err
+		}
+		if quote == "" && len(keywords) != 1 {
+			quote = `"`
+		}
+		b.WriteString(content[last:m[4]])
+		b.WriteString(quote + joinKeywordsLike(value, keywords) + quote)
+		last = m[5]
+	}
+	b.WriteString(content[last:])
+	return b.String(), b.String() != content, nil
+}
+
+// joinKeywordsLike joins keywords with the whitespace of value: its leading and trailing whitespace and,
+// in order, the separators between its keywords, repeating the last one for any extra keywords.
+func joinKeywordsLike(value string, keywords []string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(keywords) == 0 {
+		return strings.Join(keywords, " ")
+	}
+	var seps []string
+	rest := value
+	start := strings.Index(rest, fields[0])
+	leading := rest[:start]
+	rest = rest[start+len(fields[0]):]
+	for _, f := range fields[1:] {
+		i := strings.Index(rest, f)
+		seps = append(seps, rest[:i])
+		rest = rest[i+len(f):]
+	}
+	var b strings.Builder
+	b.WriteString(leading)
+	for i, kw := range keywords {
+		if i > 0 {
+			switch {
+			case i-1 < len(seps):
+				b.WriteString(seps[i-1])
+			case len(seps) > 0:
+				b.WriteString(seps[len(seps)-1])
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString(kw)
+	}
+	b.WriteString(rest)
+	return b.String()
+}
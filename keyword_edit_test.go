@@ -0,0 +1,96 @@
+package g2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeywordEditorApply(t *testing.T) {
+	e := &KeywordEditor{
+		ArchList: &ArchList{Arches: []string{"alpha", "amd64", "arm", "arm64", "x86", "amd64-linux"}},
+		Profiles: []ProfileDescEntry{
+			{Arch: "amd64", Status: "stable"},
+			{Arch: "arm64", Status: "stable"},
+			{Arch: "x86", Status: "stable"},
+			{Arch: "arm", Status: "dev"},
+		},
+	}
+	tests := []struct {
+		keywords string
+		ops      string
+		want     string
+		wantErr  string
+	}{
+		{"~amd64 ~x86", "~arm64", "~amd64 ~arm64 ~x86", ""},
+		{"~amd64 ~x86", "amd64", "amd64 ~x86", ""},
+		{"~amd64 ~arm ~x86", "all", "amd64 ~arm x86", ""},
+		{"~amd64 ~amd64-linux ~x64-macos", "all", "amd64 ~amd64-linux ~x64-macos", ""},
+		{"~amd64 ~arm", "arm", "", "no stable profile"},
+		{"~amd64 ~arm64 ~x86", "all", "amd64 arm64 x86", ""},
+		{"amd64 ~arm64 x86", "~all", "~amd64 ~arm64 ~x86", ""},
+		{"amd64 ~arm x86", "^arm -*", "-* amd64 x86", ""},
+		{"-* ~amd64", "^-* -x86", "~amd64 -x86", ""},
+		{"~amd64-linux ~amd64 ~mips", "~alpha", "~alpha ~amd64 ~amd64-linux ~mips", ""},
+		{"~amd64", "~sparc", "", "not in profiles/arch.list"},
+		{"~amd64", "^sparc", "~amd64", ""},
+	}
+	for _, tt := range tests {
+		ops, err := ParseKeywordOps(strings.Fields(tt.ops))
+		if err != nil {
+			t.Fatalf("ParseKeywordOps(%q): %v", tt.ops, err)
+		}
+		got, err := e.Apply(strings.Fields(tt.keywords), ops)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Apply(%q, %q) error = %v, want %q", tt.keywords, tt.ops, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || strings.Join(got, " ") != tt.want {
+			t.Errorf("Apply(%q, %q) = %q, %v, want %q", tt.keywords, tt.ops, strings.Join(got, " "), err, tt.want)
+		}
+	}
+
+	if _, err := ParseKeywordOp("~am d64"); err == nil {
+		t.Error("ParseKeywordOp accepted an invalid arch")
+	}
+}
+
+func TestKeywordEditorRewriteKeywords(t *testing.T) {
+	e := &KeywordEditor{ArchList: &ArchList{Arches: []string{"amd64", "arm64", "x86"}}}
+	ops := []KeywordOp{{Kind: KeywordOpStable, Arch: "amd64"}, {Kind: KeywordOpTesting, Arch: "arm64"}}
+
+	src := "EAPI=8\n\nKEYWORDS='~x86 ~amd64' # comment\nRDEPEND=\"\"\n"
+	got, changed, err := e.RewriteKeywords(src, ops)
+	if err != nil || !changed {
+		t.Fatalf("RewriteKeywords: %v, changed %v", err, changed)
+	}
+	if want := "EAPI=8\n\nKEYWORDS='amd64 ~arm64 ~x86' # comment\nRDEPEND=\"\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	live := "if [[ ${PV} == 9999 ]]; then\n\tKEYWORDS=\"\"\nelse\n\tKEYWORDS=~x86\nfi\n"
+	got, _, err = e.RewriteKeywords(live, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "if [[ ${PV} == 9999 ]]; then\n\tKEYWORDS=\"\"\nelse\n\tKEYWORDS=\"amd64 ~arm64 ~x86\"\nfi\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	multi := "KEYWORDS=\"\n\t~amd64\n\t~x86\n\"\n"
+	got, _, err = e.RewriteKeywords(multi, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "KEYWORDS=\"\n\tamd64\n\t~arm64\n\t~x86\n\"\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, changed, err := e.RewriteKeywords("KEYWORDS=\"amd64 ~arm64\"\n", ops); err != nil || changed {
+		t.Errorf("unchanged keywords reported changed %v, %v", changed, err)
+	}
+	if _, _, err := e.RewriteKeywords("EAPI=8\n", ops); err == nil {
+		t.Error("expected an error without KEYWORDS")
+	}
+}
@@ -0,0 +1,127 @@
+package g2
+
+import (
+	"sort"
+	"strings"
+)
+
+// KeywordStatus is the state of one arch in the KEYWORDS of an ebuild.
+type KeywordStatus string
+
+const (
+	KeywordNone     KeywordStatus = ""         // not keyworded
+	KeywordStable   KeywordStatus = "stable"   // arch
+	KeywordTesting  KeywordStatus = "testing"  // ~arch
+	KeywordDisabled KeywordStatus = "disabled" // -arch, or -* without the arch
+)
+
+// Symbol returns the status as shown in keyword tables: +, ~, - or a blank.
+func (s KeywordStatus) Symbol() string {
+	switch s {
+	case KeywordStable:
+		return "+"
+	case KeywordTesting:
+		return "~"
+	case KeywordDisabled:
+		return "-"
+	}
+	return " "
+}
+
+// ArchKeywordStatus returns the status of arch in a KEYWORDS value.
+func ArchKeywordStatus(keywords, arch string) KeywordStatus {
+	status := KeywordNone
+	for _, kw := range strings.Fields(keywords) {
+		switch kw {
+		case arch:
+			return KeywordStable
+		case "~" + arch:
+			return KeywordTesting
+		case "-" + arch:
+			return KeywordDisabled
+		case "-*":
+			status = KeywordDisabled
+		}
+	}
+	return status
+}
+
+// KeywordMatrixRow is the keywords of one version of a package, by arch.
+type KeywordMatrixRow struct {
+	Version  string
+	Slot     string
+	Keywords map[string]KeywordStatus
+}
+
+// Status returns the status of arch in the row, for templates.
+func (r KeywordMatrixRow) Status(arch string) KeywordStatus {
+	return r.Keywords[arch]
+}
+
+// KeywordMatrix is the version by arch keyword table of a package.
+type KeywordMatrix struct {
+	Arches     []string          // columns, in arch.list order
+	ArchStatus map[string]string // the arches.desc status of each column: stable, transitional or testing
+	Rows       []KeywordMatrixRow
+}
+
+// NewKeywordMatrix builds the keyword table of versions, newest first. The columns are the arches of
+// archList that arches.desc lists, or all of them without an arches.desc, plus any other arch a version
+// is keyworded on. Either list may be nil.
+func NewKeywordMatrix(versions []VersionData, archList *ArchList, archesDesc *ArchesDesc) *KeywordMatrix {
+	m := &KeywordMatrix{ArchStatus: map[string]string{}}
+	keyworded := map[string]bool{}
+	for _, v := range versions {
+		if v.Ebuild == nil {
+			continue
+		}
+		for _, kw := range strings.Fields(v.Ebuild.Vars["KEYWORDS"]) {
+			if arch := strings.TrimLeft(kw, "~-"); arch != "*" {
+				keyworded[arch] = true
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	if archList != nil {
+		for _, arch := range archList.Arches {
+			listed := archesDesc == nil || len(archesDesc.Arches) == 0 || archesDesc.Arches[arch] != ""
+			if listed || keyworded[arch] {
+				m.Arches = append(m.Arches, arch)
+				seen[arch] = true
+			}
+		}
+	}
+	var extra []string
+	for arch := range keyworded {
+		if !seen[arch] {
+			extra = append(extra, arch)
+		}
+	}
+	sort.Strings(extra)
+	m.Arches = append(m.Arches, extra...)
+	if archesDesc != nil {
+		for _, arch := range m.Arches {
+			if status := archesDesc.Arches[arch]; status != "" {
+				m.ArchStatus[arch] = status
+			}
+		}
+	}
+
+	for _, v := range versions {
+		if v.Ebuild == nil {
+			continue
+		}
+		row := KeywordMatrixRow{Version: v.PVR(), Slot: v.Ebuild.Vars["SLOT"], Keywords: map[string]KeywordStatus{}}
+		for _, arch := range m.Arches {
+			if status := ArchKeywordStatus(v.Ebuild.Vars["KEYWORDS"], arch); status != KeywordNone {
+				row.Keywords[arch] = status
+			}
+		}
+		m.Rows = append(m.Rows, row)
+	}
+	sort.SliceStable(m.Rows, func(i, j int) bool {
+		return CompareVersions(m.Rows[i].Version, m.Rows[j].Version) > 0
+	})
+	return m
+}
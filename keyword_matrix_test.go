@@ -0,0 +1,56 @@
+package g2
+
+import (
+	"testing"
+)
+
+func testVersion(version, keywords string) VersionData {
+	return VersionData{Version: stripRevision(version), Ebuild: &Ebuild{Vars: map[string]string{"PVR": version, "KEYWORDS": keywords, "SLOT": "0"}}}
+}
+
+func TestArchKeywordStatus(t *testing.T) {
+	tests := []struct {
+		keywords, arch string
+		want           KeywordStatus
+	}{
+		{"amd64 ~x86", "amd64", KeywordStable},
+		{"amd64 ~x86", "x86", KeywordTesting},
+		{"amd64 ~x86", "arm", KeywordNone},
+		{"-* ~amd64", "x86", KeywordDisabled},
+		{"-* ~amd64", "amd64", KeywordTesting},
+		{"-arm ~amd64", "arm", KeywordDisabled},
+	}
+	for _, tt := range tests {
+		if got := ArchKeywordStatus(tt.keywords, tt.arch); got != tt.want {
+			t.Errorf("ArchKeywordStatus(%q, %q) = %q, want %q", tt.keywords, tt.arch, got, tt.want)
+		}
+	}
+}
+
+func TestNewKeywordMatrix(t *testing.T) {
+	versions := []VersionData{
+		testVersion("1.9", "amd64 ~x86"),
+		testVersion("1.10", "~amd64 ~riscv"),
+	}
+	archList := &ArchList{Arches: []string{"amd64", "arm", "x86", "amd64-linux"}}
+	archesDesc := &ArchesDesc{Arches: map[string]string{"amd64": "stable", "arm": "stable", "x86": "transitional"}}
+
+	m := NewKeywordMatrix(versions, archList, archesDesc)
+	if got, want := m.Arches, []string{"amd64", "arm", "x86", "riscv"}; len(got) != len(want) || got[0] != want[0] || got[3] != want[3] {
+		t.Errorf("arches = %v, want %v", got, want)
+	}
+	if m.ArchStatus["x86"] != "transitional" || m.ArchStatus["riscv"] != "" {
+		t.Errorf("arch status = %v", m.ArchStatus)
+	}
+	if len(m.Rows) != 2 || m.Rows[0].Version != "1.10" {
+		t.Fatalf("rows = %+v", m.Rows)
+	}
+	if m.Rows[0].Status("riscv") != KeywordTesting || m.Rows[1].Status("amd64") != KeywordStable || m.Rows[1].Status("arm").Symbol() != " " {
+		t.Errorf("rows = %+v", m.Rows)
+	}
+
+	// Without arch.list the columns are the keyworded arches.
+	if m := NewKeywordMatrix(versions, nil, nil); len(m.Arches) != 3 || m.Arches[0] != "amd64" {
+		t.Errorf("arches without arch.list = %v", m.Arches)
+	}
+}
@@ -0,0 +1,41 @@
+package g2
+
+import "strings"
+
+// KeywordsAccepted reports whether an ebuild carrying keywords is visible under the accept list, using
+// ACCEPT_KEYWORDS semantics: "arch" accepts stable, "~arch" accepts testing, "*" and "~*" accept any
+// stable or testing keyword, "**" accepts everything, and a "-kw" token withdraws an earlier one.
+func KeywordsAccepted(keywords []string, accept []string) bool {
+	accepted := make(map[string]bool)
+	for _, tok := range accept {
+		if strings.HasPrefix(tok, "-") {
+			if tok == "-*" {
+				accepted = make(map[string]bool)
+			} else {
+				delete(accepted, tok[1:])
+			}
+			continue
+		}
+		accepted[tok] = true
+	}
+	if accepted["**"] {
+		return true
+	}
+
+	for _, kw := range keywords {
+		if kw == "" || strings.HasPrefix(kw, "-") {
+			continue
+		}
+		if accepted[kw] {
+			return true
+		}
+		if strings.HasPrefix(kw, "~") {
+			if accepted["~*"] {
+				return true
+			}
+		} else if accepted["*"] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,27 @@
+package g2
+
+import "testing"
+
+func TestKeywordsAccepted(t *testing.T) {
+	tests := []struct {
+		keywords []string
+		accept   []string
+		want     bool
+	}{
+		{[]string{"amd64", "~x86"}, []string{"amd64"}, true},
+		{[]string{"~amd64"}, []string{"amd64"}, false},
+		{[]string{"~amd64"}, []string{"amd64", "~amd64"}, true},
+		{[]string{"~arm64"}, []string{"~*"}, true},
+		{[]string{"arm64"}, []string{"~*"}, false},
+		{[]string{"arm64"}, []string{"*"}, true},
+		{nil, []string{"**"}, true},
+		{[]string{"-*"}, []string{"amd64"}, false},
+		{[]string{"~amd64"}, []string{"~amd64", "-~amd64"}, false},
+		{[]string{"amd64"}, []string{"x86", "-*", "amd64"}, true},
+	}
+	for _, tt := range tests {
+		if got := KeywordsAccepted(tt.keywords, tt.accept); got != tt.want {
+			t.Errorf("KeywordsAccepted(%v, %v) = %v, want %v", tt.keywords, tt.accept, got, tt.want)
+		}
+	}
+}
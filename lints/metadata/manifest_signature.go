@@ -0,0 +1,110 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/arran4/g2"
+	"github.com/arran4/g2/lints"
+)
+
+// ManifestKeyring holds the keys that Manifest signatures are checked against. Without it only the
+// presence and form of the signatures are checked.
+var ManifestKeyring openpgp.KeyRing
+
+var ruleManifestSignature = lints.RuleMetadata{
+	ID:          "ManifestSignature",
+	Title:       "Manifest Signature",
+	Description: "Ensures Manifests are signed with a good OpenPGP signature when layout.conf sets sign-manifests = true, and that a signed top-level Manifest has a good signature.",
+	References: []lints.RuleReference{
+		{URL: "https://wiki.gentoo.org/wiki/Repository_format/metadata/layout.conf", Label: "layout.conf"},
+		{URL: "https://www.gentoo.org/glep/glep-0074.html", Label: "GLEP 74"},
+	},
+	Severity: lints.SeverityError,
+	Source:   lints.SourceG2,
+	Tags:     []string{"manifest", "openpgp"},
+}
+
+func init() {
+	lints.RegisterRuleMetadata(ruleManifestSignature)
+	lints.RegisterLintRule(&ManifestSignatureLintRule{})
+	lints.RegisterRepoLintRule(&ManifestSignatureLintRule{})
+}
+
+type ManifestSignatureLintRule struct{}
+
+func (r *ManifestSignatureLintRule) Lint(repoDir string, pkg *g2.PackageData) []lints.LintResult {
+	return r.LintWithQA(repoDir, pkg, nil)
+}
+
+func (r *ManifestSignatureLintRule) LintWithQA(repoDir string, pkg *g2.PackageData, qa *g2.QAPolicy) []lints.LintResult {
+	lc, err := g2.ParseLayoutConf(filepath.Join(repoDir, "metadata", "layout.conf"))
+	if err != nil || !lc.SignManifests() {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(repoDir, pkg.Category, pkg.Name, "Manifest"))
+	if err != nil {
+		// A missing Manifest is reported by ManifestChecks.
+		return nil
+	}
+
+	message := manifestSignatureProblem(data, true)
+	if message == "" {
+		return nil
+	}
+	return []lints.LintResult{{
+		RuleMetadata: ruleManifestSignature,
+		Message:      fmt.Sprintf("[%s] %s", ruleManifestSignature.Severity, message),
+		Package:      pkg.Category + "/" + pkg.Name,
+		File:         "Manifest",
+	}}
+}
+
+// LintRepo checks the signature of the GLEP 74 top-level Manifest. It must be signed when layout.conf
+// sets sign-manifests = true; otherwise only a signature it carries is checked.
+func (r *ManifestSignatureLintRule) LintRepo(repoDir string, site *g2.SiteData) []lints.LintResult {
+	data, err := os.ReadFile(filepath.Join(repoDir, "Manifest"))
+	if err != nil {
+		// Repositories without a Manifest tree have no top-level Manifest.
+		return nil
+	}
+	lc, err := g2.ParseLayoutConf(filepath.Join(repoDir, "metadata", "layout.conf"))
+	required := err == nil && lc.SignManifests()
+	message := manifestSignatureProblem(data, required)
+	if message == "" {
+		return nil
+	}
+	return []lints.LintResult{{
+		RuleMetadata: ruleManifestSignature,
+		Message:      fmt.Sprintf("[%s] top-level %s", ruleManifestSignature.Severity, message),
+		File:         "Manifest",
+	}}
+}
+
+// manifestSignatureProblem describes what is wrong with the signature of the Manifest data, or returns ""
+// when there is nothing to report. An unsigned Manifest is only reported when required is set.
+func manifestSignatureProblem(data []byte, required bool) string {
+	keyring := ManifestKeyring
+	if keyring == nil {
+		keyring = openpgp.EntityList{}
+	}
+	_, err := g2.VerifyManifestSignature(data, keyring)
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, g2.ErrManifestUnsigned):
+		if !required {
+			return ""
+		}
+		return "Manifest is not signed but layout.conf sets sign-manifests = true"
+	case ManifestKeyring == nil && errors.Is(err, pgperrors.ErrUnknownIssuer):
+		// Without a keyring a well-formed signature cannot be checked any further.
+		return ""
+	default:
+		return fmt.Sprintf("Manifest has a %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/arran4/g2"
+)
+
+func TestManifestSignatureLintRule(t *testing.T) {
+	signer, err := openpgp.NewEntity("Overlay Maintainer", "", "maintainer@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := openpgp.NewEntity("Other", "", "other@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "DIST foo-1.0.tar.gz 10 BLAKE2B aa SHA512 bb\n"
+	signed, err := g2.SignManifest([]byte(content), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(signed), " 10 ", " 11 ", 1)
+	malformed := strings.Replace(string(signed), "-----BEGIN PGP SIGNATURE-----\n", "-----BEGIN PGP SIGNATURE-----\n\nAAAA\n", 1)
+	defer func() {
+		ManifestKeyring = nil
+	}()
+
+	tests := []struct {
+		name      string
+		layout    string
+		manifest  string
+		keyring   openpgp.KeyRing
+		wantIssue string
+	}{
+		{"not required", "masters = gentoo\n", content, nil, ""},
+		{"unsigned", "sign-manifests = true\n", content, nil, "is not signed"},
+		{"signed without keyring", "sign-manifests = true\n", string(signed), nil, ""},
+		{"signed by a known key", "sign-manifests = true\n", string(signed), openpgp.EntityList{signer}, ""},
+		{"signed by an unknown key", "sign-manifests = true\n", string(signed), openpgp.EntityList{other}, "bad signature"},
+		{"tampered", "sign-manifests = true\n", tampered, openpgp.EntityList{signer}, "bad signature"},
+		{"malformed", "sign-manifests = true\n", malformed, nil, "bad signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := t.TempDir()
+			for name, data := range map[string]string{
+				"metadata/layout.conf":  tt.layout,
+				"app-misc/foo/Manifest": tt.manifest,
+			} {
+				p := filepath.Join(repo, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ManifestKeyring = tt.keyring
+			results := (&ManifestSignatureLintRule{}).Lint(repo, &g2.PackageData{Category: "app-misc", Name: "foo"})
+			if tt.wantIssue == "" {
+				if len(results) != 0 {
+					t.Errorf("unexpected results: %+v", results)
+				}
+				return
+			}
+			if len(results) != 1 || !strings.Contains(results[0].Message, tt.wantIssue) {
+				t.Errorf("results = %+v, want %q", results, tt.wantIssue)
+			}
+		})
+	}
+}
+
+func TestManifestSignatureLintRepo(t *testing.T) {
+	signer, err := openpgp.NewEntity("Repository", "", "infra@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "TIMESTAMP 2026-01-01T00:00:00Z\nMANIFEST app-misc/Manifest.gz 10 BLAKE2B aa SHA512 bb\n"
+	signed, err := g2.SignManifest([]byte(content), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(signed), " 10 ", " 11 ", 1)
+	defer func() {
+		ManifestKeyring = nil
+	}()
+
+	tests := []struct {
+		name      string
+		layout    string
+		manifest  string
+		wantIssue string
+	}{
+		{"no Manifest tree", "sign-manifests = true\n", "", ""},
+		{"unsigned, not required", "masters = gentoo\n", content, ""},
+		{"unsigned, required", "sign-manifests = true\n", content, "top-level Manifest is not signed"},
+		{"signed", "masters = gentoo\n", string(signed), ""},
+		{"tampered", "masters = gentoo\n", tampered, "top-level Manifest has a bad signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := t.TempDir()
+			files := map[string]string{"metadata/layout.conf": tt.layout}
+			if tt.manifest != "" {
+				files["Manifest"] = tt.manifest
+			}
+			for name, data := range files {
+				p := filepath.Join(repo, filepath.FromSlash(name))
+				if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ManifestKeyring = openpgp.EntityList{signer}
+			results := (&ManifestSignatureLintRule{}).LintRepo(repo, &g2.SiteData{})
+			if tt.wantIssue == "" {
+				if len(results) != 0 {
+					t.Errorf("unexpected results: %+v", results)
+				}
+				return
+			}
+			if len(results) != 1 || !strings.Contains(results[0].Message, tt.wantIssue) {
+				t.Errorf("results = %+v, want %q", results, tt.wantIssue)
+			}
+		})
+	}
+}
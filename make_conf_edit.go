@@ -0,0 +1,482 @@
+package g2
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MakeConfIncrementalVars are the make.conf variables whose values are stacked on top of the profile's
+// rather than replacing them. USE_EXPAND variables such as VIDEO_CARDS are incremental as well.
+var MakeConfIncrementalVars = []string{
+	"USE", "FEATURES", "ACCEPT_KEYWORDS", "ACCEPT_LICENSE", "ACCEPT_PROPERTIES", "ACCEPT_RESTRICT",
+	"CONFIG_PROTECT", "CONFIG_PROTECT_MASK", "IUSE_IMPLICIT", "USE_EXPAND", "USE_EXPAND_HIDDEN",
+	"USE_EXPAND_IMPLICIT", "USE_EXPAND_UNPREFIXED", "ENV_UNSET", "PROFILE_ONLY_VARIABLES",
+	"ALSA_CARDS", "APACHE2_MODULES", "CALLIGRA_FEATURES", "CAMERAS", "COLLECTD_PLUGINS", "CPU_FLAGS_ARM",
+	"CPU_FLAGS_PPC", "CPU_FLAGS_X86", "CURL_SSL", "GPSD_PROTOCOLS", "GRUB_PLATFORMS", "INPUT_DEVICES",
+	"L10N", "LCD_DEVICES", "LLVM_TARGETS", "LUA_TARGETS", "NGINX_MODULES_HTTP", "OFFICE_IMPLEMENTATION",
+	"PHP_TARGETS", "POSTGRES_TARGETS", "PYTHON_TARGETS", "QEMU_SOFTMMU_TARGETS", "QEMU_USER_TARGETS",
+	"RUBY_TARGETS", "SANE_BACKENDS", "VIDEO_CARDS", "XTABLES_ADDONS",
+}
+
+// IsIncrementalMakeConfVar reports whether key is an incremental make.conf variable.
+func IsIncrementalMakeConfVar(key string) bool {
+	for _, v := range MakeConfIncrementalVars {
+		if v == key {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	makeConfAssignRegex = regexp.MustCompile(`^(?:export[ \t]+)?([A-Za-z_][A-Za-z0-9_]*)=`)
+	makeConfSourceRegex = regexp.MustCompile(`^(?:source|\.)[ \t]+("[^"]*"|'[^']*'|[^ \t#\n]+)`)
+)
+
+// MakeConfAssignment is a variable assignment in a make.conf file, located by byte offsets.
+type MakeConfAssignment struct {
+	File       string
+	Key        string
+	Line       int
+	Quote      byte // '"', '\'' or 0 for an unquoted value
+	LineStart  int  // Start of the line holding the assignment
+	ValueStart int  // Start of the value, including any opening quote
+	ValueEnd   int  // End of the value, including any closing quote
+	StmtEnd    int  // End of the statement, after the trailing newline
+}
+
+// makeConfFile is a make.conf file loaded for editing.
+type makeConfFile struct {
+	Path    string
+	Content string
+	Mode    os.FileMode
+	Dirty   bool
+	Sources []string
+	Assigns []MakeConfAssignment
+	items   []makeConfItem
+}
+
+// makeConfItem is an assignment or source line in file order.
+type makeConfItem struct {
+	Assign *MakeConfAssignment
+	Source string
+}
+
+// MakeConfEditor edits make.conf, or the files of a make.conf directory, keeping comments, quoting and
+// line continuations. Files pulled in with source are edited in place as well.
+type MakeConfEditor struct {
+	Path  string
+	files map[string]*makeConfFile
+	roots []string
+}
+
+// LoadMakeConfEditor loads the make.conf file or directory at path. A missing path is treated as an
+// empty make.conf file.
+func LoadMakeConfEditor(path string) (*MakeConfEditor, error) {
+	e := &MakeConfEditor{Path: path, files: make(map[string]*makeConfFile)}
+	info, err := os.Stat(path)
+	switch {
+	case err == nil && info.IsDir():
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading directory %s: %w", path, err)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+		for _, de := range entries {
+			name := de.Name()
+			if de.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".bak") {
+				continue
+			}
+			e.roots = append(e.roots, filepath.Join(path, name))
+		}
+	case err == nil || os.IsNotExist(err):
+		e.roots = []string{path}
+	default:
+		return nil, fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	for _, root := range e.roots {
+		if err := e.load(root); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (e *MakeConfEditor) load(path string) error {
+	if _, ok := e.files[path]; ok {
+		return nil
+	}
+	f := &makeConfFile{Path: path, Mode: 0644}
+	e.files[path] = f
+	info, err := os.Stat(path)
+	if err == nil {
+		f.Mode = info.Mode().Perm()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		f.Content = string(data)
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	f.scan()
+	for _, src := range f.Sources {
+		if _, err := os.Stat(src); err != nil {
+			continue
+		}
+		if err := e.load(src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan locates the assignments and source lines of the file.
+func (f *makeConfFile) scan() {
+	f.Assigns = nil
+	f.Sources = nil
+	f.items = nil
+	c := f.Content
+	line := 1
+	pos := 0
+	for pos < len(c) {
+		lineStart := pos
+		for pos < len(c) && (c[pos] == ' ' || c[pos] == '\t') {
+			pos++
+		}
+		rest := c[pos:]
+		if m := makeConfAssignRegex.FindStringSubmatchIndex(rest); m != nil {
+			a := MakeConfAssignment{File: f.Path, Key: rest[m[2]:m[3]], Line: line, LineStart: lineStart, ValueStart: pos + m[1]}
+			end := a.ValueStart
+			if end < len(c) && (c[end] == '"' || c[end] == '\'') {
+				a.Quote = c[end]
+				end++
+				for end < len(c) && c[end] != a.Quote {
+					if a.Quote == '"' && c[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				end = min(end+1, len(c))
+			} else {
+				for end < len(c) && !strings.ContainsRune(" \t\n;#", rune(c[end])) {
+					if c[end] == '\\' {
+						end++
+					}
+					end++
+				}
+				end = min(end, len(c))
+			}
+			a.ValueEnd = end
+			stmtEnd := strings.IndexByte(c[end:], '\n')
+			if stmtEnd == -1 {
+				a.StmtEnd = len(c)
+			} else {
+				a.StmtEnd = end + stmtEnd + 1
+			}
+			line += strings.Count(c[lineStart:a.StmtEnd], "\n")
+			pos = a.StmtEnd
+			f.Assigns = append(f.Assigns, a)
+			f.items = append(f.items, makeConfItem{Assign: &f.Assigns[len(f.Assigns)-1]})
+			continue
+		}
+		if m := makeConfSourceRegex.FindStringSubmatch(rest); m != nil {
+			src := strings.Trim(m[1], `"'`)
+			if !filepath.IsAbs(src) {
+				src = filepath.Join(filepath.Dir(f.Path), src)
+			}
+			f.Sources = append(f.Sources, src)
+			f.items = append(f.items, makeConfItem{Source: src})
+		}
+		next := strings.IndexByte(c[pos:], '\n')
+		if next == -1 {
+			break
+		}
+		pos += next + 1
+		line++
+	}
+	// Item pointers must refer to the final backing array of Assigns.
+	ai := 0
+	for i := range f.items {
+		if f.items[i].Assign != nil {
+			f.items[i].Assign = &f.Assigns[ai]
+			ai++
+		}
+	}
+}
+
+// Assignments returns every assignment of key in evaluation order, following source lines.
+func (e *MakeConfEditor) Assignments(key string) []MakeConfAssignment {
+	var res []MakeConfAssignment
+	visited := make(map[string]bool)
+	var walk func(path string)
+	walk = func(path string) {
+		f := e.files[path]
+		if f == nil || visited[path] {
+			return
+		}
+		visited[path] = true
+		for _, item := range f.items {
+			if item.Assign != nil && item.Assign.Key == key {
+				res = append(res, *item.Assign)
+			} else if item.Source != "" {
+				walk(item.Source)
+			}
+		}
+	}
+	for _, root := range e.roots {
+		walk(root)
+	}
+	return res
+}
+
+// Value returns the raw, unquoted text of the last assignment of key.
+func (e *MakeConfEditor) Value(key string) (string, bool) {
+	as := e.Assignments(key)
+	if len(as) == 0 {
+		return "", false
+	}
+	a := as[len(as)-1]
+	return e.innerValue(a), true
+}
+
+func (e *MakeConfEditor) innerValue(a MakeConfAssignment) string {
+	raw := e.files[a.File].Content[a.ValueStart:a.ValueEnd]
+	if a.Quote != 0 {
+		raw = strings.TrimPrefix(raw, string(a.Quote))
+		raw = strings.TrimSuffix(raw, string(a.Quote))
+	}
+	return raw
+}
+
+func (e *MakeConfEditor) splice(file string, start, end int, text string) {
+	f := e.files[file]
+	f.Content = f.Content[:start] + text + f.Content[end:]
+	f.Dirty = true
+	f.scan()
+}
+
+// quoteMakeConfValue double quotes value, escaping the characters that are special inside double quotes
+// except $, so that references such as ${USE} keep working.
+func quoteMakeConfValue(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`")
+	return `"` + r.Replace(value) + `"`
+}
+
+// appendTarget returns the file new assignments are added to.
+func (e *MakeConfEditor) appendTarget() string {
+	if len(e.roots) == 1 && e.roots[0] == e.Path {
+		return e.Path
+	}
+	target := filepath.Join(e.Path, "g2.conf")
+	if _, ok := e.files[target]; !ok {
+		e.files[target] = &makeConfFile{Path: target, Mode: 0644}
+		e.roots = append(e.roots, target)
+	}
+	return target
+}
+
+func (e *MakeConfEditor) appendAssignment(key, quoted string) {
+	target := e.appendTarget()
+	f := e.files[target]
+	text := key + "=" + quoted + "\n"
+	if f.Content != "" && !strings.HasSuffix(f.Content, "\n") {
+		text = "\n" + text
+	}
+	e.splice(target, len(f.Content), len(f.Content), text)
+}
+
+// Set replaces the value of the last assignment of key, or appends a new assignment. It reports whether
+// anything changed.
+func (e *MakeConfEditor) Set(key, value string) bool {
+	quoted := quoteMakeConfValue(value)
+	as := e.Assignments(key)
+	if len(as) == 0 {
+		e.appendAssignment(key, quoted)
+		return true
+	}
+	a := as[len(as)-1]
+	if a.Quote == '\'' && !strings.Contains(value, "'") {
+		quoted = "'" + value + "'"
+	}
+	if e.files[a.File].Content[a.ValueStart:a.ValueEnd] == quoted {
+		return false
+	}
+	e.splice(a.File, a.ValueStart, a.ValueEnd, quoted)
+	return true
+}
+
+// Unset removes every assignment of key and returns how many were removed.
+func (e *MakeConfEditor) Unset(key string) int {
+	as := e.Assignments(key)
+	// Remove from the end so earlier offsets in the same file stay valid.
+	for i := len(as) - 1; i >= 0; i-- {
+		a := as[i]
+		cur := e.findAssignment(a)
+		e.splice(cur.File, cur.LineStart, cur.StmtEnd, "")
+	}
+	return len(as)
+}
+
+// findAssignment returns the current location of an assignment located before an earlier edit.
+func (e *MakeConfEditor) findAssignment(a MakeConfAssignment) MakeConfAssignment {
+	for _, cur := range e.files[a.File].Assigns {
+		if cur.Line == a.Line && cur.Key == a.Key {
+			return cur
+		}
+	}
+	return a
+}
+
+// UpdateTokens adds and removes whitespace separated tokens in the last assignment of key, keeping the
+// layout of multi-line values. Adding a token drops its negated form. When key is not assigned and tokens
+// are added, a new assignment is appended. It reports whether anything changed.
+func (e *MakeConfEditor) UpdateTokens(key string, add, remove []string) bool {
+	as := e.Assignments(key)
+	if len(as) == 0 {
+		tokens := MergeConfigTokens(nil, add, remove)
+		if len(tokens) == 0 {
+			return false
+		}
+		e.appendAssignment(key, quoteMakeConfValue(strings.Join(tokens, " ")))
+		return true
+	}
+	a := as[len(as)-1]
+	inner := e.innerValue(a)
+	updated := editValueTokens(inner, add, remove)
+	if updated == inner {
+		return false
+	}
+	var quoted string
+	switch a.Quote {
+	case 0:
+		quoted = quoteMakeConfValue(updated)
+	default:
+		quoted = string(a.Quote) + updated + string(a.Quote)
+	}
+	e.splice(a.File, a.ValueStart, a.ValueEnd, quoted)
+	return true
+}
+
+// editValueTokens applies MergeConfigTokens to the tokens of a raw value, keeping the surrounding
+// whitespace and line breaks. Removed tokens take an adjacent space with them; added tokens are placed
+// after the last token.
+func editValueTokens(raw string, add, remove []string) string {
+	type segment struct {
+		text  string
+		space bool
+	}
+	var segs []segment
+	for i := 0; i < len(raw); {
+		j := i
+		space := raw[i] == ' ' || raw[i] == '\t' || raw[i] == '\n' || (raw[i] == '\\' && i+1 < len(raw) && raw[i+1] == '\n')
+		for j < len(raw) {
+			isSpace := raw[j] == ' ' || raw[j] == '\t' || raw[j] == '\n' || (raw[j] == '\\' && j+1 < len(raw) && raw[j+1] == '\n')
+			if isSpace != space {
+				break
+			}
+			if raw[j] == '\\' && space {
+				j++
+			}
+			j++
+		}
+		segs = append(segs, segment{text: raw[i:j], space: space})
+		i = j
+	}
+
+	var tokens []string
+	for _, s := range segs {
+		if !s.space {
+			tokens = append(tokens, s.text)
+		}
+	}
+	merged := MergeConfigTokens(tokens, add, remove)
+	keep := make(map[string]bool)
+	for _, t := range merged {
+		keep[t] = true
+	}
+
+	// Drop tokens that are no longer wanted, together with one neighbouring whitespace run. A token
+	// replaced by its negated form is substituted in place.
+	seen := make(map[string]bool)
+	var out []segment
+	for i := 0; i < len(segs); i++ {
+		s := segs[i]
+		if !s.space && !(keep[s.text] && !seen[s.text]) {
+			if neg := negatedToken(s.text); keep[neg] && !seen[neg] && !containsToken(tokens, neg) {
+				s.text = neg
+			}
+		}
+		if s.space || (keep[s.text] && !seen[s.text]) {
+			if !s.space {
+				seen[s.text] = true
+			}
+			out = append(out, s)
+			continue
+		}
+		hasNext := i+1 < len(segs) && segs[i+1].space
+		if n := len(out); n > 0 && out[n-1].space && (!strings.Contains(out[n-1].text, "\n") || !hasNext) {
+			out = out[:n-1]
+		} else if hasNext {
+			i++
+		}
+	}
+
+	var sb strings.Builder
+	lastToken := -1
+	for i, s := range out {
+		if !s.space {
+			lastToken = i
+		}
+	}
+	var added []string
+	for _, t := range merged {
+		if !seen[t] {
+			added = append(added, t)
+		}
+	}
+	for i, s := range out {
+		sb.WriteString(s.text)
+		if i == lastToken && len(added) > 0 {
+			sb.WriteString(" " + strings.Join(added, " "))
+		}
+	}
+	if lastToken == -1 && len(added) > 0 {
+		// No tokens remain to attach to, so keep any surrounding layout and put the new tokens first.
+		return strings.Join(added, " ") + sb.String()
+	}
+	return sb.String()
+}
+
+func containsToken(tokens []string, token string) bool {
+	for _, t := range tokens {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the modified files and returns their paths.
+func (e *MakeConfEditor) Save() ([]string, error) {
+	var paths []string
+	for p := range e.files {
+		if e.files[p].Dirty {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		f := e.files[p]
+		if err := SafeWriteFileAtomic(p, []byte(f.Content), f.Mode); err != nil {
+			return nil, err
+		}
+		f.Dirty = false
+	}
+	return paths, nil
+}
@@ -0,0 +1,115 @@
+package g2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMakeConfEditor(t *testing.T) {
+	dir := t.TempDir()
+	makeConf := filepath.Join(dir, "make.conf")
+	content := "# Build settings\n" +
+		"COMMON_FLAGS=\"-O2 -pipe\" # tuned\n" +
+		"USE=\"X \\\n" +
+		"    -gnome\n" +
+		"    pulseaudio\"\n" +
+		"FEATURES='parallel-fetch'\n" +
+		"MAKEOPTS=-j4\n" +
+		"source local.conf\n"
+	local := "# Local overrides\nVIDEO_CARDS=\"amdgpu radeonsi\"\n"
+	if err := os.WriteFile(makeConf, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "local.conf"), []byte(local), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := LoadMakeConfEditor(makeConf)
+	if err != nil {
+		t.Fatalf("LoadMakeConfEditor failed: %v", err)
+	}
+	if v, _ := e.Value("VIDEO_CARDS"); v != "amdgpu radeonsi" {
+		t.Errorf("unexpected sourced value: %q", v)
+	}
+
+	e.Set("COMMON_FLAGS", "-O2 -march=native -pipe")
+	e.UpdateTokens("USE", []string{"gnome", "wayland"}, []string{"pulseaudio"})
+	e.UpdateTokens("FEATURES", []string{"ccache"}, nil)
+	e.UpdateTokens("MAKEOPTS", []string{"-l4"}, nil)
+	e.UpdateTokens("VIDEO_CARDS", nil, []string{"radeonsi"})
+	e.Set("ACCEPT_LICENSE", "*")
+	if n := e.Unset("NOPE"); n != 0 {
+		t.Errorf("expected nothing to unset, got %d", n)
+	}
+
+	files, err := e.Save()
+	if err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("expected 2 modified files, got %v", files)
+	}
+
+	data, _ := os.ReadFile(makeConf)
+	want := "# Build settings\n" +
+		"COMMON_FLAGS=\"-O2 -march=native -pipe\" # tuned\n" +
+		"USE=\"X \\\n" +
+		"    gnome wayland\"\n" +
+		"FEATURES='parallel-fetch ccache'\n" +
+		"MAKEOPTS=\"-j4 -l4\"\n" +
+		"source local.conf\n" +
+		"ACCEPT_LICENSE=\"*\"\n"
+	if string(data) != want {
+		t.Errorf("unexpected make.conf:\n%s\nwant:\n%s", data, want)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, "local.conf"))
+	if string(data) != "# Local overrides\nVIDEO_CARDS=\"amdgpu\"\n" {
+		t.Errorf("unexpected local.conf:\n%s", data)
+	}
+
+	vars, err := ParseMakeConf(makeConf)
+	if err != nil {
+		t.Fatalf("edited make.conf no longer parses: %v", err)
+	}
+	if vars["USE"] != "X     gnome wayland" && vars["USE"] != "X gnome wayland" {
+		t.Errorf("unexpected evaluated USE: %q", vars["USE"])
+	}
+}
+
+func TestMakeConfEditorDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "make.conf")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "10-base"), []byte("USE=\"a\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "20-host"), []byte("USE=\"b\"\nFEATURES=\"x\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := LoadMakeConfEditor(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.UpdateTokens("USE", []string{"c"}, nil)
+	if n := e.Unset("FEATURES"); n != 1 {
+		t.Errorf("expected 1 assignment removed, got %d", n)
+	}
+	e.Set("MAKEOPTS", "-j8")
+	if _, err := e.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"10-base": "USE=\"a\"\n",
+		"20-host": "USE=\"b c\"\n",
+		"g2.conf": "MAKEOPTS=\"-j8\"\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(data) != want {
+			t.Errorf("unexpected %s: %q, %v", name, data, err)
+		}
+	}
+}
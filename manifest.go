@@ -46,7 +46,14 @@ func (e *ManifestEntry) String() string {
 	var sb strings.Builder
 	sb.WriteString(e.Type)
 	sb.WriteString(" ")
-	sb.WriteString(e.Filename)
+	if e.Type == ManifestTimestamp {
+		sb.WriteString(e.Filename)
+		return sb.String()
+	}
+	sb.WriteString(EscapeManifestPath(e.Filename))
+	if e.Type == ManifestIgnore {
+		return sb.String()
+	}
 	sb.WriteString(" ")
 	sb.WriteString(strconv.FormatInt(e.Size, 10))
 
@@ -99,7 +106,13 @@ func ParseManifest(path string) (*Manifest, error) {
 	return ParseManifestContent(string(content))
 }
 
+// ParseManifestContent parses the entries of a Manifest. The signature of a clearsigned Manifest is
+// skipped; use VerifyManifestSignature to check it.
 func ParseManifestContent(content string) (*Manifest, error) {
+	if strings.HasPrefix(content, "-----BEGIN PGP SIGNED MESSAGE-----") {
+		plaintext, _ := ManifestPlaintext([]byte(content))
+		content = string(plaintext)
+	}
 	m := &Manifest{}
 	lines := strings.Split(content, "\n")
 	for _, line := range lines {
@@ -120,13 +133,28 @@ func ParseManifestContent(content string) (*Manifest, error) {
 
 func ParseManifestEntry(line string) (*ManifestEntry, error) {
 	parts := strings.Fields(line)
+	// IGNORE and TIMESTAMP entries of GLEP 74 have a path or timestamp but no size or hashes.
+	if len(parts) == 2 && parts[0] == ManifestTimestamp {
+		return &ManifestEntry{Type: parts[0], Filename: parts[1]}, nil
+	}
+	if len(parts) == 2 && parts[0] == ManifestIgnore {
+		name, err := UnescapeManifestPath(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return &ManifestEntry{Type: parts[0], Filename: name}, nil
+	}
 	if len(parts) < 3 {
 		return nil, fmt.Errorf("invalid manifest entry: not enough fields")
 	}
 
+	name, err := UnescapeManifestPath(parts[1])
+	if err != nil {
+		return nil, err
+	}
 	entry := &ManifestEntry{
 		Type:     parts[0],
-		Filename: parts[1],
+		Filename: name,
 	}
 
 	size, err := strconv.ParseInt(parts[2], 10, 64)
@@ -0,0 +1,103 @@
+package g2
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// ErrManifestUnsigned is returned by VerifyManifestSignature for a Manifest without a clearsignature.
+var ErrManifestUnsigned = errors.New("manifest is not signed")
+
+// ManifestPlaintext returns the Manifest inside a clearsignature and true, or data and false when it is
+// not clearsigned.
+func ManifestPlaintext(data []byte) ([]byte, bool) {
+	if block, _ := clearsign.Decode(data); block != nil {
+		return block.Plaintext, true
+	}
+	return data, false
+}
+
+// ManifestSigningKey returns the private key of signer that SignManifest signs with: the newest valid
+// signing subkey, or the primary key when it may sign and there is no such subkey, as gpg picks it.
+func ManifestSigningKey(signer *openpgp.Entity) (*packet.PrivateKey, error) {
+	key, ok := signer.SigningKey(time.Now())
+	if !ok {
+		return nil, fmt.Errorf("key %X has no valid signing key", signer.PrimaryKey.Fingerprint)
+	}
+	if key.PrivateKey == nil || key.PrivateKey.Dummy() {
+		return nil, fmt.Errorf("key %X has no private key for signing key %X", signer.PrimaryKey.Fingerprint, key.PublicKey.Fingerprint)
+	}
+	if key.PrivateKey.Encrypted {
+		return nil, fmt.Errorf("private key %X is encrypted", key.PublicKey.Fingerprint)
+	}
+	return key.PrivateKey, nil
+}
+
+// SignManifest clearsigns a Manifest with the signing key of signer chosen by ManifestSigningKey,
+// replacing any existing signature.
+func SignManifest(data []byte, signer *openpgp.Entity) ([]byte, error) {
+	key, err := ManifestSigningKey(signer)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, _ := ManifestPlaintext(data)
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, key, &packet.Config{DefaultHash: crypto.SHA512})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// ManifestSignature describes the key that made a good Manifest signature.
+type ManifestSignature struct {
+	KeyID       string
+	Fingerprint string
+	Identity    string
+	Created     time.Time
+}
+
+func (s *ManifestSignature) String() string {
+	if s.Identity == "" {
+		return fmt.Sprintf("key %s", s.Fingerprint)
+	}
+	return fmt.Sprintf("%s (key %s)", s.Identity, s.Fingerprint)
+}
+
+// VerifyManifestSignature checks the clearsignature of a Manifest against keyring. It returns
+// ErrManifestUnsigned when there is no signature.
+func VerifyManifestSignature(data []byte, keyring openpgp.KeyRing) (*ManifestSignature, error) {
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		return nil, ErrManifestUnsigned
+	}
+	sig, signer, err := openpgp.VerifyDetachedSignature(keyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body, nil)
+	if err != nil {
+		return nil, fmt.Errorf("bad signature: %w", err)
+	}
+	res := &ManifestSignature{
+		Fingerprint: fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint),
+		Created:     sig.CreationTime,
+	}
+	if sig.IssuerKeyId != nil {
+		res.KeyID = fmt.Sprintf("%016X", *sig.IssuerKeyId)
+	}
+	if id := signer.PrimaryIdentity(); id != nil {
+		res.Identity = id.Name
+	}
+	return res, nil
+}
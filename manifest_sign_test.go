@@ -0,0 +1,105 @@
+package g2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+)
+
+func TestSignManifest(t *testing.T) {
+	signer, err := openpgp.NewEntity("Overlay Maintainer", "", "maintainer@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := openpgp.NewEntity("Other", "", "other@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "DIST foo-1.0.tar.gz 10 BLAKE2B aa SHA512 bb\nEBUILD foo-1.0.ebuild 20 BLAKE2B cc SHA512 dd\n"
+
+	signed, err := SignManifest([]byte(content), signer)
+	if err != nil {
+		t.Fatalf("SignManifest: %v", err)
+	}
+	if !strings.HasPrefix(string(signed), "-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA512\n") {
+		t.Errorf("unexpected signed Manifest:\n%s", signed)
+	}
+	plaintext, ok := ManifestPlaintext(signed)
+	if !ok || string(plaintext) != content {
+		t.Errorf("ManifestPlaintext = %q, %v", plaintext, ok)
+	}
+	m, err := ParseManifestContent(string(signed))
+	if err != nil || len(m.Entries) != 2 {
+		t.Fatalf("ParseManifestContent of a signed Manifest = %v, %v", m, err)
+	}
+
+	sig, err := VerifyManifestSignature(signed, openpgp.EntityList{other, signer})
+	if err != nil {
+		t.Fatalf("VerifyManifestSignature: %v", err)
+	}
+	if sig.Identity != "Overlay Maintainer <maintainer@example.org>" || sig.Fingerprint != fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint) {
+		t.Errorf("signature = %+v", sig)
+	}
+
+	// Re-signing replaces the signature instead of signing it.
+	resigned, err := SignManifest(signed, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, _ := ManifestPlaintext(resigned); string(plaintext) != content {
+		t.Errorf("re-signed plaintext = %q", plaintext)
+	}
+	if _, err := VerifyManifestSignature(resigned, openpgp.EntityList{signer}); err == nil {
+		t.Error("expected a signature by an unknown key to fail")
+	}
+
+	tampered := strings.Replace(string(signed), "DIST foo-1.0.tar.gz 10", "DIST foo-1.0.tar.gz 11", 1)
+	if _, err := VerifyManifestSignature([]byte(tampered), openpgp.EntityList{signer}); err == nil {
+		t.Error("expected a modified Manifest to fail")
+	}
+	if _, err := VerifyManifestSignature([]byte(content), openpgp.EntityList{signer}); !errors.Is(err, ErrManifestUnsigned) {
+		t.Errorf("unsigned Manifest: %v", err)
+	}
+}
+
+func TestSignManifestSubkey(t *testing.T) {
+	signer, err := openpgp.NewEntity("Overlay Maintainer", "", "maintainer@example.org", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.AddSigningSubkey(nil); err != nil {
+		t.Fatal(err)
+	}
+	// A certify-only primary key, as in keys kept offline, can only sign through the subkey.
+	for _, id := range signer.Identities {
+		id.SelfSignature.FlagSign = false
+	}
+	subkey := &signer.Subkeys[len(signer.Subkeys)-1]
+
+	key, err := ManifestSigningKey(signer)
+	if err != nil {
+		t.Fatalf("ManifestSigningKey: %v", err)
+	}
+	if key.KeyId != subkey.PublicKey.KeyId {
+		t.Errorf("signing key = %X, want subkey %X", key.KeyId, subkey.PublicKey.KeyId)
+	}
+	signed, err := SignManifest([]byte("DIST foo-1.0.tar.gz 10 BLAKE2B aa SHA512 bb\n"), signer)
+	if err != nil {
+		t.Fatalf("SignManifest: %v", err)
+	}
+	sig, err := VerifyManifestSignature(signed, openpgp.EntityList{signer})
+	if err != nil {
+		t.Fatalf("VerifyManifestSignature: %v", err)
+	}
+	if sig.KeyID != fmt.Sprintf("%016X", subkey.PublicKey.KeyId) {
+		t.Errorf("signature issuer = %s, want subkey %016X", sig.KeyID, subkey.PublicKey.KeyId)
+	}
+
+	subkey.PrivateKey = nil
+	if _, err := SignManifest([]byte("DIST foo-1.0.tar.gz 10 BLAKE2B aa SHA512 bb\n"), signer); err == nil {
+		t.Error("expected signing without the private subkey to fail")
+	}
+}
@@ -0,0 +1,537 @@
+package g2
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Manifest entry types of the GLEP 74 Manifest tree.
+const (
+	ManifestManifest  = "MANIFEST"
+	ManifestData      = "DATA"
+	ManifestMisc      = "MISC"
+	ManifestIgnore    = "IGNORE"
+	ManifestTimestamp = "TIMESTAMP"
+	ManifestDist      = "DIST"
+)
+
+// ManifestTreeIgnored are the top-level directories that the repository Manifest ignores, as Portage keeps
+// local data in them.
+var ManifestTreeIgnored = []string{"distfiles", "local", "lost+found", "packages"}
+
+// DefaultManifestTreeHashes are used when layout.conf sets no manifest-hashes.
+var DefaultManifestTreeHashes = []string{HashBlake2b, HashSha512}
+
+// EscapeManifestPath escapes whitespace, backslashes and control characters in a path as GLEP 74 requires.
+func EscapeManifestPath(p string) string {
+	var sb strings.Builder
+	for _, r := range p {
+		switch {
+		case r == '\\':
+			sb.WriteString(`\x5C`)
+		case r <= ' ' || r == 0x7f:
+			fmt.Fprintf(&sb, `\x%02X`, r)
+		case r == 0x85 || r == 0xa0 || r == 0x1680 || r >= 0x2000 && r <= 0x200a || r == 0x2028 || r == 0x2029 || r == 0x202f || r == 0x205f || r == 0x3000:
+			fmt.Fprintf(&sb, `\u%04X`, r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// UnescapeManifestPath reverses EscapeManifestPath, accepting \xHH, \uHHHH and \UHHHHHHHH escapes.
+func UnescapeManifestPath(p string) (string, error) {
+	if !strings.Contains(p, `\`) {
+		return p, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] != '\\' {
+			sb.WriteByte(p[i])
+			continue
+		}
+		if i+1 >= len(p) {
+			return "", fmt.Errorf("invalid escape at end of %q", p)
+		}
+		digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[p[i+1]]
+		if digits == 0 || i+2+digits > len(p) {
+			return "", fmt.Errorf("invalid escape in %q", p)
+		}
+		v, err := strconv.ParseUint(p[i+2:i+2+digits], 16, 32)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in %q", p)
+		}
+		sb.WriteRune(rune(v))
+		i += 1 + digits
+	}
+	return sb.String(), nil
+}
+
+// isManifestFile reports whether name is a Manifest file of the tree.
+func isManifestFile(name string) bool {
+	return name == "Manifest" || name == "Manifest.gz"
+}
+
+// ReadManifestFile parses a Manifest, decompressing it when its name ends in .gz.
+func ReadManifestFile(fsys fs.FS, name string) (*Manifest, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	m, err := ParseManifestContent(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return m, nil
+}
+
+// manifestCoveredPath returns the path, relative to the Manifest's directory, of a file checked by an
+// entry, or false for entries that do not describe a file in the tree.
+func manifestCoveredPath(e *ManifestEntry) (string, bool) {
+	switch e.Type {
+	case ManifestManifest, ManifestData, ManifestMisc, "EBUILD":
+		return e.Filename, true
+	case "AUX":
+		return path.Join("files", e.Filename), true
+	}
+	return "", false
+}
+
+// ManifestTreeOptions controls GenerateManifestTree.
+type ManifestTreeOptions struct {
+	Hashes []string
+	// Compress writes the sub-Manifests as Manifest.gz. The top-level Manifest is never compressed.
+	Compress  bool
+	Timestamp time.Time
+}
+
+// GenerateManifestTree writes the GLEP 74 Manifest tree of the repository at root: a top-level Manifest
+// with a TIMESTAMP, a sub-Manifest for each top-level directory and each directory below metadata/, and
+// MANIFEST entries for the package Manifests, which are left as they are. metadata.xml and ChangeLog
+// files are recorded as MISC, everything else as DATA. It returns the Manifests written.
+func GenerateManifestTree(root string, opts ManifestTreeOptions) ([]string, error) {
+	if len(opts.Hashes) == 0 {
+		opts.Hashes = DefaultManifestTreeHashes
+	}
+	for _, h := range opts.Hashes {
+		if _, err := NewHash(h); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Timestamp.IsZero() {
+		opts.Timestamp = time.Now()
+	}
+
+	generated := map[string]bool{}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") || isManifestTreeIgnored(e.Name()) {
+			continue
+		}
+		generated[e.Name()] = true
+		if e.Name() != "metadata" {
+			continue
+		}
+		sub, err := os.ReadDir(filepath.Join(root, "metadata"))
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range sub {
+			if s.IsDir() && !strings.HasPrefix(s.Name(), ".") {
+				generated["metadata/"+s.Name()] = true
+			}
+		}
+	}
+	dirs := make([]string, 0, len(generated))
+	for dir := range generated {
+		dirs = append(dirs, dir)
+	}
+	// Deeper directories first, so that their Manifests exist when the parent lists them.
+	sort.Slice(dirs, func(i, j int) bool {
+		if di, dj := strings.Count(dirs[i], "/"), strings.Count(dirs[j], "/"); di != dj {
+			return di > dj
+		}
+		return dirs[i] < dirs[j]
+	})
+
+	manifestName := func(dir string) string {
+		if dir == "." || !opts.Compress {
+			return path.Join(dir, "Manifest")
+		}
+		return path.Join(dir, "Manifest.gz")
+	}
+
+	var written []string
+	for _, dir := range append(dirs, ".") {
+		m := &Manifest{}
+		if dir == "." {
+			m.Entries = append(m.Entries, &ManifestEntry{Type: ManifestTimestamp, Filename: opts.Timestamp.UTC().Format("2006-01-02T15:04:05Z")})
+			for _, name := range ManifestTreeIgnored {
+				m.Entries = append(m.Entries, &ManifestEntry{Type: ManifestIgnore, Filename: name})
+			}
+		}
+		if err := manifestTreeEntries(root, dir, generated, manifestName, opts.Hashes, m); err != nil {
+			return nil, err
+		}
+		name := manifestName(dir)
+		if err := writeManifestFile(filepath.Join(root, filepath.FromSlash(name)), m); err != nil {
+			return nil, err
+		}
+		// Drop a Manifest left over from a run with the other compression setting.
+		other := path.Join(dir, "Manifest.gz")
+		if strings.HasSuffix(name, ".gz") {
+			other = path.Join(dir, "Manifest")
+		}
+		if err := os.Remove(filepath.Join(root, filepath.FromSlash(other))); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		written = append(written, name)
+	}
+	return written, nil
+}
+
+// manifestTreeEntries adds the entries for the files below dir to m, stopping at the directories with
+// generated Manifests and at package Manifests, whose entries are not repeated.
+func manifestTreeEntries(root, dir string, generated map[string]bool, manifestName func(string) string, hashes []string, m *Manifest) error {
+	var files []*ManifestEntry
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		covered := make(map[string]bool)
+		if rel != dir {
+			for _, e := range entries {
+				if e.Type().IsRegular() && isManifestFile(e.Name()) {
+					sub, err := ReadManifestFile(os.DirFS(root), path.Join(rel, e.Name()))
+					if err != nil {
+						return err
+					}
+					for _, se := range sub.Entries {
+						if p, ok := manifestCoveredPath(se); ok {
+							covered[path.Join(rel, p)] = true
+						}
+					}
+				}
+			}
+		}
+		for _, e := range entries {
+			name := e.Name()
+			p := path.Join(rel, name)
+			if rel == "." {
+				p = name
+			}
+			if strings.HasPrefix(name, ".") || covered[p] {
+				continue
+			}
+			if rel == "." && dir == "." && isManifestTreeIgnored(name) {
+				continue
+			}
+			if e.IsDir() {
+				if generated[p] {
+					entry, err := manifestTreeEntry(root, manifestName(p), ManifestManifest, hashes)
+					if err != nil {
+						return err
+					}
+					files = append(files, entry)
+					continue
+				}
+				if err := walk(p); err != nil {
+					return err
+				}
+				continue
+			}
+			if !e.Type().IsRegular() {
+				continue
+			}
+			typ := ManifestData
+			switch {
+			case isManifestFile(name) && rel == dir:
+				continue
+			case isManifestFile(name):
+				typ = ManifestManifest
+			case name == "metadata.xml" || name == "ChangeLog":
+				typ = ManifestMisc
+			}
+			entry, err := manifestTreeEntry(root, p, typ, hashes)
+			if err != nil {
+				return err
+			}
+			files = append(files, entry)
+		}
+		return nil
+	}
+	if err := walk(dir); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if dir != "." {
+			f.Filename = strings.TrimPrefix(f.Filename, dir+"/")
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Filename < files[j].Filename })
+	m.Entries = append(m.Entries, files...)
+	return nil
+}
+
+func isManifestTreeIgnored(name string) bool {
+	for _, v := range ManifestTreeIgnored {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+func manifestTreeEntry(root, rel, typ string, hashes []string) (*ManifestEntry, error) {
+	f, err := os.Open(filepath.Join(root, filepath.FromSlash(rel)))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	sums, err := ChecksumReader(f, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", rel, err)
+	}
+	entry := &ManifestEntry{Type: typ, Filename: rel, Size: sums.Size}
+	for _, h := range hashes {
+		entry.Hashes = append(entry.Hashes, Hash{Type: h, Value: sums.Hashes[h]})
+	}
+	return entry, nil
+}
+
+func writeManifestFile(name string, m *Manifest) error {
+	data := []byte(m.String())
+	if strings.HasSuffix(name, ".gz") {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	return SafeWriteFileAtomic(name, data, 0644)
+}
+
+// ManifestTreeProblem is a file that does not match the Manifest tree. Problems with MISC entries are
+// not fatal.
+type ManifestTreeProblem struct {
+	Path    string
+	Message string
+	Fatal   bool
+}
+
+func (p ManifestTreeProblem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// ManifestTreeResult is the outcome of VerifyManifestTree.
+type ManifestTreeResult struct {
+	Timestamp string
+	Manifests []string
+	Files     int
+	Distfiles int
+	Problems  []ManifestTreeProblem
+}
+
+// Failed reports whether any fatal problem was found.
+func (r *ManifestTreeResult) Failed() bool {
+	for _, p := range r.Problems {
+		if p.Fatal {
+			return true
+		}
+	}
+	return false
+}
+
+type manifestTreeFile struct {
+	entry    *ManifestEntry
+	manifest string
+}
+
+// VerifyManifestTree checks the repository in fsys against its GLEP 74 Manifest tree, starting from the
+// top-level Manifest. Every file must be listed with a matching size and hashes, except dot files and
+// ignored paths, and every listed file must exist. Entries lacking one of requiredHashes are reported.
+func VerifyManifestTree(fsys fs.FS, requiredHashes []string) (*ManifestTreeResult, error) {
+	res := &ManifestTreeResult{}
+	files := make(map[string]manifestTreeFile)
+	var ignores []string
+	problemf := func(p string, fatal bool, format string, args ...any) {
+		res.Problems = append(res.Problems, ManifestTreeProblem{Path: p, Message: fmt.Sprintf(format, args...), Fatal: fatal})
+	}
+
+	var load func(name string) error
+	load = func(name string) error {
+		m, err := ReadManifestFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		res.Manifests = append(res.Manifests, name)
+		dir := path.Dir(name)
+		for _, e := range m.Entries {
+			switch e.Type {
+			case ManifestTimestamp:
+				if name == "Manifest" {
+					res.Timestamp = e.Filename
+				}
+				continue
+			case ManifestIgnore:
+				ignores = append(ignores, path.Join(dir, e.Filename))
+				continue
+			case ManifestDist:
+				res.Distfiles++
+				continue
+			}
+			rel, ok := manifestCoveredPath(e)
+			if !ok {
+				problemf(name, false, "unknown entry type %s", e.Type)
+				continue
+			}
+			p := path.Join(dir, rel)
+			if prev, dup := files[p]; dup {
+				if prev.entry.Size != e.Size || !sameHashes(prev.entry.Hashes, e.Hashes) {
+					problemf(p, true, "listed differently in %s and %s", prev.manifest, name)
+				}
+				continue
+			}
+			files[p] = manifestTreeFile{entry: e, manifest: name}
+			if e.Type == ManifestManifest {
+				if _, err := fs.Stat(fsys, p); err != nil {
+					continue // Reported as a missing file below.
+				}
+				if err := load(p); err != nil {
+					problemf(p, true, "cannot read sub-Manifest: %v", err)
+				}
+			}
+		}
+		return nil
+	}
+	if err := load("Manifest"); err != nil {
+		return nil, err
+	}
+
+	isIgnored := func(p string) bool {
+		for _, ig := range ignores {
+			if p == ig || strings.HasPrefix(p, ig+"/") {
+				return true
+			}
+		}
+		return false
+	}
+	seen := make(map[string]bool)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") || isIgnored(p) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || p == "Manifest" {
+			return nil
+		}
+		seen[p] = true
+		f, ok := files[p]
+		if !ok {
+			problemf(p, true, "not listed in any Manifest")
+			return nil
+		}
+		res.Files++
+		fatal := f.entry.Type != ManifestMisc
+		for _, h := range requiredHashes {
+			if f.entry.GetHash(h) == "" {
+				problemf(p, true, "missing required %s hash in %s", h, f.manifest)
+			}
+		}
+		var supported []string
+		for _, h := range f.entry.Hashes {
+			if _, err := NewHash(h.Type); err == nil {
+				supported = append(supported, h.Type)
+			}
+		}
+		if len(supported) == 0 {
+			problemf(p, fatal, "no supported hash in %s", f.manifest)
+			return nil
+		}
+		file, err := fsys.Open(p)
+		if err != nil {
+			return err
+		}
+		sums, err := ChecksumReader(file, supported)
+		_ = file.Close()
+		if err != nil {
+			return err
+		}
+		if sums.Size != f.entry.Size {
+			problemf(p, fatal, "size %d, %s says %d", sums.Size, f.manifest, f.entry.Size)
+			return nil
+		}
+		for _, h := range supported {
+			if !strings.EqualFold(sums.Hashes[h], f.entry.GetHash(h)) {
+				problemf(p, fatal, "%s mismatch with %s", h, f.manifest)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for p := range files {
+		if !seen[p] && !isIgnored(p) {
+			missing = append(missing, p)
+		}
+	}
+	sort.Strings(missing)
+	for _, p := range missing {
+		f := files[p]
+		problemf(p, f.entry.Type != ManifestMisc, "listed in %s but missing", f.manifest)
+	}
+	return res, nil
+}
+
+func sameHashes(a, b []Hash) bool {
+	for _, h := range a {
+		for _, o := range b {
+			if h.Type == o.Type && !strings.EqualFold(h.Value, o.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
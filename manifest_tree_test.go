@@ -0,0 +1,191 @@
+package g2
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeManifestTreeRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"header.txt":                        "Copyright\n",
+		"profiles/repo_name":                "example\n",
+		"metadata/layout.conf":              "masters = gentoo\n",
+		"metadata/md5-cache/app-misc/foo-1": "EAPI=8\n",
+		"app-misc/metadata.xml":             "<catmetadata/>\n",
+		"app-misc/foo/foo-1.ebuild":         "EAPI=8\n",
+		"app-misc/foo/metadata.xml":         "<pkgmetadata/>\n",
+		"app-misc/foo/files/my patch.diff":  "--- a\n",
+		"app-misc/foo/Manifest":             "DIST foo-1.tar.gz 10 BLAKE2B aa SHA512 bb\n",
+		".git/HEAD":                         "ref: refs/heads/master\n",
+		"distfiles/foo-1.tar.gz":            "0123456789",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestGenerateAndVerifyManifestTree(t *testing.T) {
+	root := writeManifestTreeRepo(t)
+	written, err := GenerateManifestTree(root, ManifestTreeOptions{Compress: true, Timestamp: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("GenerateManifestTree: %v", err)
+	}
+	want := []string{"metadata/md5-cache/Manifest.gz", "app-misc/Manifest.gz", "header.txt"}
+	if len(written) != 5 || written[0] != want[0] || written[len(written)-1] != "Manifest" {
+		t.Errorf("written = %v", written)
+	}
+
+	top, err := ReadManifestFile(os.DirFS(root), "Manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top.Entries[0].String() != "TIMESTAMP 2026-01-02T03:04:05Z" || manifestTreeEntryOf(top, ManifestIgnore, "distfiles") == nil {
+		t.Errorf("top-level Manifest:\n%s", top)
+	}
+	if e := manifestTreeEntryOf(top, ManifestManifest, want[1]); e == nil || e.GetHash(HashBlake2b) == "" {
+		t.Errorf("missing %s entry:\n%s", want[1], top)
+	}
+	if manifestTreeEntryOf(top, ManifestData, want[2]) == nil || manifestTreeEntryOf(top, ManifestManifest, "metadata/Manifest.gz") == nil {
+		t.Errorf("top-level Manifest:\n%s", top)
+	}
+	cat, err := ReadManifestFile(os.DirFS(root), "app-misc/Manifest.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range []struct{ typ, name string }{
+		{ManifestMisc, "metadata.xml"},
+		{ManifestManifest, "foo/Manifest"},
+		{ManifestData, "foo/foo-1.ebuild"},
+		{ManifestMisc, "foo/metadata.xml"},
+		{ManifestData, "foo/files/my patch.diff"},
+	} {
+		if manifestTreeEntryOf(cat, e.typ, e.name) == nil {
+			t.Errorf("category Manifest lacks %s %s:\n%s", e.typ, e.name, cat)
+		}
+	}
+	if !strings.Contains(cat.String(), `foo/files/my\x20patch.diff`) {
+		t.Errorf("path not escaped:\n%s", cat)
+	}
+
+	res, err := VerifyManifestTree(os.DirFS(root), []string{HashBlake2b})
+	if err != nil {
+		t.Fatalf("VerifyManifestTree: %v", err)
+	}
+	if len(res.Problems) != 0 || res.Timestamp != "2026-01-02T03:04:05Z" || res.Distfiles != 1 || len(res.Manifests) != 6 {
+		t.Fatalf("result = %+v", res)
+	}
+
+	// Regenerating uncompressed replaces the Manifest.gz files.
+	if _, err := GenerateManifestTree(root, ManifestTreeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "app-misc", "Manifest.gz")); !os.IsNotExist(err) {
+		t.Errorf("stale Manifest.gz left behind: %v", err)
+	}
+	if res, err := VerifyManifestTree(os.DirFS(root), nil); err != nil || len(res.Problems) != 0 {
+		t.Fatalf("result = %+v, %v", res, err)
+	}
+}
+
+func TestVerifyManifestTreeProblems(t *testing.T) {
+	root := writeManifestTreeRepo(t)
+	if _, err := GenerateManifestTree(root, ManifestTreeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("app-misc/foo/metadata.xml", "<pkgmetadata></pkgmetadata>\n")
+	res, err := VerifyManifestTree(os.DirFS(root), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Problems) != 1 || res.Failed() {
+		t.Errorf("a modified MISC file should only warn: %+v", res.Problems)
+	}
+
+	write("app-misc/foo/foo-1.ebuild", "EAPI=7\n")
+	write("app-misc/foo/foo-2.ebuild", "EAPI=8\n")
+	write("distfiles/bar.tar.gz", "unlisted but ignored")
+	write("extra.txt", "abc")
+	manifest, err := os.ReadFile(filepath.Join(root, "Manifest"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("Manifest", string(manifest)+"DATA extra.txt 3 WHIRLPOOL 0123\n")
+	if err := os.Remove(filepath.Join(root, "header.txt")); err != nil {
+		t.Fatal(err)
+	}
+	res, err = VerifyManifestTree(os.DirFS(root), []string{HashSha256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Failed() {
+		t.Fatal("expected the verification to fail")
+	}
+	var msgs []string
+	for _, p := range res.Problems {
+		msgs = append(msgs, p.String())
+	}
+	got := strings.Join(msgs, "\n")
+	for _, want := range []string{
+		"app-misc/foo/foo-1.ebuild: BLAKE2B mismatch with app-misc/Manifest",
+		"app-misc/foo/foo-2.ebuild: not listed in any Manifest",
+		"header.txt: listed in Manifest but missing",
+		"extra.txt: no supported hash in Manifest",
+		"profiles/repo_name: missing required SHA256 hash in profiles/Manifest",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("problems lack %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "distfiles") {
+		t.Errorf("ignored directory was checked:\n%s", got)
+	}
+}
+
+func TestManifestTreeEntries(t *testing.T) {
+	for _, line := range []string{
+		"TIMESTAMP 2026-01-02T03:04:05Z",
+		`IGNORE lost\x20found`,
+		`DATA a\x5Cb\x09c 3 SHA512 abc`,
+	} {
+		e, err := ParseManifestEntry(line)
+		if err != nil {
+			t.Fatalf("ParseManifestEntry(%q): %v", line, err)
+		}
+		if e.String() != line {
+			t.Errorf("round trip of %q gave %q", line, e.String())
+		}
+	}
+	if e, _ := ParseManifestEntry(`IGNORE lost\x20found`); e.Filename != "lost found" {
+		t.Errorf("Filename = %q", e.Filename)
+	}
+	if _, err := ParseManifestEntry(`DATA bad\q 1 SHA512 abc`); err == nil {
+		t.Error("expected an invalid escape to fail")
+	}
+	if got, _ := UnescapeManifestPath(`\u00A0\U0001F600`); got != "\u00a0\U0001F600" {
+		t.Errorf("UnescapeManifestPath = %q", got)
+	}
+}
+
+func manifestTreeEntryOf(m *Manifest, typ, name string) *ManifestEntry {
+	if e := m.GetEntry(name); e != nil && e.Type == typ {
+		return e
+	}
+	return nil
+}
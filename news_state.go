@@ -0,0 +1,243 @@
+package g2
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultNewsStateDir is where portage keeps the read state of news items.
+const DefaultNewsStateDir = "/var/lib/gentoo/news"
+
+// NewsState is the read state of the news items of a single repository, kept in
+// news-<repo>.read, news-<repo>.unread and news-<repo>.skip under Dir.
+// Items listed in Skip have already been considered and are not added to Unread again.
+type NewsState struct {
+	Dir    string
+	Repo   string
+	Read   map[string]bool
+	Unread map[string]bool
+	Skip   map[string]bool
+}
+
+// LoadNewsState reads the news state files of repo from dir. Missing files are treated as empty.
+func LoadNewsState(dir, repo string) (*NewsState, error) {
+	s := &NewsState{Dir: dir, Repo: repo}
+	var err error
+	if s.Read, err = readNewsStateFile(s.path("read")); err != nil {
+		return nil, err
+	}
+	if s.Unread, err = readNewsStateFile(s.path("unread")); err != nil {
+		return nil, err
+	}
+	if s.Skip, err = readNewsStateFile(s.path("skip")); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *NewsState) path(kind string) string {
+	return filepath.Join(s.Dir, "news-"+s.Repo+"."+kind)
+}
+
+func readNewsStateFile(p string) (map[string]bool, error) {
+	res := make(map[string]bool)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return res, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", p, err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); name != "" {
+			res[name] = true
+		}
+	}
+	return res, scanner.Err()
+}
+
+// Save writes the state files back to Dir.
+func (s *NewsState) Save() error {
+	for kind, set := range map[string]map[string]bool{"read": s.Read, "unread": s.Unread, "skip": s.Skip} {
+		names := make([]string, 0, len(set))
+		for name := range set {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var buf bytes.Buffer
+		for _, name := range names {
+			buf.WriteString(name)
+			buf.WriteByte('\n')
+		}
+		if err := SafeWriteFileAtomic(s.path(kind), buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update adds every relevant item that has not been seen before to the unread list.
+// Irrelevant items are left alone so that they are picked up if they become relevant later.
+// It returns the names of the newly added items.
+func (s *NewsState) Update(items []NewsItem, env NewsEnvironment) []string {
+	var added []string
+	for _, item := range items {
+		if s.Skip[item.DirName] || !item.IsRelevant(env) {
+			continue
+		}
+		s.Skip[item.DirName] = true
+		s.Unread[item.DirName] = true
+		added = append(added, item.DirName)
+	}
+	return added
+}
+
+// MarkRead moves an item from the unread to the read list.
+func (s *NewsState) MarkRead(name string) {
+	delete(s.Unread, name)
+	s.Read[name] = true
+	s.Skip[name] = true
+}
+
+// MarkUnread moves an item from the read to the unread list.
+func (s *NewsState) MarkUnread(name string) {
+	delete(s.Read, name)
+	s.Unread[name] = true
+	s.Skip[name] = true
+}
+
+// Purge forgets read items, which then no longer show up in listings, and drops
+// entries for items that no longer exist in the repository. It returns the number of removed entries.
+func (s *NewsState) Purge(items []NewsItem) int {
+	exists := make(map[string]bool, len(items))
+	for _, item := range items {
+		exists[item.DirName] = true
+	}
+	removed := len(s.Read)
+	s.Read = make(map[string]bool)
+	for name := range s.Unread {
+		if !exists[name] {
+			delete(s.Unread, name)
+			removed++
+		}
+	}
+	for name := range s.Skip {
+		if !exists[name] {
+			delete(s.Skip, name)
+		}
+	}
+	return removed
+}
+
+// NewsEnvironment describes the system news items are checked against for relevance.
+type NewsEnvironment struct {
+	Installed []AtomCandidate
+	Keywords  []string // ARCH or ACCEPT_KEYWORDS; a leading ~ is ignored
+	Profile   string   // Active profile relative to the profiles directory
+}
+
+// IsRelevant reports whether the item should be displayed on a system. Each Display-If
+// header kind must be satisfied by at least one of its values; absent kinds always match.
+func (n NewsItem) IsRelevant(env NewsEnvironment) bool {
+	if len(n.DisplayIfInstalled) > 0 {
+		found := false
+		for _, s := range n.DisplayIfInstalled {
+			atom := ParsePackageAtom(s)
+			for _, c := range env.Installed {
+				if atom.Matches(c) {
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(n.DisplayIfKeyword) > 0 {
+		found := false
+		for _, want := range n.DisplayIfKeyword {
+			for _, kw := range env.Keywords {
+				if strings.TrimPrefix(kw, "~") == want {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(n.DisplayIfProfile) > 0 {
+		found := false
+		for _, want := range n.DisplayIfProfile {
+			if ok, _ := path.Match(want, env.Profile); ok || want == env.Profile {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ReadRepoNewsFS reads the news items under metadata/news of a repository, oldest first.
+// The translation for lang is used when present, falling back to English.
+func ReadRepoNewsFS(fsys fs.FS, lang string) ([]NewsItem, error) {
+	entries, err := fs.ReadDir(fsys, "metadata/news")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading news directory: %w", err)
+	}
+
+	var items []NewsItem
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		dirName := entry.Name()
+		var content []byte
+		var fileName string
+		for _, l := range []string{lang, "en"} {
+			if l == "" {
+				continue
+			}
+			fileName = dirName + "." + l + ".txt"
+			content, err = fs.ReadFile(fsys, path.Join("metadata/news", dirName, fileName))
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			continue
+		}
+		item := ParseNewsItem(string(content))
+		item.DirName = dirName
+		item.FileName = fileName
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if !items[i].Posted.Equal(items[j].Posted) {
+			return items[i].Posted.Before(items[j].Posted)
+		}
+		return items[i].DirName < items[j].DirName
+	})
+	return items, nil
+}
@@ -0,0 +1,80 @@
+package g2
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewsItemIsRelevant(t *testing.T) {
+	env := NewsEnvironment{
+		Installed: []AtomCandidate{NewAtomCandidate("dev-lang/python-3.12.1", "3.12", "gentoo")},
+		Keywords:  []string{"~amd64"},
+		Profile:   "default/linux/amd64/23.0/desktop",
+	}
+	tests := []struct {
+		name string
+		item NewsItem
+		want bool
+	}{
+		{"no restrictions", NewsItem{}, true},
+		{"installed", NewsItem{DisplayIfInstalled: []string{"<dev-lang/python-3.13"}}, true},
+		{"not installed", NewsItem{DisplayIfInstalled: []string{"dev-lang/ruby"}}, false},
+		{"any installed", NewsItem{DisplayIfInstalled: []string{"dev-lang/ruby", "dev-lang/python:3.12"}}, true},
+		{"keyword", NewsItem{DisplayIfKeyword: []string{"amd64"}}, true},
+		{"other keyword", NewsItem{DisplayIfKeyword: []string{"arm64"}}, false},
+		{"profile glob", NewsItem{DisplayIfProfile: []string{"default/linux/amd64/23.0/*"}}, true},
+		{"all kinds must match", NewsItem{DisplayIfInstalled: []string{"dev-lang/python"}, DisplayIfKeyword: []string{"x86"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.item.IsRelevant(env); got != tt.want {
+				t.Errorf("IsRelevant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewsState(t *testing.T) {
+	fsys := fstest.MapFS{
+		"metadata/news/2024-01-01-old/2024-01-01-old.en.txt": &fstest.MapFile{Data: []byte("Title: Old\nPosted: 2024-01-01\nRevision: 1\nNews-Item-Format: 2.0\n\nOld body.\n")},
+		"metadata/news/2024-02-01-new/2024-02-01-new.en.txt": &fstest.MapFile{Data: []byte("Title: New\nPosted: 2024-02-01\nRevision: 1\nNews-Item-Format: 2.0\n\nNew body.\n")},
+		"metadata/news/2024-02-01-new/2024-02-01-new.de.txt": &fstest.MapFile{Data: []byte("Title: Neu\nPosted: 2024-02-01\nRevision: 1\nNews-Item-Format: 2.0\n\nNeu.\n")},
+		"metadata/news/2024-03-01-arm/2024-03-01-arm.en.txt": &fstest.MapFile{Data: []byte("Title: Arm\nPosted: 2024-03-01\nRevision: 1\nNews-Item-Format: 2.0\nDisplay-If-Keyword: arm\n\nArm.\n")},
+	}
+	items, err := ReadRepoNewsFS(fsys, "de")
+	if err != nil {
+		t.Fatalf("ReadRepoNewsFS failed: %v", err)
+	}
+	if len(items) != 3 || items[0].Title != "Old" || items[1].Title != "Neu" {
+		t.Fatalf("unexpected items: %+v", items)
+	}
+
+	dir := t.TempDir()
+	state, err := LoadNewsState(dir, "gentoo")
+	if err != nil {
+		t.Fatalf("LoadNewsState failed: %v", err)
+	}
+	added := state.Update(items, NewsEnvironment{Keywords: []string{"amd64"}})
+	if len(added) != 2 {
+		t.Fatalf("expected 2 new items, got %v", added)
+	}
+	state.MarkRead("2024-01-01-old")
+	if err := state.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reloaded, err := LoadNewsState(dir, "gentoo")
+	if err != nil {
+		t.Fatalf("LoadNewsState failed: %v", err)
+	}
+	if !reloaded.Read["2024-01-01-old"] || reloaded.Unread["2024-01-01-old"] || !reloaded.Unread["2024-02-01-new"] {
+		t.Errorf("unexpected state: %+v", reloaded)
+	}
+	if added := reloaded.Update(items, NewsEnvironment{Keywords: []string{"amd64"}}); len(added) != 0 {
+		t.Errorf("expected no new items on second update, got %v", added)
+	}
+
+	if removed := reloaded.Purge(items[1:]); removed != 1 || len(reloaded.Read) != 0 || reloaded.Skip["2024-01-01-old"] {
+		t.Errorf("unexpected purge result: %d %+v", removed, reloaded)
+	}
+}
@@ -0,0 +1,174 @@
+package g2
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// NewsBodyWidth is the column at which GLEP 42 news item bodies are wrapped.
+const NewsBodyWidth = 72
+
+// NewsHeader is a single raw header line of a news item.
+type NewsHeader struct {
+	Key   string
+	Value string
+}
+
+// newsTranslatedHeaders are the headers a translation keeps its own values for.
+var newsTranslatedHeaders = map[string]bool{
+	"Title":      true,
+	"Translator": true,
+	"Revision":   true,
+}
+
+// ParseNewsHeaders splits a news item into its headers, in file order, and body.
+// Unlike ParseNewsItem the header values are kept verbatim, including e-mail addresses.
+func ParseNewsHeaders(content string) ([]NewsHeader, string) {
+	var headers []NewsHeader
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			return headers, strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		headers = append(headers, NewsHeader{Key: strings.TrimSpace(key), Value: strings.TrimSpace(val)})
+	}
+	return headers, ""
+}
+
+// NewsHeaderValue returns the first value of key, or "" when it is not present.
+func NewsHeaderValue(headers []NewsHeader, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// FormatNewsFile renders headers and body as the contents of a news item file.
+func FormatNewsFile(headers []NewsHeader, body string) string {
+	var sb strings.Builder
+	for _, h := range headers {
+		sb.WriteString(h.Key)
+		sb.WriteString(": ")
+		sb.WriteString(h.Value)
+		sb.WriteByte('\n')
+	}
+	sb.WriteByte('\n')
+	sb.WriteString(strings.TrimRight(body, "\n"))
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+// SyncNewsTranslationHeaders returns the headers of a translation updated from the English original.
+// Title, Translator and Revision keep the translation's values; every other header is taken from english.
+// Translator lines follow the Author lines.
+func SyncNewsTranslationHeaders(english, translation []NewsHeader) []NewsHeader {
+	own := make(map[string][]NewsHeader)
+	for _, h := range translation {
+		if newsTranslatedHeaders[h.Key] {
+			own[h.Key] = append(own[h.Key], h)
+		}
+	}
+
+	var res []NewsHeader
+	translatorsAdded := false
+	for i, h := range english {
+		switch {
+		case h.Key == "Translator":
+			continue
+		case newsTranslatedHeaders[h.Key] && len(own[h.Key]) > 0:
+			res = append(res, own[h.Key]...)
+			delete(own, h.Key)
+		default:
+			res = append(res, h)
+		}
+		lastAuthor := h.Key == "Author" && (i+1 == len(english) || english[i+1].Key != "Author")
+		if lastAuthor && !translatorsAdded {
+			res = append(res, own["Translator"]...)
+			translatorsAdded = true
+		}
+	}
+	if !translatorsAdded {
+		res = append(res, own["Translator"]...)
+	}
+	return res
+}
+
+// WrapNewsBody wraps the paragraphs and list items of a news item body at width columns.
+// Indented lines (code blocks) are kept as they are.
+func WrapNewsBody(body string, width int) string {
+	var out []string
+	var para []string
+	prefix, indent := "", ""
+
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		out = append(out, wrapWords(strings.Fields(strings.Join(para, " ")), width, prefix, indent)...)
+		para = nil
+		prefix, indent = "", ""
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\t", "    "), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+			out = append(out, "")
+		case strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* "):
+			flush()
+			prefix, indent = line[:2], "  "
+			para = append(para, trimmed[2:])
+		case strings.HasPrefix(line, " "):
+			if len(para) > 0 && indent != "" && strings.HasPrefix(line, indent) && !strings.HasPrefix(line, indent+" ") {
+				// Continuation of a list item
+				para = append(para, trimmed)
+				continue
+			}
+			flush()
+			out = append(out, strings.TrimRight(line, " "))
+		default:
+			if indent != "" {
+				flush()
+			}
+			para = append(para, trimmed)
+		}
+	}
+	flush()
+	return strings.Join(out, "\n")
+}
+
+// wrapWords joins words into lines of at most width characters where the words allow, starting the
+// first line with prefix and the others with indent. Characters are counted as runes.
+func wrapWords(words []string, width int, prefix, indent string) []string {
+	var lines []string
+	cur := prefix
+	n := utf8.RuneCountInString(prefix)
+	empty := true
+	for _, w := range words {
+		wn := utf8.RuneCountInString(w)
+		if !empty && n+1+wn > width {
+			lines = append(lines, cur)
+			cur = indent
+			n = utf8.RuneCountInString(indent)
+			empty = true
+		}
+		if !empty {
+			cur += " "
+			n++
+		}
+		cur += w
+		n += wn
+		empty = false
+	}
+	if !empty {
+		lines = append(lines, cur)
+	}
+	return lines
+}
@@ -0,0 +1,59 @@
+package g2
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrapNewsBody(t *testing.T) {
+	body := "This is a rather long paragraph that should be wrapped because it goes well past the limit.\n" +
+		"\n" +
+		"- a list item that is also quite long and needs to be wrapped with a hanging indent\n" +
+		"  continuing here\n" +
+		"\n" +
+		"  # emerge --ask --oneshot some/really-long-package-name-that-must-not-be-wrapped-at-all\n"
+	want := "This is a rather long paragraph that should be wrapped because it goes\n" +
+		"well past the limit.\n" +
+		"\n" +
+		"- a list item that is also quite long and needs to be wrapped with a\n" +
+		"  hanging indent continuing here\n" +
+		"\n" +
+		"  # emerge --ask --oneshot some/really-long-package-name-that-must-not-be-wrapped-at-all\n"
+	if got := WrapNewsBody(body, NewsBodyWidth); got != want {
+		t.Errorf("unexpected wrapping:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Width is counted in characters, not bytes.
+	umlauts := "Übergänge für ältere Änderungen"
+	if got := WrapNewsBody(umlauts, len([]rune(umlauts))); got != umlauts {
+		t.Errorf("wrapped a line of %d characters: %q", len([]rune(umlauts)), got)
+	}
+}
+
+func TestSyncNewsTranslationHeaders(t *testing.T) {
+	english, _ := ParseNewsHeaders("Title: New title\nAuthor: A <a@example.com>\nAuthor: B <b@example.com>\nPosted: 2024-01-02\nRevision: 3\nNews-Item-Format: 2.0\nDisplay-If-Installed: app-misc/foo\n\nBody\n")
+	translation, body := ParseNewsHeaders("Title: Alter Titel\nAuthor: A <a@example.com>\nTranslator: T <t@example.com>\nPosted: 2024-01-01\nRevision: 2\nNews-Item-Format: 2.0\n\nInhalt\n")
+	if body != "Inhalt" {
+		t.Errorf("unexpected body: %q", body)
+	}
+
+	got := FormatNewsFile(SyncNewsTranslationHeaders(english, translation), body)
+	want := "Title: Alter Titel\n" +
+		"Author: A <a@example.com>\n" +
+		"Author: B <b@example.com>\n" +
+		"Translator: T <t@example.com>\n" +
+		"Posted: 2024-01-02\n" +
+		"Revision: 2\n" +
+		"News-Item-Format: 2.0\n" +
+		"Display-If-Installed: app-misc/foo\n" +
+		"\n" +
+		"Inhalt\n"
+	if got != want {
+		t.Errorf("unexpected headers:\n%s\nwant:\n%s", got, want)
+	}
+
+	fresh := SyncNewsTranslationHeaders(english, nil)
+	if NewsHeaderValue(fresh, "Revision") != "3" || NewsHeaderValue(fresh, "Title") != "New title" || strings.Contains(FormatNewsFile(fresh, ""), "Translator") {
+		t.Errorf("unexpected headers for new translation: %+v", fresh)
+	}
+}
@@ -0,0 +1,164 @@
+package g2
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxProfileDepth guards against parent files that form a cycle.
+const maxProfileDepth = 64
+
+// ProfileStack returns the directories of the profile at profileDir and all of its parents in the order
+// Portage applies them: parents first, the profile itself last. Entries of a parent file are relative to
+// the profile directory, or of the form "repo:path" relative to the profiles directory of a repository
+// found in repoLocations.
+func ProfileStack(profileDir string, repoLocations map[string]string) ([]string, error) {
+	var stack []string
+	var walk func(dir string, depth int) error
+	walk = func(dir string, depth int) error {
+		if depth > maxProfileDepth {
+			return fmt.Errorf("profile parents nested too deeply at %s", dir)
+		}
+		if info, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("profile %s: %w", dir, err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("profile %s is not a directory", dir)
+		}
+		lines, err := readProfileLines(filepath.Join(dir, "parent"))
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			parent := filepath.Join(dir, line)
+			if repo, rel, ok := strings.Cut(line, ":"); ok {
+				loc, found := repoLocations[repo]
+				if !found {
+					return fmt.Errorf("profile %s: unknown repository %q in parent %q", dir, repo, line)
+				}
+				parent = filepath.Join(loc, "profiles", rel)
+			}
+			if err := walk(parent, depth+1); err != nil {
+				return err
+			}
+		}
+		stack = append(stack, dir)
+		return nil
+	}
+	if err := walk(profileDir, 0); err != nil {
+		return nil, err
+	}
+	return stack, nil
+}
+
+// readProfileLines returns the non-empty, non-comment lines of a profile file, or nothing when it is missing.
+// A directory is read as its files in name order, leaving out hidden and backup files.
+func readProfileLines(path string) ([]string, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var lines []string
+		for _, e := range entries { // sorted by name
+			name := e.Name()
+			if e.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") || strings.HasSuffix(name, ".bak") {
+				continue
+			}
+			fileLines, err := readProfileLines(filepath.Join(path, name))
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, fileLines...)
+		}
+		return lines, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx != -1 {
+			line = line[:idx]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// ReadSystemSet returns the atoms of the @system set defined by the packages files of a profile stack as
+// returned by ProfileStack. Lines prefixed with "*" add to the set, "-*" removes an earlier entry and a
+// lone "-*" clears everything inherited so far.
+func ReadSystemSet(stack []string) ([]PackageAtom, error) {
+	var entries []string
+	for _, dir := range stack {
+		lines, err := readProfileLines(filepath.Join(dir, "packages"))
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			switch {
+			case line == "-*":
+				entries = nil
+			case strings.HasPrefix(line, "-*"):
+				atom := line[2:]
+				kept := entries[:0]
+				for _, e := range entries {
+					if e != atom {
+						kept = append(kept, e)
+					}
+				}
+				entries = kept
+			case strings.HasPrefix(line, "*"):
+				entries = append(entries, line[1:])
+			}
+		}
+	}
+	atoms := make([]PackageAtom, 0, len(entries))
+	for _, e := range entries {
+		atoms = append(atoms, ParsePackageAtom(e))
+	}
+	return atoms, nil
+}
+
+// ReadProfilePackageMask returns the atoms masked by the package.mask files of a profile stack as returned
+// by ProfileStack. A line "-atom" removes an atom masked by an earlier profile.
+func ReadProfilePackageMask(stack []string) ([]PackageAtom, error) {
+	var entries []string
+	for _, dir := range stack {
+		lines, err := readProfileLines(filepath.Join(dir, "package.mask"))
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			if atom, ok := strings.CutPrefix(line, "-"); ok {
+				kept := entries[:0]
+				for _, e := range entries {
+					if e != atom {
+						kept = append(kept, e)
+					}
+				}
+				entries = kept
+				continue
+			}
+			entries = append(entries, line)
+		}
+	}
+	atoms := make([]PackageAtom, 0, len(entries))
+	for _, e := range entries {
+		atoms = append(atoms, ParsePackageAtom(e))
+	}
+	return atoms, nil
+}
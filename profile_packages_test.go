@@ -0,0 +1,58 @@
+package g2
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestProfileStackAndSystemSet(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"gentoo/profiles/base/packages":          "*sys-apps/baselayout\n*sys-apps/portage\n*app-editors/nano\nsys-libs/glibc\n",
+		"gentoo/profiles/default/linux/parent":   "../../base\n",
+		"gentoo/profiles/default/linux/packages": "# no nano for us\n-*app-editors/nano\n",
+		"overlay/profiles/custom/parent":         "gentoo:default/linux\n",
+		"overlay/profiles/custom/packages":       "*>=sys-apps/openrc-0.50\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	custom := filepath.Join(root, "overlay", "profiles", "custom")
+	stack, err := ProfileStack(custom, map[string]string{"gentoo": filepath.Join(root, "gentoo")})
+	if err != nil {
+		t.Fatalf("ProfileStack: %v", err)
+	}
+	want := []string{
+		filepath.Join(root, "gentoo", "profiles", "base"),
+		filepath.Join(root, "gentoo", "profiles", "default", "linux"),
+		custom,
+	}
+	if !reflect.DeepEqual(stack, want) {
+		t.Errorf("ProfileStack() = %v, want %v", stack, want)
+	}
+
+	atoms, err := ReadSystemSet(stack)
+	if err != nil {
+		t.Fatalf("ReadSystemSet: %v", err)
+	}
+	var got []string
+	for _, a := range atoms {
+		got = append(got, a.String())
+	}
+	if want := []string{"sys-apps/baselayout", "sys-apps/portage", ">=sys-apps/openrc-0.50"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadSystemSet() = %v, want %v", got, want)
+	}
+
+	if _, err := ProfileStack(custom, nil); err == nil {
+		t.Error("expected an unknown parent repository to fail")
+	}
+}
@@ -243,6 +243,15 @@ func (s *ReposConfSection) Unset(key string) {
 	s.Lines = newLines
 }
 
+// IsDisabled reports whether the section is commented out or turned off with a disabled or enabled key.
+func (s *ReposConfSection) IsDisabled() bool {
+	return s.Disabled ||
+		strings.EqualFold(s.Get("disabled"), "true") ||
+		strings.EqualFold(s.Get("disabled"), "yes") ||
+		strings.EqualFold(s.Get("enabled"), "false") ||
+		strings.EqualFold(s.Get("enabled"), "no")
+}
+
 // ValidateRepoName validates a repository name according to Gentoo PMS rules.
 // Repository names must:
 // - be non-empty;
@@ -307,11 +316,7 @@ func ListConfiguredRepos(location string) ([]*RepoInfo, error) {
 			if strings.EqualFold(s.Name, "DEFAULT") {
 				continue
 			}
-			disabled := s.Disabled ||
-				strings.EqualFold(s.Get("disabled"), "true") ||
-				strings.EqualFold(s.Get("disabled"), "yes") ||
-				strings.EqualFold(s.Get("enabled"), "false") ||
-				strings.EqualFold(s.Get("enabled"), "no")
+			disabled := s.IsDisabled()
 			if disabled {
 				continue
 			}
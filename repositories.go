@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 // Repositories represents a list of Gentoo repositories.
@@ -78,3 +79,76 @@ func ParseRepositoriesFromReader(r io.Reader) (*Repositories, error) {
 
 	return &repositories, nil
 }
+
+// RepositorySyncTypePreference orders source types by how strongly they are preferred when adding a
+// repository to repos.conf. Types not listed are not supported by Portage's sync modules.
+var RepositorySyncTypePreference = []string{"git", "rsync", "mercurial", "svn", "cvs"}
+
+// Find returns the repository called name, or nil.
+func (r *Repositories) Find(name string) *Repository {
+	for i := range r.Repositories {
+		if r.Repositories[i].Name == name {
+			return &r.Repositories[i]
+		}
+	}
+	return nil
+}
+
+// Search returns the repositories whose name, description or owners contain text, case-insensitively.
+func (r *Repositories) Search(text string) []Repository {
+	text = strings.ToLower(text)
+	var res []Repository
+	for _, repo := range r.Repositories {
+		fields := []string{repo.Name, repo.Homepage}
+		for _, d := range repo.Descriptions {
+			fields = append(fields, d.Text)
+		}
+		for _, o := range repo.Owners {
+			fields = append(fields, o.Name, o.Email)
+		}
+		for _, f := range fields {
+			if strings.Contains(strings.ToLower(f), text) {
+				res = append(res, repo)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// Description returns the English description of the repository, or the first one available.
+func (r Repository) Description() string {
+	for _, d := range r.Descriptions {
+		if d.Lang == "" || d.Lang == "en" {
+			return strings.TrimSpace(d.Text)
+		}
+	}
+	if len(r.Descriptions) > 0 {
+		return strings.TrimSpace(r.Descriptions[0].Text)
+	}
+	return ""
+}
+
+// PreferredSource returns the source to sync the repository from, following RepositorySyncTypePreference.
+// Among git sources, https URIs are preferred over other transports.
+func (r Repository) PreferredSource() (RepositorySource, bool) {
+	for _, t := range RepositorySyncTypePreference {
+		var candidates []RepositorySource
+		for _, s := range r.Sources {
+			if s.Type == t {
+				s.Text = strings.TrimSpace(s.Text)
+				candidates = append(candidates, s)
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		for _, s := range candidates {
+			if strings.HasPrefix(s.Text, "https://") {
+				return s, true
+			}
+		}
+		return candidates[0], true
+	}
+	return RepositorySource{}, false
+}
@@ -136,3 +136,55 @@ func TestParseRepositories_ErrorHandling(t *testing.T) {
 		}
 	})
 }
+
+func TestRepositoriesFindAndSearch(t *testing.T) {
+	repos, err := ParseRepositoriesBytes(validRepositoriesXML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r := repos.Find("gentoo"); r == nil || r.Description() != "Gentoo Linux repository" {
+		t.Errorf("Find(gentoo) = %+v", r)
+	}
+	if r := repos.Find("missing"); r != nil {
+		t.Errorf("Find(missing) = %+v, want nil", r)
+	}
+
+	for text, want := range map[string][]string{
+		"FOO":              {"foo-overlay"},
+		"bar person":       {"foo-overlay"},
+		"bug-wranglers":    {"gentoo"},
+		"linux repository": {"gentoo"},
+		"nothing":          nil,
+	} {
+		var got []string
+		for _, r := range repos.Search(text) {
+			got = append(got, r.Name)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Search(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestRepositoryPreferredSource(t *testing.T) {
+	tests := []struct {
+		name    string
+		sources []RepositorySource
+		want    string
+		ok      bool
+	}{
+		{"git before rsync", []RepositorySource{{Type: "rsync", Text: "rsync://r/x"}, {Type: "git", Text: "https://g/x.git"}}, "https://g/x.git", true},
+		{"https git first", []RepositorySource{{Type: "git", Text: "git+ssh://g/x.git"}, {Type: "git", Text: "https://g/x.git"}}, "https://g/x.git", true},
+		{"rsync only", []RepositorySource{{Type: "rsync", Text: " rsync://r/x "}}, "rsync://r/x", true},
+		{"unsupported", []RepositorySource{{Type: "tar", Text: "https://t/x.tar"}}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Repository{Sources: tt.sources}.PreferredSource()
+			if ok != tt.ok || got.Text != tt.want {
+				t.Errorf("PreferredSource() = %+v, %v; want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
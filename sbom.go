@@ -0,0 +1,453 @@
+package g2
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SBOMPackage is a package version described in a software bill of materials.
+type SBOMPackage struct {
+	Category    string
+	Name        string
+	Version     string
+	Slot        string
+	Repository  string
+	Description string
+	Homepage    string
+	License     string // LICENSE as written in the ebuild
+	// Use holds the enabled USE flags of installed packages, used to resolve LICENSE conditionals. It is
+	// nil for packages read from a repository.
+	Use       []string
+	RemoteIDs []RemoteID
+	Distfiles []SBOMDistfile
+}
+
+// SBOMDistfile is a source archive of a package with its Manifest size and hashes.
+type SBOMDistfile struct {
+	Filename string
+	URL      string
+	Size     int64
+	Hashes   []Hash
+}
+
+// SBOM is a bill of materials for a repository or an installed system.
+type SBOM struct {
+	Name     string
+	Created  time.Time
+	Packages []SBOMPackage
+	Licenses SPDXLicenseMap
+	// LicenseTexts holds the text of Gentoo licenses by name, used for licenses without an SPDX
+	// identifier.
+	LicenseTexts map[string]string
+}
+
+// CPV returns "category/name-version".
+func (p *SBOMPackage) CPV() string {
+	return p.Category + "/" + p.Name + "-" + p.Version
+}
+
+func (p *SBOMPackage) licenseExpression(m SPDXLicenseMap) string {
+	var use map[string]bool
+	if p.Use != nil {
+		use = make(map[string]bool)
+		for _, u := range p.Use {
+			use[u] = true
+		}
+	}
+	return m.LicenseExpression(p.License, use)
+}
+
+// sbomPurls returns package URLs for the remote-ids of metadata.xml that have a purl type.
+func sbomPurls(ids []RemoteID) []string {
+	var res []string
+	for _, id := range ids {
+		text := strings.TrimSpace(id.Text)
+		switch id.Type {
+		case "github", "gitlab", "bitbucket":
+			res = append(res, "pkg:"+id.Type+"/"+strings.ToLower(text))
+		case "pypi":
+			res = append(res, "pkg:pypi/"+strings.ToLower(text))
+		case "rubygems":
+			res = append(res, "pkg:gem/"+text)
+		case "cpan":
+			res = append(res, "pkg:cpan/"+text)
+		case "cran":
+			res = append(res, "pkg:cran/"+text)
+		case "hackage":
+			res = append(res, "pkg:hackage/"+text)
+		}
+	}
+	return res
+}
+
+func sbomCPE(ids []RemoteID) string {
+	for _, id := range ids {
+		if id.Type == "cpe" {
+			return strings.TrimSpace(id.Text)
+		}
+	}
+	return ""
+}
+
+// spdxHashAlgorithms maps Manifest hash names to SPDX checksum algorithms.
+var spdxHashAlgorithms = map[string]string{
+	HashBlake2b:  "BLAKE2b-512",
+	HashMd5:      "MD5",
+	HashSha1:     "SHA1",
+	HashSha256:   "SHA256",
+	HashSha512:   "SHA512",
+	HashSha3_256: "SHA3-256",
+	HashSha3_512: "SHA3-512",
+}
+
+// cycloneDXHashAlgorithms maps Manifest hash names to CycloneDX hash algorithms.
+var cycloneDXHashAlgorithms = map[string]string{
+	HashBlake2b:  "BLAKE2b-512",
+	HashMd5:      "MD5",
+	HashSha1:     "SHA-1",
+	HashSha256:   "SHA-256",
+	HashSha512:   "SHA-512",
+	HashSha3_256: "SHA3-256",
+	HashSha3_512: "SHA3-512",
+}
+
+// spdxID turns s into a valid SPDX element identifier suffix.
+func spdxID(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '-'
+	}, s)
+}
+
+func orNoAssertion(s string) string {
+	if s == "" {
+		return "NOASSERTION"
+	}
+	return s
+}
+
+func (s *SBOM) sortedPackages() []SBOMPackage {
+	pkgs := append([]SBOMPackage(nil), s.Packages...)
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		if pkgs[i].Category+"/"+pkgs[i].Name != pkgs[j].Category+"/"+pkgs[j].Name {
+			return pkgs[i].Category+"/"+pkgs[i].Name < pkgs[j].Category+"/"+pkgs[j].Name
+		}
+		if c := CompareVersions(pkgs[i].Version, pkgs[j].Version); c != 0 {
+			return c < 0
+		}
+		return pkgs[i].Repository < pkgs[j].Repository
+	})
+	return pkgs
+}
+
+// documentNamespace derives a namespace that is unique for the name, time and contents of the document.
+func (s *SBOM) documentNamespace() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", s.Name, s.Created.UTC().Format(time.RFC3339))
+	for _, p := range s.Packages {
+		fmt.Fprintf(h, "%s::%s\n", p.CPV(), p.Repository)
+	}
+	return fmt.Sprintf("https://spdx.org/spdxdocs/%s-%x", spdxID(s.Name), h.Sum(nil)[:16])
+}
+
+type spdxDocument struct {
+	SPDXVersion          string                 `json:"spdxVersion"`
+	DataLicense          string                 `json:"dataLicense"`
+	SPDXID               string                 `json:"SPDXID"`
+	Name                 string                 `json:"name"`
+	DocumentNamespace    string                 `json:"documentNamespace"`
+	CreationInfo         spdxCreationInfo       `json:"creationInfo"`
+	Packages             []spdxPackage          `json:"packages"`
+	Relationships        []spdxRelationship     `json:"relationships"`
+	ExtractedLicenseInfo []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID                string            `json:"SPDXID"`
+	Name                  string            `json:"name"`
+	VersionInfo           string            `json:"versionInfo,omitempty"`
+	PackageFileName       string            `json:"packageFileName,omitempty"`
+	DownloadLocation      string            `json:"downloadLocation"`
+	Homepage              string            `json:"homepage,omitempty"`
+	FilesAnalyzed         bool              `json:"filesAnalyzed"`
+	Checksums             []spdxChecksum    `json:"checksums,omitempty"`
+	LicenseConcluded      string            `json:"licenseConcluded"`
+	LicenseDeclared       string            `json:"licenseDeclared"`
+	CopyrightText         string            `json:"copyrightText"`
+	Description           string            `json:"description,omitempty"`
+	PrimaryPackagePurpose string            `json:"primaryPackagePurpose,omitempty"`
+	ExternalRefs          []spdxExternalRef `json:"externalRefs,omitempty"`
+	SourceInfo            string            `json:"sourceInfo,omitempty"`
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+type spdxExtractedLicense struct {
+	LicenseID     string `json:"licenseId"`
+	Name          string `json:"name"`
+	ExtractedText string `json:"extractedText"`
+}
+
+// SPDX returns the bill of materials as an SPDX 2.3 JSON document. Every package version is an SPDX
+// package described by the document, and its distfiles are source packages it is generated from,
+// carrying the Manifest hashes as checksums.
+func (s *SBOM) SPDX() ([]byte, error) {
+	doc := spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              s.Name,
+		DocumentNamespace: s.documentNamespace(),
+		CreationInfo: spdxCreationInfo{
+			Created:  s.Created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: g2"},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+	licenseRefs := make(map[string]bool)
+	noteRefs := func(expr string) {
+		for _, ref := range SPDXLicenseRefs(expr) {
+			licenseRefs[ref] = true
+		}
+	}
+	distfileIDs := make(map[string]bool)
+
+	for _, p := range s.sortedPackages() {
+		id := "SPDXRef-Package-" + spdxID(p.Category+"-"+p.Name+"-"+p.Version)
+		if p.Repository != "" {
+			id += "-" + spdxID(p.Repository)
+		}
+		declared := orNoAssertion(p.licenseExpression(s.Licenses))
+		concluded := "NOASSERTION"
+		if p.Use != nil {
+			concluded = declared
+		}
+		noteRefs(declared)
+		pkg := spdxPackage{
+			SPDXID:                id,
+			Name:                  p.Category + "/" + p.Name,
+			VersionInfo:           p.Version,
+			DownloadLocation:      "NOASSERTION",
+			Homepage:              firstField(p.Homepage),
+			LicenseConcluded:      concluded,
+			LicenseDeclared:       declared,
+			CopyrightText:         "NOASSERTION",
+			Description:           p.Description,
+			PrimaryPackagePurpose: "APPLICATION",
+		}
+		if p.Repository != "" {
+			pkg.SourceInfo = "built from the " + p.Repository + " repository"
+		}
+		for _, purl := range sbomPurls(p.RemoteIDs) {
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{ReferenceCategory: "PACKAGE-MANAGER", ReferenceType: "purl", ReferenceLocator: purl})
+		}
+		if cpe := sbomCPE(p.RemoteIDs); cpe != "" {
+			refType := "cpe22Type"
+			if strings.HasPrefix(cpe, "cpe:2.3:") {
+				refType = "cpe23Type"
+			}
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{ReferenceCategory: "SECURITY", ReferenceType: refType, ReferenceLocator: cpe})
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{SPDXElementID: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSPDXElement: id})
+
+		for _, d := range p.Distfiles {
+			distID := "SPDXRef-Distfile-" + spdxID(d.Filename)
+			if !distfileIDs[distID] {
+				distfileIDs[distID] = true
+				dist := spdxPackage{
+					SPDXID:                distID,
+					Name:                  d.Filename,
+					PackageFileName:       d.Filename,
+					DownloadLocation:      orNoAssertion(d.URL),
+					LicenseConcluded:      "NOASSERTION",
+					LicenseDeclared:       "NOASSERTION",
+					CopyrightText:         "NOASSERTION",
+					PrimaryPackagePurpose: "SOURCE",
+				}
+				for _, h := range d.Hashes {
+					if alg, ok := spdxHashAlgorithms[h.Type]; ok {
+						dist.Checksums = append(dist.Checksums, spdxChecksum{Algorithm: alg, ChecksumValue: strings.ToLower(h.Value)})
+					}
+				}
+				doc.Packages = append(doc.Packages, dist)
+			}
+			doc.Relationships = append(doc.Relationships, spdxRelationship{SPDXElementID: id, RelationshipType: "GENERATED_FROM", RelatedSPDXElement: distID})
+		}
+	}
+
+	refs := make([]string, 0, len(licenseRefs))
+	for ref := range licenseRefs {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	gentooNames := make(map[string]string)
+	for _, p := range s.Packages {
+		for _, lic := range ParseLicense(p.License) {
+			gentooNames[s.Licenses.Expression(lic)] = lic
+		}
+	}
+	for _, ref := range refs {
+		name := gentooNames[ref]
+		if name == "" {
+			name = strings.TrimPrefix(ref, "LicenseRef-Gentoo-")
+		}
+		text := s.LicenseTexts[name]
+		if text == "" {
+			text = "Gentoo license " + name + ", see licenses/" + name + " in the repository of the package"
+		}
+		doc.ExtractedLicenseInfo = append(doc.ExtractedLicenseInfo, spdxExtractedLicense{LicenseID: ref, Name: name, ExtractedText: text})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+type cycloneDXDocument struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     cycloneDXMetadata    `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string              `json:"timestamp"`
+	Tools     []cycloneDXTool     `json:"tools"`
+	Component *cycloneDXComponent `json:"component,omitempty"`
+}
+
+type cycloneDXTool struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXComponent struct {
+	Type               string                 `json:"type"`
+	BOMRef             string                 `json:"bom-ref,omitempty"`
+	Group              string                 `json:"group,omitempty"`
+	Name               string                 `json:"name"`
+	Version            string                 `json:"version,omitempty"`
+	Description        string                 `json:"description,omitempty"`
+	Hashes             []cycloneDXHash        `json:"hashes,omitempty"`
+	Licenses           []cycloneDXLicense     `json:"licenses,omitempty"`
+	CPE                string                 `json:"cpe,omitempty"`
+	ExternalReferences []cycloneDXExternalRef `json:"externalReferences,omitempty"`
+	Properties         []cycloneDXProperty    `json:"properties,omitempty"`
+	Components         []cycloneDXComponent   `json:"components,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cycloneDXLicense struct {
+	Expression string `json:"expression"`
+}
+
+type cycloneDXExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CycloneDX returns the bill of materials as a CycloneDX 1.5 JSON document. Distfiles are nested
+// components of their package with the Manifest hashes.
+func (s *SBOM) CycloneDX() ([]byte, error) {
+	// The serial number is a name-based UUID, so regenerating the same BOM gives the same serial.
+	u := sha256.Sum256([]byte(s.documentNamespace()))
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	doc := cycloneDXDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: s.Created.UTC().Format(time.RFC3339),
+			Tools:     []cycloneDXTool{{Name: "g2"}},
+			Component: &cycloneDXComponent{Type: "platform", Name: s.Name},
+		},
+		Components: []cycloneDXComponent{},
+	}
+	for _, p := range s.sortedPackages() {
+		c := cycloneDXComponent{
+			Type:        "application",
+			BOMRef:      p.CPV() + "::" + p.Repository,
+			Group:       p.Category,
+			Name:        p.Name,
+			Version:     p.Version,
+			Description: p.Description,
+			CPE:         sbomCPE(p.RemoteIDs),
+		}
+		if expr := p.licenseExpression(s.Licenses); expr != "" {
+			c.Licenses = []cycloneDXLicense{{Expression: expr}}
+		}
+		for _, h := range strings.Fields(p.Homepage) {
+			c.ExternalReferences = append(c.ExternalReferences, cycloneDXExternalRef{Type: "website", URL: h})
+		}
+		for _, purl := range sbomPurls(p.RemoteIDs) {
+			c.Properties = append(c.Properties, cycloneDXProperty{Name: "gentoo:upstream-purl", Value: purl})
+		}
+		if p.Repository != "" {
+			c.Properties = append(c.Properties, cycloneDXProperty{Name: "gentoo:repository", Value: p.Repository})
+		}
+		if p.Slot != "" {
+			c.Properties = append(c.Properties, cycloneDXProperty{Name: "gentoo:slot", Value: p.Slot})
+		}
+		if p.License != "" {
+			c.Properties = append(c.Properties, cycloneDXProperty{Name: "gentoo:license", Value: strings.Join(strings.Fields(p.License), " ")})
+		}
+		for _, d := range p.Distfiles {
+			dist := cycloneDXComponent{Type: "file", BOMRef: p.CPV() + "::" + p.Repository + "/" + d.Filename, Name: d.Filename}
+			for _, h := range d.Hashes {
+				if alg, ok := cycloneDXHashAlgorithms[h.Type]; ok {
+					dist.Hashes = append(dist.Hashes, cycloneDXHash{Alg: alg, Content: strings.ToLower(h.Value)})
+				}
+			}
+			if d.URL != "" {
+				dist.ExternalReferences = []cycloneDXExternalRef{{Type: "distribution", URL: d.URL}}
+			}
+			c.Components = append(c.Components, dist)
+		}
+		doc.Components = append(doc.Components, c)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func firstField(s string) string {
+	if f := strings.Fields(s); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
@@ -0,0 +1,132 @@
+package g2
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSPDXLicenseExpression(t *testing.T) {
+	overrides, err := ParseSPDXLicenseMap(strings.NewReader("# local names\nfoo-license MIT\nGPL-2 GPL-2.0-only\n"))
+	if err != nil {
+		t.Fatalf("ParseSPDXLicenseMap: %v", err)
+	}
+	m := DefaultSPDXLicenses.Merge(overrides)
+	tests := []struct {
+		license string
+		use     map[string]bool
+		want    string
+	}{
+		{"GPL-2+", nil, "GPL-2.0-or-later"},
+		{"|| ( MIT Apache-2.0 ) BSD", nil, "(MIT OR Apache-2.0) AND BSD-3-Clause"},
+		{"foo-license ssl? ( OpenSSL )", nil, "MIT AND OpenSSL"},
+		{"foo-license ssl? ( OpenSSL )", map[string]bool{}, "MIT"},
+		{"LGPL-2.1+ !minimal? ( GPL-3 )", map[string]bool{"minimal": true}, "LGPL-2.1-or-later"},
+		{"all-rights-reserved Foo+", nil, "LicenseRef-Gentoo-all-rights-reserved AND LicenseRef-Gentoo-Foo-plus"},
+		{"", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := m.LicenseExpression(tt.license, tt.use); got != tt.want {
+			t.Errorf("LicenseExpression(%q, %v) = %q, want %q", tt.license, tt.use, got, tt.want)
+		}
+	}
+	if _, ok := DefaultSPDXLicenses["foo-license"]; ok {
+		t.Error("Merge modified the default map")
+	}
+	if _, err := ParseSPDXLicenseMap(strings.NewReader("lonely\n")); err == nil {
+		t.Error("expected a line without an expression to fail")
+	}
+}
+
+func testSBOM() *SBOM {
+	return &SBOM{
+		Name:     "example",
+		Created:  time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		Licenses: DefaultSPDXLicenses,
+		Packages: []SBOMPackage{
+			{
+				Category: "app-misc", Name: "foo", Version: "1.0-r1", Repository: "example",
+				Description: "Foo", Homepage: "https://foo.example.org https://mirror.example.org",
+				License:   "MIT custom",
+				RemoteIDs: []RemoteID{{Type: "github", Text: "Example/Foo"}, {Type: "cpe", Text: "cpe:/a:example:foo"}},
+				Distfiles: []SBOMDistfile{{Filename: "foo-1.0.tar.gz", URL: "https://foo.example.org/foo-1.0.tar.gz", Size: 3, Hashes: []Hash{{Type: HashBlake2b, Value: "AB"}, {Type: HashSha512, Value: "cd"}}}},
+			},
+			{Category: "app-misc", Name: "bar", Version: "2", License: "GPL-2", Use: []string{}},
+		},
+		LicenseTexts: map[string]string{"custom": "Custom licence text"},
+	}
+}
+
+func TestSBOMSPDX(t *testing.T) {
+	data, err := testSBOM().SPDX()
+	if err != nil {
+		t.Fatalf("SPDX: %v", err)
+	}
+	var doc spdxDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.SPDXVersion != "SPDX-2.3" || doc.CreationInfo.Created != "2026-01-02T03:04:05Z" || !strings.HasPrefix(doc.DocumentNamespace, "https://") {
+		t.Errorf("unexpected document header: %+v", doc)
+	}
+	if len(doc.Packages) != 3 || doc.Packages[0].Name != "app-misc/bar" || doc.Packages[1].Name != "app-misc/foo" {
+		t.Fatalf("packages = %+v", doc.Packages)
+	}
+	bar, foo, dist := doc.Packages[0], doc.Packages[1], doc.Packages[2]
+	if bar.LicenseConcluded != "GPL-2.0-only" || foo.LicenseConcluded != "NOASSERTION" {
+		t.Errorf("licenseConcluded = %q, %q", bar.LicenseConcluded, foo.LicenseConcluded)
+	}
+	if foo.LicenseDeclared != "MIT AND LicenseRef-Gentoo-custom" || foo.Homepage != "https://foo.example.org" {
+		t.Errorf("foo = %+v", foo)
+	}
+	if len(foo.ExternalRefs) != 2 || foo.ExternalRefs[0].ReferenceLocator != "pkg:github/example/foo" || foo.ExternalRefs[1].ReferenceType != "cpe22Type" {
+		t.Errorf("externalRefs = %+v", foo.ExternalRefs)
+	}
+	if dist.PrimaryPackagePurpose != "SOURCE" || len(dist.Checksums) != 2 || dist.Checksums[0] != (spdxChecksum{Algorithm: "BLAKE2b-512", ChecksumValue: "ab"}) {
+		t.Errorf("distfile = %+v", dist)
+	}
+	found := false
+	for _, r := range doc.Relationships {
+		if r.SPDXElementID == foo.SPDXID && r.RelationshipType == "GENERATED_FROM" && r.RelatedSPDXElement == dist.SPDXID {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("missing GENERATED_FROM relationship: %+v", doc.Relationships)
+	}
+	if len(doc.ExtractedLicenseInfo) != 1 || doc.ExtractedLicenseInfo[0].ExtractedText != "Custom licence text" {
+		t.Errorf("hasExtractedLicensingInfos = %+v", doc.ExtractedLicenseInfo)
+	}
+}
+
+func TestSBOMCycloneDX(t *testing.T) {
+	s := testSBOM()
+	data, err := s.CycloneDX()
+	if err != nil {
+		t.Fatalf("CycloneDX: %v", err)
+	}
+	var doc cycloneDXDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if doc.BOMFormat != "CycloneDX" || doc.SpecVersion != "1.5" || len(doc.SerialNumber) != len("urn:uuid:")+36 {
+		t.Errorf("unexpected header: %+v", doc)
+	}
+	if again, _ := s.CycloneDX(); string(again) != string(data) {
+		t.Error("CycloneDX output is not reproducible")
+	}
+	if len(doc.Components) != 2 {
+		t.Fatalf("components = %+v", doc.Components)
+	}
+	foo := doc.Components[1]
+	if foo.Group != "app-misc" || foo.Name != "foo" || foo.Licenses[0].Expression != "MIT AND LicenseRef-Gentoo-custom" || foo.CPE != "cpe:/a:example:foo" {
+		t.Errorf("foo = %+v", foo)
+	}
+	if len(foo.Components) != 1 || foo.Components[0].Hashes[1] != (cycloneDXHash{Alg: "SHA-512", Content: "cd"}) {
+		t.Errorf("distfiles = %+v", foo.Components)
+	}
+	if len(foo.ExternalReferences) != 2 {
+		t.Errorf("externalReferences = %+v", foo.ExternalReferences)
+	}
+}
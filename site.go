@@ -18,6 +18,7 @@ type SiteData struct {
 	Moves             []PackageMove
 	SlotMoves         []PackageSlotMove
 	News              []NewsItem
+	RecentChanges     []PackageChange // newest first, across all packages
 	LayoutConf        *LayoutConf
 	LicenseMapping    map[string][]string
 	ProvidedLicenses  []string
@@ -122,6 +123,7 @@ type PackageData struct {
 	// Git info
 	MetadataRawURL string
 	ModTime        time.Time // Changed from time.Time to break cycle or keep as int64 if needed, wait time is standard
+	History        []PackageChange
 
 	// Processed Uses (per package)
 	PkgUseFlags []PkgUseFlag
@@ -161,6 +163,15 @@ type VersionData struct {
 	ApplicableMirrors map[string][]string
 }
 
+// PVR returns the version and revision of the ebuild, such as 1.0-r1. Version holds only PV, so the
+// revisions of a version share it.
+func (v VersionData) PVR() string {
+	if v.Ebuild != nil && v.Ebuild.Vars["PVR"] != "" {
+		return v.Ebuild.Vars["PVR"]
+	}
+	return v.Version
+}
+
 type EclassData struct {
 	Name string
 }
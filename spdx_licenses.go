@@ -0,0 +1,209 @@
+package g2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SPDXLicenseMapFile is the repository file that adds to or overrides DefaultSPDXLicenses. Each line
+// holds a Gentoo license name followed by an SPDX license expression.
+const SPDXLicenseMapFile = "metadata/spdx-licenses"
+
+// SPDXLicenseMap maps Gentoo license names, as found in licenses/, to SPDX license expressions.
+type SPDXLicenseMap map[string]string
+
+// DefaultSPDXLicenses maps the common licenses of the Gentoo repository to their SPDX identifiers.
+// Licenses without an SPDX equivalent, such as public-domain or the various EULAs, are not listed and
+// are reported as LicenseRef-Gentoo-<name>.
+var DefaultSPDXLicenses = SPDXLicenseMap{
+	"0BSD":                            "0BSD",
+	"AFL-2.1":                         "AFL-2.1",
+	"AFL-3.0":                         "AFL-3.0",
+	"AGPL-3":                          "AGPL-3.0-only",
+	"AGPL-3+":                         "AGPL-3.0-or-later",
+	"Apache-1.1":                      "Apache-1.1",
+	"Apache-2.0":                      "Apache-2.0",
+	"Apache-2.0-with-LLVM-exceptions": "Apache-2.0 WITH LLVM-exception",
+	"Artistic":                        "Artistic-1.0-Perl",
+	"Artistic-2":                      "Artistic-2.0",
+	"BSD":                             "BSD-3-Clause",
+	"BSD-1":                           "BSD-1-Clause",
+	"BSD-2":                           "BSD-2-Clause",
+	"BSD-4":                           "BSD-4-Clause",
+	"Boost-1.0":                       "BSL-1.0",
+	"CC-BY-3.0":                       "CC-BY-3.0",
+	"CC-BY-4.0":                       "CC-BY-4.0",
+	"CC-BY-SA-3.0":                    "CC-BY-SA-3.0",
+	"CC-BY-SA-4.0":                    "CC-BY-SA-4.0",
+	"CC0-1.0":                         "CC0-1.0",
+	"CDDL":                            "CDDL-1.0",
+	"CDDL-1.1":                        "CDDL-1.1",
+	"EPL-1.0":                         "EPL-1.0",
+	"EPL-2.0":                         "EPL-2.0",
+	"FDL-1.2":                         "GFDL-1.2-only",
+	"FDL-1.2+":                        "GFDL-1.2-or-later",
+	"FDL-1.3":                         "GFDL-1.3-only",
+	"FDL-1.3+":                        "GFDL-1.3-or-later",
+	"FTL":                             "FTL",
+	"GPL-1":                           "GPL-1.0-only",
+	"GPL-1+":                          "GPL-1.0-or-later",
+	"GPL-2":                           "GPL-2.0-only",
+	"GPL-2+":                          "GPL-2.0-or-later",
+	"GPL-2-with-classpath-exception":  "GPL-2.0-only WITH Classpath-exception-2.0",
+	"GPL-3":                           "GPL-3.0-only",
+	"GPL-3+":                          "GPL-3.0-or-later",
+	"HPND":                            "HPND",
+	"IJG":                             "IJG",
+	"ISC":                             "ISC",
+	"LGPL-2":                          "LGPL-2.0-only",
+	"LGPL-2+":                         "LGPL-2.0-or-later",
+	"LGPL-2.1":                        "LGPL-2.1-only",
+	"LGPL-2.1+":                       "LGPL-2.1-or-later",
+	"LGPL-3":                          "LGPL-3.0-only",
+	"LGPL-3+":                         "LGPL-3.0-or-later",
+	"LPPL-1.3c":                       "LPPL-1.3c",
+	"MIT":                             "MIT",
+	"MIT-0":                           "MIT-0",
+	"MPL-1.1":                         "MPL-1.1",
+	"MPL-2.0":                         "MPL-2.0",
+	"NCSA":                            "NCSA",
+	"OFL-1.1":                         "OFL-1.1",
+	"OpenSSL":                         "OpenSSL",
+	"PSF-2":                           "PSF-2.0",
+	"PHP-3.01":                        "PHP-3.01",
+	"Ruby":                            "Ruby",
+	"Ruby-BSD":                        "Ruby OR BSD-2-Clause",
+	"SGI-B-2.0":                       "SGI-B-2.0",
+	"Sleepycat":                       "Sleepycat",
+	"Unicode-3.0":                     "Unicode-3.0",
+	"Unicode-DFS-2016":                "Unicode-DFS-2016",
+	"Unlicense":                       "Unlicense",
+	"UoI-NCSA":                        "NCSA",
+	"Vim":                             "Vim",
+	"W3C":                             "W3C",
+	"WTFPL-2":                         "WTFPL",
+	"X11":                             "X11",
+	"ZLIB":                            "Zlib",
+	"curl":                            "curl",
+	"libpng":                          "Libpng",
+	"libpng2":                         "libpng-2.0",
+	"libtiff":                         "libtiff",
+	"tcltk":                           "TCL",
+}
+
+// ParseSPDXLicenseMap parses a license map file: "GENTOO-NAME SPDX-EXPRESSION" per line, with # comments.
+func ParseSPDXLicenseMap(r io.Reader) (SPDXLicenseMap, error) {
+	m := make(SPDXLicenseMap)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected a license name and an SPDX expression", lineNo)
+		}
+		m[fields[0]] = strings.Join(fields[1:], " ")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Merge returns a copy of m with the entries of overrides added, replacing those already present.
+func (m SPDXLicenseMap) Merge(overrides SPDXLicenseMap) SPDXLicenseMap {
+	res := make(SPDXLicenseMap, len(m)+len(overrides))
+	for k, v := range m {
+		res[k] = v
+	}
+	for k, v := range overrides {
+		res[k] = v
+	}
+	return res
+}
+
+// Expression returns the SPDX expression for a single Gentoo license. Unmapped licenses become a
+// LicenseRef, with characters SPDX does not allow in references replaced.
+func (m SPDXLicenseMap) Expression(license string) string {
+	if expr, ok := m[license]; ok {
+		return expr
+	}
+	return SPDXLicenseRef(license)
+}
+
+// SPDXLicenseRef returns the LicenseRef used for a Gentoo license with no SPDX identifier.
+func SPDXLicenseRef(license string) string {
+	var sb strings.Builder
+	sb.WriteString("LicenseRef-Gentoo-")
+	for _, r := range license {
+		switch {
+		case r == '+':
+			sb.WriteString("-plus")
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('-')
+		}
+	}
+	return sb.String()
+}
+
+// LicenseExpression converts a LICENSE value into an SPDX license expression. || groups become OR and
+// everything else AND. USE conditionals are resolved against use when it is non-nil and are otherwise
+// all included, giving the licenses that may apply. It returns "" for an empty LICENSE.
+func (m SPDXLicenseMap) LicenseExpression(license string, use map[string]bool) string {
+	return m.joinNodes(ParseDepTree(license).Nodes, " AND ", use)
+}
+
+func (m SPDXLicenseMap) joinNodes(nodes []DepNode, op string, use map[string]bool) string {
+	var parts []string
+	seen := make(map[string]bool)
+	add := func(s string) {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			parts = append(parts, s)
+		}
+	}
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case DepString:
+			add(m.Expression(string(n)))
+		case DepAllOf:
+			add(m.joinNodes(n.Children, " AND ", use))
+		case DepAnyOf:
+			add(m.joinNodes(n.Children, " OR ", use))
+		case DepUseConditional:
+			if use != nil && use[n.Flag] == n.IsNegated {
+				continue
+			}
+			add(m.joinNodes(n.Children, " AND ", use))
+		}
+	}
+	if len(parts) > 1 {
+		for i, p := range parts {
+			if strings.Contains(p, " AND ") || strings.Contains(p, " OR ") {
+				parts[i] = "(" + p + ")"
+			}
+		}
+	}
+	return strings.Join(parts, op)
+}
+
+// SPDXLicenseRefs returns the LicenseRefs used in expr, in order of first use.
+func SPDXLicenseRefs(expr string) []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, tok := range strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expr)) {
+		if strings.HasPrefix(tok, "LicenseRef-") && !seen[tok] {
+			seen[tok] = true
+			res = append(res, tok)
+		}
+	}
+	return res
+}
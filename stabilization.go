@@ -0,0 +1,229 @@
+package g2
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// StabilizationOptions selects the ebuilds reported by StabilizationCandidates.
+type StabilizationOptions struct {
+	// MinAge is how long an ebuild must have been keyworded for testing.
+	MinAge time.Duration
+	// Arches limits the report to these arches; empty means every arch arches.desc marks stable, or
+	// every arch without an arches.desc.
+	Arches []string
+	// Now is the time ages are measured to; zero means the current time.
+	Now time.Time
+	// Masters returns the versions of a category/name package that is not in the site, from the
+	// repositories the site's repository names as masters. With nil Masters such dependencies are
+	// assumed stable.
+	Masters func(pkg string) []VersionData
+}
+
+// StabilizationCandidate is an ebuild that can be stabilized on an arch.
+type StabilizationCandidate struct {
+	Package string // category/name
+	Version string
+	Arch    string
+	Since   time.Time // when the ebuild was added or keyworded ~Arch
+}
+
+// Age returns how long the candidate has been keyworded for testing at now.
+func (c StabilizationCandidate) Age(now time.Time) time.Duration {
+	return now.Sub(c.Since)
+}
+
+// StabilizationCandidates returns the ebuilds of site keyworded ~arch for at least opts.MinAge, with no
+// newer revision of their version, whose dependencies all have a stable version on arch. The time an
+// ebuild was keyworded ~arch is read from the package History, the last commit that added the ebuild or
+// newly keyworded it ~arch; keywording other arches does not count. When the history does not reach
+// back that far the oldest change it holds is used, and without history the modification time of the
+// ebuild. Dependencies on packages outside site are looked up with opts.Masters. Candidates are sorted by
+// package, version and arch.
+func StabilizationCandidates(site *SiteData, opts StabilizationOptions) []StabilizationCandidate {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	packages := map[string]*PackageData{}
+	for i := range site.Categories {
+		for j := range site.Categories[i].Packages {
+			pkg := &site.Categories[i].Packages[j]
+			packages[pkg.Category+"/"+pkg.Name] = pkg
+		}
+	}
+	arches := opts.Arches
+	if len(arches) == 0 && site.ArchesDesc != nil && len(site.ArchesDesc.Arches) > 0 {
+		for arch, status := range site.ArchesDesc.Arches {
+			if status == "stable" {
+				arches = append(arches, arch)
+			}
+		}
+		sort.Strings(arches)
+	}
+
+	r := &stabilityResolver{packages: packages, masters: opts.Masters, external: map[string][]VersionData{}}
+	var candidates []StabilizationCandidate
+	for _, key := range sortedKeys(packages) {
+		pkg := packages[key]
+		for _, v := range pkg.Versions {
+			if v.Ebuild == nil || hasNewerRevision(pkg, v.PVR()) {
+				continue
+			}
+			keywords := v.Ebuild.Vars["KEYWORDS"]
+			for _, arch := range candidateArches(keywords, arches) {
+				since := keywordedSince(pkg, v, arch)
+				if since.IsZero() || now.Sub(since) < opts.MinAge {
+					continue
+				}
+				if ArchKeywordStatus(keywords, arch) != KeywordTesting || !r.depsStableOn(v.Ebuild, arch) {
+					continue
+				}
+				candidates = append(candidates, StabilizationCandidate{Package: key, Version: v.PVR(), Arch: arch, Since: since})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		if c := CompareVersions(a.Version, b.Version); c != 0 {
+			return c < 0
+		}
+		return a.Arch < b.Arch
+	})
+	return candidates
+}
+
+func sortedKeys(m map[string]*PackageData) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// candidateArches returns the arches of keywords that are in arches, or all of them when arches is empty.
+func candidateArches(keywords string, arches []string) []string {
+	allowed := map[string]bool{}
+	for _, a := range arches {
+		allowed[a] = true
+	}
+	var out []string
+	for _, kw := range strings.Fields(keywords) {
+		if !strings.HasPrefix(kw, "~") {
+			continue
+		}
+		if arch := kw[1:]; len(arches) == 0 || allowed[arch] {
+			out = append(out, arch)
+		}
+	}
+	return out
+}
+
+// hasNewerRevision reports whether pkg has a higher revision of the version of pvr.
+func hasNewerRevision(pkg *PackageData, pvr string) bool {
+	base := stripRevision(pvr)
+	for _, v := range pkg.Versions {
+		if other := v.PVR(); other != pvr && stripRevision(other) == base && CompareVersions(other, pvr) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// keywordedSince returns when v was added or keyworded ~arch.
+func keywordedSince(pkg *PackageData, v VersionData, arch string) time.Time {
+	pvr := v.PVR()
+	for _, c := range pkg.History { // newest first
+		if containsString(c.Added, pvr) || containsString(c.NewTesting[pvr], arch) {
+			return c.Date
+		}
+	}
+	if n := len(pkg.History); n > 0 {
+		return pkg.History[n-1].Date
+	}
+	return v.ModTime
+}
+
+// stabilityResolver finds the versions of dependencies in the site, then in its masters.
+type stabilityResolver struct {
+	packages map[string]*PackageData
+	masters  func(pkg string) []VersionData
+	external map[string][]VersionData
+}
+
+// versions returns the versions of the category/name package key, and false when it cannot tell.
+func (r *stabilityResolver) versions(key string) ([]VersionData, bool) {
+	if pkg, ok := r.packages[key]; ok {
+		return pkg.Versions, true
+	}
+	if r.masters == nil {
+		return nil, false
+	}
+	versions, ok := r.external[key]
+	if !ok {
+		versions = r.masters(key)
+		r.external[key] = versions
+	}
+	return versions, true
+}
+
+// depsStableOn reports whether every dependency of e has a stable version on arch. Any-of groups need
+// one stable member, USE conditional groups are treated as enabled and blockers are ignored.
+func (r *stabilityResolver) depsStableOn(e *Ebuild, arch string) bool {
+	for _, class := range DependencyClasses {
+		if !r.depNodesStable(ParseDepTree(e.Vars[class]).Nodes, arch) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *stabilityResolver) depNodesStable(nodes []DepNode, arch string) bool {
+	for _, n := range nodes {
+		if !r.depNodeStable(n, arch) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *stabilityResolver) depNodeStable(n DepNode, arch string) bool {
+	switch d := n.(type) {
+	case DepAnyOf:
+		for _, c := range d.Children {
+			if r.depNodeStable(c, arch) {
+				return true
+			}
+		}
+		return len(d.Children) == 0
+	case DepAllOf:
+		return r.depNodesStable(d.Children, arch)
+	case DepUseConditional:
+		return r.depNodesStable(d.Children, arch)
+	case DepString:
+		atom := ParsePackageAtom(string(d))
+		if atom.IsBlocker() || atom.Name == "" {
+			return true
+		}
+		versions, known := r.versions(atom.Key())
+		if !known {
+			return true
+		}
+		for _, v := range versions {
+			if v.Ebuild == nil || ArchKeywordStatus(v.Ebuild.Vars["KEYWORDS"], arch) != KeywordStable {
+				continue
+			}
+			// The repository stack is searched in order, so a ::repo restriction is not checked.
+			if atom.Matches(AtomCandidate{Category: atom.Category, Name: atom.Name, Version: v.PVR(), Slot: v.Ebuild.Vars["SLOT"], Repo: atom.Repo}) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package g2
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStabilizationCandidates(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := now.AddDate(0, 0, -60)
+
+	foo := PackageData{Category: "app-misc", Name: "foo", Versions: []VersionData{
+		testVersion("1.0", "~amd64 ~x86"),
+		testVersion("1.1", "~amd64"),
+		testVersion("2.0", "~amd64"),
+		testVersion("2.0-r1", "~amd64"),
+	}}
+	foo.Versions[0].Ebuild.Vars["RDEPEND"] = "dev-libs/bar || ( dev-libs/missing dev-libs/baz ) !dev-libs/old sys-libs/zlib::gentoo"
+	foo.Versions[1].Ebuild.Vars["RDEPEND"] = ">=dev-libs/bar-2"
+	foo.History = []PackageChange{
+		{Date: now.AddDate(0, 0, -5), Added: []string{"2.0-r1"}},
+		// Keywording 1.0 ~x86 does not make it younger on amd64.
+		{Date: now.AddDate(0, 0, -3), Keyworded: []string{"1.0"}, NewTesting: map[string][]string{"1.0": {"x86"}}},
+		{Date: now.AddDate(0, 0, -10), Keyworded: []string{"1.1"}, NewTesting: map[string][]string{"1.1": {"amd64"}}},
+		{Date: old, Added: []string{"1.0", "1.1", "2.0"}},
+	}
+	bar := PackageData{Category: "dev-libs", Name: "bar", Versions: []VersionData{
+		testVersion("1", "amd64 ~x86"),
+		testVersion("2", "~amd64"),
+	}}
+	baz := PackageData{Category: "dev-libs", Name: "baz", Versions: []VersionData{testVersion("1", "amd64 x86")}}
+	site := &SiteData{
+		Categories: []CategoryData{
+			{Name: "app-misc", Packages: []PackageData{foo}},
+			{Name: "dev-libs", Packages: []PackageData{bar, baz}},
+		},
+		ArchesDesc: &ArchesDesc{Arches: map[string]string{"amd64": "stable", "x86": "stable", "riscv": "testing"}},
+	}
+
+	got := StabilizationCandidates(site, StabilizationOptions{MinAge: 30 * 24 * time.Hour, Now: now})
+	var lines []string
+	for _, c := range got {
+		lines = append(lines, c.Package+"-"+c.Version+" "+c.Arch)
+	}
+	// 1.0 is blocked on x86 by dev-libs/bar, 1.1 was keyworded too recently and 2.0 has a newer revision.
+	if strings.Join(lines, ", ") != "app-misc/foo-1.0 amd64" {
+		t.Errorf("candidates = %v", lines)
+	}
+	if len(got) == 1 && got[0].Age(now) != 60*24*time.Hour {
+		t.Errorf("age = %s", got[0].Age(now))
+	}
+
+	got = StabilizationCandidates(site, StabilizationOptions{MinAge: 3 * 24 * time.Hour, Arches: []string{"amd64"}, Now: now})
+	lines = nil
+	for _, c := range got {
+		lines = append(lines, c.Package+"-"+c.Version)
+	}
+	// 1.1 needs dev-libs/bar-2, which is not stable.
+	if strings.Join(lines, ", ") != "app-misc/foo-1.0, app-misc/foo-2.0-r1" {
+		t.Errorf("candidates after three days = %v", lines)
+	}
+
+	// Dependencies outside the site are looked up in its masters.
+	masters := func(pkg string) []VersionData {
+		if pkg == "sys-libs/zlib" {
+			return []VersionData{testVersion("1.3-r1", "~amd64 x86")}
+		}
+		return nil
+	}
+	got = StabilizationCandidates(site, StabilizationOptions{MinAge: 30 * 24 * time.Hour, Arches: []string{"amd64"}, Now: now, Masters: masters})
+	if len(got) != 0 {
+		t.Errorf("candidates with an unstable master dependency = %+v", got)
+	}
+}
@@ -0,0 +1,171 @@
+package g2
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SubSlotAction describes what has to happen to a reverse dependency when a package changes SLOT or sub-slot.
+type SubSlotAction int
+
+const (
+	SubSlotRebuild SubSlotAction = iota + 1 // The package manager rebuilds the consumer automatically (slot operator)
+	SubSlotRevbump                          // The consumer pins the old slot or sub-slot and needs a manual revision bump
+)
+
+func (a SubSlotAction) String() string {
+	switch a {
+	case SubSlotRebuild:
+		return "rebuild"
+	case SubSlotRevbump:
+		return "revbump"
+	default:
+		return "unknown"
+	}
+}
+
+// SubSlotImpact records a single dependency that is affected by a SLOT or sub-slot change.
+type SubSlotImpact struct {
+	Repo       string // Repository the consumer lives in
+	EbuildPath string // Path of the consumer ebuild relative to the repository root
+	Category   string
+	Package    string
+	Version    string // PVR of the consumer
+	Class      string // Dependency class, e.g. DEPEND or RDEPEND
+	Dependency string // The dependency atom as written (after variable resolution)
+	Action     SubSlotAction
+	Reason     string
+}
+
+// SubSlotImpactClasses lists the dependency classes in which slot operators are meaningful.
+var SubSlotImpactClasses = []string{"DEPEND", "RDEPEND"}
+
+// SplitSlot splits a "slot/subslot" string. If no sub-slot is given it defaults to the slot, as per PMS.
+func SplitSlot(s string) (slot, subSlot string, err error) {
+	if err := validateSlotSpec(s); err != nil {
+		return "", "", err
+	}
+	if s == "*" || strings.HasSuffix(s, "=") {
+		return "", "", fmt.Errorf("invalid slot %q: slot operators are not permitted here", s)
+	}
+	slot, subSlot, found := strings.Cut(s, "/")
+	if !found {
+		subSlot = slot
+	}
+	return slot, subSlot, nil
+}
+
+// ClassifySlotDependency determines how a dependency's slot specification reacts to the target package
+// moving to newSlot/newSubSlot. The boolean result is false when the dependency is unaffected.
+func ClassifySlotDependency(atom PackageAtom, newSlot, newSubSlot string) (SubSlotAction, string, bool) {
+	spec := atom.Slot
+	if spec == "" || spec == "*" {
+		return 0, "", false
+	}
+	if spec == "=" {
+		return SubSlotRebuild, "slot operator := records the sub-slot at build time", true
+	}
+
+	hasOperator := strings.HasSuffix(spec, "=")
+	base := strings.TrimSuffix(spec, "=")
+	slot, subSlot, hasSubSlot := strings.Cut(base, "/")
+
+	if slot != newSlot {
+		return SubSlotRevbump, fmt.Sprintf("pins slot %s but the new slot is %s", slot, newSlot), true
+	}
+	if hasSubSlot && subSlot != newSubSlot {
+		return SubSlotRevbump, fmt.Sprintf("pins sub-slot %s but the new sub-slot is %s", subSlot, newSubSlot), true
+	}
+	if hasOperator && !hasSubSlot {
+		return SubSlotRebuild, fmt.Sprintf("slot operator :%s= records the sub-slot at build time", slot), true
+	}
+	return 0, "", false
+}
+
+// FindSubSlotImpactFS scans every ebuild in the repository rooted at fsys for DEPEND and RDEPEND entries
+// on target and classifies them against the new slot and sub-slot. Ebuilds of the target package itself are skipped,
+// as are ebuilds that fail to parse, with a warning.
+func FindSubSlotImpactFS(fsys fs.FS, repoName string, target PackageAtom, newSlot, newSubSlot string) ([]SubSlotImpact, error) {
+	if target.Category == "" || target.Name == "" {
+		return nil, fmt.Errorf("target atom must be in category/package form")
+	}
+	targetKey := target.Category + "/" + target.Name
+
+	ebuilds, err := fs.Glob(fsys, "*/*/*.ebuild")
+	if err != nil {
+		return nil, fmt.Errorf("listing ebuilds: %w", err)
+	}
+	sort.Strings(ebuilds)
+
+	var impacts []SubSlotImpact
+	for _, p := range ebuilds {
+		pkgDir := path.Dir(p)
+		category := path.Dir(pkgDir)
+		pkgName := path.Base(pkgDir)
+		if strings.HasPrefix(category, ".") || category == "eclass" || category == "profiles" || category == "metadata" {
+			continue
+		}
+		if category+"/"+pkgName == targetKey {
+			continue
+		}
+
+		e, err := ParseEbuild(fsys, p, ParseVariables)
+		if err != nil {
+			log.Printf("Warning: skipping %s in %s: %v", p, repoName, err)
+			continue
+		}
+
+		for _, class := range SubSlotImpactClasses {
+			deps, _ := ParseDepTree(e.Vars[class]).Evaluate(IgnoreUseFlags(true))
+			for _, dep := range deps {
+				if strings.HasPrefix(dep, "!") {
+					continue
+				}
+				atom := ParsePackageAtom(dep)
+				if atom.Category != target.Category || atom.Name != target.Name {
+					continue
+				}
+				action, reason, affected := ClassifySlotDependency(atom, newSlot, newSubSlot)
+				if !affected {
+					continue
+				}
+				impacts = append(impacts, SubSlotImpact{
+					Repo:       repoName,
+					EbuildPath: p,
+					Category:   category,
+					Package:    pkgName,
+					Version:    e.Vars["PVR"],
+					Class:      class,
+					Dependency: dep,
+					Action:     action,
+					Reason:     reason,
+				})
+			}
+		}
+	}
+	return impacts, nil
+}
+
+// RewriteSlotDependency returns dep with its slot specification updated to newSlot/newSubSlot.
+// The slot operator and the presence of an explicit sub-slot are preserved.
+func RewriteSlotDependency(dep string, newSlot, newSubSlot string) string {
+	atom := ParsePackageAtom(dep)
+	if atom.Slot == "" || atom.Slot == "*" || atom.Slot == "=" {
+		return dep
+	}
+	hasOperator := strings.HasSuffix(atom.Slot, "=")
+	base := strings.TrimSuffix(atom.Slot, "=")
+	spec := newSlot
+	if strings.Contains(base, "/") {
+		spec += "/" + newSubSlot
+	}
+	if hasOperator {
+		spec += "="
+	}
+	atom.Slot = spec
+	return atom.String()
+}
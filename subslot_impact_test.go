@@ -0,0 +1,118 @@
+package g2
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitSlot(t *testing.T) {
+	tests := []struct {
+		in      string
+		slot    string
+		subSlot string
+		wantErr bool
+	}{
+		{"0", "0", "0", false},
+		{"3/3.1", "3", "3.1", false},
+		{"0=", "", "", true},
+		{"*", "", "", true},
+		{"0/", "", "", true},
+	}
+	for _, tt := range tests {
+		slot, subSlot, err := SplitSlot(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SplitSlot(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if slot != tt.slot || subSlot != tt.subSlot {
+			t.Errorf("SplitSlot(%q) = %q, %q; want %q, %q", tt.in, slot, subSlot, tt.slot, tt.subSlot)
+		}
+	}
+}
+
+func TestClassifySlotDependency(t *testing.T) {
+	tests := []struct {
+		dep      string
+		affected bool
+		action   SubSlotAction
+	}{
+		{"dev-libs/foo", false, 0},
+		{"dev-libs/foo:*", false, 0},
+		{"dev-libs/foo:=", true, SubSlotRebuild},
+		{"dev-libs/foo:0=", true, SubSlotRebuild},
+		{"dev-libs/foo:1=", true, SubSlotRevbump},
+		{"dev-libs/foo:0", false, 0},
+		{"dev-libs/foo:1", true, SubSlotRevbump},
+		{"dev-libs/foo:0/2", false, 0},
+		{"dev-libs/foo:0/1=", true, SubSlotRevbump},
+		{">=dev-libs/foo-1.0:0/1[ssl]", true, SubSlotRevbump},
+	}
+	for _, tt := range tests {
+		action, _, affected := ClassifySlotDependency(ParsePackageAtom(tt.dep), "0", "2")
+		if affected != tt.affected || action != tt.action {
+			t.Errorf("ClassifySlotDependency(%q) = %v, %v; want %v, %v", tt.dep, action, affected, tt.action, tt.affected)
+		}
+	}
+}
+
+func TestFindSubSlotImpactFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dev-libs/foo/foo-2.0.ebuild": &fstest.MapFile{Data: []byte("EAPI=8\nSLOT=\"0/2\"\n")},
+		"app-misc/auto/auto-1.0.ebuild": &fstest.MapFile{
+			Data: []byte("EAPI=8\nRDEPEND=\"dev-libs/foo:=\"\nDEPEND=\"${RDEPEND}\"\n"),
+		},
+		"app-misc/pinned/pinned-1.0-r1.ebuild": &fstest.MapFile{
+			Data: []byte("EAPI=8\nRDEPEND=\"ssl? ( dev-libs/foo:0/1= )\"\n"),
+		},
+		"app-misc/plain/plain-1.0.ebuild": &fstest.MapFile{
+			Data: []byte("EAPI=8\nRDEPEND=\"dev-libs/foo !dev-libs/foo:0/1\"\n"),
+		},
+		"app-misc/broken/broken-1.0.ebuild": &fstest.MapFile{
+			Data: []byte("EAPI=8\nRDEPEND=\"dev-libs/foo:=\n"),
+		},
+	}
+
+	impacts, err := FindSubSlotImpactFS(fsys, "test", ParsePackageAtom("dev-libs/foo"), "0", "2")
+	if err != nil {
+		t.Fatalf("FindSubSlotImpactFS failed: %v", err)
+	}
+	if len(impacts) != 3 {
+		t.Fatalf("expected 3 impacts, got %d: %+v", len(impacts), impacts)
+	}
+
+	var rebuild, revbump int
+	for _, im := range impacts {
+		switch im.Action {
+		case SubSlotRebuild:
+			rebuild++
+			if im.Package != "auto" {
+				t.Errorf("unexpected rebuild impact: %+v", im)
+			}
+		case SubSlotRevbump:
+			revbump++
+			if im.Package != "pinned" || im.Version != "1.0-r1" || im.Class != "RDEPEND" {
+				t.Errorf("unexpected revbump impact: %+v", im)
+			}
+		}
+	}
+	if rebuild != 2 || revbump != 1 {
+		t.Errorf("expected 2 rebuild and 1 revbump impacts, got %d and %d", rebuild, revbump)
+	}
+}
+
+func TestRewriteSlotDependency(t *testing.T) {
+	tests := map[string]string{
+		"dev-libs/foo:0/1=":            "dev-libs/foo:0/2=",
+		"dev-libs/foo:1=":              "dev-libs/foo:0=",
+		"dev-libs/foo:1":               "dev-libs/foo:0",
+		">=dev-libs/foo-1.0:0/1[ssl]":  ">=dev-libs/foo-1.0:0/2[ssl]",
+		"dev-libs/foo:=":               "dev-libs/foo:=",
+		"dev-libs/foo::gentoo":         "dev-libs/foo::gentoo",
+		"dev-libs/foo:0/1::gentoo[-x]": "dev-libs/foo:0/2::gentoo[-x]",
+	}
+	for in, want := range tests {
+		if got := RewriteSlotDependency(in, "0", "2"); got != want {
+			t.Errorf("RewriteSlotDependency(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
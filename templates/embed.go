@@ -9,3 +9,6 @@ var EbuildFS embed.FS
 
 //go:embed app/*.html partials/*.html views/*.html site/*.xml site/*.js
 var SiteFS embed.FS
+
+//go:embed api/v1/*.schema.json
+var APIFS embed.FS
@@ -357,4 +357,122 @@ class SearchEngine {
 
         return false;
     }
+
+    async loadNames() {
+        if (this.names) return this.names;
+        try {
+            const res = await fetch('data/names.json');
+            this.names = res.ok ? await res.json() : [];
+        } catch (e) {
+            this.names = [];
+        }
+        return this.names;
+    }
+
+    async loadFacets() {
+        if (this.allFacets) return this.allFacets;
+        try {
+            const res = await fetch('data/facets.json');
+            this.allFacets = res.ok ? await res.json() : [];
+        } catch (e) {
+            this.allFacets = [];
+        }
+        return this.allFacets;
+    }
+
+    // editDistance returns the Levenshtein distance of a and b, counting a swap of neighbouring
+    // letters as one edit, or max + 1 once it must exceed max.
+    editDistance(a, b, max) {
+        if (Math.abs(a.length - b.length) > max) return max + 1;
+        let prev2 = [];
+        let prev = Array.from({ length: b.length + 1 }, (_, j) => j);
+        for (let i = 1; i <= a.length; i++) {
+            const cur = [i];
+            let rowMin = i;
+            for (let j = 1; j <= b.length; j++) {
+                const cost = a[i - 1] === b[j - 1] ? 0 : 1;
+                cur[j] = Math.min(prev[j] + 1, cur[j - 1] + 1, prev[j - 1] + cost);
+                if (i > 1 && j > 1 && a[i - 1] === b[j - 2] && a[i - 2] === b[j - 1]) {
+                    cur[j] = Math.min(cur[j], prev2[j - 2] + 1);
+                }
+                rowMin = Math.min(rowMin, cur[j]);
+            }
+            if (rowMin > max) return max + 1;
+            prev2 = prev;
+            prev = cur;
+        }
+        return prev[b.length];
+    }
+
+    // suggest returns up to three "did you mean" queries for a query that found nothing, replacing each
+    // plain term that names no package with the closest package names, as g2 package search does.
+    async suggest(query) {
+        const ast = new SearchParser(query).parse();
+        const terms = [];
+        const walk = (node) => {
+            if (!node) return;
+            if (node.type === 'AND' || node.type === 'OR') { walk(node.left); walk(node.right); }
+            if (node.type === 'GROUP') walk(node.expr);
+            if (node.type === 'TERM') terms.push(node.value.toLowerCase());
+        };
+        walk(ast);
+
+        const names = await this.loadNames();
+        const corrections = [];
+        for (const term of terms) {
+            if (term.length < 3 || /[*?]/.test(term)) continue;
+            if (names.some(n => n === term || n.endsWith('/' + term))) continue;
+            const max = term.length <= 4 ? 1 : (term.length <= 8 ? 2 : 3);
+            const best = new Map();
+            for (const name of names) {
+                const pkg = name.substring(name.indexOf('/') + 1);
+                const candidate = term.includes('/') ? name : pkg;
+                const d = this.editDistance(term, candidate, max);
+                if (d <= max && (!best.has(candidate) || best.get(candidate) > d)) best.set(candidate, d);
+            }
+            const choices = [...best.entries()]
+                .sort((a, b) => a[1] - b[1] || a[0].localeCompare(b[0]))
+                .slice(0, 3)
+                .map(e => e[0]);
+            if (choices.length > 0) corrections.push({ old: term, choices });
+        }
+        if (corrections.length === 0) return [];
+
+        const replace = (q, old, replacement) => q.split(/\s+/).map(word => {
+            const m = word.match(/^([-!(]*)(.*?)(\)*)$/);
+            return m && m[2].toLowerCase() === old ? m[1] + replacement + m[3] : word;
+        }).join(' ');
+        const out = [];
+        for (const choice of corrections[0].choices) {
+            let s = replace(query.trim(), corrections[0].old, choice);
+            for (const c of corrections.slice(1)) s = replace(s, c.old, c.choices[0]);
+            if (s !== query.trim() && !out.includes(s)) out.push(s);
+        }
+        return out;
+    }
+
+    // facets counts the results holding each category, overlay, license, EAPI, arch and mask value,
+    // in the form of data/facets.json.
+    facets(results) {
+        const fields = [
+            ['Category', 'category', d => [d.category]],
+            ['Overlay', 'overlay', d => [d.overlay]],
+            ['License', 'license', d => d.licenses || []],
+            ['EAPI', 'eapi', d => [d.eapi]],
+            ['Arch', 'arch', d => d.arches || []],
+            ['Mask', 'mask', d => [d.mask]],
+        ];
+        return fields.map(([name, field, values]) => {
+            const counts = new Map();
+            for (const doc of results) {
+                for (const v of new Set(values(doc))) {
+                    if (v) counts.set(v, (counts.get(v) || 0) + 1);
+                }
+            }
+            const list = [...counts.entries()]
+                .map(([value, count]) => ({ value, count }))
+                .sort((a, b) => b.count - a.count || a.value.localeCompare(b.value));
+            return { name, field, values: list };
+        });
+    }
 }
@@ -4,6 +4,7 @@ document.addEventListener('DOMContentLoaded', async () => {
     const searchResults = document.getElementById('searchResults');
     const searchDocs = document.getElementById('searchDocs');
     const resultsCount = document.getElementById('searchResultsCount');
+    const searchFacets = document.getElementById('searchFacets');
 
     const urlParams = new URLSearchParams(window.location.search);
     const initialQuery = urlParams.get('q');
@@ -12,26 +13,66 @@ document.addEventListener('DOMContentLoaded', async () => {
 
     searchForm.addEventListener('submit', async (e) => {
         e.preventDefault();
-        const query = searchInput.value;
+        await searchFor(searchInput.value);
+    });
+
+    async function searchFor(query) {
+        searchInput.value = query;
         await performSearch(query);
 
         // Update URL
         const newUrl = new URL(window.location);
         newUrl.searchParams.set('q', query);
         window.history.pushState({}, '', newUrl);
-    });
+    }
+
+    function queryLink(query) {
+        const link = document.createElement('a');
+        link.href = `?q=${encodeURIComponent(query)}`;
+        link.textContent = query;
+        link.addEventListener('click', async (e) => {
+            e.preventDefault();
+            await searchFor(query);
+        });
+        return link;
+    }
+
+    // renderFacets lists the most common values of each facet; a value narrows the query to it.
+    function renderFacets(facets, query) {
+        searchFacets.innerHTML = '';
+        facets.forEach(facet => {
+            if (!facet.values || facet.values.length === 0) return;
+            const p = document.createElement('p');
+            p.style.margin = '0 0 0.3em 0';
+            const label = document.createElement('strong');
+            label.textContent = `${facet.name}: `;
+            p.appendChild(label);
+            facet.values.slice(0, 10).forEach((v, index) => {
+                const term = `${facet.field}:${v.value}`;
+                const link = queryLink(query ? `${query} ${term}` : term);
+                link.textContent = `${v.value} (${v.count})`;
+                p.appendChild(link);
+                if (index < Math.min(facet.values.length, 10) - 1) {
+                    p.appendChild(document.createTextNode(' '));
+                }
+            });
+            searchFacets.appendChild(p);
+        });
+    }
 
     async function performSearch(query) {
         if (!query || !query.trim()) {
             searchResults.innerHTML = '';
             resultsCount.textContent = '';
             searchDocs.style.display = 'block';
+            renderFacets(await engine.loadFacets(), '');
             return;
         }
 
         searchDocs.style.display = 'none';
         resultsCount.textContent = 'Searching...';
         searchResults.innerHTML = '';
+        searchFacets.innerHTML = '';
 
         await engine.init();
 
@@ -42,9 +83,22 @@ document.addEventListener('DOMContentLoaded', async () => {
 
         if (results.length === 0) {
             searchResults.innerHTML = '<p>No results found for your query.</p>';
+            const suggestions = await engine.suggest(query);
+            if (suggestions.length > 0) {
+                const p = document.createElement('p');
+                p.appendChild(document.createTextNode('Did you mean: '));
+                suggestions.forEach((suggestion, index) => {
+                    if (index > 0) p.appendChild(document.createTextNode(', '));
+                    p.appendChild(queryLink(suggestion));
+                });
+                p.appendChild(document.createTextNode('?'));
+                searchResults.appendChild(p);
+            }
             return;
         }
 
+        renderFacets(engine.facets(results), query.trim());
+
         const fragment = document.createDocumentFragment();
 
         results.forEach(doc => {
@@ -138,5 +192,8 @@ document.addEventListener('DOMContentLoaded', async () => {
     if (initialQuery) {
         searchInput.value = initialQuery;
         await performSearch(initialQuery);
+    } else {
+        await engine.init();
+        renderFacets(await engine.loadFacets(), '');
     }
 });
@@ -0,0 +1,105 @@
+package g2
+
+import (
+	"strings"
+)
+
+// maxUpdateChain bounds how many chained moves are followed for one atom, guarding against move cycles.
+const maxUpdateChain = 32
+
+// RepoPackageUpdate pairs the profiles/updates entries of a repository with its name.
+type RepoPackageUpdate struct {
+	Repo   string
+	Update *PackageUpdate
+}
+
+// ApplyToAtom applies the moves and slotmoves of u to atom. Moves are followed until no more apply, so
+// the result does not depend on the order of the updates files. Blocker, version, slot, repository and
+// USE dependency parts of the atom are kept. It reports whether the atom changed.
+func (u *PackageUpdate) ApplyToAtom(atom PackageAtom) (PackageAtom, bool) {
+	if u == nil || atom.Category == "" || atom.Name == "" {
+		return atom, false
+	}
+	changed := false
+
+	for i := 0; i < maxUpdateChain; i++ {
+		moved := false
+		for _, m := range u.Moves {
+			oldCat, oldName, ok := strings.Cut(m.Old, "/")
+			if !ok || oldCat != atom.Category || oldName != atom.Name {
+				continue
+			}
+			newCat, newName, ok := strings.Cut(m.New, "/")
+			if !ok {
+				continue
+			}
+			atom.Category, atom.Name = newCat, newName
+			moved = true
+			changed = true
+			break
+		}
+		if !moved {
+			break
+		}
+	}
+
+	if atom.Slot != "" {
+		slot, rest := atom.Slot, ""
+		if idx := strings.IndexAny(slot, "/="); idx != -1 {
+			slot, rest = slot[:idx], slot[idx:]
+		}
+		for _, m := range u.SlotMoves {
+			target := ParsePackageAtom(m.Package)
+			if !target.MatchesName(atom.Category, atom.Name) || slot != m.Old {
+				continue
+			}
+			if target.Version != "" && atom.Version != "" && !target.Matches(AtomCandidate{Category: atom.Category, Name: atom.Name, Version: atom.Version, Slot: slot}) {
+				continue
+			}
+			atom.Slot = m.New + rest
+			changed = true
+			break
+		}
+	}
+	return atom, changed
+}
+
+// ApplyRepoUpdatesToAtom applies the updates of every repository to atom. Atoms qualified with a
+// repository only receive the updates of that repository.
+func ApplyRepoUpdatesToAtom(updates []RepoPackageUpdate, atom PackageAtom) (PackageAtom, bool) {
+	changed := false
+	for _, ru := range updates {
+		if atom.Repo != "" && atom.Repo != ru.Repo {
+			continue
+		}
+		var c bool
+		if atom, c = ru.Update.ApplyToAtom(atom); c {
+			changed = true
+		}
+	}
+	return atom, changed
+}
+
+// RewriteConfigLineAtom replaces the leading atom of a configuration line using fn, keeping indentation,
+// trailing tokens and inline comments. Blank lines, comments and set references are returned unchanged.
+func RewriteConfigLineAtom(line string, fn func(PackageAtom) (PackageAtom, bool)) (string, bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "@") {
+		return line, false
+	}
+	end := strings.IndexAny(trimmed, " \t#")
+	if end == -1 {
+		end = len(trimmed)
+	}
+	token := trimmed[:end]
+	atom := ParsePackageAtom(token)
+	if atom.Category == "" || atom.Name == "" {
+		return line, false
+	}
+	updated, changed := fn(atom)
+	if !changed {
+		return line, false
+	}
+	indent := line[:len(line)-len(trimmed)]
+	return indent + updated.String() + trimmed[end:], true
+}
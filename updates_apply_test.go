@@ -0,0 +1,48 @@
+package g2
+
+import "testing"
+
+func TestApplyRepoUpdatesToAtom(t *testing.T) {
+	updates := []RepoPackageUpdate{
+		{Repo: "gentoo", Update: &PackageUpdate{
+			Moves:     []PackageMove{{Old: "dev-libs/new", New: "dev-libs/newer"}, {Old: "dev-libs/old", New: "dev-libs/new"}},
+			SlotMoves: []PackageSlotMove{{Package: "dev-libs/newer", Old: "1", New: "2"}},
+		}},
+		{Repo: "guru", Update: &PackageUpdate{Moves: []PackageMove{{Old: "app-misc/foo", New: "app-misc/bar"}}}},
+	}
+	tests := []struct {
+		in, want string
+	}{
+		{">=dev-libs/old-1.0:1/1.0=::gentoo[ssl]", ">=dev-libs/newer-1.0:2/1.0=::gentoo[ssl]"},
+		{"dev-libs/old:0", "dev-libs/newer:0"},
+		{"app-misc/foo::guru", "app-misc/bar::guru"},
+		{"app-misc/foo::gentoo", "app-misc/foo::gentoo"},
+		{"!!app-misc/foo", "!!app-misc/bar"},
+	}
+	for _, tt := range tests {
+		got, _ := ApplyRepoUpdatesToAtom(updates, ParsePackageAtom(tt.in))
+		if got.String() != tt.want {
+			t.Errorf("ApplyRepoUpdatesToAtom(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRewriteConfigLineAtom(t *testing.T) {
+	u := &PackageUpdate{Moves: []PackageMove{{Old: "app-misc/foo", New: "app-misc/bar"}}}
+	tests := []struct {
+		in, want string
+		changed  bool
+	}{
+		{"app-misc/foo ssl -X # keep me", "app-misc/bar ssl -X # keep me", true},
+		{"  =app-misc/foo-1*\t~amd64", "  =app-misc/bar-1*\t~amd64", true},
+		{"# app-misc/foo", "# app-misc/foo", false},
+		{"@foo-set", "@foo-set", false},
+		{"app-misc/other", "app-misc/other", false},
+	}
+	for _, tt := range tests {
+		got, changed := RewriteConfigLineAtom(tt.in, u.ApplyToAtom)
+		if got != tt.want || changed != tt.changed {
+			t.Errorf("RewriteConfigLineAtom(%q) = %q, %v; want %q, %v", tt.in, got, changed, tt.want, tt.changed)
+		}
+	}
+}
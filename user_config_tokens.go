@@ -0,0 +1,223 @@
+package g2
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// UserConfigTokenEntry is a line of a token carrying user configuration file such as package.use,
+// package.accept_keywords, package.license or package.env.
+type UserConfigTokenEntry struct {
+	FilePath   string
+	LineNumber int
+	Atom       string
+	Tokens     []string
+}
+
+// ReadUserConfigTokens returns the atom and trailing tokens of every entry under configPath.
+func ReadUserConfigTokens(configPath string) ([]UserConfigTokenEntry, error) {
+	entries, err := ReadUserConfigEntries(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var res []UserConfigTokenEntry
+	for _, e := range entries {
+		_, _, tokens, _ := splitTokenLine(e.RawLine)
+		res = append(res, UserConfigTokenEntry{
+			FilePath:   e.FilePath,
+			LineNumber: e.LineNumber,
+			Atom:       e.AtomString,
+			Tokens:     tokens,
+		})
+	}
+	return res, nil
+}
+
+// splitTokenLine splits a configuration line into indentation, atom, tokens and the trailing comment
+// including the whitespace in front of it.
+func splitTokenLine(line string) (indent, atom string, tokens []string, comment string) {
+	content := line
+	if idx := strings.Index(content, "#"); idx != -1 {
+		content, comment = line[:idx], line[idx:]
+		trimmed := strings.TrimRight(content, " \t")
+		comment = content[len(trimmed):] + comment
+		content = trimmed
+	}
+	trimmed := strings.TrimLeft(content, " \t")
+	indent = content[:len(content)-len(trimmed)]
+	fields := strings.Fields(trimmed)
+	if len(fields) == 0 {
+		return indent, "", nil, comment
+	}
+	return indent, fields[0], fields[1:], comment
+}
+
+// negatedToken returns the counterpart of a token that can be negated with a leading "-".
+func negatedToken(token string) string {
+	if strings.HasPrefix(token, "-") {
+		return token[1:]
+	}
+	return "-" + token
+}
+
+// MergeConfigTokens adds and removes tokens from an existing token list, keeping its order. Adding a
+// token drops its negated counterpart, so adding "-foo" to "foo bar" yields "bar -foo".
+//
+// A token ending in ":", such as "PYTHON_TARGETS:" in package.use, starts a USE_EXPAND group holding the
+// tokens after it. Tokens are added to and removed from their own group: plain tokens go before the first
+// group, and the tokens after a group name in add or remove go to that group, which is appended when the
+// list has none.
+func MergeConfigTokens(tokens, add, remove []string) []string {
+	groups := splitTokenGroups(tokens)
+	addGroups := splitTokenGroups(add)
+	removeGroups := splitTokenGroups(remove)
+
+	merged := make(map[string]bool)
+	for _, g := range addGroups {
+		if !slices.ContainsFunc(groups, func(e tokenGroup) bool { return e.name == g.name }) {
+			groups = append(groups, tokenGroup{name: g.name})
+		}
+	}
+	var res []string
+	for _, g := range groups {
+		var groupAdd, groupRemove []string
+		if !merged[g.name] {
+			merged[g.name] = true
+			groupAdd, groupRemove = tokenGroupTokens(addGroups, g.name), tokenGroupTokens(removeGroups, g.name)
+		}
+		kept := mergeTokenGroup(g.tokens, groupAdd, groupRemove)
+		if g.name != "" && len(kept) > 0 {
+			res = append(res, g.name)
+		}
+		res = append(res, kept...)
+	}
+	return res
+}
+
+// tokenGroup is a run of tokens in a token list; name is the USE_EXPAND group name ending in ":" that
+// starts it, or "" for the plain tokens before the first group.
+type tokenGroup struct {
+	name   string
+	tokens []string
+}
+
+// splitTokenGroups splits tokens at every USE_EXPAND group name. The first group holds the plain tokens.
+func splitTokenGroups(tokens []string) []tokenGroup {
+	groups := []tokenGroup{{}}
+	for _, t := range tokens {
+		if len(t) > 1 && strings.HasSuffix(t, ":") {
+			groups = append(groups, tokenGroup{name: t})
+			continue
+		}
+		groups[len(groups)-1].tokens = append(groups[len(groups)-1].tokens, t)
+	}
+	return groups
+}
+
+// tokenGroupTokens returns the tokens of every group of groups named name.
+func tokenGroupTokens(groups []tokenGroup, name string) []string {
+	var tokens []string
+	for _, g := range groups {
+		if g.name == name {
+			tokens = append(tokens, g.tokens...)
+		}
+	}
+	return tokens
+}
+
+// mergeTokenGroup adds and removes the tokens of a single group as described for MergeConfigTokens.
+func mergeTokenGroup(tokens, add, remove []string) []string {
+	drop := make(map[string]bool)
+	for _, t := range remove {
+		drop[t] = true
+	}
+	for _, t := range add {
+		drop[negatedToken(t)] = true
+		delete(drop, t)
+	}
+	var res []string
+	seen := make(map[string]bool)
+	for _, t := range tokens {
+		if drop[t] || seen[t] {
+			continue
+		}
+		seen[t] = true
+		res = append(res, t)
+	}
+	for _, t := range add {
+		if !seen[t] {
+			seen[t] = true
+			res = append(res, t)
+		}
+	}
+	return res
+}
+
+// UpdateUserConfigTokens adds and removes tokens for atomStr under configPath. The last line for the
+// atom, which is the one that takes effect, is updated in place keeping indentation and comments; the
+// line is dropped when no tokens remain. When no line exists a new one is appended to the target file
+// chosen as in AddUserConfigAtom. It returns whether anything changed and the file that was considered.
+func UpdateUserConfigTokens(configPath, atomStr string, add, remove []string) (bool, string, error) {
+	existing, err := ReadUserConfigEntries(configPath)
+	if err != nil {
+		return false, "", fmt.Errorf("checking existing entries in %s: %w", configPath, err)
+	}
+
+	var match *UserConfigEntry
+	for i := range existing {
+		if existing[i].AtomString == atomStr {
+			match = &existing[i]
+		}
+	}
+
+	if match == nil {
+		tokens := MergeConfigTokens(nil, add, remove)
+		if len(tokens) == 0 {
+			return false, "", nil
+		}
+		line := atomStr + " " + strings.Join(tokens, " ")
+		// AddUserConfigAtom only checks for the exact line, which cannot exist as the atom has no entry.
+		return AddUserConfigAtom(configPath, line)
+	}
+
+	info, err := os.Stat(match.FilePath)
+	if err != nil {
+		return false, "", fmt.Errorf("stat %s: %w", match.FilePath, err)
+	}
+	data, err := os.ReadFile(match.FilePath)
+	if err != nil {
+		return false, "", fmt.Errorf("reading %s: %w", match.FilePath, err)
+	}
+	lines := strings.Split(string(data), "\n")
+	idx := match.LineNumber - 1
+	if idx >= len(lines) {
+		return false, "", fmt.Errorf("%s changed while reading", match.FilePath)
+	}
+	cr := strings.HasSuffix(lines[idx], "\r")
+	indent, atom, tokens, comment := splitTokenLine(strings.TrimSuffix(lines[idx], "\r"))
+	merged := MergeConfigTokens(tokens, add, remove)
+	if strings.Join(merged, " ") == strings.Join(tokens, " ") {
+		return false, match.FilePath, nil
+	}
+
+	if len(merged) == 0 {
+		if strings.TrimSpace(comment) != "" {
+			// Keep the comment so that no explanation is lost with the entry.
+			lines[idx] = indent + strings.TrimLeft(comment, " \t")
+		} else {
+			lines = append(lines[:idx], lines[idx+1:]...)
+		}
+	} else {
+		lines[idx] = indent + atom + " " + strings.Join(merged, " ") + comment
+		if cr {
+			lines[idx] += "\r"
+		}
+	}
+
+	if err := SafeWriteFileAtomic(match.FilePath, []byte(strings.Join(lines, "\n")), info.Mode().Perm()); err != nil {
+		return false, "", fmt.Errorf("writing %s: %w", match.FilePath, err)
+	}
+	return true, match.FilePath, nil
+}
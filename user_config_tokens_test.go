@@ -0,0 +1,95 @@
+package g2
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeConfigTokens(t *testing.T) {
+	got := MergeConfigTokens([]string{"foo", "-bar", "baz", "baz"}, []string{"bar", "-foo", "new"}, []string{"baz"})
+	if strings.Join(got, " ") != "bar -foo new" {
+		t.Errorf("unexpected tokens: %v", got)
+	}
+}
+
+func TestMergeConfigTokensExpandGroups(t *testing.T) {
+	tokens := []string{"-bar", "PYTHON_TARGETS:", "python3_12", "-python3_11", "VIDEO_CARDS:", "amdgpu"}
+	tests := []struct {
+		add, remove []string
+		want        string
+	}{
+		{[]string{"ssl"}, nil, "-bar ssl PYTHON_TARGETS: python3_12 -python3_11 VIDEO_CARDS: amdgpu"},
+		{[]string{"bar", "PYTHON_TARGETS:", "python3_11"}, nil, "bar PYTHON_TARGETS: python3_12 python3_11 VIDEO_CARDS: amdgpu"},
+		{[]string{"L10N:", "de"}, nil, "-bar PYTHON_TARGETS: python3_12 -python3_11 VIDEO_CARDS: amdgpu L10N: de"},
+		{nil, []string{"VIDEO_CARDS:", "amdgpu"}, "-bar PYTHON_TARGETS: python3_12 -python3_11"},
+		{[]string{"python3_12"}, nil, "-bar python3_12 PYTHON_TARGETS: python3_12 -python3_11 VIDEO_CARDS: amdgpu"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(MergeConfigTokens(tokens, tt.add, tt.remove), " "); got != tt.want {
+			t.Errorf("MergeConfigTokens(+%v -%v) = %q, want %q", tt.add, tt.remove, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateUserConfigTokens(t *testing.T) {
+	dir := t.TempDir()
+	useDir := filepath.Join(dir, "package.use")
+	if err := os.MkdirAll(useDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	existing := filepath.Join(useDir, "local")
+	if err := os.WriteFile(existing, []byte("# my flags\napp-misc/foo -bar baz  # why\n=cat/pkg-1* x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, file, err := UpdateUserConfigTokens(useDir, "app-misc/foo", []string{"bar", "qux"}, nil)
+	if err != nil || !changed || file != existing {
+		t.Fatalf("unexpected result: %v %s %v", changed, file, err)
+	}
+	data, _ := os.ReadFile(existing)
+	if string(data) != "# my flags\napp-misc/foo baz bar qux  # why\n=cat/pkg-1* x\n" {
+		t.Errorf("unexpected content:\n%s", data)
+	}
+	if info, _ := os.Stat(existing); info.Mode().Perm() != 0600 {
+		t.Errorf("mode not preserved: %v", info.Mode())
+	}
+
+	if changed, _, _ := UpdateUserConfigTokens(useDir, "app-misc/foo", []string{"qux"}, nil); changed {
+		t.Errorf("expected no change when token is already present")
+	}
+
+	if _, _, err := UpdateUserConfigTokens(useDir, "=cat/pkg-1*", nil, []string{"x"}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(existing)
+	if strings.Contains(string(data), "cat/pkg") {
+		t.Errorf("expected line without tokens to be removed:\n%s", data)
+	}
+
+	changed, file, err = UpdateUserConfigTokens(useDir, "dev-libs/new", []string{"-static"}, nil)
+	if err != nil || !changed || file != filepath.Join(useDir, "g2.conf") {
+		t.Fatalf("unexpected result for new entry: %v %s %v", changed, file, err)
+	}
+	entries, err := ReadUserConfigTokens(useDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Atom != "dev-libs/new" || strings.Join(entries[1].Tokens, " ") != "baz bar qux" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestUpdateUserConfigTokensExpandGroup(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "package.use")
+	if err := os.WriteFile(file, []byte("app/foo X PYTHON_TARGETS: python3_12\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := UpdateUserConfigTokens(file, "app/foo", []string{"ssl"}, nil); err != nil {
+		t.Fatalf("UpdateUserConfigTokens: %v", err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "app/foo X ssl PYTHON_TARGETS: python3_12\n" {
+		t.Errorf("ssl was not added before the PYTHON_TARGETS group:\n%s", data)
+	}
+}
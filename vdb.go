@@ -0,0 +1,266 @@
+package g2
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultVdbRoot is the location of the installed package database on a Gentoo system.
+const DefaultVdbRoot = "/var/db/pkg"
+
+// VdbKeys lists the single-value metadata files read from each installed package entry.
+var VdbKeys = []string{
+	"BDEPEND", "BUILD_TIME", "CATEGORY", "CBUILD", "CHOST", "DEFINED_PHASES", "DEPEND", "DESCRIPTION",
+	"EAPI", "FEATURES", "HOMEPAGE", "IDEPEND", "INHERITED", "IUSE", "KEYWORDS", "LICENSE", "PDEPEND",
+	"PF", "PROPERTIES", "PROVIDES", "RDEPEND", "REQUIRES", "RESTRICT", "SIZE", "SLOT", "USE", "repository",
+}
+
+// InstalledPackage is a single entry of the installed package database (vdb).
+type InstalledPackage struct {
+	Category   string
+	Name       string
+	Version    string // PVR
+	Path       string // Directory of the entry relative to the vdb root, e.g. "app-misc/foo-1.0"
+	Slot       string
+	Repository string
+	Use        []string
+	IUse       []string
+	Keywords   []string
+	BuildTime  time.Time
+	Depend     string
+	RDepend    string
+	Metadata   map[string]string // Raw values of every VdbKeys file present in the entry
+}
+
+// CPV returns "category/name-version".
+func (p *InstalledPackage) CPV() string {
+	return p.Category + "/" + p.Name + "-" + p.Version
+}
+
+// Key returns "category/name".
+func (p *InstalledPackage) Key() string {
+	return p.Category + "/" + p.Name
+}
+
+// Candidate returns the package as an AtomCandidate for atom matching.
+func (p *InstalledPackage) Candidate() AtomCandidate {
+	return AtomCandidate{Category: p.Category, Name: p.Name, Version: p.Version, Slot: p.Slot, Repo: p.Repository}
+}
+
+// VdbContentsEntry is a single line of a vdb CONTENTS file.
+type VdbContentsEntry struct {
+	Type   string // dir, obj, sym, fif or dev
+	Path   string
+	MD5    string // obj only
+	MTime  int64  // obj and sym only
+	Target string // sym only
+}
+
+// ParseVdbContents parses a vdb CONTENTS file. Paths may contain spaces; the trailing fields of obj and
+// sym lines are split off from the right.
+func ParseVdbContents(r io.Reader) ([]VdbContentsEntry, error) {
+	var entries []VdbContentsEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		typ, rest, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: malformed CONTENTS entry %q", lineNo, line)
+		}
+		entry := VdbContentsEntry{Type: typ}
+		switch typ {
+		case "dir", "fif", "dev":
+			entry.Path = rest
+		case "obj":
+			mtimeIdx := strings.LastIndex(rest, " ")
+			md5Idx := -1
+			if mtimeIdx > 0 {
+				md5Idx = strings.LastIndex(rest[:mtimeIdx], " ")
+			}
+			if md5Idx <= 0 {
+				return nil, fmt.Errorf("line %d: malformed obj entry %q", lineNo, line)
+			}
+			mtime, err := strconv.ParseInt(rest[mtimeIdx+1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid mtime: %w", lineNo, err)
+			}
+			entry.MTime = mtime
+			entry.MD5 = rest[md5Idx+1 : mtimeIdx]
+			entry.Path = rest[:md5Idx]
+		case "sym":
+			src, dst, found := strings.Cut(rest, " -> ")
+			if !found {
+				return nil, fmt.Errorf("line %d: malformed sym entry %q", lineNo, line)
+			}
+			idx := strings.LastIndex(dst, " ")
+			if idx == -1 {
+				return nil, fmt.Errorf("line %d: malformed sym entry %q", lineNo, line)
+			}
+			mtime, err := strconv.ParseInt(dst[idx+1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid mtime: %w", lineNo, err)
+			}
+			entry.Path = src
+			entry.Target = dst[:idx]
+			entry.MTime = mtime
+		default:
+			return nil, fmt.Errorf("line %d: unknown CONTENTS entry type %q", lineNo, typ)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// ReadInstalledPackageFS reads the vdb entry in dir ("category/PF") from fsys. CONTENTS is not read;
+// use ReadVdbContentsFS for that.
+func ReadInstalledPackageFS(fsys fs.FS, dir string) (*InstalledPackage, error) {
+	category := path.Dir(dir)
+	pf := path.Base(dir)
+	atom := ParsePackageAtom(category + "/" + pf)
+	if atom.Version == "" {
+		return nil, fmt.Errorf("%s: cannot determine package version", dir)
+	}
+
+	p := &InstalledPackage{
+		Category: category,
+		Name:     atom.Name,
+		Version:  atom.Version,
+		Path:     dir,
+		Metadata: make(map[string]string),
+	}
+
+	for _, key := range VdbKeys {
+		data, err := fs.ReadFile(fsys, path.Join(dir, key))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("reading %s/%s: %w", dir, key, err)
+		}
+		p.Metadata[key] = strings.TrimSpace(string(data))
+	}
+
+	p.Slot = p.Metadata["SLOT"]
+	p.Repository = p.Metadata["repository"]
+	p.Use = strings.Fields(p.Metadata["USE"])
+	p.IUse = strings.Fields(p.Metadata["IUSE"])
+	p.Keywords = strings.Fields(p.Metadata["KEYWORDS"])
+	p.Depend = p.Metadata["DEPEND"]
+	p.RDepend = p.Metadata["RDEPEND"]
+	if bt := p.Metadata["BUILD_TIME"]; bt != "" {
+		secs, err := strconv.ParseInt(bt, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid BUILD_TIME %q: %w", dir, bt, err)
+		}
+		p.BuildTime = time.Unix(secs, 0)
+	}
+	return p, nil
+}
+
+// ReadVdbContentsFS reads and parses the CONTENTS file of the vdb entry in dir. A missing CONTENTS file
+// yields no entries, as for virtual packages.
+func ReadVdbContentsFS(fsys fs.FS, dir string) ([]VdbContentsEntry, error) {
+	f, err := fsys.Open(path.Join(dir, "CONTENTS"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	entries, err := ParseVdbContents(f)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s/CONTENTS: %w", dir, err)
+	}
+	return entries, nil
+}
+
+// ListInstalledPackagesFS reads every entry of the vdb rooted at fsys, sorted by category, name and version.
+// In-progress merges ("-MERGING-") and hidden directories are skipped.
+func ListInstalledPackagesFS(fsys fs.FS) ([]*InstalledPackage, error) {
+	categories, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading vdb: %w", err)
+	}
+
+	var pkgs []*InstalledPackage
+	for _, cat := range categories {
+		if !cat.IsDir() || strings.HasPrefix(cat.Name(), ".") {
+			continue
+		}
+		entries, err := fs.ReadDir(fsys, cat.Name())
+		if err != nil {
+			return nil, fmt.Errorf("reading vdb category %s: %w", cat.Name(), err)
+		}
+		for _, e := range entries {
+			if !e.IsDir() || strings.HasPrefix(e.Name(), ".") || strings.HasPrefix(e.Name(), "-MERGING-") {
+				continue
+			}
+			p, err := ReadInstalledPackageFS(fsys, path.Join(cat.Name(), e.Name()))
+			if err != nil {
+				return nil, err
+			}
+			pkgs = append(pkgs, p)
+		}
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].Key() != pkgs[j].Key() {
+			return pkgs[i].Key() < pkgs[j].Key()
+		}
+		return CompareVersions(pkgs[i].Version, pkgs[j].Version) < 0
+	})
+	return pkgs, nil
+}
+
+// ListInstalledPackages reads every entry of the vdb at root.
+func ListInstalledPackages(root string) ([]*InstalledPackage, error) {
+	return ListInstalledPackagesFS(os.DirFS(root))
+}
+
+// VdbFileOwner pairs an installed package with the CONTENTS entry that claims a file.
+type VdbFileOwner struct {
+	Package *InstalledPackage
+	Entry   VdbContentsEntry
+}
+
+// FindFileOwnersFS returns every installed package whose CONTENTS lists one of files (absolute paths),
+// reading the vdb once. Owners are returned in package order; Entry.Path tells which file each claims.
+func FindFileOwnersFS(fsys fs.FS, files ...string) ([]VdbFileOwner, error) {
+	pkgs, err := ListInstalledPackagesFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(files))
+	for _, file := range files {
+		wanted[path.Clean(file)] = true
+	}
+
+	var owners []VdbFileOwner
+	for _, p := range pkgs {
+		contents, err := ReadVdbContentsFS(fsys, p.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range contents {
+			if wanted[c.Path] {
+				owners = append(owners, VdbFileOwner{Package: p, Entry: c})
+			}
+		}
+	}
+	return owners, nil
+}
@@ -0,0 +1,114 @@
+package g2
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func testVdbFS() fstest.MapFS {
+	return fstest.MapFS{
+		"app-misc/foo-1.2-r1/SLOT":       &fstest.MapFile{Data: []byte("0/1.2\n")},
+		"app-misc/foo-1.2-r1/repository": &fstest.MapFile{Data: []byte("gentoo\n")},
+		"app-misc/foo-1.2-r1/USE":        &fstest.MapFile{Data: []byte("amd64 ssl\n")},
+		"app-misc/foo-1.2-r1/IUSE":       &fstest.MapFile{Data: []byte("+ssl test\n")},
+		"app-misc/foo-1.2-r1/KEYWORDS":   &fstest.MapFile{Data: []byte("amd64 ~arm64\n")},
+		"app-misc/foo-1.2-r1/BUILD_TIME": &fstest.MapFile{Data: []byte("1700000000\n")},
+		"app-misc/foo-1.2-r1/RDEPEND":    &fstest.MapFile{Data: []byte("dev-libs/openssl:0/3=\n")},
+		"app-misc/foo-1.2-r1/CONTENTS": &fstest.MapFile{Data: []byte(
+			"dir /usr/bin\n" +
+				"obj /usr/bin/foo d41d8cd98f00b204e9800998ecf8427e 1700000000\n" +
+				"obj /usr/share/foo/a file d41d8cd98f00b204e9800998ecf8427e 1700000001\n" +
+				"sym /usr/bin/foo-link -> foo 1700000002\n"),
+		},
+		"sys-libs/bar-2/SLOT":           &fstest.MapFile{Data: []byte("0\n")},
+		"sys-libs/bar-2/repository":     &fstest.MapFile{Data: []byte("guru\n")},
+		"sys-libs/-MERGING-bar-3/SLOT":  &fstest.MapFile{Data: []byte("0\n")},
+		"app-misc/foo-1.10/SLOT":        &fstest.MapFile{Data: []byte("1\n")},
+		"app-misc/foo-1.10/CONTENTS":    &fstest.MapFile{Data: []byte("obj /usr/bin/foo 0123 1\n")},
+		".hidden/ignored-1/SLOT":        &fstest.MapFile{Data: []byte("0\n")},
+		"app-misc/foo-1.10/environment": &fstest.MapFile{Data: []byte("ignored")},
+		"virtual/baz-1/SLOT":            &fstest.MapFile{Data: []byte("0\n")},
+		"virtual/baz-1/repository":      &fstest.MapFile{Data: []byte("gentoo\n")},
+		"virtual/baz-1/DESCRIPTION":     &fstest.MapFile{Data: []byte("Virtual\n")},
+		"virtual/baz-1/RDEPEND":         &fstest.MapFile{Data: []byte("app-misc/foo\n")},
+		"virtual/baz-1/COUNTER":         &fstest.MapFile{Data: []byte("42\n")},
+	}
+}
+
+func TestListInstalledPackagesFS(t *testing.T) {
+	pkgs, err := ListInstalledPackagesFS(testVdbFS())
+	if err != nil {
+		t.Fatalf("ListInstalledPackagesFS failed: %v", err)
+	}
+
+	var got []string
+	for _, p := range pkgs {
+		got = append(got, p.CPV())
+	}
+	want := "app-misc/foo-1.2-r1 app-misc/foo-1.10 sys-libs/bar-2 virtual/baz-1"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("unexpected packages: %v, want %s", got, want)
+	}
+
+	foo := pkgs[0]
+	if foo.Slot != "0/1.2" || foo.Repository != "gentoo" {
+		t.Errorf("unexpected slot/repository: %q %q", foo.Slot, foo.Repository)
+	}
+	if strings.Join(foo.Use, " ") != "amd64 ssl" || strings.Join(foo.IUse, " ") != "+ssl test" {
+		t.Errorf("unexpected USE/IUSE: %v %v", foo.Use, foo.IUse)
+	}
+	if strings.Join(foo.Keywords, " ") != "amd64 ~arm64" {
+		t.Errorf("unexpected KEYWORDS: %v", foo.Keywords)
+	}
+	if foo.BuildTime.Unix() != 1700000000 {
+		t.Errorf("unexpected BUILD_TIME: %v", foo.BuildTime)
+	}
+	if foo.RDepend != "dev-libs/openssl:0/3=" {
+		t.Errorf("unexpected RDEPEND: %q", foo.RDepend)
+	}
+	if pkgs[3].Metadata["COUNTER"] != "" {
+		t.Errorf("did not expect COUNTER to be read")
+	}
+	if pkgs[3].Metadata["DESCRIPTION"] != "Virtual" {
+		t.Errorf("unexpected DESCRIPTION: %q", pkgs[3].Metadata["DESCRIPTION"])
+	}
+}
+
+func TestReadVdbContentsFS(t *testing.T) {
+	entries, err := ReadVdbContentsFS(testVdbFS(), "app-misc/foo-1.2-r1")
+	if err != nil {
+		t.Fatalf("ReadVdbContentsFS failed: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+	if entries[2].Path != "/usr/share/foo/a file" || entries[2].MD5 != "d41d8cd98f00b204e9800998ecf8427e" || entries[2].MTime != 1700000001 {
+		t.Errorf("unexpected obj entry: %+v", entries[2])
+	}
+	if entries[3].Type != "sym" || entries[3].Path != "/usr/bin/foo-link" || entries[3].Target != "foo" || entries[3].MTime != 1700000002 {
+		t.Errorf("unexpected sym entry: %+v", entries[3])
+	}
+
+	missing, err := ReadVdbContentsFS(testVdbFS(), "sys-libs/bar-2")
+	if err != nil || missing != nil {
+		t.Errorf("expected no entries for missing CONTENTS, got %v, %v", missing, err)
+	}
+
+	if _, err := ParseVdbContents(strings.NewReader("obj /usr/bin/foo\n")); err == nil {
+		t.Errorf("expected error for malformed obj entry")
+	}
+}
+
+func TestFindFileOwnersFS(t *testing.T) {
+	owners, err := FindFileOwnersFS(testVdbFS(), "/usr/bin//foo")
+	if err != nil {
+		t.Fatalf("FindFileOwnersFS failed: %v", err)
+	}
+	if len(owners) != 2 {
+		t.Fatalf("expected 2 owners, got %d", len(owners))
+	}
+	if owners[0].Package.CPV() != "app-misc/foo-1.2-r1" || owners[1].Package.CPV() != "app-misc/foo-1.10" {
+		t.Errorf("unexpected owners: %s, %s", owners[0].Package.CPV(), owners[1].Package.CPV())
+	}
+}